@@ -8,8 +8,12 @@ import (
 
 	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
 	"github.com/Adit0507/sql-query-optimizer/internal/executor"
+	"github.com/Adit0507/sql-query-optimizer/internal/optimizer"
 	"github.com/Adit0507/sql-query-optimizer/internal/parser"
 	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+	"github.com/Adit0507/sql-query-optimizer/internal/txn"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
 )
 
 func main() {
@@ -21,21 +25,36 @@ func main() {
 		return
 	}
 	fmt.Println("Catalog loaded")
-	
+
+	// commits go to the log first, what it holds is replayed on startup
+	txns := txn.ManagerOf(cat)
+	if err := txns.OpenLog("data/wal.log"); err != nil {
+		fmt.Printf("Error opening write-ahead log: %v\n", err)
+		return
+	}
+	// what the log holds goes to the table files on the way out, so
+	// the next start has nothing to replay
+	defer func() {
+		if err := txns.Checkpoint(); err != nil {
+			fmt.Printf("Checkpoint error: %v\n", err)
+		}
+	}()
+
 	planner := plan.NewPlanner(cat)
+	opt := optimizer.NewOptimizer(cat)
 	exec := executor.NewExecutor(cat)
 
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Println("\nEnter SQL queries (type 'exit' to quit, 'help' for commands):")
-	
+
 	for {
 		fmt.Println("\ngoquery> ")
-		if !scanner.Scan(){
+		if !scanner.Scan() {
 			break
 		}
 
 		input := strings.TrimSpace(scanner.Text())
-		if input == ""{
+		if input == "" {
 			continue
 		}
 		if input == "exit" || input == "quit" {
@@ -47,18 +66,30 @@ func main() {
 			continue
 		}
 
-		if strings.HasPrefix(input, "EXPLAIN"){
+		if strings.HasPrefix(input, "EXPLAIN") {
 			query := strings.TrimPrefix(input, "EXPLAIN ")
-			executeExplain(query, planner)
-			
+			traced := strings.HasPrefix(query, "(TRACE)")
+			query = strings.TrimSpace(strings.TrimPrefix(query, "(TRACE)"))
+			executeExplain(query, planner, opt, traced)
+
 			continue
 		}
 
-		executeQuery(input, planner, exec)
+		// reads the table file, which gets every commit first
+		if strings.HasPrefix(strings.ToUpper(input), "COPY") {
+			if err := txns.Checkpoint(); err != nil {
+				fmt.Printf("Checkpoint error: %v\n", err)
+				continue
+			}
+			executeCopy(input, cat)
+			continue
+		}
+
+		executeQuery(input, planner, opt, exec)
 	}
 }
 
-func executeQuery(query string, planner *plan.Planner, exec *executor.Executor) {
+func executeQuery(query string, planner *plan.Planner, opt *optimizer.Optimizer, exec *executor.Executor) {
 	p := parser.NewParser(query)
 	stmt := p.Parse()
 
@@ -77,16 +108,39 @@ func executeQuery(query string, planner *plan.Planner, exec *executor.Executor)
 		return
 	}
 
-	results, err := exec.Execute(logicalPlan)
+	optimized := opt.Optimize(logicalPlan)
+	printWarnings(opt)
+
+	results, err := exec.Execute(optimized)
 	if err != nil {
-		fmt.Printf("Execution error: %v")
+		fmt.Printf("Execution error: %v\n", err)
 		return
 	}
 
+	if t, ok := logicalPlan.(*plan.LogicalTransaction); ok {
+		fmt.Println(t.Action)
+		return
+	}
+	if analyze, ok := logicalPlan.(*plan.LogicalAnalyze); ok {
+		for _, table := range analyze.Tables {
+			if stats := table.Stats(); stats != nil {
+				fmt.Printf("%s: %d rows\n", table.Name, stats.RowCount)
+			}
+		}
+		return
+	}
+	if plan.IsDefinition(logicalPlan) {
+		fmt.Println("OK")
+		return
+	}
+	if plan.IsModification(logicalPlan) {
+		fmt.Printf("(%v rows affected)\n", results[0][plan.AffectedRows])
+		return
+	}
 	displayResults(results)
 }
 
-func executeExplain(query string, planner *plan.Planner) {
+func executeExplain(query string, planner *plan.Planner, opt *optimizer.Optimizer, traced bool) {
 	p := parser.NewParser(query)
 	stmt := p.Parse()
 
@@ -109,6 +163,63 @@ func executeExplain(query string, planner *plan.Planner) {
 	fmt.Println("\nLogical Plan:")
 	fmt.Println("-------------")
 	plan.PrintPlan(logicalPlan, 0)
+
+	var optimized plan.LogicalPlan
+	if traced {
+		var trace *optimizer.Trace
+		optimized, trace = opt.OptimizeTraced(logicalPlan)
+		fmt.Println("\nOptimizer Trace:")
+		fmt.Println("----------------")
+		fmt.Print(trace)
+	} else {
+		optimized = opt.Optimize(logicalPlan)
+	}
+
+	fmt.Println("\nOptimized Plan:")
+	fmt.Println("---------------")
+	plan.PrintPlan(optimized, 0)
+	fmt.Printf("\nEstimated rows: %.0f\n", optimizer.EstimateRows(optimized))
+	fmt.Printf("Estimated cost: %.0f\n", optimizer.EstimateCost(optimized))
+	printWarnings(opt)
+}
+
+// hints the optimizer couldn't follow
+func printWarnings(opt *optimizer.Optimizer) {
+	for _, warning := range opt.Warnings() {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+}
+
+// writes a table's rows to a file in another format
+func executeCopy(input string, cat *catalog.Catalog) {
+	p := parser.NewParser(input)
+	stmt := p.Parse()
+
+	if len(p.Errors()) > 0 {
+		fmt.Println("Parse errors:")
+		for _, err := range p.Errors() {
+			fmt.Printf("  - %s\n", err)
+		}
+		return
+	}
+
+	copyStmt, ok := stmt.(*parser.CopyStatement)
+	if !ok {
+		fmt.Println("Copy error: expected COPY table TO 'file' FORMAT kind")
+		return
+	}
+	table, err := cat.GetTable(copyStmt.Table)
+	if err != nil {
+		fmt.Printf("Copy error: %v\n", err)
+		return
+	}
+
+	count, err := storage.CopyTable(table, copyStmt.Path, copyStmt.Format)
+	if err != nil {
+		fmt.Printf("Copy error: %v\n", err)
+		return
+	}
+	fmt.Printf("Copied %d rows to %s\n", count, copyStmt.Path)
 }
 
 func displayResults(results []executor.Row) {
@@ -135,11 +246,15 @@ func displayResults(results []executor.Row) {
 	for _, row := range results {
 		for i, col := range columns {
 			val := row[col]
+			if types.IsJSONContainer(val) {
+				val = types.JSONText(val)
+			}
 			fmt.Printf("%-20v", val)
 			if i < len(columns)-1 {
 				fmt.Print("| ")
 			}
 		}
+		fmt.Println()
 	}
 
 	fmt.Printf("\n(%d rows)\n", len(results))
@@ -148,7 +263,16 @@ func displayResults(results []executor.Row) {
 func printHelp() {
 	fmt.Println("\nAvailable commands:")
 	fmt.Println("  SELECT ...           - Execute a SELECT query")
+	fmt.Println("  INSERT/UPDATE/DELETE - Change table rows and their data files")
+	fmt.Println("  CREATE/DROP/ALTER TABLE, CREATE/DROP INDEX - Change table definitions")
+	fmt.Println("  CREATE [MATERIALIZED] VIEW v AS SELECT ..., DROP [MATERIALIZED] VIEW v - Define views")
+	fmt.Println("  REFRESH MATERIALIZED VIEW v - Rerun a materialized view's query")
+	fmt.Println("  BEGIN/COMMIT/ROLLBACK - Group statements in a transaction")
 	fmt.Println("  EXPLAIN SELECT ...   - Show query execution plan")
+	fmt.Println("  EXPLAIN (TRACE) SELECT ... - Also show each rule applied and plan considered")
+	fmt.Println("  SELECT /*+ HASH_JOIN(u o) LEADING(u o) INDEX(o idx) NO_PUSHDOWN */ ... - Give the optimizer hints")
+	fmt.Println("  ANALYZE [table]      - Recompute table statistics from the data files")
+	fmt.Println("  COPY t TO 'f' FORMAT columnar - Write a table to a columnar file")
 	fmt.Println("  help                 - Show this help message")
 	fmt.Println("  exit/quit            - Exit the program")
 	fmt.Println("\nExample queries:")
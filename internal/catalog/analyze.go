@@ -0,0 +1,209 @@
+package catalog
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// computes statistics for a table from its data file and saves them with
+// the catalog. With sample > 0 and more rows than that, distinct counts and
+// min/max come from a random sample of sample rows, the row and NULL counts
+// are always exact
+func (c *Catalog) Analyze(name string, sample int) error {
+	table, err := c.GetTable(name)
+	if err != nil {
+		return err
+	}
+	if !table.HasData() {
+		return fmt.Errorf("table %s has no data file", name)
+	}
+
+	rows, err := readRows(table)
+	if err != nil {
+		return fmt.Errorf("table %s: %w", name, err)
+	}
+
+	stats := ComputeStatistics(table, rows, sample)
+	return c.update(func() error {
+		table.SetStats(stats)
+		return nil
+	}, nil)
+}
+
+// analyzes every table with a data file
+func (c *Catalog) AnalyzeAll(sample int) error {
+	for _, table := range c.Tables() {
+		if !table.HasData() {
+			continue
+		}
+		if err := c.Analyze(table.Name, sample); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statistics of a table's rows, see Analyze for what sample does
+func ComputeStatistics(table *TableInfo, rows []map[string]interface{}, sample int) *Statistics {
+	stats := &Statistics{
+		RowCount:      len(rows),
+		DistinctCount: make(map[string]int),
+		NullCount:     make(map[string]int),
+		Min:           make(map[string]interface{}),
+		Max:           make(map[string]interface{}),
+		MostCommon:    make(map[string][]ValueFrequency),
+		Histograms:    make(map[string]*Histogram),
+	}
+
+	for _, row := range rows {
+		for _, col := range table.Columns {
+			if row[col.Name] == nil {
+				stats.NullCount[col.Name]++
+			}
+		}
+	}
+
+	sampled := rows
+	if sample > 0 && len(rows) > sample {
+		sampled = sampleRows(rows, sample)
+	}
+
+	for _, col := range table.Columns {
+		counts := make(map[string]*valueCount)
+		nonNull := 0
+
+		for _, row := range sampled {
+			v := normalize(row[col.Name])
+			if v == nil {
+				continue
+			}
+			nonNull++
+			key := valueKey(v)
+			if counts[key] == nil {
+				counts[key] = &valueCount{value: v}
+			}
+			counts[key].count++
+
+			if col.Type == JSONType {
+				continue
+			}
+			if min, ok := stats.Min[col.Name]; !ok || compareValues(v, min) < 0 {
+				stats.Min[col.Name] = v
+			}
+			if max, ok := stats.Max[col.Name]; !ok || compareValues(v, max) > 0 {
+				stats.Max[col.Name] = v
+			}
+		}
+
+		distinct := len(counts)
+		if len(sampled) < len(rows) {
+			distinct = scaleDistinct(counts, nonNull, len(rows)-stats.NullCount[col.Name])
+		}
+		stats.DistinctCount[col.Name] = distinct
+		if col.Type == JSONType {
+			continue
+		}
+
+		mcv, hist := buildDistribution(counts, len(sampled))
+		if len(mcv) > 0 {
+			stats.MostCommon[col.Name] = mcv
+		}
+		if hist != nil {
+			stats.Histograms[col.Name] = hist
+		}
+	}
+
+	return stats
+}
+
+// reservoir sample with a fixed seed so repeated runs agree
+func sampleRows(rows []map[string]interface{}, n int) []map[string]interface{} {
+	rng := rand.New(rand.NewSource(1))
+	sample := append([]map[string]interface{}{}, rows[:n]...)
+
+	for i := n; i < len(rows); i++ {
+		if j := rng.Intn(i + 1); j < n {
+			sample[j] = rows[i]
+		}
+	}
+
+	return sample
+}
+
+// distinct values in the whole table from those in a sample of n values out
+// of total, the Duj1 estimator of Haas and Stokes. Values seen once in the
+// sample hint at many more unseen ones
+func scaleDistinct(counts map[string]*valueCount, n, total int) int {
+	if n == 0 || total <= n {
+		return len(counts)
+	}
+
+	once := 0
+	for _, c := range counts {
+		if c.count == 1 {
+			once++
+		}
+	}
+
+	d := float64(len(counts))
+	estimate := float64(n) * d / (float64(n-once) + float64(once)*float64(n)/float64(total))
+	if estimate > float64(total) {
+		estimate = float64(total)
+	}
+	if estimate < d {
+		estimate = d
+	}
+
+	return int(estimate + 0.5)
+}
+
+func valueKey(v interface{}) string {
+	return fmt.Sprintf("%T:%v", v, v)
+}
+
+// orders values of one column, numbers before strings before bools
+func compareValues(a, b interface{}) int {
+	a, b = normalize(a), normalize(b)
+
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return typeRank(a) - typeRank(b)
+}
+
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case float64:
+		return 0
+	case string:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
 )
 
 type DataType int //simple col types
@@ -12,6 +17,13 @@ const (
 	IntType DataType = iota
 	StringType
 	BoolType
+	NullType // type of the NULL literal
+	FloatType
+	DecimalType
+	DateType
+	TimestampType
+	IntervalType
+	JSONType // nested objects and arrays as decoded from the data file
 )
 
 func (d DataType) String() string {
@@ -22,15 +34,163 @@ func (d DataType) String() string {
 		return "BOOL"
 	case StringType:
 		return "STRING"
+	case NullType:
+		return "NULL"
+	case FloatType:
+		return "FLOAT"
+	case DecimalType:
+		return "DECIMAL"
+	case DateType:
+		return "DATE"
+	case TimestampType:
+		return "TIMESTAMP"
+	case IntervalType:
+		return "INTERVAL"
+	case JSONType:
+		return "JSON"
 
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// reports whether values of the type are numbers
+func (d DataType) IsNumeric() bool {
+	return d == IntType || d == FloatType || d == DecimalType
+}
+
+// SQL type name to DataType, used by CAST
+func ParseDataType(name string) (DataType, error) {
+	t, _, _, err := ParseTypeName(name)
+	return t, err
+}
+
+// SQL type name with optional modifiers, DECIMAL(p,s) gives its precision
+// and scale, length modifiers like VARCHAR(20) are ignored
+func ParseTypeName(name string) (DataType, int, int, error) {
+	base, mods := strings.ToUpper(strings.TrimSpace(name)), ""
+	if i := strings.IndexByte(base, '('); i >= 0 && strings.HasSuffix(base, ")") {
+		base, mods = strings.TrimSpace(base[:i]), base[i+1:len(base)-1]
+	}
+
+	switch base {
+	case "INT", "INTEGER", "BIGINT", "SMALLINT":
+		return IntType, 0, 0, nil
+	case "STRING", "TEXT", "VARCHAR", "CHAR":
+		return StringType, 0, 0, nil
+	case "BOOL", "BOOLEAN":
+		return BoolType, 0, 0, nil
+	case "FLOAT", "DOUBLE", "REAL", "DOUBLE PRECISION":
+		return FloatType, 0, 0, nil
+	case "DATE":
+		return DateType, 0, 0, nil
+	case "TIMESTAMP", "DATETIME":
+		return TimestampType, 0, 0, nil
+	case "INTERVAL":
+		return IntervalType, 0, 0, nil
+	case "JSON", "JSONB":
+		return JSONType, 0, 0, nil
+	case "DECIMAL", "NUMERIC":
+		if mods == "" {
+			return DecimalType, 0, 0, nil
+		}
+
+		var precision, scale int
+		parts := strings.Split(mods, ",")
+		_, err := fmt.Sscan(parts[0], &precision)
+		if err == nil && len(parts) == 2 {
+			_, err = fmt.Sscan(parts[1], &scale)
+		}
+		if err != nil || len(parts) > 2 || precision < 1 || scale < 0 || scale > precision {
+			return NullType, 0, 0, fmt.Errorf("invalid type %s", name)
+		}
+		return DecimalType, precision, scale, nil
+	default:
+		return NullType, 0, 0, fmt.Errorf("unknown type %s", name)
+	}
+}
+
+// SQL name of a type, with DECIMAL precision and scale when given
+func TypeName(t DataType, precision, scale int) string {
+	if t == DecimalType && precision > 0 {
+		return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+	}
+
+	return t.String()
+}
+
 type Column struct { //table column
-	Name string   `json:"name"`
-	Type DataType `json:"type"`
+	Name    string   `json:"name"`
+	Type    DataType `json:"type"`
+	NotNull bool     `json:"not_null,omitempty"`
+
+	// where the value lives in each document, like "address.city" or
+	// "items[0].sku". Empty reads the top level key named like the column
+	Path string `json:"path,omitempty"`
+
+	// DECIMAL(precision, scale), zero precision leaves it unconstrained
+	Precision int `json:"-"`
+	Scale     int `json:"-"`
+}
+
+func (c Column) TypeName() string {
+	return TypeName(c.Type, c.Precision, c.Scale)
+}
+
+// path of the column's value inside a row document
+func (c Column) Source() (types.JSONPath, error) {
+	if c.Path == "" {
+		return types.JSONPath{c.Name}, nil
+	}
+
+	path, err := types.ParseJSONPath(c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", c.Name, err)
+	}
+	return path, nil
+}
+
+// types are written by name, like "INT" or "DECIMAL(10,2)"
+func (c Column) MarshalJSON() ([]byte, error) {
+	type column Column
+	return json.Marshal(struct {
+		column
+		Type string `json:"type"`
+	}{column(c), c.TypeName()})
+}
+
+// accepts type names and the old numeric type codes
+func (c *Column) UnmarshalJSON(data []byte) error {
+	type column Column
+	aux := struct {
+		*column
+		Type json.RawMessage `json:"type"`
+	}{column: (*column)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var code int
+	if err := json.Unmarshal(aux.Type, &code); err == nil {
+		// only INT, STRING and BOOL were ever saved as codes
+		if code < int(IntType) || code > int(BoolType) {
+			return fmt.Errorf("column %s: unknown type code %d", c.Name, code)
+		}
+		c.Type = DataType(code)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(aux.Type, &name); err != nil {
+		return fmt.Errorf("column %s: type must be a name", c.Name)
+	}
+	t, precision, scale, err := ParseTypeName(name)
+	if err != nil {
+		return fmt.Errorf("column %s: %w", c.Name, err)
+	}
+	c.Type, c.Precision, c.Scale = t, precision, scale
+
+	return nil
 }
 
 type Index struct { //table index
@@ -39,9 +199,14 @@ type Index struct { //table index
 }
 
 type Statistics struct { //table statistics for cost estimation
-	RowCount      int            `json:"row_count"`
-	DistinctCount map[string]int `json:"distinct_count"`
-	NullCount     map[string]int `json:"null_count"`
+	RowCount      int                    `json:"row_count"`
+	DistinctCount map[string]int         `json:"distinct_count"`
+	NullCount     map[string]int         `json:"null_count"`
+	Min           map[string]interface{} `json:"min,omitempty"`
+	Max           map[string]interface{} `json:"max,omitempty"`
+
+	MostCommon map[string][]ValueFrequency `json:"most_common,omitempty"`
+	Histograms map[string]*Histogram       `json:"histograms,omitempty"`
 }
 
 type TableInfo struct { //metadata about table
@@ -50,10 +215,36 @@ type TableInfo struct { //metadata about table
 	Indexes    []Index     `json:"indexes"`
 	Statistics *Statistics `json:"statistics"`
 	DataFile   string      `json:"data_file"` //pat to json datafile
+
+	// kind of storage the rows come from, see the storage package. Empty
+	// means a JSON data file. Options are passed to the source as they are
+	Source  string            `json:"source,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+
+	// lives only in this process, like tables of Go values, and is left out
+	// when the catalog is saved
+	Temporary bool `json:"-"`
+
+	PrimaryKey  []string     `json:"primary_key,omitempty"`
+	Unique      [][]string   `json:"unique,omitempty"` // column sets with no duplicate values
+	ForeignKeys []ForeignKey `json:"foreign_keys,omitempty"`
+
+	// SELECT a view is defined by. Views have no data, the planner puts the
+	// query in their place. Materialized views keep its rows in their data
+	// like tables, until the next REFRESH
+	View         string `json:"view,omitempty"`
+	Materialized bool   `json:"materialized,omitempty"`
+
+	// tables a materialized view's query reads, and whether one changed
+	// since the view was last refreshed
+	Reads []string `json:"reads,omitempty"`
+	Stale bool     `json:"stale,omitempty"`
 }
 
 type Catalog struct {
 	tables map[string]*TableInfo
+	order  []string // registration order, kept when saving
+	path   string   // file the catalog was loaded from
 }
 
 func NewCatalog() *Catalog {
@@ -75,17 +266,121 @@ func (c *Catalog) LoadFromFile(filepath string) error {
 	}
 
 	for i := range tables {
-		c.tables[tables[i].Name] = &tables[i]
+		c.RegisterTable(&tables[i])
 	}
+	c.path = filepath
 
-	return nil
+	return c.Validate()
 }
 
 // adds a table to catalog
 func (c *Catalog) RegisterTable(table *TableInfo) {
+	if _, ok := c.tables[table.Name]; !ok {
+		c.order = append(c.order, table.Name)
+	}
 	c.tables[table.Name] = table
 }
 
+// every table in registration order
+func (c *Catalog) Tables() []*TableInfo {
+	tables := make([]*TableInfo, len(c.order))
+	for i, name := range c.order {
+		tables[i] = c.tables[name]
+	}
+
+	return tables
+}
+
+// views and materialized views in registration order
+func (c *Catalog) Views() []*TableInfo {
+	var views []*TableInfo
+	for _, table := range c.Tables() {
+		if table.View != "" {
+			views = append(views, table)
+		}
+	}
+
+	return views
+}
+
+// materialized views whose query reads a table
+func (c *Catalog) ReadersOf(table string) []*TableInfo {
+	var views []*TableInfo
+	for _, view := range c.Views() {
+		if view.Materialized && slices.Contains(view.Reads, table) {
+			views = append(views, view)
+		}
+	}
+
+	return views
+}
+
+// marks a materialized view out of date with its tables or refreshed,
+// saving the catalog when that changes
+func (c *Catalog) SetStale(view *TableInfo, stale bool) error {
+	if view.Stale == stale {
+		return nil
+	}
+
+	return c.update(func() error {
+		view.Stale = stale
+		return nil
+	}, nil)
+}
+
+// writes the catalog back to the file it was loaded from
+func (c *Catalog) Save() error {
+	if c.path == "" {
+		return fmt.Errorf("catalog was not loaded from a file")
+	}
+
+	return c.SaveToFile(c.path)
+}
+
+func (c *Catalog) SaveToFile(path string) error {
+	var tables []*TableInfo
+	for _, table := range c.Tables() {
+		if !table.Temporary {
+			tables = append(tables, table)
+		}
+	}
+
+	data, err := json.MarshalIndent(tables, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+
+	// write a synced temp file and rename it so a failed write or a crash
+	// keeps the old catalog
+	tmp := path + ".tmp"
+	if err := writeSynced(tmp, append(data, '\n')); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write catalog file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write catalog file: %w", err)
+	}
+
+	return nil
+}
+
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
 func (c *Catalog) GetTable(name string) (*TableInfo, error) {
 	table, ok := c.tables[name]
 	if !ok {
@@ -105,6 +400,57 @@ func (t *TableInfo) GetColumn(name string) (*Column, error) {
 	return nil, fmt.Errorf("column '%s' not found in table '%s'", name, t.Name)
 }
 
+// reports whether the table is a view planned from its query, not a
+// materialized one
+func (t *TableInfo) IsView() bool {
+	return t.View != "" && !t.Materialized
+}
+
+// storage kind of the table, json unless the catalog names another
+func (t *TableInfo) SourceKind() string {
+	if t.Source == "" {
+		return "json"
+	}
+
+	return t.Source
+}
+
+// reports whether the table has rows to read, entries without a data file
+// or source only describe the schema
+func (t *TableInfo) HasData() bool {
+	return t.DataFile != "" || t.Source != ""
+}
+
+// guards the Statistics of every table. Statistics are replaced, never
+// changed in place, so a reader keeps a consistent version while commits
+// publish new row counts
+var statsMu sync.RWMutex
+
+// statistics of the table, nil if it was never analyzed
+func (t *TableInfo) Stats() *Statistics {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+	return t.Statistics
+}
+
+func (t *TableInfo) SetStats(stats *Statistics) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	t.Statistics = stats
+}
+
+// replaces the row count of analyzed statistics, the rest stays as it was
+func (t *TableInfo) SetRowCount(n int) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if t.Statistics == nil {
+		return
+	}
+	stats := *t.Statistics
+	stats.RowCount = n
+	t.Statistics = &stats
+}
+
 func (t *TableInfo) GetColumnNames() []string {
 	names := make([]string, len(t.Columns))
 
@@ -135,3 +481,35 @@ func (t *TableInfo) HasIndex(cols []string) bool {
 
 	return false
 }
+
+// reports whether no two rows agree on all of cols, true when cols contain
+// the primary key or one of the unique column sets
+func (t *TableInfo) IsUnique(cols []string) bool {
+	if len(t.PrimaryKey) > 0 && containsAll(cols, t.PrimaryKey) {
+		return true
+	}
+	for _, key := range t.Unique {
+		if len(key) > 0 && containsAll(cols, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAll(cols, key []string) bool {
+	for _, k := range key {
+		found := false
+		for _, c := range cols {
+			if c == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,324 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writes users and orders data plus a catalog declaring constraints on them
+func writeCatalog(t *testing.T, users, orders, constraints string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	usersFile := write("users.json", users)
+	ordersFile := write("orders.json", orders)
+
+	return write("catalog.json", `[
+  {"name": "users", "columns": [{"name": "id", "type": 0}, {"name": "email", "type": 1}],
+   "data_file": "`+usersFile+`", "primary_key": ["id"], "unique": [["email"]]},
+  {"name": "orders", "columns": [{"name": "id", "type": 0}, {"name": "user_id", "type": 0`+constraints+`}],
+   "data_file": "`+ordersFile+`", "primary_key": ["id"],
+   "foreign_keys": [{"columns": ["user_id"], "references": "users", "ref_columns": ["id"]}]}
+]`)
+}
+
+func TestConstraintsValidated(t *testing.T) {
+	users := `[{"id": 1, "email": "a"}, {"id": 2, "email": null}, {"id": 3, "email": null}]`
+
+	tests := []struct {
+		name        string
+		users       string
+		orders      string
+		constraints string
+		err         string
+	}{
+		{"valid", users, `[{"id": 1, "user_id": 1}, {"id": 2, "user_id": null}]`, ``, ``},
+		{"duplicate primary key", users, `[{"id": 1, "user_id": 1}, {"id": 1, "user_id": 2}]`, ``, "duplicate value (1)"},
+		{"duplicate unique", `[{"id": 1, "email": "a"}, {"id": 2, "email": "a"}]`, `[]`, ``, "duplicate value (a) for (email)"},
+		{"NULL primary key", users, `[{"user_id": 1}]`, ``, "column id is NULL"},
+		{"NOT NULL", users, `[{"id": 1, "user_id": null}]`, `, "not_null": true`, "column user_id is NULL"},
+		{"dangling foreign key", users, `[{"id": 1, "user_id": 9}]`, ``, "(9) not found in users"},
+	}
+
+	for _, tt := range tests {
+		cat := NewCatalog()
+		err := cat.LoadFromFile(writeCatalog(t, tt.users, tt.orders, tt.constraints))
+
+		if tt.err == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestForeignKeyMustReferenceUniqueColumns(t *testing.T) {
+	cat := NewCatalog()
+	cat.RegisterTable(&TableInfo{Name: "users", Columns: []Column{{Name: "id"}, {Name: "city"}}, PrimaryKey: []string{"id"}})
+	cat.RegisterTable(&TableInfo{
+		Name:        "orders",
+		Columns:     []Column{{Name: "city"}},
+		ForeignKeys: []ForeignKey{{Columns: []string{"city"}, RefTable: "users", RefColumns: []string{"city"}}},
+	})
+
+	if err := cat.Validate(); err == nil || !strings.Contains(err.Error(), "must reference a primary key or unique columns") {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	users := `[{"id": 1, "email": "b"}, {"id": 2, "email": null}, {"id": 3, "email": "a"}, {"id": 4, "email": "b"}]`
+	path := writeCatalog(t, users, `[]`, ``)
+
+	cat := NewCatalog()
+	if err := cat.LoadFromFile(path); err == nil || !strings.Contains(err.Error(), "duplicate value (b)") {
+		t.Fatalf("expected duplicate email error, got %v", err)
+	}
+	table, _ := cat.GetTable("users")
+	table.Unique = nil
+
+	if err := cat.Analyze("users", 0); err != nil {
+		t.Fatal(err)
+	}
+	stats := table.Statistics
+	if stats.RowCount != 4 || stats.DistinctCount["email"] != 2 || stats.NullCount["email"] != 1 || stats.DistinctCount["id"] != 4 {
+		t.Fatalf("unexpected statistics %+v", stats)
+	}
+	if stats.Min["id"] != 1.0 || stats.Max["id"] != 4.0 || stats.Min["email"] != "a" || stats.Max["email"] != "b" {
+		t.Fatalf("unexpected min/max %v %v", stats.Min, stats.Max)
+	}
+
+	// saved statistics survive a reload
+	if err := cat.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewCatalog()
+	if err := reloaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	table, _ = reloaded.GetTable("users")
+	if table.Statistics.RowCount != 4 || table.Statistics.Max["email"] != "b" {
+		t.Fatalf("statistics not saved: %+v", table.Statistics)
+	}
+}
+
+func TestAnalyzeSample(t *testing.T) {
+	var rows []string
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, fmt.Sprintf(`{"id": %d, "email": "e%d"}`, i, i%10))
+	}
+	path := writeCatalog(t, "["+strings.Join(rows, ",")+"]", `[]`, ``)
+
+	cat := NewCatalog()
+	table := &TableInfo{Name: "users", Columns: []Column{{Name: "id"}, {Name: "email"}}}
+	cat.RegisterTable(table)
+	table.DataFile = filepath.Join(filepath.Dir(path), "users.json")
+
+	if err := cat.Analyze("users", 100); err != nil {
+		t.Fatal(err)
+	}
+	stats := table.Statistics
+	if stats.RowCount != 1000 {
+		t.Fatalf("row count must be exact, got %d", stats.RowCount)
+	}
+	if stats.DistinctCount["email"] != 10 {
+		t.Fatalf("expected 10 distinct emails, got %d", stats.DistinctCount["email"])
+	}
+	// all sampled ids are distinct, the estimate scales to the table
+	if stats.DistinctCount["id"] != 1000 {
+		t.Fatalf("expected 1000 distinct ids, got %d", stats.DistinctCount["id"])
+	}
+}
+
+func TestAnalyzeBuildsDistribution(t *testing.T) {
+	var rows []string
+	for i := 0; i < 100; i++ {
+		status := "delivered"
+		if i%10 == 0 {
+			status = "pending"
+		}
+		rows = append(rows, fmt.Sprintf(`{"id": %d, "email": "%s"}`, i, status))
+	}
+	path := writeCatalog(t, "["+strings.Join(rows, ",")+"]", `[]`, ``)
+
+	cat := NewCatalog()
+	table := &TableInfo{Name: "users", Columns: []Column{{Name: "id"}, {Name: "email"}}}
+	cat.RegisterTable(table)
+	table.DataFile = filepath.Join(filepath.Dir(path), "users.json")
+	if err := cat.Analyze("users", 0); err != nil {
+		t.Fatal(err)
+	}
+	stats := table.Statistics
+
+	mcv := stats.MostCommon["email"]
+	if len(mcv) != 1 || mcv[0].Value != "delivered" || mcv[0].Frequency != 0.9 {
+		t.Fatalf("unexpected most common values %v", mcv)
+	}
+
+	// ids are unique, so they only get a histogram
+	if len(stats.MostCommon["id"]) != 0 {
+		t.Fatalf("unexpected most common ids %v", stats.MostCommon["id"])
+	}
+	hist := stats.Histograms["id"]
+	if hist == nil || len(hist.Bounds) != histogramBuckets+1 || hist.Bounds[0] != 0.0 || hist.Bounds[histogramBuckets] != 99.0 {
+		t.Fatalf("unexpected histogram %v", hist)
+	}
+	if below := hist.Below(25, false); below < 0.24 || below > 0.26 {
+		t.Fatalf("expected about a quarter of ids below 25, got %v", below)
+	}
+}
+
+func TestColumnTypeNames(t *testing.T) {
+	var cols []Column
+	data := `[{"name": "price", "type": "DECIMAL(10,2)"}, {"name": "day", "type": "date"}, {"name": "id", "type": 0}]`
+	if err := json.Unmarshal([]byte(data), &cols); err != nil {
+		t.Fatal(err)
+	}
+
+	if cols[0].Type != DecimalType || cols[0].Precision != 10 || cols[0].Scale != 2 {
+		t.Fatalf("unexpected decimal column %+v", cols[0])
+	}
+	if cols[1].Type != DateType || cols[2].Type != IntType {
+		t.Fatalf("unexpected column types %s and %s", cols[1].Type, cols[2].Type)
+	}
+
+	out, err := json.Marshal(cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"name":"price","type":"DECIMAL(10,2)"},{"name":"day","type":"DATE"},{"name":"id","type":"INT"}]`
+	if string(out) != expected {
+		t.Fatalf("expected %s, got %s", expected, out)
+	}
+
+	for _, code := range []string{"3", "5", "42", "-1"} {
+		if err := json.Unmarshal([]byte(`[{"name": "x", "type": `+code+`}]`), &cols); err == nil || !strings.Contains(err.Error(), "column x") {
+			t.Fatalf("expected an error naming the column for type code %s, got %v", code, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`[{"name": "x", "type": "BLOB"}]`), &cols); err == nil {
+		t.Fatal("expected unknown type to be rejected")
+	}
+}
+
+func TestDefinitionChangesAreSaved(t *testing.T) {
+	path := writeCatalog(t, `[{"id": 1, "email": "a"}]`, `[{"id": 1, "user_id": 1}]`, ``)
+	cat := NewCatalog()
+	if err := cat.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	reload := func() *Catalog {
+		t.Helper()
+		saved := NewCatalog()
+		if err := saved.LoadFromFile(path); err != nil {
+			t.Fatal(err)
+		}
+		return saved
+	}
+
+	if err := cat.CreateTable(&TableInfo{Name: "tags", Columns: []Column{{Name: "name", Type: StringType}}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.CreateIndex("orders", Index{Name: "idx_user", Columns: []string{"user_id"}}); err != nil {
+		t.Fatal(err)
+	}
+	saved := reload()
+	if _, err := saved.GetTable("tags"); err != nil {
+		t.Fatalf("expected the new table to be saved: %v", err)
+	}
+	if orders, _ := saved.GetTable("orders"); !orders.HasIndex([]string{"user_id"}) {
+		t.Fatal("expected the new index to be saved")
+	}
+
+	users, _ := cat.GetTable("users")
+	renamed := users.Clone()
+	if err := renamed.RenameColumn("id", "user_id"); err != nil {
+		t.Fatal(err)
+	}
+	renamed.Name = "people"
+	if err := cat.ReplaceTable(users, renamed, map[string]string{"id": "user_id"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	orders, _ := cat.GetTable("orders")
+	if fk := orders.ForeignKeys[0]; fk.RefTable != "people" || fk.RefColumns[0] != "user_id" {
+		t.Fatalf("expected the foreign key to follow the rename, got %+v", fk)
+	}
+	// the data file still has the old key, so only the file is checked
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"references": "people"`) {
+		t.Fatal("expected the renamed table to be saved")
+	}
+
+	if _, err := cat.DropTable("people"); err == nil || !strings.Contains(err.Error(), "referenced by a foreign key of orders") {
+		t.Fatalf("expected a referenced table to stay, got %v", err)
+	}
+	if _, err := cat.DropIndex("missing", ""); err == nil {
+		t.Fatal("expected an error for a missing index")
+	}
+}
+
+func TestFailedDefinitionChangeIsUndone(t *testing.T) {
+	path := writeCatalog(t, `[{"id": 1, "email": "a"}]`, `[]`, ``)
+	cat := NewCatalog()
+	if err := cat.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := &TableInfo{Name: "tags", Columns: []Column{{Name: "name"}}}
+	if err := cat.CreateTable(table, func() error { return fmt.Errorf("disk full") }); err == nil {
+		t.Fatal("expected the failed write to fail the change")
+	}
+	if _, err := cat.GetTable("tags"); err == nil {
+		t.Fatal("expected the table to be gone after the failed write")
+	}
+
+	// dropping a column another table's foreign key uses leaves it invalid
+	users, _ := cat.GetTable("users")
+	changed := users.Clone()
+	changed.PrimaryKey = nil
+	if err := changed.DropColumn("id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.ReplaceTable(users, changed, nil, nil); err == nil {
+		t.Fatal("expected the dangling foreign key to be rejected")
+	}
+	if current, _ := cat.GetTable("users"); current != users || len(current.Columns) != 2 {
+		t.Fatalf("expected the old definition back, got %+v", current)
+	}
+
+	dup := &TableInfo{Name: "dup", Columns: []Column{{Name: "a"}, {Name: "a"}}}
+	if err := cat.CreateTable(dup, nil); err == nil || !strings.Contains(err.Error(), "defined twice") {
+		t.Fatalf("expected duplicate columns to be rejected, got %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatal("expected failed changes to leave the catalog file alone")
+	}
+}
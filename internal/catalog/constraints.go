@@ -0,0 +1,330 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// columns referencing a unique key of another table, every non NULL value
+// must exist there
+type ForeignKey struct {
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"references"`
+	RefColumns []string `json:"ref_columns"`
+}
+
+// reports whether a column can never hold NULL, primary key columns can't
+func (t *TableInfo) IsNotNull(name string) bool {
+	for _, pk := range t.PrimaryKey {
+		if pk == name {
+			return true
+		}
+	}
+
+	col, err := t.GetColumn(name)
+	return err == nil && col.NotNull
+}
+
+// foreign key of t made of exactly cols referencing table ref
+func (t *TableInfo) ForeignKeyTo(cols []string, ref string) (*ForeignKey, bool) {
+	for i := range t.ForeignKeys {
+		fk := &t.ForeignKeys[i]
+		if fk.RefTable == ref && len(fk.Columns) == len(cols) && containsAll(cols, fk.Columns) {
+			return fk, true
+		}
+	}
+
+	return nil, false
+}
+
+// checks that constraints name real columns and tables and that the data
+// files satisfy them
+func (c *Catalog) Validate() error {
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.validateSchema(c.tables[name]); err != nil {
+			return fmt.Errorf("table %s: %w", name, err)
+		}
+	}
+
+	rows := make(map[string][]map[string]interface{})
+	for _, name := range names {
+		table := c.tables[name]
+		if !table.HasData() {
+			continue
+		}
+
+		data, err := readRows(table)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", name, err)
+		}
+		rows[name] = data
+	}
+
+	for _, name := range names {
+		data, ok := rows[name]
+		if !ok {
+			continue
+		}
+		if err := c.validateData(c.tables[name], data, rows); err != nil {
+			return fmt.Errorf("table %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// checks constraints as if rows were all of t's data: NOT NULL columns,
+// primary and unique keys, t's foreign keys and the foreign keys of other
+// tables referencing t. Writes call it before changing a table
+func (c *Catalog) CheckRows(t *TableInfo, rows []map[string]interface{}) error {
+	return c.CheckRowsWith(t, rows, readRows)
+}
+
+// CheckRows with the rows of other tables coming from read, for checks
+// that see tables as a transaction does
+func (c *Catalog) CheckRowsWith(t *TableInfo, rows []map[string]interface{}, read RowReader) error {
+	all := map[string][]map[string]interface{}{t.Name: rows}
+	load := func(name string) error {
+		if _, ok := all[name]; ok {
+			return nil
+		}
+		table, err := c.GetTable(name)
+		if err != nil || !table.HasData() {
+			return nil // nothing to check against
+		}
+		data, err := read(table)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", name, err)
+		}
+		all[name] = data
+		return nil
+	}
+
+	for _, fk := range t.ForeignKeys {
+		if err := load(fk.RefTable); err != nil {
+			return err
+		}
+	}
+	if err := c.validateData(t, rows, all); err != nil {
+		return err
+	}
+
+	for _, other := range c.Tables() {
+		if other == t || !other.references(t.Name) {
+			continue
+		}
+		if err := load(other.Name); err != nil {
+			return err
+		}
+		data, ok := all[other.Name]
+		if !ok {
+			continue
+		}
+		if err := c.validateData(other, data, all); err != nil {
+			return fmt.Errorf("table %s: %w", other.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TableInfo) references(name string) bool {
+	for _, fk := range t.ForeignKeys {
+		if fk.RefTable == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Catalog) validateSchema(t *TableInfo) error {
+	check := func(cols []string, what string) error {
+		if len(cols) == 0 {
+			return fmt.Errorf("%s has no columns", what)
+		}
+		for _, col := range cols {
+			if _, err := t.GetColumn(col); err != nil {
+				return fmt.Errorf("%s: %w", what, err)
+			}
+		}
+		return nil
+	}
+
+	if t.Name == "" {
+		return fmt.Errorf("table has no name")
+	}
+	// rows of views come from their query
+	if t.IsView() && (t.HasData() || len(t.Indexes) > 0 || len(t.PrimaryKey) > 0 || len(t.Unique) > 0 || len(t.ForeignKeys) > 0) {
+		return fmt.Errorf("views can't have data, indexes or keys")
+	}
+	seen := make(map[string]bool)
+	for _, col := range t.Columns {
+		if seen[col.Name] {
+			return fmt.Errorf("column %s is defined twice", col.Name)
+		}
+		seen[col.Name] = true
+		if _, err := col.Source(); err != nil {
+			return err
+		}
+	}
+
+	indexes := make(map[string]bool)
+	for _, idx := range t.Indexes {
+		if indexes[idx.Name] {
+			return fmt.Errorf("index %s is defined twice", idx.Name)
+		}
+		indexes[idx.Name] = true
+		if err := check(idx.Columns, "index "+idx.Name); err != nil {
+			return err
+		}
+	}
+
+	if len(t.PrimaryKey) > 0 {
+		if err := check(t.PrimaryKey, "primary key"); err != nil {
+			return err
+		}
+	}
+	for _, key := range t.Unique {
+		if err := check(key, "unique constraint"); err != nil {
+			return err
+		}
+	}
+
+	for _, fk := range t.ForeignKeys {
+		what := fmt.Sprintf("foreign key (%s)", strings.Join(fk.Columns, ", "))
+		if err := check(fk.Columns, what); err != nil {
+			return err
+		}
+
+		ref, err := c.GetTable(fk.RefTable)
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		if len(fk.RefColumns) != len(fk.Columns) {
+			return fmt.Errorf("%s references %d columns", what, len(fk.RefColumns))
+		}
+		for _, col := range fk.RefColumns {
+			if _, err := ref.GetColumn(col); err != nil {
+				return fmt.Errorf("%s: %w", what, err)
+			}
+		}
+		if !ref.IsUnique(fk.RefColumns) {
+			return fmt.Errorf("%s must reference a primary key or unique columns of %s", what, ref.Name)
+		}
+	}
+
+	return nil
+}
+
+func (c *Catalog) validateData(t *TableInfo, data []map[string]interface{}, all map[string][]map[string]interface{}) error {
+	for i, row := range data {
+		for _, col := range t.Columns {
+			if row[col.Name] == nil && t.IsNotNull(col.Name) {
+				return fmt.Errorf("row %d: column %s is NULL", i+1, col.Name)
+			}
+		}
+	}
+
+	keys := t.Unique
+	if len(t.PrimaryKey) > 0 {
+		keys = append([][]string{t.PrimaryKey}, keys...)
+	}
+	for _, key := range keys {
+		seen := make(map[string]bool)
+		for i, row := range data {
+			k, ok := keyOf(row, key)
+			if !ok {
+				continue // NULLs never collide
+			}
+			if seen[k] {
+				return fmt.Errorf("row %d: duplicate value %s for (%s)", i+1, k, strings.Join(key, ", "))
+			}
+			seen[k] = true
+		}
+	}
+
+	for _, fk := range t.ForeignKeys {
+		refRows, ok := all[fk.RefTable]
+		if !ok {
+			continue // nothing to check against
+		}
+
+		existing := make(map[string]bool)
+		for _, row := range refRows {
+			if k, ok := keyOf(row, fk.RefColumns); ok {
+				existing[k] = true
+			}
+		}
+		for i, row := range data {
+			k, ok := keyOf(row, fk.Columns)
+			if ok && !existing[k] {
+				return fmt.Errorf("row %d: %s not found in %s", i+1, k, fk.RefTable)
+			}
+		}
+	}
+
+	return nil
+}
+
+// key of a row over cols, false when one of them is NULL
+func keyOf(row map[string]interface{}, cols []string) (string, bool) {
+	parts := make([]string, len(cols))
+	for i, col := range cols {
+		v := row[col]
+		if v == nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprintf("%v", v)
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")", true
+}
+
+// reads every row of a table, columns mapped to nested paths under their
+// column name. The storage package installs one that handles every source
+// kind, without it tables are read as JSON files
+type RowReader func(table *TableInfo) ([]map[string]interface{}, error)
+
+var readRows RowReader = readJSONRows
+
+// replaces how ANALYZE and constraint checks read table data
+func SetRowReader(r RowReader) {
+	readRows = r
+}
+
+func readJSONRows(table *TableInfo) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse data file: %w", err)
+	}
+
+	for _, col := range table.Columns {
+		if col.Path == "" {
+			continue
+		}
+		path, err := col.Source()
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			row[col.Name], _ = path.Lookup(row)
+		}
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,349 @@
+package catalog
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// CreateTable adds a new table. create, when not nil, makes its data once
+// the definition is known to be valid and before the catalog is saved
+func (c *Catalog) CreateTable(t *TableInfo, create func() error) error {
+	return c.update(func() error {
+		if _, ok := c.tables[t.Name]; ok {
+			return fmt.Errorf("table %s already exists", t.Name)
+		}
+		c.RegisterTable(t)
+		return nil
+	}, create)
+}
+
+// DropTable removes a table no other table references, its data is left to
+// the caller
+func (c *Catalog) DropTable(name string) (*TableInfo, error) {
+	table, err := c.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range c.Tables() {
+		if other != table && other.references(name) {
+			return nil, fmt.Errorf("table %s is referenced by a foreign key of %s", name, other.Name)
+		}
+	}
+
+	err = c.update(func() error {
+		delete(c.tables, name)
+		order := make([]string, 0, len(c.order))
+		for _, n := range c.order {
+			if n != name {
+				order = append(order, n)
+			}
+		}
+		c.order = order
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// CreateIndex adds an index to a table, index names are unique per table
+func (c *Catalog) CreateIndex(name string, index Index) error {
+	table, err := c.GetTable(name)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func() error {
+		for _, idx := range table.Indexes {
+			if idx.Name == index.Name {
+				return fmt.Errorf("index %s already exists on %s", index.Name, name)
+			}
+		}
+		table.Indexes = append(table.Indexes[:len(table.Indexes):len(table.Indexes)], index)
+		return nil
+	}, nil)
+}
+
+// FindIndex is the table holding the index name, searched in table when
+// it isn't empty. Several tables can have an index of the same name, which
+// is an error without a table
+func (c *Catalog) FindIndex(name, table string) (*TableInfo, error) {
+	var found []*TableInfo
+	for _, t := range c.Tables() {
+		if table != "" && t.Name != table {
+			continue
+		}
+		for _, idx := range t.Indexes {
+			if idx.Name == name {
+				found = append(found, t)
+				break
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		if table != "" {
+			if _, err := c.GetTable(table); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("index '%s' not found on table '%s'", name, table)
+		}
+		return nil, fmt.Errorf("index '%s' not found", name)
+	case 1:
+		return found[0], nil
+	}
+
+	names := make([]string, len(found))
+	for i, t := range found {
+		names[i] = t.Name
+	}
+	return nil, fmt.Errorf("index %s exists on %s, name the table with ON", name, strings.Join(names, " and "))
+}
+
+// DropIndex removes an index, see FindIndex for table
+func (c *Catalog) DropIndex(name, table string) (*TableInfo, error) {
+	t, err := c.FindIndex(name, table)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.update(func() error {
+		indexes := make([]Index, 0, len(t.Indexes))
+		for _, idx := range t.Indexes {
+			if idx.Name != name {
+				indexes = append(indexes, idx)
+			}
+		}
+		t.Indexes = indexes
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ReplaceTable puts a changed copy of a table in its place, as ALTER TABLE
+// does. renamed maps old column names to new ones so foreign keys of other
+// tables follow them. write, when not nil, changes the data once the new
+// definition is known to be valid and before the catalog is saved
+func (c *Catalog) ReplaceTable(old, t *TableInfo, renamed map[string]string, write func() error) error {
+	if c.tables[old.Name] != old {
+		return fmt.Errorf("table '%s' not found", old.Name)
+	}
+
+	return c.update(func() error {
+		if t.Name != old.Name {
+			if _, ok := c.tables[t.Name]; ok {
+				return fmt.Errorf("table %s already exists", t.Name)
+			}
+			delete(c.tables, old.Name)
+		}
+		c.tables[t.Name] = t
+
+		order := make([]string, len(c.order))
+		for i, n := range c.order {
+			if n == old.Name {
+				n = t.Name
+			}
+			order[i] = n
+		}
+		c.order = order
+
+		for _, other := range c.Tables() {
+			if other == t || !other.references(old.Name) {
+				continue
+			}
+			fks := make([]ForeignKey, len(other.ForeignKeys))
+			for i, fk := range other.ForeignKeys {
+				if fk.RefTable == old.Name {
+					fk.RefTable = t.Name
+					fk.RefColumns = renameAll(fk.RefColumns, renamed)
+				}
+				fks[i] = fk
+			}
+			other.ForeignKeys = fks
+		}
+		return nil
+	}, write)
+}
+
+// deep copy of a table's definition, for changing it without touching the
+// one in use
+func (t *TableInfo) Clone() *TableInfo {
+	c := *t
+	c.Columns = append([]Column(nil), t.Columns...)
+	c.Indexes = nil
+	for _, idx := range t.Indexes {
+		c.Indexes = append(c.Indexes, Index{Name: idx.Name, Columns: append([]string(nil), idx.Columns...)})
+	}
+	c.PrimaryKey = append([]string(nil), t.PrimaryKey...)
+	c.Unique = nil
+	for _, key := range t.Unique {
+		c.Unique = append(c.Unique, append([]string(nil), key...))
+	}
+	c.ForeignKeys = nil
+	for _, fk := range t.ForeignKeys {
+		c.ForeignKeys = append(c.ForeignKeys, ForeignKey{
+			Columns:    append([]string(nil), fk.Columns...),
+			RefTable:   fk.RefTable,
+			RefColumns: append([]string(nil), fk.RefColumns...),
+		})
+	}
+	if t.Options != nil {
+		c.Options = make(map[string]string, len(t.Options))
+		for k, v := range t.Options {
+			c.Options[k] = v
+		}
+	}
+
+	return &c
+}
+
+// renames a column everywhere the table's definition names it. Statistics
+// are dropped, the caller recomputes them
+func (t *TableInfo) RenameColumn(name, newName string) error {
+	col, err := t.GetColumn(name)
+	if err != nil {
+		return err
+	}
+	if _, err := t.GetColumn(newName); err == nil {
+		return fmt.Errorf("column %s already exists in table %s", newName, t.Name)
+	}
+	col.Name = newName
+
+	renamed := map[string]string{name: newName}
+	for i := range t.Indexes {
+		t.Indexes[i].Columns = renameAll(t.Indexes[i].Columns, renamed)
+	}
+	t.PrimaryKey = renameAll(t.PrimaryKey, renamed)
+	for i := range t.Unique {
+		t.Unique[i] = renameAll(t.Unique[i], renamed)
+	}
+	for i := range t.ForeignKeys {
+		t.ForeignKeys[i].Columns = renameAll(t.ForeignKeys[i].Columns, renamed)
+		if t.ForeignKeys[i].RefTable == t.Name {
+			t.ForeignKeys[i].RefColumns = renameAll(t.ForeignKeys[i].RefColumns, renamed)
+		}
+	}
+	t.Statistics = nil
+
+	return nil
+}
+
+// removes a column no index or constraint uses
+func (t *TableInfo) DropColumn(name string) error {
+	if _, err := t.GetColumn(name); err != nil {
+		return err
+	}
+	if len(t.Columns) == 1 {
+		return fmt.Errorf("can't drop %s, the only column of %s", name, t.Name)
+	}
+
+	uses := func(cols []string) bool {
+		for _, col := range cols {
+			if col == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, idx := range t.Indexes {
+		if uses(idx.Columns) {
+			return fmt.Errorf("column %s is used by index %s", name, idx.Name)
+		}
+	}
+	if uses(t.PrimaryKey) {
+		return fmt.Errorf("column %s is part of the primary key", name)
+	}
+	for _, key := range t.Unique {
+		if uses(key) {
+			return fmt.Errorf("column %s is part of a unique constraint", name)
+		}
+	}
+	for _, fk := range t.ForeignKeys {
+		if uses(fk.Columns) || (fk.RefTable == t.Name && uses(fk.RefColumns)) {
+			return fmt.Errorf("column %s is part of a foreign key", name)
+		}
+	}
+
+	columns := make([]Column, 0, len(t.Columns)-1)
+	for _, col := range t.Columns {
+		if col.Name != name {
+			columns = append(columns, col)
+		}
+	}
+	t.Columns = columns
+	t.Statistics = nil
+
+	return nil
+}
+
+// data file of a new table named after it, next to the catalog file in
+// its data directory
+func (c *Catalog) DataPath(table, ext string) string {
+	return filepath.Join(filepath.Dir(c.path), "data", table+"."+ext)
+}
+
+// runs change and keeps it when every table is still valid and write and
+// saving the catalog succeed, otherwise the catalog is put back as it was.
+// Changes assign new slices instead of changing shared ones in place
+func (c *Catalog) update(change func() error, write func() error) error {
+	tables := make(map[string]*TableInfo, len(c.tables))
+	saved := make(map[*TableInfo]TableInfo, len(c.tables))
+	for name, t := range c.tables {
+		tables[name] = t
+		saved[t] = *t
+	}
+	order := c.order
+
+	err := change()
+	if err == nil {
+		err = c.validateSchemas()
+	}
+	if err == nil && write != nil {
+		err = write()
+	}
+	if err == nil && c.path != "" {
+		err = c.SaveToFile(c.path)
+	}
+	if err != nil {
+		for t, v := range saved {
+			*t = v
+		}
+		c.tables, c.order = tables, order
+	}
+
+	return err
+}
+
+func (c *Catalog) validateSchemas() error {
+	for _, table := range c.Tables() {
+		if err := c.validateSchema(table); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func renameAll(cols []string, renamed map[string]string) []string {
+	if cols == nil {
+		return nil
+	}
+
+	out := make([]string, len(cols))
+	for i, col := range cols {
+		if n, ok := renamed[col]; ok {
+			col = n
+		}
+		out[i] = col
+	}
+
+	return out
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+const (
+	maxMostCommon    = 10
+	histogramBuckets = 10
+)
+
+// a frequent value and the fraction of all rows holding it
+type ValueFrequency struct {
+	Value     interface{} `json:"value"`
+	Frequency float64     `json:"frequency"`
+}
+
+// equi-depth histogram over the non NULL values that are not in the most
+// common list. Each of the len(Bounds)-1 buckets holds the same number of
+// rows, bucket i covers Bounds[i] to Bounds[i+1]
+type Histogram struct {
+	Bounds []interface{} `json:"bounds"`
+}
+
+// fraction of the histogram's rows below v, or at most v when inclusive
+func (h *Histogram) Below(v interface{}, inclusive bool) float64 {
+	n := len(h.Bounds)
+	if n < 2 {
+		return 0.5
+	}
+	v = normalize(v)
+
+	if c := compareValues(v, h.Bounds[0]); c < 0 || (c == 0 && !inclusive) {
+		return 0
+	}
+	if c := compareValues(v, h.Bounds[n-1]); c > 0 || (c == 0 && inclusive) {
+		return 1
+	}
+
+	// first bound above v, v lies in the bucket ending there
+	i := sort.Search(n, func(i int) bool { return compareValues(h.Bounds[i], v) > 0 })
+	buckets := float64(n - 1)
+
+	return (float64(i-1) + interpolate(h.Bounds[i-1], h.Bounds[i], v)) / buckets
+}
+
+// reports whether v lies between the lowest and highest bound
+func (h *Histogram) Covers(v interface{}) bool {
+	if len(h.Bounds) == 0 {
+		return false
+	}
+	v = normalize(v)
+
+	return compareValues(v, h.Bounds[0]) >= 0 && compareValues(v, h.Bounds[len(h.Bounds)-1]) <= 0
+}
+
+// position of v between lo and hi, linear for numbers and strings and
+// halfway otherwise
+func interpolate(lo, hi, v interface{}) float64 {
+	a, ok1 := scalar(lo)
+	b, ok2 := scalar(hi)
+	x, ok3 := scalar(v)
+	if !ok1 || !ok2 || !ok3 || b <= a {
+		return 0.5
+	}
+
+	return math.Min(math.Max((x-a)/(b-a), 0), 1)
+}
+
+// numbers as they are, strings as a fraction from their first bytes
+func scalar(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, scale := 0.0, 1.0
+		for i := 0; i < len(x) && i < 8; i++ {
+			scale /= 256
+			f += float64(x[i]) * scale
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+type valueCount struct {
+	value interface{}
+	count int
+}
+
+// most common values of a column and a histogram of the rest. Values only
+// make the list when they are more frequent than the average value
+func buildDistribution(counts map[string]*valueCount, sampled int) ([]ValueFrequency, *Histogram) {
+	entries := make([]*valueCount, 0, len(counts))
+	nonNull := 0
+	for _, e := range counts {
+		entries = append(entries, e)
+		nonNull += e.count
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return compareValues(entries[i].value, entries[j].value) < 0
+	})
+
+	average := float64(nonNull) / float64(len(entries))
+	var mcv []ValueFrequency
+	rest := entries
+	for len(mcv) < maxMostCommon && len(rest) > 0 && rest[0].count > 1 && float64(rest[0].count) > average {
+		mcv = append(mcv, ValueFrequency{Value: rest[0].value, Frequency: float64(rest[0].count) / float64(sampled)})
+		rest = rest[1:]
+	}
+
+	var values []interface{}
+	for _, e := range rest {
+		for i := 0; i < e.count; i++ {
+			values = append(values, e.value)
+		}
+	}
+	if len(rest) < 2 {
+		return mcv, nil
+	}
+	sort.SliceStable(values, func(i, j int) bool { return compareValues(values[i], values[j]) < 0 })
+
+	buckets := histogramBuckets
+	if len(rest)-1 < buckets {
+		buckets = len(rest) - 1
+	}
+	bounds := make([]interface{}, buckets+1)
+	for i := range bounds {
+		bounds[i] = values[i*(len(values)-1)/buckets]
+	}
+
+	return mcv, &Histogram{Bounds: bounds}
+}
+
+// numbers compare as float64 like the values decoded from data files,
+// dates and times by their text which sorts the same way
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case types.Decimal:
+		return x.Float64()
+	case types.Date, types.Timestamp:
+		return fmt.Sprint(x)
+	}
+
+	return v
+}
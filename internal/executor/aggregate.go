@@ -0,0 +1,241 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+type aggregateGroup struct {
+	keys   []interface{}
+	states []interface{}
+}
+
+// hash aggregation, groups come out in the order they were first seen
+func (e *Executor) executeAggregate(agg *plan.LogicalAggregate) (Iterator, error) {
+	input, err := e.executeNode(agg.Input)
+	if err != nil {
+		return nil, err
+	}
+	if agg.Sorted {
+		return &streamAggregateIterator{agg: agg, input: input, err: &e.err}, nil
+	}
+	defer input.Close()
+
+	groups := make(map[string]*aggregateGroup)
+	var order []*aggregateGroup
+
+	for {
+		row, ok := input.Next()
+		if !ok {
+			break
+		}
+
+		keys := evaluateKeys(agg.GroupBy, row)
+		hash := valuesKey(keys)
+
+		group, ok := groups[hash]
+		if !ok {
+			group = newAggregateGroup(agg, keys)
+			groups[hash] = group
+			order = append(order, group)
+		}
+		if err := group.accumulate(agg, row); err != nil {
+			return nil, err
+		}
+	}
+
+	// without GROUP BY an empty input still gives one row
+	if len(order) == 0 && len(agg.GroupBy) == 0 {
+		order = append(order, newAggregateGroup(agg, nil))
+	}
+
+	rows := make([]Row, 0, len(order))
+	for _, group := range order {
+		row, err := group.finish(agg)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return &scanIterator{rows: rows, index: 0}, nil
+}
+
+func (g *aggregateGroup) accumulate(agg *plan.LogicalAggregate, row Row) error {
+	for i, a := range agg.Aggregates {
+		args, ok := aggregateArgs(a, row)
+		if !ok {
+			continue
+		}
+
+		args, err := a.Func.Coerce(args)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Name, err)
+		}
+		g.states[i], err = a.Func.Accumulate(g.states[i], args)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// output row of a group
+func (g *aggregateGroup) finish(agg *plan.LogicalAggregate) (Row, error) {
+	row := make(Row, len(agg.GroupBy)+len(agg.Aggregates))
+	for i, name := range agg.GroupNames {
+		row[name] = g.keys[i]
+	}
+
+	for i, a := range agg.Aggregates {
+		val, err := a.Func.Finalize(g.states[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Name, err)
+		}
+		row[agg.AggregateNames[i]] = val
+	}
+
+	return row, nil
+}
+
+// aggregation of an input sorted by the group keys, a group is done as soon
+// as the next one starts. Errors end the rows and fail the query
+type streamAggregateIterator struct {
+	agg   *plan.LogicalAggregate
+	input Iterator
+	group *aggregateGroup
+	done  bool
+	err   *error
+}
+
+func (s *streamAggregateIterator) Next() (Row, bool) {
+	for !s.done {
+		row, ok := s.input.Next()
+		if !ok {
+			s.done = true
+			if s.group == nil && len(s.agg.GroupBy) == 0 {
+				s.group = newAggregateGroup(s.agg, nil)
+			}
+			return s.emit()
+		}
+
+		var out Row
+		keys := evaluateKeys(s.agg.GroupBy, row)
+		if s.group != nil && compareKeys(s.group.keys, keys, nil) != 0 {
+			if out, ok = s.emit(); !ok {
+				return nil, false
+			}
+		}
+		if s.group == nil {
+			s.group = newAggregateGroup(s.agg, keys)
+		}
+		if err := s.group.accumulate(s.agg, row); err != nil {
+			s.fail(err)
+			return nil, false
+		}
+		if out != nil {
+			return out, true
+		}
+	}
+
+	return nil, false
+}
+
+// row of the finished group
+func (s *streamAggregateIterator) emit() (Row, bool) {
+	if s.group == nil {
+		return nil, false
+	}
+	row, err := s.group.finish(s.agg)
+	s.group = nil
+	if err != nil {
+		s.fail(err)
+		return nil, false
+	}
+	return row, true
+}
+
+func (s *streamAggregateIterator) fail(err error) {
+	s.done = true
+	if *s.err == nil {
+		*s.err = err
+	}
+}
+
+func (s *streamAggregateIterator) Close() {
+	s.input.Close()
+}
+
+func newAggregateGroup(agg *plan.LogicalAggregate, keys []interface{}) *aggregateGroup {
+	group := &aggregateGroup{
+		keys:   keys,
+		states: make([]interface{}, len(agg.Aggregates)),
+	}
+	for i, a := range agg.Aggregates {
+		group.states[i] = a.Func.Init()
+	}
+
+	return group
+}
+
+// evaluates aggregate arguments, false when the row has a NULL argument
+func aggregateArgs(a *plan.AggregateExpr, row Row) ([]interface{}, bool) {
+	var args []interface{}
+
+	for _, arg := range a.Args {
+		if _, ok := arg.(*plan.StarExpr); ok { // COUNT(*)
+			continue
+		}
+
+		val := evaluateOrNil(arg, row)
+		if val == nil {
+			return nil, false
+		}
+		args = append(args, val)
+	}
+
+	return args, true
+}
+
+// hashable form of group keys, numbers with equal values share a group
+func groupKey(keys []interface{}) string {
+	var sb strings.Builder
+
+	for _, key := range keys {
+		if f, ok := toFloat64(key); ok {
+			key = f
+		}
+
+		switch key.(type) {
+		case nil:
+			sb.WriteString("N")
+		case float64:
+			sb.WriteString("n" + function.FormatValue(key))
+		case string:
+			sb.WriteString("s" + function.FormatValue(key))
+		default:
+			sb.WriteString(fmt.Sprintf("%T%v", key, key))
+		}
+		sb.WriteByte(0)
+	}
+
+	return sb.String()
+}
+
+// hashable form of values, decimals equal to other numbers hash alike
+func valuesKey(values []interface{}) string {
+	keys := make([]interface{}, len(values))
+	for i, v := range values {
+		if d, ok := v.(types.Decimal); ok {
+			v = d.Float64()
+		}
+		keys[i] = v
+	}
+
+	return groupKey(keys)
+}
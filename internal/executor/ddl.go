@@ -0,0 +1,221 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// registers the table and writes its empty data file, which is removed
+// again when the catalog can't be saved
+func (e *Executor) executeCreateTable(create *plan.LogicalCreateTable) (Iterator, error) {
+	table := create.Table
+	if _, err := e.catalog.GetTable(table.Name); err == nil && create.IfNotExists {
+		return &scanIterator{}, nil
+	}
+
+	created := false
+	err := e.catalog.CreateTable(table, func() error {
+		if err := storage.CreateData(table); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		if created {
+			storage.DropData(table)
+		}
+		return nil, err
+	}
+
+	return &scanIterator{}, nil
+}
+
+// removes the table from the catalog first, so a failed save keeps both
+// the entry and the data
+func (e *Executor) executeDropTable(drop *plan.LogicalDropTable) (Iterator, error) {
+	if _, err := e.catalog.GetTable(drop.Name); err != nil && drop.IfExists {
+		return &scanIterator{}, nil
+	}
+
+	if table, err := e.catalog.GetTable(drop.Name); err == nil {
+		if err := e.idle(table); err != nil {
+			return nil, err
+		}
+	}
+
+	table, err := e.catalog.DropTable(drop.Name)
+	if err != nil {
+		return nil, err
+	}
+	delete(e.sources, table)
+
+	if !table.HasData() {
+		return &scanIterator{}, nil
+	}
+	if err := storage.DropData(table); err != nil {
+		return nil, fmt.Errorf("table %s was dropped but its data wasn't removed: %w", table.Name, err)
+	}
+	return &scanIterator{}, nil
+}
+
+func (e *Executor) executeCreateIndex(create *plan.LogicalCreateIndex) (Iterator, error) {
+	table, err := e.catalog.GetTable(create.Table.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range table.Indexes {
+		if idx.Name == create.Index.Name && create.IfNotExists {
+			return &scanIterator{}, nil
+		}
+	}
+
+	if err := e.catalog.CreateIndex(table.Name, create.Index); err != nil {
+		return nil, err
+	}
+	return &scanIterator{}, nil
+}
+
+func (e *Executor) executeAnalyze(analyze *plan.LogicalAnalyze) (Iterator, error) {
+	for _, table := range analyze.Tables {
+		if err := e.catalog.Analyze(table.Name, analyze.Sample); err != nil {
+			return nil, err
+		}
+	}
+	return &scanIterator{}, nil
+}
+
+func (e *Executor) executeDropIndex(drop *plan.LogicalDropIndex) (Iterator, error) {
+	if _, err := e.catalog.FindIndex(drop.Name, drop.Table); err != nil && drop.IfExists {
+		return &scanIterator{}, nil
+	}
+
+	if _, err := e.catalog.DropIndex(drop.Name, drop.Table); err != nil {
+		return nil, err
+	}
+	return &scanIterator{}, nil
+}
+
+// swaps in the altered definition, rewriting the rows when the columns
+// change. Rows are written once the new definition is valid and put back
+// when the catalog can't be saved
+func (e *Executor) executeAlterTable(alter *plan.LogicalAlterTable) (Iterator, error) {
+	old, altered := alter.Table, alter.Altered
+	if err := e.idle(old); err != nil {
+		return nil, err
+	}
+
+	var before, after []storage.Row
+	rewrite := !sameColumns(old.Columns, altered.Columns)
+	if rewrite {
+		source, err := e.writable(old)
+		if err != nil {
+			return nil, err
+		}
+		if before, err = readRows(source); err != nil {
+			return nil, err
+		}
+		if after, err = alteredRows(old, altered, alter.Renamed, before); err != nil {
+			return nil, err
+		}
+		if old.Stats() != nil {
+			altered.Statistics = storage.StatisticsOf(altered, after, 0)
+		}
+	}
+
+	wrote := false
+	err := e.catalog.ReplaceTable(old, altered, alter.Renamed, func() error {
+		if !rewrite {
+			return nil
+		}
+		if err := e.catalog.CheckRows(altered, after); err != nil {
+			return fmt.Errorf("table %s: %w", altered.Name, err)
+		}
+		source, err := e.writable(altered)
+		if err != nil {
+			return err
+		}
+		if err := source.Replace(after); err != nil {
+			return err
+		}
+		wrote = true
+		return nil
+	})
+	if err != nil {
+		if wrote {
+			if source, werr := e.writable(old); werr == nil {
+				source.Replace(before)
+			}
+		}
+		delete(e.sources, altered)
+		return nil, err
+	}
+
+	delete(e.sources, old)
+	return &scanIterator{}, nil
+}
+
+// open transactions keep rows of the tables they changed in their current
+// definition
+func (e *Executor) idle(table *catalog.TableInfo) error {
+	if e.txns.InUse(table) {
+		return fmt.Errorf("table %s is in use by open transactions", table.Name)
+	}
+	return nil
+}
+
+// rows of old in the columns of altered. Renamed columns move, new ones
+// are NULL and values of columns whose type changed are converted
+func alteredRows(old, altered *catalog.TableInfo, renamed map[string]string, rows []storage.Row) ([]storage.Row, error) {
+	from := make(map[string]string, len(renamed))
+	for o, n := range renamed {
+		from[n] = o
+	}
+
+	out := make([]storage.Row, len(rows))
+	for i, row := range rows {
+		changed := make(storage.Row, len(row))
+		for k, v := range row {
+			if _, err := old.GetColumn(k); err != nil {
+				changed[k] = v // other keys of documents are kept
+			}
+		}
+
+		for _, col := range altered.Columns {
+			name := col.Name
+			if o, ok := from[name]; ok {
+				name = o
+			}
+			val := row[name]
+
+			if prev, err := old.GetColumn(name); err == nil && (prev.Type != col.Type || prev.Precision != col.Precision || prev.Scale != col.Scale) {
+				converted, err := function.CastTo(val, col.Type, col.Precision, col.Scale)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: column %s: %w", i+1, col.Name, err)
+				}
+				val = converted
+			}
+			changed[col.Name] = val
+		}
+		out[i] = changed
+	}
+
+	return out, nil
+}
+
+func sameColumns(a, b []catalog.Column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
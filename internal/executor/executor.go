@@ -1,12 +1,15 @@
 package executor
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"strings"
 
 	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
 	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+	"github.com/Adit0507/sql-query-optimizer/internal/txn"
 )
 
 type Row map[string]interface{} //row of data
@@ -18,16 +21,108 @@ type Iterator interface { //result set iterator
 
 type Executor struct {
 	catalog *catalog.Catalog
+	sources map[*catalog.TableInfo]storage.TableSource
+	txns    *txn.Manager
+	tx      *txn.Txn // opened by BEGIN, nil between transactions
+	stmt    *txn.Txn // transaction of the running statement
+
+	refreshed []*catalog.TableInfo // materialized views the open transaction refreshed
+
+	deltas map[*plan.LogicalScan][]storage.Row // rows scans read instead of their table's, see maintain
+
+	err error // first error a source reported while running a query
 }
 
 func NewExecutor(cat *catalog.Catalog) *Executor {
 	return &Executor{
 		catalog: cat,
+		sources: make(map[*catalog.TableInfo]storage.TableSource),
+		txns:    txn.ManagerOf(cat),
+	}
+}
+
+// runs a statement in the open transaction, statements outside BEGIN and
+// COMMIT run in one of their own
+func (e *Executor) Execute(node plan.LogicalPlan) ([]Row, error) {
+	e.err = nil
+	if t, ok := node.(*plan.LogicalTransaction); ok {
+		return nil, e.executeTransaction(t)
+	}
+	if plan.IsDefinition(node) {
+		if e.tx != nil {
+			return nil, fmt.Errorf("table definitions can't change inside a transaction")
+		}
+		// the table files have to hold every commit before they change
+		if err := e.txns.Checkpoint(); err != nil {
+			return nil, err
+		}
+		if err := e.markStale(node); err != nil {
+			return nil, err
+		}
+		return e.run(node)
+	}
+
+	e.stmt = e.tx
+	if e.stmt == nil {
+		e.stmt = e.txns.Begin()
+	}
+	defer func() { e.stmt = nil }()
+
+	err := e.markStale(node)
+	var results []Row
+	if err == nil {
+		results, err = e.run(node)
+	}
+	if e.tx == nil {
+		if err != nil {
+			e.stmt.Rollback()
+			e.refreshed = nil
+			return nil, err
+		}
+		if err := e.stmt.Commit(); err != nil {
+			e.refreshed = nil
+			return nil, err
+		}
+		return results, e.committed()
+	}
+
+	if err != nil && e.tx.Done() {
+		e.tx = nil // a conflict rolled it back
+		e.refreshed = nil
+		return nil, fmt.Errorf("%w, the transaction was rolled back", err)
 	}
+	return results, err
 }
 
-func (e *Executor) Execute(plan plan.LogicalPlan) ([]Row, error) {
-	iter, err := e.executeNode(plan)
+func (e *Executor) executeTransaction(t *plan.LogicalTransaction) error {
+	if t.Action == "BEGIN" {
+		if e.tx != nil {
+			return fmt.Errorf("a transaction is already in progress")
+		}
+		e.tx = e.txns.Begin()
+		return nil
+	}
+
+	if e.tx == nil {
+		return fmt.Errorf("no transaction is in progress")
+	}
+	tx := e.tx
+	e.tx = nil
+	if t.Action == "ROLLBACK" {
+		tx.Rollback()
+		e.refreshed = nil
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		e.refreshed = nil
+		return err
+	}
+	return e.committed()
+}
+
+// rows of a plan
+func (e *Executor) run(node plan.LogicalPlan) ([]Row, error) {
+	iter, err := e.executeNode(node)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +136,9 @@ func (e *Executor) Execute(plan plan.LogicalPlan) ([]Row, error) {
 		}
 		results = append(results, row)
 	}
+	if e.err != nil {
+		return nil, e.err
+	}
 
 	return results, nil
 }
@@ -54,8 +152,44 @@ func (e *Executor) executeNode(node plan.LogicalPlan) (Iterator, error) {
 		return e.executeFilter(n)
 	case *plan.LogicalJoin:
 		return e.executeJoin(n)
+	case *plan.LogicalUnnest:
+		return e.executeUnnest(n)
 	case *plan.LogicalProject:
 		return e.executeProject(n)
+	case *plan.LogicalWindow:
+		return e.executeWindow(n)
+	case *plan.LogicalAggregate:
+		return e.executeAggregate(n)
+	case *plan.LogicalSort:
+		return e.executeSort(n)
+	case *plan.LogicalEmpty:
+		return &scanIterator{}, nil
+	case *plan.LogicalValues:
+		return e.executeValues(n)
+	case *plan.LogicalInsert:
+		return e.executeInsert(n)
+	case *plan.LogicalUpdate:
+		return e.executeUpdate(n)
+	case *plan.LogicalDelete:
+		return e.executeDelete(n)
+	case *plan.LogicalCreateTable:
+		return e.executeCreateTable(n)
+	case *plan.LogicalDropTable:
+		return e.executeDropTable(n)
+	case *plan.LogicalCreateIndex:
+		return e.executeCreateIndex(n)
+	case *plan.LogicalDropIndex:
+		return e.executeDropIndex(n)
+	case *plan.LogicalAlterTable:
+		return e.executeAlterTable(n)
+	case *plan.LogicalView:
+		return e.executeView(n)
+	case *plan.LogicalCreateView:
+		return e.executeCreateView(n)
+	case *plan.LogicalRefresh:
+		return e.executeRefresh(n)
+	case *plan.LogicalAnalyze:
+		return e.executeAnalyze(n)
 
 	default:
 		return nil, fmt.Errorf("unsupported plan node: %T", node)
@@ -79,17 +213,105 @@ func (s *scanIterator) Next() (Row, bool) {
 func (s *scanIterator) Close() {}
 
 func (e *Executor) executeScan(scan *plan.LogicalScan) (Iterator, error) {
-	data, err := os.ReadFile(scan.Table.DataFile)
+	if rows, ok := e.deltas[scan]; ok {
+		opts := storage.ScanOptions{Columns: scan.Columns, Predicates: scan.Pushed}
+		return &sourceIterator{rows: storage.NewSliceIterator(rows, opts), qualifier: scan.QualifiedName(), columns: scan.Table.Columns, err: &e.err}, nil
+	}
+
+	source, err := e.source(scan.Table)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read data file: %w", err)
+		return nil, err
 	}
 
-	var rows []Row
-	if err := json.Unmarshal(data, &rows); err != nil {
-		return nil, fmt.Errorf("failed to parse data: %w", err)
+	var rows storage.RowIterator
+	if idx, key, ok := indexKey(scan); ok {
+		if indexed, isIndexed := source.(storage.IndexSource); isIndexed {
+			rows, err = indexed.IndexLookup(idx, key)
+		}
+	}
+	if rows == nil && err == nil {
+		rows, err = source.Scan(storage.ScanOptions{Columns: scan.Columns, Predicates: scan.Pushed})
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &scanIterator{rows: rows, index: 0}, nil
+	return &sourceIterator{rows: rows, qualifier: scan.QualifiedName(), columns: scan.Table.Columns, err: &e.err}, nil
+}
+
+// opened once per table and kept, sources may cache data between scans.
+// Statements see the table through their transaction
+func (e *Executor) source(table *catalog.TableInfo) (storage.TableSource, error) {
+	source, ok := e.sources[table]
+	if !ok {
+		var err error
+		if source, err = storage.Open(table); err != nil {
+			return nil, err
+		}
+		e.sources[table] = source
+	}
+
+	if e.stmt != nil {
+		return e.stmt.Source(table, source), nil
+	}
+	return source, nil
+}
+
+// index the optimizer picked for scan and the values its columns are
+// pinned to
+func indexKey(scan *plan.LogicalScan) (catalog.Index, []interface{}, bool) {
+	for _, idx := range scan.Table.Indexes {
+		if idx.Name != scan.Index {
+			continue
+		}
+
+		key := make([]interface{}, len(idx.Columns))
+		for i, col := range idx.Columns {
+			found := false
+			for _, p := range scan.Pushed {
+				if p.Column == col && p.Op == "=" {
+					key[i], found = p.Value, true
+					break
+				}
+			}
+			if !found {
+				return catalog.Index{}, nil, false
+			}
+		}
+		return idx, key, true
+	}
+
+	return catalog.Index{}, nil, false
+}
+
+// rows of a table source. Qualified copies keep table.col apart from same
+// named join columns, read errors end the scan and fail the query
+type sourceIterator struct {
+	rows      storage.RowIterator
+	qualifier string
+	columns   []catalog.Column
+	err       *error
+}
+
+func (s *sourceIterator) Next() (Row, bool) {
+	row, err := s.rows.Next()
+	if err != nil {
+		if err != io.EOF && *s.err == nil {
+			*s.err = err
+		}
+		return nil, false
+	}
+
+	for _, col := range s.columns {
+		if val, ok := row[col.Name]; ok {
+			row[s.qualifier+"."+col.Name] = val
+		}
+	}
+	return Row(row), true
+}
+
+func (s *sourceIterator) Close() {
+	s.rows.Close()
 }
 
 type filterIterator struct {
@@ -134,6 +356,7 @@ type projectIterator struct {
 	input       Iterator
 	projections []plan.Expr
 	columnNames []string
+	err         *error // first failed projection, it ends the rows
 }
 
 func (p *projectIterator) Next() (Row, bool) {
@@ -146,7 +369,10 @@ func (p *projectIterator) Next() (Row, bool) {
 	for i, expr := range p.projections {
 		value, err := evaluateExpr(expr, row)
 		if err != nil {
-			continue
+			if *p.err == nil {
+				*p.err = err
+			}
+			return nil, false
 		}
 
 		ans[p.columnNames[i]] = value
@@ -169,6 +395,7 @@ func (e *Executor) executeProject(proj *plan.LogicalProject) (Iterator, error) {
 		input:       input,
 		projections: proj.Projections,
 		columnNames: proj.ColumnNames,
+		err:         &e.err,
 	}, nil
 }
 
@@ -181,6 +408,18 @@ type joinIterator struct {
 	leftRow   Row
 	rightRows []Row
 	rightIdx  int
+
+	// hash joins only try the right rows whose keys equal the left row's
+	probe      []plan.Expr
+	buckets    map[string][]int
+	candidates []int
+
+	// outer joins pad rows that found no match with NULLs for these keys
+	leftKeys     []string
+	rightKeys    []string
+	leftMatched  bool
+	rightMatched []bool
+	unmatchedIdx int
 }
 
 func (j *joinIterator) Next() (Row, bool) {
@@ -188,28 +427,30 @@ func (j *joinIterator) Next() (Row, bool) {
 		if j.leftRow == nil {
 			row, ok := j.left.Next()
 			if !ok {
-				return nil, false
+				return j.nextUnmatchedRight()
 			}
 
 			j.leftRow = row
 			j.rightIdx = 0
+			j.leftMatched = false
+			if j.buckets != nil {
+				j.candidates = j.buckets[probeKey(j.probe, row)]
+			}
 		}
 
-		if j.rightIdx >= len(j.rightRows) {
+		if j.rightIdx >= len(j.candidates) {
+			leftRow := j.leftRow
 			j.leftRow = nil
+			if j.joinType == plan.LeftJoin && !j.leftMatched {
+				return combineRows(leftRow, nil, j.rightKeys), true
+			}
 			continue
 		}
-		rightRow := j.rightRows[j.rightIdx]
+		idx := j.candidates[j.rightIdx]
+		rightRow := j.rightRows[idx]
 		j.rightIdx++
 
-		// combining rows
-		combined := make(Row)
-		for k, v := range j.leftRow {
-			combined[k] = v
-		}
-		for k, v := range rightRow {
-			combined[k] = v
-		}
+		combined := combineRows(j.leftRow, rightRow, nil)
 
 		// evaluate comdition
 		ans, err := evaluateExpr(j.condition, combined)
@@ -217,15 +458,58 @@ func (j *joinIterator) Next() (Row, bool) {
 			continue
 		}
 
+		j.leftMatched = true
+		if j.rightMatched != nil {
+			j.rightMatched[idx] = true
+		}
 		return combined, true
 	}
 }
+
+// right rows no left row matched, for RIGHT joins once the left side is done
+func (j *joinIterator) nextUnmatchedRight() (Row, bool) {
+	for j.joinType == plan.RightJoin && j.unmatchedIdx < len(j.rightRows) {
+		idx := j.unmatchedIdx
+		j.unmatchedIdx++
+
+		if !j.rightMatched[idx] {
+			return combineRows(nil, j.rightRows[idx], j.leftKeys), true
+		}
+	}
+
+	return nil, false
+}
+
 func (j *joinIterator) Close() {
 	j.left.Close()
 	j.right.Close()
 }
 
+// merges two rows, a nil side is padded with NULL for nullKeys. Bare
+// column names already set by the other side are kept, qualified names
+// always stay distinct
+func combineRows(left, right Row, nullKeys []string) Row {
+	combined := make(Row)
+	for k, v := range left {
+		combined[k] = v
+	}
+	for _, k := range nullKeys {
+		if _, ok := combined[k]; !ok || strings.Contains(k, ".") {
+			combined[k] = nil
+		}
+	}
+	for k, v := range right {
+		combined[k] = v
+	}
+
+	return combined
+}
+
 func (e *Executor) executeJoin(join *plan.LogicalJoin) (Iterator, error) {
+	if join.Method == plan.MergeJoin {
+		return e.executeMergeJoin(join)
+	}
+
 	left, err := e.executeNode(join.Left)
 	if err != nil {
 		return nil, err
@@ -246,19 +530,157 @@ func (e *Executor) executeJoin(join *plan.LogicalJoin) (Iterator, error) {
 		rightRows = append(rightRows, row)
 	}
 
-	return &joinIterator{
+	iter := &joinIterator{
 		left:      left,
 		right:     right,
 		condition: join.Condition,
 		joinType:  join.JoinType,
 		rightRows: rightRows,
 		rightIdx:  0,
-	}, nil
+		leftKeys:  outputKeys(join.Left),
+		rightKeys: outputKeys(join.Right),
+	}
+	if join.JoinType == plan.RightJoin {
+		iter.rightMatched = make([]bool, len(rightRows))
+	}
+
+	if join.Method == plan.HashJoin {
+		iter.probe = join.LeftKeys
+		iter.buckets = make(map[string][]int)
+		for i, row := range rightRows {
+			if key := probeKey(join.RightKeys, row); key != "" {
+				iter.buckets[key] = append(iter.buckets[key], i)
+			}
+		}
+	} else {
+		iter.candidates = make([]int, len(rightRows))
+		for i := range iter.candidates {
+			iter.candidates[i] = i
+		}
+	}
+
+	return iter, nil
+}
+
+// hashed join keys of a row, empty when one is NULL and matches nothing
+func probeKey(keys []plan.Expr, row Row) string {
+	values := evaluateKeys(keys, row)
+	if hasNull(values) {
+		return ""
+	}
+
+	return valuesKey(values)
+}
+
+// row keys a plan produces, scans add qualified names next to bare ones
+func outputKeys(node plan.LogicalPlan) []string {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		var keys []string
+		for _, col := range n.Table.Columns {
+			keys = append(keys, col.Name, n.QualifiedName()+"."+col.Name)
+		}
+		return keys
+	case *plan.LogicalFilter:
+		return outputKeys(n.Input)
+	case *plan.LogicalSort:
+		return outputKeys(n.Input)
+	case *plan.LogicalJoin:
+		return append(outputKeys(n.Left), outputKeys(n.Right)...)
+	case *plan.LogicalUnnest:
+		return append(outputKeys(n.Input), n.Alias)
+	default:
+		var keys []string
+		for _, col := range node.Schema() {
+			keys = append(keys, col.Name)
+		}
+		return keys
+	}
+}
+
+// lateral unnest, one row per array element of each input row
+type unnestIterator struct {
+	input   Iterator
+	node    *plan.LogicalUnnest
+	row     Row
+	elems   []interface{}
+	idx     int
+	matched bool
+}
+
+func (u *unnestIterator) Next() (Row, bool) {
+	for {
+		if u.row == nil {
+			row, ok := u.input.Next()
+			if !ok {
+				return nil, false
+			}
+
+			u.row, u.elems, u.idx, u.matched = row, nil, 0, false
+			if val, err := evaluateExpr(u.node.Expr, row); err == nil {
+				u.elems = unnestElements(val)
+			}
+		}
+
+		if u.idx >= len(u.elems) {
+			row := u.row
+			u.row = nil
+			if u.node.Outer && !u.matched {
+				return combineRows(row, Row{u.node.Alias: nil}, nil), true
+			}
+			continue
+		}
+		elem := u.elems[u.idx]
+		u.idx++
+
+		out := combineRows(u.row, Row{u.node.Alias: elem}, nil)
+		if u.node.Condition != nil {
+			ans, err := evaluateExpr(u.node.Condition, out)
+			if err != nil || ans != true {
+				continue
+			}
+		}
+
+		u.matched = true
+		return out, true
+	}
+}
+
+func (u *unnestIterator) Close() {
+	u.input.Close()
+}
+
+// elements of an array, NULL and empty arrays have none and anything else
+// is a single element
+func unnestElements(v interface{}) []interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return x
+	default:
+		return []interface{}{x}
+	}
+}
+
+func (e *Executor) executeUnnest(node *plan.LogicalUnnest) (Iterator, error) {
+	input, err := e.executeNode(node.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unnestIterator{input: input, node: node}, nil
 }
 
 func evaluateExpr(expr plan.Expr, row Row) (interface{}, error) {
 	switch e := expr.(type) {
 	case *plan.ColumnExpr:
+		if e.Table != "" {
+			if val, ok := row[e.Table+"."+e.Column]; ok {
+				return val, nil
+			}
+		}
+
 		val, ok := row[e.Column]
 		if !ok {
 			return nil, fmt.Errorf("column %s not found", e.Column)
@@ -278,7 +700,52 @@ func evaluateExpr(expr plan.Expr, row Row) (interface{}, error) {
 			return nil, err
 		}
 
-		return evaluateBinaryOp(left, e.Operator, right)
+		return function.EvalBinary(left, e.Operator, right)
+
+	case *plan.FuncCallExpr:
+		args := make([]interface{}, len(e.Args))
+		for i, arg := range e.Args {
+			val, err := evaluateExpr(arg, row)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = val
+		}
+		args, err := e.Func.Coerce(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name, err)
+		}
+
+		return e.Func.Eval(args)
+
+	case *plan.NotExpr:
+		val, err := evaluateExpr(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			return nil, nil
+		}
+		b, _ := val.(bool)
+		return !b, nil
+
+	case *plan.IsNullExpr:
+		val, err := evaluateExpr(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+		return (val == nil) != e.Not, nil
+
+	case *plan.CastExpr:
+		val, err := evaluateExpr(e.Expr, row)
+		if err != nil {
+			return nil, err
+		}
+
+		return function.CastTo(val, e.Type, e.Precision, e.Scale)
+
+	case *plan.CaseExpr:
+		return evaluateCase(e, row)
 
 	default:
 		return nil, fmt.Errorf("unsuppiorted expression type: %T", expr)
@@ -286,60 +753,52 @@ func evaluateExpr(expr plan.Expr, row Row) (interface{}, error) {
 	}
 }
 
-func evaluateBinaryOp(left interface{}, op string, right interface{}) (interface{}, error) {
-	switch op {
-	case "=":
-		return left == right, nil
-	case "!=", "<>":
-		return left != right, nil
-	case ">":
-		return compareValues(left, right) > 0, nil
-	case "<":
-		return compareValues(left, right) < 0, nil
-	case ">=":
-		return compareValues(left, right) >= 0, nil
-	case "<=":
-		return compareValues(left, right) <= 0, nil
-	case "AND":
-		leftBool, _ := left.(bool)
-		rightBool, _ := right.(bool)
-		return leftBool && rightBool, nil
-	case "OR":
-		leftBool, _ := left.(bool)
-		rightBool, _ := right.(bool)
-		return leftBool || rightBool, nil
-	default:
-		return nil, fmt.Errorf("unsupoorted operator %s", op)
+// first matching WHEN wins, NULL when nothing matches and there is no ELSE
+func evaluateCase(c *plan.CaseExpr, row Row) (interface{}, error) {
+	var operand interface{}
+	if c.Operand != nil {
+		val, err := evaluateExpr(c.Operand, row)
+		if err != nil {
+			return nil, err
+		}
+		operand = val
+	}
+
+	for _, w := range c.Whens {
+		cond, err := evaluateExpr(w.Condition, row)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		if c.Operand != nil {
+			matched = operand != nil && function.Equal(operand, cond)
+		} else {
+			matched, _ = cond.(bool)
+		}
+
+		if matched {
+			return evaluateExpr(w.Result, row)
+		}
+	}
+
+	if c.Else != nil {
+		return evaluateExpr(c.Else, row)
 	}
+
+	return nil, nil
 }
 
-func compareValues(left, right interface{}) int {
-	switch l := left.(type) {
+// numeric value as float64, json numbers decode as float64 and literals as int
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
 	case int:
-		r, _ := right.(int)
-		if l < r {
-			return -1
-		} else if l > r {
-			return 1
-		}
-		return 0
+		return float64(n), true
+	case int64:
+		return float64(n), true
 	case float64:
-		r, _ := right.(float64)
-		if l < r {
-			return -1
-		} else if l > r {
-			return 1
-		}
-		return 0
-	case string:
-		r, _ := right.(string)
-		if l < r {
-			return -1
-		} else if l > r {
-			return 1
-		}
-		return 0
+		return n, true
 	default:
-		return 0
+		return 0, false
 	}
 }
@@ -0,0 +1,1402 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/optimizer"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+const testOrders = `[
+  {"id": 1, "user_id": 1, "amount": 100, "status": "delivered"},
+  {"id": 2, "user_id": 1, "amount": 50, "status": "shipped"},
+  {"id": 3, "user_id": 2, "amount": 75, "status": "delivered"},
+  {"id": 4, "user_id": 1, "amount": 50, "status": "pending"},
+  {"id": 5, "user_id": 2, "amount": 200, "status": "shipped"}
+]`
+
+const testUsers = `[
+  {"id": 1, "name": "alice"},
+  {"id": 2, "name": "bob"},
+  {"id": 3, "name": "carol"}
+]`
+
+func newTestCatalog(t *testing.T) *catalog.Catalog {
+	t.Helper()
+
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "orders.json")
+	if err := os.WriteFile(dataFile, []byte(testOrders), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	usersFile := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(usersFile, []byte(testUsers), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "orders",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "user_id", Type: catalog.IntType},
+			{Name: "amount", Type: catalog.IntType},
+			{Name: "status", Type: catalog.StringType},
+		},
+		DataFile:   dataFile,
+		PrimaryKey: []string{"id"},
+	})
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "users",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "name", Type: catalog.StringType},
+		},
+		DataFile:   usersFile,
+		PrimaryKey: []string{"id"},
+	})
+
+	return cat
+}
+
+func runQuery(t *testing.T, cat *catalog.Catalog, query string) []Row {
+	t.Helper()
+
+	return runQueryWith(t, cat, plan.NewPlanner(cat), query)
+}
+
+func runQueryWith(t *testing.T, cat *catalog.Catalog, planner *plan.Planner, query string) []Row {
+	t.Helper()
+
+	p := parser.NewParser(query)
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	logicalPlan, err := planner.CreateLogicalPlan(stmt)
+	if err != nil {
+		t.Fatalf("planning failed: %v", err)
+	}
+
+	rows, err := NewExecutor(cat).Execute(logicalPlan)
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+
+	return rows
+}
+
+func TestWindowRanking(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY amount) AS rn, RANK() OVER (PARTITION BY user_id ORDER BY amount) AS rnk, DENSE_RANK() OVER (ORDER BY amount DESC) AS drnk FROM orders`)
+
+	expected := map[float64][3]int{
+		2: {1, 1, 4},
+		4: {2, 1, 4},
+		1: {3, 3, 2},
+		3: {1, 1, 3},
+		5: {2, 2, 1},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows, got %d", len(expected), len(rows))
+	}
+	for _, row := range rows {
+		want := expected[row["id"].(float64)]
+		got := [3]int{row["rn"].(int), row["rnk"].(int), row["drnk"].(int)}
+		if got != want {
+			t.Fatalf("id %v: expected %v, got %v", row["id"], want, got)
+		}
+	}
+}
+
+func TestWindowLagLead(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT id, LAG(amount) OVER (ORDER BY id) AS prev, LEAD(amount, 2, 0) OVER (ORDER BY id) AS next2 FROM orders`)
+
+	expected := map[float64][2]interface{}{
+		1: {nil, 75.0},
+		2: {100.0, 50.0},
+		3: {50.0, 200.0},
+		4: {75.0, 0},
+		5: {50.0, 0},
+	}
+
+	for _, row := range rows {
+		want := expected[row["id"].(float64)]
+		if row["prev"] != want[0] || row["next2"] != want[1] {
+			t.Fatalf("id %v: expected %v, got [%v %v]", row["id"], want, row["prev"], row["next2"])
+		}
+	}
+
+	for _, query := range []string{`SELECT LAG(amount, -1) OVER (ORDER BY id) FROM orders`, `SELECT LEAD(amount, -2, 0) OVER (ORDER BY id) FROM orders`} {
+		if _, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse()); err == nil {
+			t.Errorf("%s: expected an error for a negative offset", query)
+		}
+	}
+}
+
+func TestWindowFrames(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT id, SUM(amount) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) AS moving, SUM(amount) OVER (ORDER BY amount) AS running, AVG(amount) OVER (PARTITION BY user_id) AS avg_amount, FIRST_VALUE(id) OVER (ORDER BY amount RANGE BETWEEN 25 PRECEDING AND CURRENT ROW) AS first, MIN(amount) OVER (ORDER BY id ROWS 1 PRECEDING) AS min2 FROM orders`)
+
+	expected := map[float64][5]interface{}{
+		1: {150, 275, 200.0 / 3, 3.0, 100.0},
+		2: {225, 100, 200.0 / 3, 2.0, 50.0},
+		3: {175, 175, 137.5, 2.0, 50.0},
+		4: {325, 100, 200.0 / 3, 2.0, 50.0},
+		5: {250, 475, 137.5, 5.0, 50.0},
+	}
+
+	for _, row := range rows {
+		want := expected[row["id"].(float64)]
+		got := [5]interface{}{row["moving"], row["running"], row["avg_amount"], row["first"], row["min2"]}
+		if got != want {
+			t.Fatalf("id %v: expected %v, got %v", row["id"], want, got)
+		}
+	}
+}
+
+func TestWindowRangeKeys(t *testing.T) {
+	cat := newTypedCatalog(t)
+	rows := runQuery(t, cat, `SELECT id, COUNT(*) OVER (ORDER BY paid_on RANGE BETWEEN 30 PRECEDING AND CURRENT ROW) AS days, COUNT(*) OVER (ORDER BY price RANGE BETWEEN 15 PRECEDING AND CURRENT ROW) AS cheaper FROM payments`)
+
+	expected := map[float64][2]int{1: {1, 2}, 2: {2, 2}, 3: {2, 1}}
+	for _, row := range rows {
+		want := expected[row["id"].(float64)]
+		if got := [2]int{row["days"].(int), row["cheaper"].(int)}; got != want {
+			t.Fatalf("id %v: expected %v, got %v", row["id"], want, got)
+		}
+	}
+
+	// decimals are summed exactly
+	rows = runQuery(t, cat, `SELECT id, SUM(price) OVER (ORDER BY id) AS running, SUM(price) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS pair, AVG(price) OVER () AS avg_price FROM payments`)
+	sums := map[float64][2]string{1: {"10.10", "10.10"}, 2: {"30.30", "30.30"}, 3: {"30.35", "20.25"}}
+	for _, row := range rows {
+		want := sums[row["id"].(float64)]
+		if got := [2]string{fmt.Sprint(row["running"]), fmt.Sprint(row["pair"])}; got != want {
+			t.Fatalf("id %v: expected sums %v, got %v", row["id"], want, got)
+		}
+		if avg, ok := row["avg_price"].(float64); !ok || math.Abs(avg-30.35/3) > 1e-9 {
+			t.Fatalf("expected a FLOAT average of 30.35 / 3, got %v", row["avg_price"])
+		}
+	}
+
+	// no distance between strings
+	query := `SELECT SUM(amount) OVER (ORDER BY status RANGE BETWEEN 1 PRECEDING AND CURRENT ROW) FROM orders`
+	if _, err := plan.NewPlanner(newTestCatalog(t)).CreateLogicalPlan(parser.NewParser(query).Parse()); err == nil {
+		t.Fatal("expected an error for RANGE over a string key")
+	}
+}
+
+func TestWindowFuncTypes(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	query := `SELECT SUM(amount) OVER () AS s, AVG(amount) OVER () AS a, MAX(status) OVER () AS m, LAG(status) OVER (ORDER BY id) AS l, FIRST_VALUE(amount) OVER (ORDER BY id) AS f, RANK() OVER (ORDER BY id) AS r, COUNT(status) OVER () AS c FROM orders`
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	window, ok := logicalPlan.Children()[0].(*plan.LogicalWindow)
+	if !ok {
+		t.Fatalf("expected a window below the projection:\n%s", plan.FormatPlan(logicalPlan, 0))
+	}
+	expected := map[string]catalog.DataType{"s": catalog.IntType, "a": catalog.FloatType, "m": catalog.StringType, "l": catalog.StringType, "f": catalog.IntType, "r": catalog.IntType, "c": catalog.IntType}
+	for _, col := range window.Schema()[4:] {
+		if col.Type != expected[col.Name] {
+			t.Errorf("%s: expected %s, got %s", col.Name, expected[col.Name], col.Type)
+		}
+	}
+
+	if _, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(`SELECT SUM(status) OVER () FROM orders`).Parse()); err == nil {
+		t.Fatal("expected an error for SUM over a string")
+	}
+}
+
+func TestWindowFuncPlacement(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	for _, query := range []string{
+		`SELECT id FROM orders WHERE ROW_NUMBER() OVER (ORDER BY id) = 1`,
+		`SELECT user_id, COUNT(*) FROM orders GROUP BY user_id HAVING ROW_NUMBER() OVER (ORDER BY user_id) = 1`,
+		`SELECT COUNT(*) FROM orders GROUP BY ROW_NUMBER() OVER (ORDER BY id)`,
+		`SELECT orders.id FROM orders JOIN users ON ROW_NUMBER() OVER (ORDER BY users.id) = orders.user_id`,
+		`SELECT SUM(ROW_NUMBER() OVER (ORDER BY id)) FROM orders`,
+		`SELECT SUM(ROW_NUMBER() OVER (ORDER BY id)) OVER () FROM orders`,
+		`SELECT RANK() OVER (PARTITION BY ROW_NUMBER() OVER (ORDER BY id) ORDER BY id) FROM orders`,
+		`SELECT RANK() OVER (ORDER BY ROW_NUMBER() OVER (ORDER BY id)) FROM orders`,
+		`DELETE FROM orders WHERE ROW_NUMBER() OVER (ORDER BY id) = 1`,
+	} {
+		p := parser.NewParser(query)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: parser has errors: %v", query, p.Errors())
+		}
+		if _, err := plan.NewPlanner(cat).CreateLogicalPlan(stmt); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestScalarFunctions(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT id, UPPER(SUBSTRING(status, 1, 4)) AS code, CONCAT(status, '-', CAST(amount AS STRING)) AS label, CASE user_id WHEN 1 THEN 'one' ELSE 'other' END AS who, NULLIF(amount, 50) AS amt, MOD(ROUND(amount), 7) AS m FROM orders WHERE id = 2`)
+
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+
+	row := rows[0]
+	if row["code"] != "SHIP" {
+		t.Fatalf("expected code SHIP, got %v", row["code"])
+	}
+	if row["label"] != "shipped-50" {
+		t.Fatalf("expected label shipped-50, got %v", row["label"])
+	}
+	if row["who"] != "one" {
+		t.Fatalf("expected who one, got %v", row["who"])
+	}
+	if row["amt"] != nil {
+		t.Fatalf("expected NULLIF to return NULL, got %v", row["amt"])
+	}
+	if row["m"] != 1.0 {
+		t.Fatalf("expected m 1, got %v", row["m"])
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	rows := runQuery(t, cat, `SELECT 9223372036854775806 + 1 AS v FROM orders WHERE id = 1`)
+	if rows[0]["v"] != 9223372036854775807 {
+		t.Fatalf("expected the largest int, got %v", rows[0]["v"])
+	}
+
+	for _, query := range []string{
+		`SELECT 9223372036854775807 + 1 FROM orders`,
+		`SELECT -9223372036854775807 - 2 FROM orders`,
+		`SELECT 4611686018427387904 * 2 FROM orders`,
+	} {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewExecutor(cat).Execute(logicalPlan); err == nil {
+			t.Errorf("%s: expected integer out of range", query)
+		}
+	}
+}
+
+func TestUnknownFunction(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	p := parser.NewParser(`SELECT NOPE(id) FROM orders`)
+	stmt := p.Parse()
+
+	if _, err := plan.NewPlanner(cat).CreateLogicalPlan(stmt); err == nil {
+		t.Fatal("expected error for unknown function")
+	}
+}
+
+func TestGroupByHaving(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT user_id, COUNT(*) AS n, SUM(amount) AS total, MAX(status) FROM orders GROUP BY user_id HAVING SUM(amount) > 200`)
+
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(rows))
+	}
+
+	row := rows[0]
+	if row["user_id"] != 2.0 || row["n"] != 2 || row["total"] != 275.0 || row["MAX(status)"] != "shipped" {
+		t.Fatalf("unexpected group: %v", row)
+	}
+}
+
+func TestAggregateWithoutGroupBy(t *testing.T) {
+	cat := newTestCatalog(t)
+	rows := runQuery(t, cat, `SELECT COUNT(*) AS n, AVG(amount) AS avg_amount, MIN(amount) AS lowest FROM orders WHERE amount > 1000`)
+
+	if len(rows) != 1 || rows[0]["n"] != 0 || rows[0]["avg_amount"] != nil || rows[0]["lowest"] != nil {
+		t.Fatalf("expected one empty aggregate row, got %v", rows)
+	}
+}
+
+func TestUngroupedColumnRejected(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	p := parser.NewParser(`SELECT status, COUNT(*) FROM orders GROUP BY user_id`)
+	stmt := p.Parse()
+
+	if _, err := plan.NewPlanner(cat).CreateLogicalPlan(stmt); err == nil {
+		t.Fatal("expected error for column missing from GROUP BY")
+	}
+}
+
+func TestUserDefinedFunctions(t *testing.T) {
+	cat := newTestCatalog(t)
+	planner := plan.NewPlanner(cat)
+
+	err := planner.RegisterScalarFunction(&function.ScalarFunc{
+		Name:          "to_cents",
+		Signature:     function.Signature{ArgTypes: []catalog.DataType{catalog.IntType}, Result: catalog.IntType},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			// declared INT, whatever the source holds
+			n, ok := args[0].(int)
+			if !ok {
+				return nil, fmt.Errorf("expected an int, got %T", args[0])
+			}
+			return n * 100, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// concatenates statuses, no Merge needed to run it
+	err = planner.RegisterAggregateFunction(&function.AggregateFunc{
+		Name:      "status_list",
+		Signature: function.Signature{ArgTypes: []catalog.DataType{catalog.StringType}, Result: catalog.StringType},
+		Init:      func() interface{} { return "" },
+		Accumulate: func(state interface{}, args []interface{}) (interface{}, error) {
+			if state.(string) == "" {
+				return args[0], nil
+			}
+			return state.(string) + "," + args[0].(string), nil
+		},
+		Finalize: func(state interface{}) (interface{}, error) { return state, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := runQueryWith(t, cat, planner, `SELECT user_id, STATUS_LIST(status) AS statuses, SUM(TO_CENTS(amount)) AS cents FROM orders GROUP BY user_id`)
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(rows))
+	}
+	if rows[0]["statuses"] != "delivered,shipped,pending" || rows[0]["cents"] != 20000.0 {
+		t.Fatalf("unexpected first group: %v", rows[0])
+	}
+
+	// declared argument types are checked at plan time
+	p := parser.NewParser(`SELECT TO_CENTS(status) FROM orders`)
+	if _, err := planner.CreateLogicalPlan(p.Parse()); err == nil {
+		t.Fatal("expected type error for STRING argument")
+	}
+
+	if err := planner.RegisterScalarFunction(&function.ScalarFunc{Name: "upper", Eval: func([]interface{}) (interface{}, error) { return nil, nil }}); err == nil {
+		t.Fatal("expected error when redefining a builtin")
+	}
+}
+
+func TestOuterJoins(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	queries := []string{
+		`SELECT users.name, orders.amount FROM users LEFT JOIN orders ON users.id = orders.user_id`,
+		`SELECT users.name, orders.amount FROM orders RIGHT JOIN users ON users.id = orders.user_id`,
+	}
+	for _, query := range queries {
+		rows := runQuery(t, cat, query)
+		if len(rows) != 6 {
+			t.Fatalf("%s: expected 6 rows, got %d", query, len(rows))
+		}
+
+		padded := 0
+		for _, row := range rows {
+			if row["amount"] == nil {
+				padded++
+				if row["name"] != "carol" {
+					t.Fatalf("%s: unexpected padded row %v", query, row)
+				}
+			}
+		}
+		if padded != 1 {
+			t.Fatalf("%s: expected one padded row, got %d", query, padded)
+		}
+	}
+
+	rows := runQuery(t, cat, `SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id AND orders.amount > 100`)
+	if len(rows) != 3 {
+		t.Fatalf("expected every user once, got %v", rows)
+	}
+}
+
+// joins and aggregates run each way the optimizer can pick give the rows
+// of the plain plan
+func TestPhysicalOperators(t *testing.T) {
+	cat := newTestCatalog(t)
+	col := func(table, name string) plan.Expr { return &plan.ColumnExpr{Table: table, Column: name} }
+
+	physical := func(node plan.LogicalPlan, method plan.JoinMethod, left, right plan.Expr) plan.LogicalPlan {
+		var rewrite func(plan.LogicalPlan) plan.LogicalPlan
+		rewrite = func(node plan.LogicalPlan) plan.LogicalPlan {
+			children := node.Children()
+			for i, child := range children {
+				children[i] = rewrite(child)
+			}
+			node = plan.WithChildren(node, children)
+
+			switch n := node.(type) {
+			case *plan.LogicalJoin:
+				n.Method, n.LeftKeys, n.RightKeys = method, []plan.Expr{left}, []plan.Expr{right}
+				if method == plan.MergeJoin {
+					n.Left = &plan.LogicalSort{Input: n.Left, Keys: n.LeftKeys}
+					n.Right = &plan.LogicalSort{Input: n.Right, Keys: n.RightKeys}
+				}
+			case *plan.LogicalAggregate:
+				n.Sorted = true
+				n.Input = &plan.LogicalSort{Input: n.Input, Keys: n.GroupBy}
+			}
+			return node
+		}
+		return rewrite(node)
+	}
+
+	tests := []struct {
+		query       string
+		left, right plan.Expr
+		outer       bool
+		cols        []string
+	}{
+		{`SELECT users.name, orders.amount FROM users JOIN orders ON users.id = orders.user_id`, col("users", "id"), col("orders", "user_id"), false, []string{"name", "amount"}},
+		{`SELECT users.name, orders.amount FROM orders JOIN users ON users.id = orders.user_id AND orders.amount > 60`, col("orders", "user_id"), col("users", "id"), false, []string{"name", "amount"}},
+		{`SELECT users.name, orders.amount FROM users LEFT JOIN orders ON users.id = orders.user_id AND orders.amount > 100`, col("users", "id"), col("orders", "user_id"), true, []string{"name", "amount"}},
+		{`SELECT users.name, COUNT(*), SUM(orders.amount) FROM users JOIN orders ON users.id = orders.user_id GROUP BY users.name`, col("users", "id"), col("orders", "user_id"), false, []string{"name", "COUNT(*)", "SUM(orders.amount)"}},
+		{`SELECT status, MAX(amount) FROM orders GROUP BY status`, nil, nil, false, []string{"status", "MAX(amount)"}},
+	}
+
+	for _, tt := range tests {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(tt.query).Parse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := runQuery(t, cat, tt.query)
+
+		for _, method := range []plan.JoinMethod{plan.HashJoin, plan.MergeJoin} {
+			if method == plan.MergeJoin && tt.outer {
+				continue // inner joins only
+			}
+			got, err := NewExecutor(cat).Execute(physical(logicalPlan, method, tt.left, tt.right))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.query, err)
+			}
+			if fmt.Sprint(sortedRows(got, tt.cols...)) != fmt.Sprint(sortedRows(want, tt.cols...)) {
+				t.Fatalf("%s with %s: expected %v, got %v", tt.query, method, sortedRows(want, tt.cols...), sortedRows(got, tt.cols...))
+			}
+		}
+	}
+}
+
+func TestLike(t *testing.T) {
+	cat := newTestCatalog(t)
+
+	tests := map[string]int{
+		`SELECT id FROM orders WHERE status LIKE 'd%'`:       2,
+		`SELECT id FROM orders WHERE status LIKE '%pp%'`:     2,
+		`SELECT id FROM orders WHERE status LIKE 'p_nding'`:  1,
+		`SELECT id FROM orders WHERE status NOT LIKE '%ed'`:  1,
+		`SELECT id FROM orders WHERE status LIKE 'shipped_'`: 0,
+		`SELECT id FROM orders WHERE status LIKE '%'`:        5,
+	}
+	for query, expected := range tests {
+		if rows := runQuery(t, cat, query); len(rows) != expected {
+			t.Fatalf("%s: expected %d rows, got %d", query, expected, len(rows))
+		}
+	}
+}
+
+const testPayments = `[
+  {"id": 1, "price": 10.10, "paid_on": "2024-01-31", "paid_at": "2024-01-31 23:30:00"},
+  {"id": 2, "price": 20.20, "paid_on": "2024-02-29", "paid_at": "2024-02-29 08:00:00"},
+  {"id": 3, "price": 0.05, "paid_on": "2024-03-15", "paid_at": "2024-03-15 12:15:00"}
+]`
+
+func newTypedCatalog(t *testing.T) *catalog.Catalog {
+	t.Helper()
+
+	dataFile := filepath.Join(t.TempDir(), "payments.json")
+	if err := os.WriteFile(dataFile, []byte(testPayments), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "payments",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "price", Type: catalog.DecimalType, Precision: 10, Scale: 2},
+			{Name: "paid_on", Type: catalog.DateType},
+			{Name: "paid_at", Type: catalog.TimestampType},
+		},
+		DataFile: dataFile,
+	})
+
+	return cat
+}
+
+func TestTypedColumns(t *testing.T) {
+	cat := newTypedCatalog(t)
+
+	rows := runQuery(t, cat, "SELECT SUM(price) AS total FROM payments")
+	if got := fmt.Sprint(rows[0]["total"]); got != "30.35" {
+		t.Fatalf("expected exact decimal sum 30.35, got %s", got)
+	}
+
+	counts := map[string]int{
+		"SELECT id FROM payments WHERE paid_on >= DATE '2024-02-01'":                                            2,
+		"SELECT id FROM payments WHERE paid_on + 1 = DATE '2024-03-01'":                                         1,
+		"SELECT id FROM payments WHERE paid_at < TIMESTAMP '2024-02-01 00:00:00'":                               1,
+		"SELECT id FROM payments WHERE paid_on + INTERVAL '1 month' = DATE '2024-02-29'":                        1,
+		"SELECT id FROM payments WHERE price > 10.1":                                                            1,
+		"SELECT id FROM payments WHERE price = 0.050":                                                           1,
+		"SELECT id FROM payments WHERE EXTRACT(MONTH FROM paid_on) = 2":                                         1,
+		"SELECT id FROM payments WHERE DATE_TRUNC('month', paid_at) = DATE '2024-03-01'":                        1,
+		"SELECT id FROM payments WHERE paid_at - paid_on > INTERVAL '13 hours'":                                 1,
+		"SELECT id FROM payments WHERE paid_on - DATE '2024-01-01' >= 30 AND paid_on - DATE '2024-01-01' <= 60": 2,
+	}
+	for query, expected := range counts {
+		if rows := runQuery(t, cat, query); len(rows) != expected {
+			t.Fatalf("%s: expected %d rows, got %d", query, expected, len(rows))
+		}
+	}
+
+	// equal decimals group together whatever their scale, as they join
+	rows = runQuery(t, cat, "SELECT CASE WHEN id < 3 THEN 10.1 ELSE 10.10 END AS k, COUNT(*) AS n FROM payments GROUP BY CASE WHEN id < 3 THEN 10.1 ELSE 10.10 END")
+	if len(rows) != 1 || rows[0]["n"] != 3 {
+		t.Fatalf("expected one group of 3, got %v", rows)
+	}
+
+	values := map[string]string{
+		"SELECT price * 3 AS v FROM payments WHERE id = 1":                       "30.30",
+		"SELECT CAST(price / 3 AS DECIMAL(5,2)) AS v FROM payments WHERE id = 2": "6.73",
+		"SELECT ROUND(price, 1) AS v FROM payments WHERE id = 3":                 "0.1",
+		"SELECT paid_on - 31 AS v FROM payments WHERE id = 1":                    "2023-12-31",
+		"SELECT paid_at + INTERVAL '1 day' AS v FROM payments WHERE id = 2":      "2024-03-01 08:00:00",
+	}
+	for query, expected := range values {
+		rows := runQuery(t, cat, query)
+		if got := fmt.Sprint(rows[0]["v"]); got != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, got)
+		}
+	}
+}
+
+func TestDecimalPrecisionOverflow(t *testing.T) {
+	if _, err := function.CastTo(12345.6, catalog.DecimalType, 5, 2); err == nil {
+		t.Fatal("expected 12345.60 not to fit DECIMAL(5,2)")
+	}
+}
+
+const testDocuments = `[
+  {"id": 1, "customer": {"name": "alice", "address": {"city": "Paris"}},
+   "items": [{"sku": "a1", "qty": 2}, {"sku": "b2", "qty": 1}]},
+  {"id": 2, "customer": {"name": "bob", "address": {"city": "Berlin"}},
+   "items": [{"sku": "c3", "qty": 5}]},
+  {"id": 3, "customer": {"name": "carol"}, "items": []}
+]`
+
+func newDocumentCatalog(t *testing.T) *catalog.Catalog {
+	t.Helper()
+
+	dataFile := filepath.Join(t.TempDir(), "documents.json")
+	if err := os.WriteFile(dataFile, []byte(testDocuments), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "docs",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "customer", Type: catalog.JSONType},
+			{Name: "items", Type: catalog.JSONType},
+			{Name: "city", Type: catalog.StringType, Path: "customer.address.city"},
+			{Name: "first_sku", Type: catalog.StringType, Path: "$.items[0].sku"},
+		},
+		DataFile: dataFile,
+	})
+
+	return cat
+}
+
+func TestJSONPaths(t *testing.T) {
+	cat := newDocumentCatalog(t)
+
+	values := map[string]string{
+		"SELECT customer->>'name' AS v FROM docs WHERE id = 2":                        "bob",
+		"SELECT customer->'address'->>'city' AS v FROM docs WHERE id = 1":             "Paris",
+		"SELECT customer->'address' AS v FROM docs WHERE id = 1":                      `{"city":"Paris"}`,
+		"SELECT items->0->>'qty' AS v FROM docs WHERE id = 1":                         "2",
+		"SELECT items->-1->>'sku' AS v FROM docs WHERE id = 1":                        "b2",
+		"SELECT JSON_EXTRACT(customer, '$.address.city') AS v FROM docs WHERE id = 2": "Berlin",
+		"SELECT JSON_ARRAY_LENGTH(items) AS v FROM docs WHERE id = 1":                 "2",
+		"SELECT city AS v FROM docs WHERE id = 2":                                     "Berlin",
+		"SELECT first_sku AS v FROM docs WHERE id = 2":                                "c3",
+	}
+	for query, expected := range values {
+		rows := runQuery(t, cat, query)
+		if len(rows) != 1 {
+			t.Fatalf("%s: expected 1 row, got %d", query, len(rows))
+		}
+		if got := function.FormatValue(rows[0]["v"]); got != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, got)
+		}
+	}
+
+	counts := map[string]int{
+		"SELECT id FROM docs WHERE customer->'address'->>'city' = 'Paris'": 1,
+		"SELECT id FROM docs WHERE city IS NULL":                           1,
+		"SELECT id FROM docs WHERE items->0->'qty' > 1":                    2,
+		"SELECT id FROM docs WHERE customer->'phone' IS NULL":              3,
+	}
+	for query, expected := range counts {
+		if rows := runQuery(t, cat, query); len(rows) != expected {
+			t.Fatalf("%s: expected %d rows, got %d", query, expected, len(rows))
+		}
+	}
+}
+
+func TestUnnest(t *testing.T) {
+	cat := newDocumentCatalog(t)
+
+	rows := runQuery(t, cat, "SELECT d.id, item->>'sku' AS sku FROM docs d CROSS JOIN UNNEST(d.items) AS item")
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	var skus []string
+	for _, row := range rows {
+		skus = append(skus, fmt.Sprint(row["sku"]))
+	}
+	if fmt.Sprint(skus) != "[a1 b2 c3]" {
+		t.Fatalf("unexpected skus %v", skus)
+	}
+
+	counts := map[string]int{
+		"SELECT id FROM docs, UNNEST(items) AS item WHERE item->'qty' >= 2":           2,
+		"SELECT id FROM docs LEFT JOIN UNNEST(items) AS item ON TRUE":                 4,
+		"SELECT id FROM docs LEFT JOIN LATERAL UNNEST(items) item ON item->'qty' > 4": 3,
+		"SELECT id FROM docs JOIN UNNEST(items) AS item ON item->>'sku' = 'b2'":       1,
+		"SELECT SUM(item->'qty') AS total FROM docs CROSS JOIN UNNEST(items) AS item": 1,
+	}
+	for query, expected := range counts {
+		if rows := runQuery(t, cat, query); len(rows) != expected {
+			t.Fatalf("%s: expected %d rows, got %d", query, expected, len(rows))
+		}
+	}
+}
+
+// source that serves fixed rows and remembers what the executor asked for
+type recordingSource struct {
+	rows []storage.Row
+	opts []storage.ScanOptions
+}
+
+func (s *recordingSource) Scan(opts storage.ScanOptions) (storage.RowIterator, error) {
+	s.opts = append(s.opts, opts)
+	return storage.NewSliceIterator(s.rows, opts), nil
+}
+
+func (s *recordingSource) Statistics(sample int) (*catalog.Statistics, error) {
+	return &catalog.Statistics{RowCount: len(s.rows)}, nil
+}
+
+func TestCustomTableSource(t *testing.T) {
+	source := &recordingSource{rows: []storage.Row{
+		{"id": 1, "name": "alice", "city": "Paris"},
+		{"id": 2, "name": "bob", "city": "Berlin"},
+	}}
+	storage.Register("recording", func(*catalog.TableInfo) (storage.TableSource, error) {
+		return source, nil
+	})
+
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "people",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "name", Type: catalog.StringType},
+			{Name: "city", Type: catalog.StringType},
+		},
+		Source: "recording",
+	})
+
+	p := parser.NewParser("SELECT name FROM people WHERE city = 'Berlin'")
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(p.Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := NewExecutor(cat).Execute(optimizer.NewOptimizer(cat).Optimize(logicalPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0]["name"] != "bob" {
+		t.Fatalf("expected bob, got %v", rows)
+	}
+	opts := source.opts[0]
+	if fmt.Sprint(opts.Columns) != "[name city]" || len(opts.Predicates) != 1 || opts.Predicates[0].String() != "city = 'Berlin'" {
+		t.Fatalf("unexpected scan options %+v", opts)
+	}
+}
+
+func TestQueryGoValues(t *testing.T) {
+	type region struct {
+		UserID int    `sql:"user_id,pk"`
+		Region string `sql:",notnull"`
+	}
+
+	cat := newTestCatalog(t)
+	if _, err := storage.RegisterRows(cat, "regions", []region{{1, "eu"}, {2, "us"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	query := "SELECT r.region, SUM(o.amount) AS total FROM orders o JOIN regions r ON o.user_id = r.user_id " +
+		"WHERE r.region = 'us' GROUP BY r.region"
+	p := parser.NewParser(query)
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(p.Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := NewExecutor(cat).Execute(optimizer.NewOptimizer(cat).Optimize(logicalPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0]["region"] != "us" || fmt.Sprint(rows[0]["total"]) != "275" {
+		t.Fatalf("expected us with 275, got %v", rows)
+	}
+}
+
+func TestModifyRows(t *testing.T) {
+	cat := newTestCatalog(t)
+	affected := func(query string) interface{} {
+		t.Helper()
+		rows := runQuery(t, cat, query)
+		if len(rows) != 1 {
+			t.Fatalf("%s: expected one result row, got %v", query, rows)
+		}
+		return rows[0][plan.AffectedRows]
+	}
+
+	if n := affected("INSERT INTO orders VALUES (6, 3, 30, 'pending'), (7, 3, 40, 'shipped')"); n != 2 {
+		t.Fatalf("expected 2 inserted rows, got %v", n)
+	}
+	if n := affected("UPDATE orders o SET amount = o.amount * 2 WHERE o.user_id = 3"); n != 2 {
+		t.Fatalf("expected 2 updated rows, got %v", n)
+	}
+	if n := affected("DELETE FROM orders WHERE status = 'pending'"); n != 2 {
+		t.Fatalf("expected 2 deleted rows, got %v", n)
+	}
+
+	rows := runQuery(t, cat, "SELECT id, amount FROM orders WHERE user_id = 3")
+	if len(rows) != 1 || fmt.Sprint(rows[0]["id"], rows[0]["amount"]) != "7 80" {
+		t.Fatalf("expected order 7 with 80, got %v", rows)
+	}
+
+	if n := affected("INSERT INTO orders (id, user_id, amount) SELECT id + 100, user_id, amount FROM orders WHERE status = 'delivered'"); n != 2 {
+		t.Fatalf("expected 2 copied rows, got %v", n)
+	}
+	rows = runQuery(t, cat, "SELECT id FROM orders WHERE status IS NULL")
+	if len(rows) != 2 {
+		t.Fatalf("expected the copied rows without a status, got %v", rows)
+	}
+}
+
+func TestModifyRowsChecksConstraints(t *testing.T) {
+	cat := newTestCatalog(t)
+	orders, _ := cat.GetTable("orders")
+	orders.Columns[3].NotNull = true
+	orders.ForeignKeys = []catalog.ForeignKey{{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}}
+
+	for _, query := range []string{
+		"INSERT INTO orders VALUES (1, 1, 10, 'new')",
+		"INSERT INTO orders (id, user_id, amount) VALUES (8, 1, 10)",
+		"INSERT INTO orders VALUES (8, 9, 10, 'new')",
+		"UPDATE orders SET id = 1 WHERE id = 2",
+		"DELETE FROM users WHERE id = 1",
+		"INSERT INTO orders VALUES (8, 1, 'ten', 'new')",
+	} {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if _, err := NewExecutor(cat).Execute(logicalPlan); err == nil {
+			t.Errorf("%s: expected a constraint error", query)
+		}
+	}
+
+	rows := runQuery(t, cat, "SELECT COUNT(*) AS n FROM orders")
+	if fmt.Sprint(rows[0]["n"]) != "5" {
+		t.Fatalf("expected rejected changes to leave the table alone, got %v", rows)
+	}
+}
+
+// test catalog saved to and loaded from a file, so definition changes are
+// written back
+func newSavedCatalog(t *testing.T) (*catalog.Catalog, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	if err := newTestCatalog(t).SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	cat := catalog.NewCatalog()
+	if err := cat.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	return cat, path
+}
+
+func TestDefinitionStatements(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+	dataFile := filepath.Join(filepath.Dir(path), "data", "notes.json")
+
+	runQuery(t, cat, "CREATE TABLE notes (id INT PRIMARY KEY, user_id INT REFERENCES users, body TEXT NOT NULL)")
+	if _, err := os.Stat(dataFile); err != nil {
+		t.Fatalf("expected an empty data file: %v", err)
+	}
+
+	runQuery(t, cat, "INSERT INTO notes VALUES (1, 1, 'hello'), (2, 2, '42')")
+	runQuery(t, cat, "ALTER TABLE notes ADD COLUMN score DECIMAL(4,1)")
+	runQuery(t, cat, "UPDATE notes SET score = 1.5 WHERE id = 1")
+	runQuery(t, cat, "ALTER TABLE notes RENAME COLUMN body TO text")
+	runQuery(t, cat, "ALTER TABLE notes ALTER COLUMN score TYPE INT")
+	runQuery(t, cat, "CREATE INDEX idx_notes_user ON notes (user_id)")
+
+	saved := catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatalf("expected the saved catalog to match the data: %v", err)
+	}
+	notes, err := saved.GetTable("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(notes.GetColumnNames()) != "[id user_id text score]" || !notes.HasIndex([]string{"user_id"}) {
+		t.Fatalf("unexpected saved table %+v", notes)
+	}
+
+	query := "SELECT n.text, n.score, u.name FROM notes n JOIN users u ON n.user_id = u.id WHERE n.user_id = 1"
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := NewExecutor(cat).Execute(optimizer.NewOptimizer(cat).Optimize(logicalPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["text"] != "hello" || fmt.Sprint(rows[0]["score"]) != "2" || rows[0]["name"] != "alice" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	runQuery(t, cat, "DROP TABLE notes")
+	if _, err := os.Stat(dataFile); !os.IsNotExist(err) {
+		t.Fatalf("expected the data file to be removed, got %v", err)
+	}
+	saved = catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saved.GetTable("notes"); err == nil {
+		t.Fatal("expected the dropped table to be gone from the saved catalog")
+	}
+	runQuery(t, cat, "DROP TABLE IF EXISTS notes")
+}
+
+func TestAnalyzeStatement(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+
+	runQuery(t, cat, "INSERT INTO orders VALUES (6, 1, 30, 'delivered')")
+	runQuery(t, cat, "ANALYZE orders")
+	if stats := mustTable(t, cat, "orders").Stats(); stats == nil || stats.RowCount != 6 || stats.DistinctCount["user_id"] != 2 {
+		t.Fatalf("expected statistics of the committed rows, got %+v", stats)
+	}
+	if mustTable(t, cat, "users").Stats() != nil {
+		t.Fatal("expected only the named table to be analyzed")
+	}
+
+	saved := catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if stats := mustTable(t, saved, "orders").Stats(); stats == nil || stats.RowCount != 6 {
+		t.Fatalf("expected the statistics to be saved, got %+v", stats)
+	}
+
+	runQuery(t, cat, "ANALYZE SAMPLE 2")
+	if stats := mustTable(t, cat, "users").Stats(); stats == nil || stats.RowCount != 3 {
+		t.Fatalf("expected every table to be analyzed, got %+v", stats)
+	}
+
+	if _, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser("ANALYZE missing").Parse()); err == nil {
+		t.Fatal("expected an unknown table to fail planning")
+	}
+
+	e := NewExecutor(cat)
+	execute := func(query string) error {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = e.Execute(logicalPlan)
+		return err
+	}
+	if err := execute("BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if err := execute("ANALYZE orders"); err == nil {
+		t.Fatal("expected ANALYZE to be refused inside a transaction")
+	}
+}
+
+func TestFailedAlterKeepsTable(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"ALTER TABLE orders ALTER COLUMN status TYPE INT",
+		"ALTER TABLE orders ADD COLUMN due DATE NOT NULL",
+		"ALTER TABLE users DROP COLUMN id",
+		"CREATE TABLE orders (id INT)",
+		"CREATE TABLE extra (id INT) USING parquet",
+		"DROP INDEX missing",
+	} {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err == nil {
+			_, err = NewExecutor(cat).Execute(logicalPlan)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatal("expected failed statements to leave the catalog file alone")
+	}
+	rows := runQuery(t, cat, "SELECT status FROM orders WHERE id = 1")
+	if len(rows) != 1 || rows[0]["status"] != "delivered" {
+		t.Fatalf("expected the orders data to be unchanged, got %v", rows)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	cat := newTestCatalog(t)
+	run := func(e *Executor, query string) ([]Row, error) {
+		t.Helper()
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return e.Execute(logicalPlan)
+	}
+	count := func(e *Executor) string {
+		t.Helper()
+		rows, err := run(e, "SELECT COUNT(*) AS n FROM orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(rows[0]["n"])
+	}
+
+	a, b := NewExecutor(cat), NewExecutor(cat)
+	for _, query := range []string{"BEGIN", "INSERT INTO orders VALUES (6, 3, 30, 'pending')", "DELETE FROM orders WHERE id = 1"} {
+		if _, err := run(a, query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if n := count(a); n != "5" {
+		t.Fatalf("expected the transaction to see its changes, got %s rows", n)
+	}
+	if n := count(b); n != "5" {
+		t.Fatalf("expected other executors not to see uncommitted rows, got %s rows", n)
+	}
+	if rows, _ := run(b, "SELECT id FROM orders WHERE id = 1"); len(rows) != 1 {
+		t.Fatalf("expected the uncommitted delete to be invisible, got %v", rows)
+	}
+
+	// b's update of the row a deleted conflicts
+	if _, err := run(b, "BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(b, "UPDATE orders SET amount = 1 WHERE id = 1"); err == nil {
+		t.Fatal("expected a write-write conflict")
+	}
+	if _, err := run(b, "COMMIT"); err == nil {
+		t.Fatal("expected the conflict to end b's transaction")
+	}
+
+	if _, err := run(a, "ALTER TABLE orders ADD COLUMN note TEXT"); err == nil {
+		t.Fatal("expected definitions not to change inside a transaction")
+	}
+	if _, err := run(a, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(b); n != "5" {
+		t.Fatalf("expected 5 rows after the commit, got %s", n)
+	}
+	if rows, _ := run(b, "SELECT id FROM orders WHERE id = 6"); len(rows) != 1 {
+		t.Fatalf("expected the committed insert, got %v", rows)
+	}
+
+	// rolled back changes are gone, the data file kept the commit
+	for _, query := range []string{"BEGIN", "DELETE FROM orders", "ROLLBACK"} {
+		if _, err := run(a, query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	rows, err := storage.ReadTable(mustTable(t, cat, "orders"))
+	if err != nil || len(rows) != 5 {
+		t.Fatalf("expected 5 rows in the data file, got %v (%v)", rows, err)
+	}
+	if _, err := run(a, "ROLLBACK"); err == nil {
+		t.Fatal("expected ROLLBACK without a transaction to fail")
+	}
+}
+
+func mustTable(t *testing.T, cat *catalog.Catalog, name string) *catalog.TableInfo {
+	t.Helper()
+
+	table, err := cat.GetTable(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestViews(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+
+	runQuery(t, cat, "CREATE VIEW spend (user_id, total) AS SELECT user_id, SUM(amount) FROM orders GROUP BY user_id")
+	rows := runQuery(t, cat, "SELECT u.name, s.total FROM users u JOIN spend s ON u.id = s.user_id WHERE s.total > 250")
+	if len(rows) != 1 || rows[0]["name"] != "bob" || fmt.Sprint(rows[0]["total"]) != "275" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	// views see the rows of the tables they read
+	runQuery(t, cat, "INSERT INTO orders VALUES (6, 3, 10, 'pending')")
+	if rows := runQuery(t, cat, "SELECT * FROM spend"); len(rows) != 3 {
+		t.Fatalf("expected 3 users with orders, got %v", rows)
+	}
+
+	saved := catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := saved.GetTable("spend"); err != nil || !view.IsView() {
+		t.Fatalf("expected the view to be saved, got %+v (%v)", view, err)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO spend VALUES (4, 1)",
+		"DROP TABLE spend",
+		"DROP MATERIALIZED VIEW spend",
+		"REFRESH MATERIALIZED VIEW spend",
+		"CREATE VIEW loop AS SELECT * FROM loop",
+		"CREATE VIEW wide (a, b, c) AS SELECT id FROM users",
+	} {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err == nil {
+			_, err = NewExecutor(cat).Execute(logicalPlan)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+
+	runQuery(t, cat, "DROP VIEW spend")
+	if _, err := cat.GetTable("spend"); err == nil {
+		t.Fatal("expected the view to be dropped")
+	}
+}
+
+func TestMaterializedViews(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+
+	runQuery(t, cat, "CREATE MATERIALIZED VIEW delivered AS SELECT id, amount FROM orders WHERE status = 'delivered'")
+	if rows := runQuery(t, cat, "SELECT * FROM delivered"); len(rows) != 2 {
+		t.Fatalf("expected 2 delivered orders, got %v", rows)
+	}
+
+	// changes of the orders are applied to the stored rows
+	runQuery(t, cat, "UPDATE orders SET status = 'delivered' WHERE id = 2")
+	if rows := runQuery(t, cat, "SELECT * FROM delivered"); len(rows) != 3 {
+		t.Fatalf("expected the view to follow the update, got %v", rows)
+	}
+	rows := runQuery(t, cat, "REFRESH MATERIALIZED VIEW delivered")
+	if fmt.Sprint(rows[0][plan.AffectedRows]) != "3" {
+		t.Fatalf("expected 3 refreshed rows, got %v", rows)
+	}
+	rows = runQuery(t, cat, "SELECT SUM(amount) AS total FROM delivered")
+	if fmt.Sprint(rows[0]["total"]) != "225" {
+		t.Fatalf("unexpected total %v", rows)
+	}
+
+	dataFile := filepath.Join(filepath.Dir(path), "data", "delivered.json")
+	if _, err := os.Stat(dataFile); err != nil {
+		t.Fatalf("expected a data file: %v", err)
+	}
+	runQuery(t, cat, "DROP MATERIALIZED VIEW delivered")
+	if _, err := os.Stat(dataFile); !os.IsNotExist(err) {
+		t.Fatalf("expected the data file to be removed, got %v", err)
+	}
+}
+
+// rows of an optimized query and whether a materialized view answered it
+func runOptimized(t *testing.T, cat *catalog.Catalog, query string) ([]Row, bool) {
+	t.Helper()
+
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	optimized := optimizer.NewOptimizer(cat).Optimize(logicalPlan)
+	rows, err := NewExecutor(cat).Execute(optimized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromView func(node plan.LogicalPlan) bool
+	fromView = func(node plan.LogicalPlan) bool {
+		if scan, ok := node.(*plan.LogicalScan); ok && scan.FromView {
+			return true
+		}
+		for _, child := range node.Children() {
+			if fromView(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return rows, fromView(optimized)
+}
+
+func TestMaterializedViewAnswers(t *testing.T) {
+	cat, path := newSavedCatalog(t)
+	runQuery(t, cat, "CREATE MATERIALIZED VIEW spend AS SELECT user_id, status, SUM(amount) AS total, COUNT(*) AS n FROM orders GROUP BY user_id, status")
+
+	query := "SELECT user_id, SUM(amount) AS total, COUNT(*) AS n FROM orders WHERE status = 'delivered' GROUP BY user_id"
+	rows, used := runOptimized(t, cat, query)
+	if !used {
+		t.Fatal("expected the view to answer the query")
+	}
+	if got := fmt.Sprint(sortedRows(rows, "user_id", "total", "n")); got != "[[1 100 1] [2 75 1]]" {
+		t.Fatalf("unexpected rows %s", got)
+	}
+
+	// inserted orders are added to the view right away
+	runQuery(t, cat, "INSERT INTO orders VALUES (6, 1, 30, 'delivered')")
+	rows, used = runOptimized(t, cat, query)
+	if !used {
+		t.Fatal("expected the maintained view to answer the query")
+	}
+	if got := fmt.Sprint(sortedRows(rows, "user_id", "total", "n")); got != "[[1 130 2] [2 75 1]]" {
+		t.Fatalf("unexpected rows after the insert %s", got)
+	}
+
+	// changing the table's definition makes the view stale until it is
+	// refreshed, a refresh rolled back doesn't count
+	runQuery(t, cat, "ALTER TABLE orders ADD COLUMN note TEXT")
+	if _, used := runOptimized(t, cat, query); used {
+		t.Fatal("expected the stale view to be skipped")
+	}
+	saved := catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !mustTable(t, saved, "spend").Stale {
+		t.Fatal("expected the saved catalog to know the view is stale")
+	}
+
+	e := NewExecutor(cat)
+	for _, query := range []string{"BEGIN", "REFRESH MATERIALIZED VIEW spend", "ROLLBACK"} {
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Execute(logicalPlan); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if !mustTable(t, cat, "spend").Stale {
+		t.Fatal("expected the rolled back refresh to leave the view stale")
+	}
+
+	runQuery(t, cat, "REFRESH MATERIALIZED VIEW spend")
+	rows, used = runOptimized(t, cat, query)
+	if !used {
+		t.Fatal("expected the refreshed view to answer the query")
+	}
+	if got := fmt.Sprint(sortedRows(rows, "user_id", "total", "n")); got != "[[1 130 2] [2 75 1]]" {
+		t.Fatalf("unexpected rows after the refresh %s", got)
+	}
+}
+
+func TestViewMaintenance(t *testing.T) {
+	cat, _ := newSavedCatalog(t)
+	runQuery(t, cat, "CREATE TABLE sales (id INT PRIMARY KEY, user_id INT, amount INT NOT NULL)")
+	runQuery(t, cat, "INSERT INTO sales VALUES (1, 1, 10), (2, 1, 20), (3, 2, 5)")
+
+	views := map[string]string{
+		"per_user":  "SELECT user_id, SUM(amount) AS total, COUNT(*) AS n, MIN(amount) AS low, MAX(amount) AS high FROM orders GROUP BY user_id",
+		"named":     "SELECT u.name, o.amount FROM orders o JOIN users u ON o.user_id = u.id WHERE o.amount > 60",
+		"by_name":   "SELECT u.name, COUNT(*) AS n, AVG(o.amount) AS mean FROM orders o JOIN users u ON o.user_id = u.id GROUP BY u.name",
+		"big":       "SELECT user_id, SUM(amount) AS total FROM orders GROUP BY user_id HAVING SUM(amount) > 100",
+		"overall":   "SELECT COUNT(*) AS n, SUM(amount) AS total FROM orders",
+		"by_status": "SELECT status, COUNT(*) + 1 AS n FROM orders GROUP BY status",
+		"sale_sums": "SELECT user_id, SUM(amount) AS total, COUNT(*) AS n FROM sales GROUP BY user_id",
+	}
+	for name, query := range views {
+		runQuery(t, cat, "CREATE MATERIALIZED VIEW "+name+" AS "+query)
+	}
+	check := func(after string) {
+		t.Helper()
+		for name, query := range views {
+			cols := mustTable(t, cat, name).GetColumnNames()
+			got := fmt.Sprint(sortedRows(runQuery(t, cat, "SELECT * FROM "+name), cols...))
+			want := fmt.Sprint(sortedRows(runQuery(t, cat, query), cols...))
+			if got != want {
+				t.Errorf("after %s: view %s holds %s, its query returns %s", after, name, got, want)
+			}
+			if mustTable(t, cat, name).Stale {
+				t.Errorf("after %s: expected view %s to be maintained", after, name)
+			}
+		}
+	}
+
+	for _, stmt := range []string{
+		"INSERT INTO orders VALUES (6, 1, 30, 'delivered'), (7, 9, 500, 'pending')",
+		"INSERT INTO orders VALUES (8, 2, NULL, 'pending')",
+		"UPDATE orders SET amount = amount + 100 WHERE user_id = 2",
+		"UPDATE orders SET user_id = 3 WHERE id = 1",
+		"DELETE FROM orders WHERE id = 6",
+		"DELETE FROM orders WHERE user_id = 9",
+		"DELETE FROM orders WHERE amount IS NULL",
+		"INSERT INTO sales VALUES (4, 2, 7), (5, 3, 1)",
+		"UPDATE sales SET amount = 12 WHERE id = 2",
+		"DELETE FROM sales WHERE user_id = 1",
+		"DELETE FROM orders",
+	} {
+		runQuery(t, cat, stmt)
+		check(stmt)
+	}
+
+	// the view changes belong to the statement's transaction
+	e := NewExecutor(cat)
+	run := func(query string) []Row {
+		t.Helper()
+		logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := e.Execute(logicalPlan)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return rows
+	}
+	run("BEGIN")
+	run("INSERT INTO sales VALUES (6, 2, 100)")
+	if rows := run("SELECT total FROM sale_sums WHERE user_id = 2"); len(rows) != 1 || fmt.Sprint(rows[0]["total"]) != "112" {
+		t.Fatalf("expected the transaction to see its change, got %v", rows)
+	}
+	if rows := runQuery(t, cat, "SELECT total FROM sale_sums WHERE user_id = 2"); len(rows) != 1 || fmt.Sprint(rows[0]["total"]) != "12" {
+		t.Fatalf("expected other transactions not to, got %v", rows)
+	}
+	run("ROLLBACK")
+	check("ROLLBACK")
+
+	// views the change can't be applied to go stale
+	runQuery(t, cat, "CREATE MATERIALIZED VIEW everyone AS SELECT u.name, s.amount FROM users u LEFT JOIN sales s ON s.user_id = u.id")
+	runQuery(t, cat, "INSERT INTO sales VALUES (7, 1, 3)")
+	if !mustTable(t, cat, "everyone").Stale || mustTable(t, cat, "sale_sums").Stale {
+		t.Fatal("expected only the outer join view to be stale")
+	}
+}
+
+func TestUserDefinedFunctionsInViews(t *testing.T) {
+	cat, _ := newSavedCatalog(t)
+
+	// registered through one planner, every planner, optimizer and view on the catalog sees it
+	err := plan.NewPlanner(cat).RegisterScalarFunction(&function.ScalarFunc{
+		Name:          "to_cents",
+		Signature:     function.Signature{ArgTypes: []catalog.DataType{catalog.IntType}, Result: catalog.IntType},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0].(int) * 100, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := "SELECT user_id, SUM(TO_CENTS(amount)) AS cents FROM orders GROUP BY user_id"
+	runQuery(t, cat, "CREATE MATERIALIZED VIEW cents AS "+query)
+	if _, used := runOptimized(t, cat, query); !used {
+		t.Fatal("expected the query to be answered from the view")
+	}
+
+	runQuery(t, cat, "INSERT INTO orders VALUES (6, 1, 30, 'delivered')")
+	if mustTable(t, cat, "cents").Stale {
+		t.Fatal("expected the view to be maintained")
+	}
+	got := fmt.Sprint(sortedRows(runQuery(t, cat, "SELECT * FROM cents"), "user_id", "cents"))
+	want := fmt.Sprint(sortedRows(runQuery(t, cat, query), "user_id", "cents"))
+	if got != want {
+		t.Fatalf("view holds %s, its query returns %s", got, want)
+	}
+
+	// aggregates without Merge are recomputed for the changed groups
+	err = plan.NewPlanner(cat).RegisterAggregateFunction(&function.AggregateFunc{
+		Name:      "longest",
+		Signature: function.Signature{ArgTypes: []catalog.DataType{catalog.StringType}, Result: catalog.StringType},
+		Init:      func() interface{} { return "" },
+		Accumulate: func(state interface{}, args []interface{}) (interface{}, error) {
+			if len(args[0].(string)) > len(state.(string)) {
+				return args[0], nil
+			}
+			return state, nil
+		},
+		Finalize: func(state interface{}) (interface{}, error) { return state, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runQuery(t, cat, "CREATE MATERIALIZED VIEW longest AS SELECT user_id, LONGEST(status) AS status FROM orders GROUP BY user_id")
+	runQuery(t, cat, "INSERT INTO orders VALUES (7, 2, 10, 'returned to sender')")
+	rows := runQuery(t, cat, "SELECT status FROM longest WHERE user_id = 2")
+	if mustTable(t, cat, "longest").Stale || len(rows) != 1 || rows[0]["status"] != "returned to sender" {
+		t.Fatalf("expected the view to be maintained, got %v", rows)
+	}
+}
+
+// values of cols in each row, rows sorted by their printed form
+func sortedRows(rows []Row, cols ...string) [][]interface{} {
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		for _, col := range cols {
+			out[i] = append(out[i], row[col])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return fmt.Sprint(out[i]) < fmt.Sprint(out[j]) })
+
+	return out
+}
@@ -0,0 +1,641 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// how a materialized view follows the changes of one table it reads. The
+// view's query has to be a projection over inner joins, filters and at most
+// one aggregation, which reads the table once
+type maintenance struct {
+	view   *catalog.TableInfo
+	query  *plan.LogicalProject
+	agg    *plan.LogicalAggregate // nil for views without aggregates
+	having bool
+	block  plan.LogicalPlan  // the joins and filters below the aggregation
+	scan   *plan.LogicalScan // the changed table in block
+	scans  []*plan.LogicalScan
+	groups []int // view column holding each group key
+	stored []int // view column holding each aggregate, -1 when none does
+	count  int   // aggregate counting the rows of a group, -1 without one
+}
+
+// maintenance of view for changes of table, nil when the view can only be
+// refreshed. Stale views stay stale, unless the transaction refreshed them
+func (e *Executor) maintenanceOf(view, table *catalog.TableInfo) *maintenance {
+	if view.Stale && !slices.Contains(e.refreshed, view) {
+		return nil
+	}
+
+	parse := parser.NewParser(view.View)
+	stmt, ok := parse.Parse().(*parser.SelectStatement)
+	if len(parse.Errors()) > 0 || !ok {
+		return nil
+	}
+	node, err := plan.NewPlanner(e.catalog).CreateLogicalPlan(stmt)
+	if err != nil {
+		return nil
+	}
+	proj, ok := node.(*plan.LogicalProject)
+	if !ok || len(proj.Projections) != len(view.Columns) {
+		return nil
+	}
+
+	m := &maintenance{view: view, query: proj, block: proj.Input, count: -1}
+	if having, ok := m.block.(*plan.LogicalFilter); ok {
+		if _, ok := having.Input.(*plan.LogicalAggregate); ok {
+			m.having = true
+			m.block = having.Input
+		}
+	}
+	if agg, ok := m.block.(*plan.LogicalAggregate); ok {
+		m.agg = agg
+		m.block = agg.Input
+	}
+
+	if m.scans, ok = joinScans(m.block); !ok {
+		return nil
+	}
+	for _, scan := range m.scans {
+		if scan.Table.Name != table.Name {
+			continue
+		}
+		if m.scan != nil {
+			return nil // self joins would need the delta joined with itself
+		}
+		m.scan = scan
+	}
+	if m.scan == nil {
+		return nil
+	}
+	if m.agg == nil {
+		return m
+	}
+
+	// stored rows are found by their group keys, so the view has to hold them
+	for _, name := range m.agg.GroupNames {
+		i := projected(proj, name)
+		if i < 0 {
+			return nil
+		}
+		m.groups = append(m.groups, i)
+	}
+	for i, a := range m.agg.Aggregates {
+		m.stored = append(m.stored, projected(proj, m.agg.AggregateNames[i]))
+		if m.count < 0 && m.stored[i] >= 0 && countsRows(a) {
+			m.count = i
+		}
+	}
+
+	return m
+}
+
+// scans of a plan made of inner joins and filters, false for anything else
+func joinScans(node plan.LogicalPlan) ([]*plan.LogicalScan, bool) {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return []*plan.LogicalScan{n}, true
+	case *plan.LogicalFilter:
+		return joinScans(n.Input)
+	case *plan.LogicalJoin:
+		if n.JoinType != plan.InnerJoin {
+			return nil, false
+		}
+		left, ok := joinScans(n.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := joinScans(n.Right)
+		return append(left, right...), ok
+	}
+
+	return nil, false
+}
+
+// position of the projection that is just the named column, -1 without one
+func projected(proj *plan.LogicalProject, name string) int {
+	for i, expr := range proj.Projections {
+		if col, ok := expr.(*plan.ColumnExpr); ok && col.Table == "" && col.Column == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// COUNT(*)
+func countsRows(a *plan.AggregateExpr) bool {
+	if a.Name != "COUNT" || len(a.Args) != 1 {
+		return false
+	}
+	_, ok := a.Args[0].(*plan.StarExpr)
+	return ok
+}
+
+// applies a change of table to the materialized views reading it, in the
+// running transaction. Views that can't follow it are marked stale, changed
+// views pass their own change on
+func (e *Executor) maintain(table *catalog.TableInfo, deleted, inserted []storage.Row) error {
+	if len(deleted) == 0 && len(inserted) == 0 {
+		return nil
+	}
+
+	for _, view := range e.catalog.ReadersOf(table.Name) {
+		m := e.maintenanceOf(view, table)
+		if m == nil {
+			if err := e.stale(view); err != nil {
+				return err
+			}
+			continue
+		}
+
+		removed, added, err := e.applyDelta(m, deleted, inserted)
+		if errors.Is(err, errOutOfSync) {
+			if err := e.stale(view); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("view %s: %w", view.Name, err)
+		}
+		if err := e.maintain(view, removed, added); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changes the stored rows of a view by what the query returns for the
+// deleted and inserted rows, returning the view rows it removed and added
+func (e *Executor) applyDelta(m *maintenance, deleted, inserted []storage.Row) ([]storage.Row, []storage.Row, error) {
+	source, err := e.writable(m.view)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := readRows(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes map[int]storage.Row
+	var fresh []storage.Row
+	if m.agg == nil {
+		changes, fresh, err = e.joinDelta(m, rows, deleted, inserted)
+	} else {
+		changes, fresh, err = e.aggregateDelta(m, rows, deleted, inserted)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var removed, added []storage.Row
+	out := make([]storage.Row, 0, len(rows))
+	for i, row := range rows {
+		changed, ok := changes[i]
+		if ok {
+			removed = append(removed, row)
+			row = changed
+		}
+		if row != nil {
+			out = append(out, row)
+		}
+		if ok && changed != nil {
+			added = append(added, changed)
+		}
+	}
+	added = append(added, fresh...)
+
+	if len(changes) > 0 {
+		if changer, ok := source.(storage.RowChanger); ok {
+			err = changer.Change(changes)
+		} else {
+			err = source.Replace(out)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(fresh) > 0 {
+		if err := source.Insert(fresh); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return removed, added, nil
+}
+
+// returned when a view's rows don't hold what a change removes from it, a
+// refresh has to bring it back in line
+var errOutOfSync = errors.New("view rows don't match its query")
+
+// rows of node with the changed table's scan reading rows in its place
+func (e *Executor) deltaRows(m *maintenance, node plan.LogicalPlan, rows []storage.Row) ([]Row, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if e.deltas == nil {
+		e.deltas = make(map[*plan.LogicalScan][]storage.Row)
+	}
+	e.deltas[m.scan] = rows
+	defer delete(e.deltas, m.scan)
+
+	return e.run(node)
+}
+
+// view rows without aggregates come and go with the joined rows they are
+// made of. Each removed row takes away one stored row equal to it
+func (e *Executor) joinDelta(m *maintenance, rows, deleted, inserted []storage.Row) (map[int]storage.Row, []storage.Row, error) {
+	from := m.query.Schema()
+	viewRows := func(changed []storage.Row) ([]storage.Row, error) {
+		in, err := e.deltaRows(m, m.query, changed)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]storage.Row, len(in))
+		for i, row := range in {
+			out[i] = make(storage.Row, len(m.view.Columns))
+			for j, col := range m.view.Columns {
+				out[i][col.Name] = row[from[j].Name]
+			}
+		}
+		return out, nil
+	}
+	gone, err := viewRows(deleted)
+	if err != nil {
+		return nil, nil, err
+	}
+	fresh, err := viewRows(inserted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := func(row storage.Row) string {
+		values := make([]interface{}, len(m.view.Columns))
+		for i, col := range m.view.Columns {
+			values[i] = row[col.Name]
+		}
+		return valuesKey(values)
+	}
+	stored := make(map[string][]int)
+	for i, row := range rows {
+		k := key(row)
+		stored[k] = append(stored[k], i)
+	}
+
+	changes := make(map[int]storage.Row)
+	for _, row := range gone {
+		k := key(row)
+		if len(stored[k]) == 0 {
+			return nil, nil, errOutOfSync
+		}
+		changes[stored[k][0]] = nil
+		stored[k] = stored[k][1:]
+	}
+
+	return changes, fresh, nil
+}
+
+// counts the rows behind each group of a delta
+func (e *Executor) countStar() *plan.AggregateExpr {
+	count, _ := function.RegistryOf(e.catalog).LookupAggregate("COUNT")
+	return &plan.AggregateExpr{Name: "COUNT", Args: []plan.Expr{&plan.StarExpr{}}, Func: count, Type: catalog.IntType}
+}
+
+// name of the row count among a delta's partial results
+const countName = "COUNT(*)"
+
+// the view's aggregates over the joined rows of a delta, with the number
+// of rows each group got from it
+func (e *Executor) partials(m *maintenance, changed []storage.Row) ([]Row, error) {
+	agg := &plan.LogicalAggregate{
+		Input:          m.block,
+		GroupBy:        m.agg.GroupBy,
+		GroupNames:     m.agg.GroupNames,
+		Aggregates:     append(slices.Clone(m.agg.Aggregates), e.countStar()),
+		AggregateNames: append(slices.Clone(m.agg.AggregateNames), countName),
+	}
+	rows, err := e.deltaRows(m, agg, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	// without GROUP BY no rows still give one group
+	return slices.DeleteFunc(rows, func(row Row) bool { return row[countName] == 0 }), nil
+}
+
+// aggregate view rows are changed group by group. The partial aggregates of
+// the deleted and inserted rows are merged into the stored ones where that
+// is exact, the other groups are computed again from the tables
+func (e *Executor) aggregateDelta(m *maintenance, rows, deleted, inserted []storage.Row) (map[int]storage.Row, []storage.Row, error) {
+	minus, err := e.partials(m, deleted)
+	if err != nil {
+		return nil, nil, err
+	}
+	plus, err := e.partials(m, inserted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := make(map[string]int)
+	for i, row := range rows {
+		stored[m.storedKey(row)] = i
+	}
+
+	var order []string
+	keys := make(map[string][]interface{})
+	removes := make(map[string]Row)
+	adds := make(map[string]Row)
+	for _, delta := range []struct {
+		rows []Row
+		to   map[string]Row
+	}{{minus, removes}, {plus, adds}} {
+		for _, row := range delta.rows {
+			values := make([]interface{}, len(m.agg.GroupNames))
+			for i, name := range m.agg.GroupNames {
+				values[i] = row[name]
+			}
+			k := valuesKey(values)
+			if _, ok := keys[k]; !ok {
+				keys[k] = values
+				order = append(order, k)
+			}
+			delta.to[k] = row
+		}
+	}
+
+	changes := make(map[int]storage.Row)
+	var fresh []storage.Row
+	var again [][]interface{}
+	for _, k := range order {
+		i, found := stored[k]
+		var old storage.Row
+		if found {
+			old = rows[i]
+		}
+
+		merged, gone, ok := m.merge(old, adds[k], removes[k])
+		switch {
+		case !ok:
+			again = append(again, keys[k])
+		case gone:
+			changes[i] = nil
+		default:
+			row, err := m.viewRow(merged)
+			if err != nil {
+				return nil, nil, err
+			}
+			if found {
+				changes[i] = row
+			} else {
+				fresh = append(fresh, row)
+			}
+		}
+	}
+	if len(again) == 0 {
+		return changes, fresh, nil
+	}
+
+	recomputed, err := e.regroup(m, again)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, values := range again {
+		i, found := stored[valuesKey(values)]
+		row, ok := recomputed[valuesKey(values)]
+		switch {
+		case ok && found:
+			changes[i] = row
+		case ok:
+			fresh = append(fresh, row)
+		case found:
+			changes[i] = nil
+		}
+	}
+
+	return changes, fresh, nil
+}
+
+// hashable group keys of a stored view row
+func (m *maintenance) storedKey(row storage.Row) string {
+	values := make([]interface{}, len(m.groups))
+	for i, col := range m.groups {
+		values[i] = row[m.view.Columns[col].Name]
+	}
+
+	return valuesKey(values)
+}
+
+// aggregate output of a group after the change, from its stored view row
+// and the partial aggregates of the rows inserted into and deleted from it.
+// gone when the group lost all its rows, false when only computing the group
+// again tells
+func (m *maintenance) merge(old storage.Row, plus, minus Row) (Row, bool, bool) {
+	if m.having {
+		return nil, false, false // groups HAVING dropped aren't stored
+	}
+
+	out := make(Row, len(m.agg.GroupNames)+len(m.agg.AggregateNames))
+	from := plus
+	if from == nil {
+		from = minus
+	}
+	for _, name := range m.agg.GroupNames {
+		out[name] = from[name]
+	}
+
+	if old == nil {
+		if minus != nil {
+			return nil, false, false
+		}
+		for _, name := range m.agg.AggregateNames {
+			out[name] = plus[name]
+		}
+		return out, false, true
+	}
+
+	// whether a group still has rows is only known from its count
+	if minus != nil {
+		if m.count < 0 {
+			return nil, false, false
+		}
+		n, ok := adjust(old[m.view.Columns[m.stored[m.count]].Name], countOf(plus), countOf(minus))
+		if !ok {
+			return nil, false, false
+		}
+		if function.Compare(n, 0) <= 0 {
+			if len(m.agg.GroupBy) == 0 {
+				return nil, false, false // the one group stays, with its NULL sums
+			}
+			return nil, true, true
+		}
+	}
+
+	for i, a := range m.agg.Aggregates {
+		if m.stored[i] < 0 {
+			return nil, false, false
+		}
+		name := m.agg.AggregateNames[i]
+		var added, taken interface{}
+		if plus != nil {
+			added = plus[name]
+		}
+		if minus != nil {
+			taken = minus[name]
+		}
+
+		val, ok := m.combine(a, old[m.view.Columns[m.stored[i]].Name], added, taken)
+		if !ok {
+			return nil, false, false
+		}
+		out[name] = val
+	}
+
+	return out, false, true
+}
+
+func countOf(partial Row) interface{} {
+	if partial == nil {
+		return nil
+	}
+	return partial[countName]
+}
+
+// value of an aggregate after adding one partial result and taking another
+// away. COUNT and SUM add up, MIN and MAX only while the extreme stays
+func (m *maintenance) combine(a *plan.AggregateExpr, prev, added, taken interface{}) (interface{}, bool) {
+	switch a.Name {
+	case "COUNT":
+		return adjust(prev, added, taken)
+	case "SUM":
+		// a sum only turns NULL again when no value is left, which counts
+		// of the argument would tell
+		if taken != nil && !m.notNull(a.Args[0]) {
+			return nil, false
+		}
+		return adjust(prev, added, taken)
+	case "MIN", "MAX":
+		if taken != nil && prev != nil {
+			c := function.Compare(taken, prev)
+			if (a.Name == "MIN" && c <= 0) || (a.Name == "MAX" && c >= 0) {
+				return nil, false
+			}
+		}
+		val, err := a.Func.Merge(prev, added)
+		return val, err == nil
+	}
+
+	return nil, false
+}
+
+// prev plus added minus taken, NULLs add nothing
+func adjust(prev, added, taken interface{}) (interface{}, bool) {
+	val := prev
+	var err error
+	if added != nil {
+		if val == nil {
+			val = added
+		} else if val, err = function.EvalBinary(val, "+", added); err != nil {
+			return nil, false
+		}
+	}
+	if taken != nil {
+		if val == nil {
+			return nil, false
+		}
+		if val, err = function.EvalBinary(val, "-", taken); err != nil {
+			return nil, false
+		}
+	}
+
+	return val, true
+}
+
+// whether expr is a column that can't hold NULL
+func (m *maintenance) notNull(expr plan.Expr) bool {
+	col, ok := expr.(*plan.ColumnExpr)
+	if !ok {
+		return false
+	}
+
+	for _, scan := range m.scans {
+		if col.Table != "" && col.Table != scan.QualifiedName() {
+			continue
+		}
+		if c, err := scan.Table.GetColumn(col.Column); err == nil {
+			return c.NotNull
+		}
+	}
+	return false
+}
+
+// view row of a group's aggregate output
+func (m *maintenance) viewRow(agg Row) (storage.Row, error) {
+	row := make(storage.Row, len(m.view.Columns))
+	for i, expr := range m.query.Projections {
+		val, err := evaluateExpr(expr, agg)
+		if err != nil {
+			return nil, err
+		}
+		row[m.view.Columns[i].Name] = val
+	}
+
+	return row, nil
+}
+
+// view rows of the groups with the given keys, computed from the tables and
+// hashed by their keys
+func (e *Executor) regroup(m *maintenance, keys [][]interface{}) (map[string]storage.Row, error) {
+	agg := *m.agg
+	if len(agg.GroupBy) > 0 {
+		schema := agg.Input.Schema()
+		var any plan.Expr
+		for _, values := range keys {
+			var all plan.Expr
+			for i, g := range agg.GroupBy {
+				var cond plan.Expr = &plan.IsNullExpr{Expr: g}
+				if values[i] != nil {
+					cond = &plan.BinaryExpr{Left: g, Operator: "=", Right: &plan.LiteralExpr{Value: values[i], Type: plan.ExprType(g, schema)}}
+				}
+				all = conjoin(all, "AND", cond)
+			}
+			any = conjoin(any, "OR", all)
+		}
+		agg.Input = &plan.LogicalFilter{Input: agg.Input, Predicate: any}
+	}
+
+	var node plan.LogicalPlan = &agg
+	if m.having {
+		having := *m.query.Input.(*plan.LogicalFilter)
+		having.Input = node
+		node = &having
+	}
+	query := *m.query
+	query.Input = node
+
+	rows, err := e.viewRows(m.view, &query)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]storage.Row, len(rows))
+	for _, row := range rows {
+		out[m.storedKey(row)] = row
+	}
+	return out, nil
+}
+
+func conjoin(left plan.Expr, op string, right plan.Expr) plan.Expr {
+	if left == nil {
+		return right
+	}
+	return &plan.BinaryExpr{Left: left, Operator: op, Right: right}
+}
@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// rows of VALUES, each expression evaluated on its own
+func (e *Executor) executeValues(values *plan.LogicalValues) (Iterator, error) {
+	rows := make([]Row, len(values.Rows))
+	for i, exprs := range values.Rows {
+		rows[i] = make(Row, len(exprs))
+		for j, expr := range exprs {
+			val, err := evaluateExpr(expr, Row{})
+			if err != nil {
+				return nil, fmt.Errorf("VALUES row %d: %w", i+1, err)
+			}
+			rows[i][values.Columns[j].Name] = val
+		}
+	}
+
+	return &scanIterator{rows: rows}, nil
+}
+
+// table a statement writes to, with its source
+func (e *Executor) writable(table *catalog.TableInfo) (storage.WritableSource, error) {
+	source, err := e.source(table)
+	if err != nil {
+		return nil, err
+	}
+
+	writable, ok := source.(storage.WritableSource)
+	if !ok {
+		return nil, fmt.Errorf("table %s can't be written, its %s source is read only", table.Name, table.SourceKind())
+	}
+	return writable, nil
+}
+
+func affected(n int) Iterator {
+	return &scanIterator{rows: []Row{{plan.AffectedRows: n}}}
+}
+
+func (e *Executor) executeInsert(insert *plan.LogicalInsert) (Iterator, error) {
+	table := insert.Table
+	source, err := e.writable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := e.executeNode(insert.Input)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	names := make([]string, 0, len(insert.Columns))
+	for _, col := range insert.Input.Schema() {
+		names = append(names, col.Name)
+	}
+
+	var rows []storage.Row
+	for {
+		in, ok := input.Next()
+		if !ok {
+			break
+		}
+
+		row := make(storage.Row, len(table.Columns))
+		for _, col := range table.Columns {
+			row[col.Name] = nil
+		}
+		for i, name := range insert.Columns {
+			if row[name], err = castColumn(table, name, in[names[i]]); err != nil {
+				return nil, fmt.Errorf("row %d: %w", len(rows)+1, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	// keys are checked against what the table already holds
+	all := rows
+	if len(table.PrimaryKey) > 0 || len(table.Unique) > 0 {
+		existing, err := readRows(source)
+		if err != nil {
+			return nil, err
+		}
+		all = append(existing, rows...)
+	}
+	if err := e.catalog.CheckRowsWith(table, all, e.readTable); err != nil {
+		return nil, fmt.Errorf("table %s: %w", table.Name, err)
+	}
+
+	if err := source.Insert(rows); err != nil {
+		return nil, err
+	}
+	if err := e.maintain(table, nil, rows); err != nil {
+		return nil, err
+	}
+	return affected(len(rows)), nil
+}
+
+func (e *Executor) executeUpdate(update *plan.LogicalUpdate) (Iterator, error) {
+	count := 0
+	err := e.rewrite(update.Table, update.Alias, update.Predicate, func(row, qualified Row) (storage.Row, error) {
+		count++
+		changed := make(storage.Row, len(row))
+		for k, v := range row {
+			changed[k] = v
+		}
+
+		for i, col := range update.Columns {
+			val, err := evaluateExpr(update.Values[i], qualified)
+			if err != nil {
+				return nil, err
+			}
+			if changed[col], err = castColumn(update.Table, col, val); err != nil {
+				return nil, err
+			}
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return affected(count), nil
+}
+
+func (e *Executor) executeDelete(del *plan.LogicalDelete) (Iterator, error) {
+	count := 0
+	err := e.rewrite(del.Table, del.Alias, del.Predicate, func(Row, Row) (storage.Row, error) {
+		count++
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return affected(count), nil
+}
+
+// replaces the rows of table matching predicate with what change returns
+// for them, nil drops the row. change sees the row and a copy with column
+// names qualified like a scan's
+func (e *Executor) rewrite(table *catalog.TableInfo, alias string, predicate plan.Expr, change func(row, qualified Row) (storage.Row, error)) error {
+	source, err := e.writable(table)
+	if err != nil {
+		return err
+	}
+	rows, err := readRows(source)
+	if err != nil {
+		return err
+	}
+
+	qualifier := table.Name
+	if alias != "" {
+		qualifier = alias
+	}
+
+	out := make([]storage.Row, 0, len(rows))
+	changes := make(map[int]storage.Row)
+	var deleted, inserted []storage.Row
+	for i, row := range rows {
+		qualified := make(Row, 2*len(row))
+		for k, v := range row {
+			qualified[k] = v
+		}
+		for _, col := range table.Columns {
+			qualified[qualifier+"."+col.Name] = row[col.Name]
+		}
+
+		if predicate != nil {
+			ans, err := evaluateExpr(predicate, qualified)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", i+1, err)
+			}
+			if ans != true {
+				out = append(out, row)
+				continue
+			}
+		}
+
+		changed, err := change(Row(row), qualified)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		changes[i] = changed
+		deleted = append(deleted, row)
+		if changed != nil {
+			out = append(out, changed)
+			inserted = append(inserted, changed)
+		}
+	}
+
+	if err := e.catalog.CheckRowsWith(table, out, e.readTable); err != nil {
+		return fmt.Errorf("table %s: %w", table.Name, err)
+	}
+	if changer, ok := source.(storage.RowChanger); ok {
+		err = changer.Change(changes)
+	} else {
+		err = source.Replace(out)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.maintain(table, deleted, inserted)
+}
+
+// rows of a table as the running statement sees them
+func (e *Executor) readTable(table *catalog.TableInfo) ([]map[string]interface{}, error) {
+	source, err := e.source(table)
+	if err != nil {
+		return nil, err
+	}
+
+	return readRows(source)
+}
+
+func readRows(source storage.TableSource) ([]storage.Row, error) {
+	it, err := source.Scan(storage.ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.ReadAll(it)
+}
+
+// value converted to the type of a table column
+func castColumn(table *catalog.TableInfo, name string, v interface{}) (interface{}, error) {
+	col, err := table.GetColumn(name)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := function.CastTo(v, col.Type, col.Precision, col.Scale)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", name, err)
+	}
+	return val, nil
+}
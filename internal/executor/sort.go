@@ -0,0 +1,163 @@
+package executor
+
+import (
+	"sort"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// keyed rows, sorted by their keys
+type keyedRows struct {
+	rows []Row
+	keys [][]interface{}
+}
+
+// reads every row of input and sorts it by keys, NULLs last like window
+// ordering
+func (e *Executor) sortRows(input Iterator, keys []plan.Expr) (*keyedRows, error) {
+	s, err := e.keyed(input, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, len(s.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareKeys(s.keys[order[a]], s.keys[order[b]], nil) < 0
+	})
+
+	sorted := &keyedRows{rows: make([]Row, len(order)), keys: make([][]interface{}, len(order))}
+	for i, idx := range order {
+		sorted.rows[i], sorted.keys[i] = s.rows[idx], s.keys[idx]
+	}
+	return sorted, nil
+}
+
+func (e *Executor) executeSort(s *plan.LogicalSort) (Iterator, error) {
+	input, err := e.executeNode(s.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted, err := e.sortRows(input, s.Keys)
+	if err != nil {
+		return nil, err
+	}
+	return &scanIterator{rows: sorted.rows}, nil
+}
+
+// inner join of inputs sorted by their keys. Each left row is matched
+// against the run of right rows with the same keys, which the next left
+// row reuses when its keys are the same too
+type mergeIterator struct {
+	left, right *keyedRows
+	condition   plan.Expr
+
+	li      int // current left row
+	started bool
+	start   int // run of right rows equal to the left row's keys
+	end     int
+	pos     int
+}
+
+func (m *mergeIterator) Next() (Row, bool) {
+	for m.li < len(m.left.rows) {
+		if m.started && m.pos < m.end {
+			combined := combineRows(m.left.rows[m.li], m.right.rows[m.pos], nil)
+			m.pos++
+
+			ans, err := evaluateExpr(m.condition, combined)
+			if err == nil && ans == true {
+				return combined, true
+			}
+			continue
+		}
+
+		if m.started {
+			m.li++
+		}
+		m.started = true
+		if m.li >= len(m.left.rows) {
+			break
+		}
+
+		keys := m.left.keys[m.li]
+		if hasNull(keys) {
+			m.pos = m.end
+			continue
+		}
+		if m.end > m.start && compareKeys(keys, m.right.keys[m.start], nil) == 0 {
+			m.pos = m.start
+			continue
+		}
+
+		m.start = m.end
+		for m.start < len(m.right.rows) && compareKeys(m.right.keys[m.start], keys, nil) < 0 {
+			m.start++
+		}
+		m.end = m.start
+		for m.end < len(m.right.rows) && compareKeys(m.right.keys[m.end], keys, nil) == 0 {
+			m.end++
+		}
+		m.pos = m.start
+	}
+
+	return nil, false
+}
+
+func (m *mergeIterator) Close() {}
+
+func hasNull(values []interface{}) bool {
+	for _, v := range values {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// both inputs arrive sorted by their keys, the optimizer sorts them when
+// they don't. Their keys are still evaluated here to step through them
+func (e *Executor) executeMergeJoin(join *plan.LogicalJoin) (Iterator, error) {
+	left, err := e.executeNode(join.Left)
+	if err != nil {
+		return nil, err
+	}
+	leftRows, err := e.keyed(left, join.LeftKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.executeNode(join.Right)
+	if err != nil {
+		return nil, err
+	}
+	rightRows, err := e.keyed(right, join.RightKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mergeIterator{left: leftRows, right: rightRows, condition: join.Condition}, nil
+}
+
+// rows of an input already in order, with their keys
+func (e *Executor) keyed(input Iterator, keys []plan.Expr) (*keyedRows, error) {
+	defer input.Close()
+
+	s := &keyedRows{}
+	for {
+		row, ok := input.Next()
+		if !ok {
+			break
+		}
+		s.rows = append(s.rows, row)
+		s.keys = append(s.keys, evaluateKeys(keys, row))
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"slices"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+func (e *Executor) executeView(view *plan.LogicalView) (Iterator, error) {
+	input, err := e.executeNode(view.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &viewIterator{
+		input:     input,
+		from:      view.Input.Schema(),
+		columns:   view.View.Columns,
+		qualifier: view.QualifiedName(),
+	}, nil
+}
+
+// renames the columns of a view's query to the view's, both plain and
+// qualified like a scan's
+type viewIterator struct {
+	input     Iterator
+	from      []catalog.Column
+	columns   []catalog.Column
+	qualifier string
+}
+
+func (v *viewIterator) Next() (Row, bool) {
+	row, ok := v.input.Next()
+	if !ok {
+		return nil, false
+	}
+
+	out := make(Row, 2*len(v.columns))
+	for i, col := range v.columns {
+		val := row[v.from[i].Name]
+		out[col.Name] = val
+		out[v.qualifier+"."+col.Name] = val
+	}
+	return out, true
+}
+
+func (v *viewIterator) Close() {
+	v.input.Close()
+}
+
+// rows of a materialized view's query, named like the view's columns
+func (e *Executor) viewRows(view *catalog.TableInfo, query plan.LogicalPlan) ([]storage.Row, error) {
+	input, err := e.executeNode(query)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	from := query.Schema()
+	var rows []storage.Row
+	for {
+		in, ok := input.Next()
+		if !ok {
+			break
+		}
+		row := make(storage.Row, len(view.Columns))
+		for i, col := range view.Columns {
+			row[col.Name] = in[from[i].Name]
+		}
+		rows = append(rows, row)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return rows, nil
+}
+
+// registers a view, materialized ones are filled with their query's rows
+// before the catalog is saved
+func (e *Executor) executeCreateView(create *plan.LogicalCreateView) (Iterator, error) {
+	view := create.View
+	if _, err := e.catalog.GetTable(view.Name); err == nil && create.IfNotExists {
+		return &scanIterator{}, nil
+	}
+	if create.Input == nil {
+		if err := e.catalog.CreateTable(view, nil); err != nil {
+			return nil, err
+		}
+		return &scanIterator{}, nil
+	}
+
+	rows, err := e.viewRows(view, create.Input)
+	if err != nil {
+		return nil, err
+	}
+	view.Statistics = storage.StatisticsOf(view, rows, 0)
+
+	created := false
+	err = e.catalog.CreateTable(view, func() error {
+		if err := storage.CreateData(view); err != nil {
+			return err
+		}
+		created = true
+		source, err := e.writable(view)
+		if err != nil {
+			return err
+		}
+		return source.Replace(rows)
+	})
+	if err != nil {
+		if created {
+			storage.DropData(view)
+		}
+		delete(e.sources, view)
+		return nil, err
+	}
+
+	return &scanIterator{}, nil
+}
+
+// replaces the rows of a materialized view in the running transaction
+func (e *Executor) executeRefresh(refresh *plan.LogicalRefresh) (Iterator, error) {
+	source, err := e.writable(refresh.Table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := e.viewRows(refresh.Table, refresh.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := source.Replace(rows); err != nil {
+		return nil, err
+	}
+	refresh.Table.SetStats(storage.StatisticsOf(refresh.Table, rows, 0))
+	e.refreshed = append(e.refreshed, refresh.Table)
+	return affected(len(rows)), nil
+}
+
+// materialized views reading the table a statement changes no longer match
+// their query, unless they follow its changes, see maintain. They are marked
+// before the change, a failed statement at worst leaves a view stale that
+// wasn't
+func (e *Executor) markStale(node plan.LogicalPlan) error {
+	var table *catalog.TableInfo
+	maintained := true
+	switch n := node.(type) {
+	case *plan.LogicalInsert:
+		table = n.Table
+	case *plan.LogicalUpdate:
+		table = n.Table
+	case *plan.LogicalDelete:
+		table = n.Table
+	case *plan.LogicalAlterTable:
+		table, maintained = n.Table, false
+	default:
+		return nil
+	}
+
+	for _, view := range e.catalog.ReadersOf(table.Name) {
+		if maintained && e.maintenanceOf(view, table) != nil {
+			continue
+		}
+		if err := e.stale(view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marks a view stale, refreshing it earlier in the transaction doesn't help
+func (e *Executor) stale(view *catalog.TableInfo) error {
+	if err := e.catalog.SetStale(view, true); err != nil {
+		return err
+	}
+	e.refreshed = slices.DeleteFunc(e.refreshed, func(v *catalog.TableInfo) bool { return v == view })
+	return nil
+}
+
+// views the transaction refreshed are up to date once it committed
+func (e *Executor) committed() error {
+	refreshed := e.refreshed
+	e.refreshed = nil
+	for _, view := range refreshed {
+		if err := e.catalog.SetStale(view, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
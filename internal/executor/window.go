@@ -0,0 +1,564 @@
+package executor
+
+import (
+	"sort"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+// window operator, buffers its input and emits rows in window order
+type windowIterator struct {
+	input Iterator
+	rows  []Row
+	index int
+}
+
+func (w *windowIterator) Next() (Row, bool) {
+	if w.index >= len(w.rows) {
+		return nil, false
+	}
+	row := w.rows[w.index]
+	w.index++
+
+	return row, true
+}
+func (w *windowIterator) Close() {
+	w.input.Close()
+}
+
+func (e *Executor) executeWindow(window *plan.LogicalWindow) (Iterator, error) {
+	input, err := e.executeNode(window.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		row, ok := input.Next()
+		if !ok {
+			break
+		}
+
+		// copyin so input rows are never mutated
+		out := make(Row, len(row)+len(window.Functions))
+		for k, v := range row {
+			out[k] = v
+		}
+		rows = append(rows, out)
+	}
+
+	var order []int
+	for i, fn := range window.Functions {
+		perm := evaluateWindowFunc(fn, window.ColumnNames[i], rows)
+		if i == 0 {
+			order = perm
+		}
+	}
+
+	sorted := make([]Row, len(rows))
+	for i, idx := range order {
+		sorted[i] = rows[idx]
+	}
+
+	return &windowIterator{input: input, rows: sorted}, nil
+}
+
+// computes one window function into rows[*][name], returns the sort permutation
+func evaluateWindowFunc(fn *plan.WindowFuncExpr, name string, rows []Row) []int {
+	partKeys := make([][]interface{}, len(rows))
+	orderKeys := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		partKeys[i] = evaluateKeys(fn.PartitionBy, row)
+
+		orderKeys[i] = make([]interface{}, len(fn.OrderBy))
+		for j, key := range fn.OrderBy {
+			orderKeys[i][j] = evaluateOrNil(key.Expr, row)
+		}
+	}
+
+	desc := make([]bool, len(fn.OrderBy))
+	for i, key := range fn.OrderBy {
+		desc[i] = key.Desc
+	}
+
+	perm := make([]int, len(rows))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(a, b int) bool {
+		if c := compareKeys(partKeys[perm[a]], partKeys[perm[b]], nil); c != 0 {
+			return c < 0
+		}
+		return compareKeys(orderKeys[perm[a]], orderKeys[perm[b]], desc) < 0
+	})
+
+	// walkin partitions, each one is a contiguous run of perm
+	for start := 0; start < len(perm); {
+		end := start + 1
+		for end < len(perm) && compareKeys(partKeys[perm[start]], partKeys[perm[end]], nil) == 0 {
+			end++
+		}
+
+		part := &windowPartition{
+			fn:        fn,
+			rows:      rows,
+			idx:       perm[start:end],
+			orderKeys: orderKeys,
+			desc:      desc,
+		}
+		results := part.evaluate()
+		for i, idx := range part.idx {
+			rows[idx][name] = results[i]
+		}
+
+		start = end
+	}
+
+	return perm
+}
+
+type windowPartition struct {
+	fn        *plan.WindowFuncExpr
+	rows      []Row
+	idx       []int // row indexes in window order
+	orderKeys [][]interface{}
+	desc      []bool
+	groups    [][2]int
+}
+
+func (p *windowPartition) evaluate() []interface{} {
+	n := len(p.idx)
+	results := make([]interface{}, n)
+
+	switch p.fn.Name {
+	case "ROW_NUMBER":
+		for i := range results {
+			results[i] = i + 1
+		}
+
+	case "RANK", "DENSE_RANK":
+		rank, dense := 0, 0
+		for i := range results {
+			if i == 0 || !p.peers(i-1, i) {
+				rank = i + 1
+				dense++
+			}
+			if p.fn.Name == "RANK" {
+				results[i] = rank
+			} else {
+				results[i] = dense
+			}
+		}
+
+	case "LAG", "LEAD":
+		offset := 1
+		if len(p.fn.Args) > 1 {
+			offset = p.fn.Args[1].(*plan.LiteralExpr).Value.(int)
+		}
+		if p.fn.Name == "LAG" {
+			offset = -offset
+		}
+
+		var def interface{}
+		if len(p.fn.Args) > 2 {
+			def = evaluateOrNil(p.fn.Args[2], nil)
+		}
+
+		for i := range results {
+			j := i + offset
+			if j < 0 || j >= n {
+				results[i] = def
+				continue
+			}
+			results[i] = evaluateOrNil(p.fn.Args[0], p.rows[p.idx[j]])
+		}
+
+	default:
+		p.evaluateFramed(results)
+	}
+
+	return results
+}
+
+// aggregates and value functions over the frame, the frame only slides
+// forward so rows are added and removed incrementally
+func (p *windowPartition) evaluateFramed(results []interface{}) {
+	n := len(p.idx)
+
+	values := make([]interface{}, n)
+	for i, idx := range p.idx {
+		if _, ok := p.fn.Args[0].(*plan.StarExpr); ok {
+			values[i] = true
+			continue
+		}
+		values[i] = evaluateOrNil(p.fn.Args[0], p.rows[idx])
+	}
+
+	acc := &frameAccumulator{fn: p.fn.Name, typ: p.fn.Type, values: values}
+	frameStart, frameEnd := 0, 0
+
+	for i := range results {
+		start, end := p.frameBounds(i)
+
+		for frameEnd < end {
+			acc.add(frameEnd)
+			frameEnd++
+		}
+		for frameStart < start {
+			if frameStart < frameEnd {
+				acc.remove(frameStart)
+			}
+			frameStart++
+		}
+		if frameEnd < frameStart {
+			frameEnd = frameStart
+		}
+
+		switch p.fn.Name {
+		case "FIRST_VALUE":
+			if start < end {
+				results[i] = values[start]
+			}
+		case "LAST_VALUE":
+			if start < end {
+				results[i] = values[end-1]
+			}
+		default:
+			results[i] = acc.result(frameStart, frameEnd)
+		}
+	}
+}
+
+// frame of row i as a half open range of partition positions
+func (p *windowPartition) frameBounds(i int) (int, int) {
+	n := len(p.idx)
+	frame := p.fn.Frame
+
+	var start, end int
+	if frame.Unit == plan.RowsFrame {
+		start = rowsBound(frame.Start, i, n)
+		end = rowsBound(frame.End, i, n) + 1
+	} else {
+		start = p.rangeStart(frame.Start, i)
+		end = p.rangeEnd(frame.End, i)
+	}
+
+	start = clamp(start, 0, n)
+	end = clamp(end, 0, n)
+	if end < start {
+		end = start
+	}
+
+	return start, end
+}
+
+func rowsBound(b plan.FrameBound, i, n int) int {
+	switch b.Type {
+	case plan.UnboundedPreceding:
+		return 0
+	case plan.Preceding:
+		return i - b.Offset
+	case plan.Following:
+		return i + b.Offset
+	case plan.UnboundedFollowing:
+		return n - 1
+	default:
+		return i
+	}
+}
+
+func (p *windowPartition) rangeStart(b plan.FrameBound, i int) int {
+	switch b.Type {
+	case plan.UnboundedPreceding:
+		return 0
+	case plan.UnboundedFollowing:
+		return len(p.idx)
+	case plan.CurrentRow:
+		return p.peerGroups()[i][0]
+	default:
+		target, ok := p.rangeTarget(b, i)
+		if !ok {
+			return p.peerGroups()[i][0]
+		}
+		// first row not before target in window order
+		return sort.Search(len(p.idx), func(j int) bool {
+			return !p.beforeTarget(j, target)
+		})
+	}
+}
+
+func (p *windowPartition) rangeEnd(b plan.FrameBound, i int) int {
+	switch b.Type {
+	case plan.UnboundedPreceding:
+		return 0
+	case plan.UnboundedFollowing:
+		return len(p.idx)
+	case plan.CurrentRow:
+		return p.peerGroups()[i][1]
+	default:
+		target, ok := p.rangeTarget(b, i)
+		if !ok {
+			return p.peerGroups()[i][1]
+		}
+		// first row after target in window order
+		return sort.Search(len(p.idx), func(j int) bool {
+			return p.afterTarget(j, target)
+		})
+	}
+}
+
+// RANGE order key as a number, dates and timestamps count days so offsets
+// move them by whole days. NULL keys have none
+func rangeValue(v interface{}) (float64, bool) {
+	switch k := v.(type) {
+	case types.Decimal:
+		return k.Float64(), true
+	case types.Date:
+		return float64(k.Time().Unix()) / secondsPerDay, true
+	case types.Timestamp:
+		return float64(k.Time().Unix()) / secondsPerDay, true
+	}
+	return toFloat64(v)
+}
+
+const secondsPerDay = 24 * 60 * 60
+
+// order key value that an offset bound reaches from row i
+func (p *windowPartition) rangeTarget(b plan.FrameBound, i int) (float64, bool) {
+	v, ok := rangeValue(p.orderKeys[p.idx[i]][0])
+	if !ok {
+		return 0, false
+	}
+
+	offset := float64(b.Offset)
+	if b.Type == plan.Preceding {
+		offset = -offset
+	}
+	if p.desc[0] {
+		offset = -offset
+	}
+
+	return v + offset, true
+}
+
+// NULL keys sort last ascending and first descending
+func (p *windowPartition) beforeTarget(j int, target float64) bool {
+	v, ok := rangeValue(p.orderKeys[p.idx[j]][0])
+	if !ok {
+		return p.desc[0]
+	}
+	if p.desc[0] {
+		return v > target
+	}
+	return v < target
+}
+
+func (p *windowPartition) afterTarget(j int, target float64) bool {
+	v, ok := rangeValue(p.orderKeys[p.idx[j]][0])
+	if !ok {
+		return !p.desc[0]
+	}
+	if p.desc[0] {
+		return v < target
+	}
+	return v > target
+}
+
+// rows with equal ORDER BY keys
+func (p *windowPartition) peers(a, b int) bool {
+	return compareKeys(p.orderKeys[p.idx[a]], p.orderKeys[p.idx[b]], nil) == 0
+}
+
+// [start, end) of the peer group of every row, computed once per partition
+func (p *windowPartition) peerGroups() [][2]int {
+	if p.groups != nil {
+		return p.groups
+	}
+
+	p.groups = make([][2]int, len(p.idx))
+	for start := 0; start < len(p.idx); {
+		end := start + 1
+		for end < len(p.idx) && p.peers(start, end) {
+			end++
+		}
+		for i := start; i < end; i++ {
+			p.groups[i] = [2]int{start, end}
+		}
+		start = end
+	}
+
+	return p.groups
+}
+
+// running state of an aggregate over the current frame. Decimals are
+// summed exactly as long as every value in the frame is one
+type frameAccumulator struct {
+	fn      string
+	typ     catalog.DataType // of the result, as planned
+	values  []interface{}
+	sum     float64
+	exact   types.Decimal
+	inexact int // values in the frame that aren't decimals
+	count   int
+	extreme interface{}
+	stale   bool // extreme left the frame, rescan on next result
+}
+
+func (a *frameAccumulator) add(i int) {
+	v := a.values[i]
+	if v == nil {
+		return
+	}
+	a.count++
+
+	switch a.fn {
+	case "SUM", "AVG":
+		if d, ok := v.(types.Decimal); ok {
+			a.exact = a.exact.Add(d)
+			a.sum += d.Float64()
+			break
+		}
+		f, _ := toFloat64(v)
+		a.sum += f
+		a.inexact++
+	case "MIN":
+		if !a.stale && (a.extreme == nil || compareSortValues(v, a.extreme) < 0) {
+			a.extreme = v
+		}
+	case "MAX":
+		if !a.stale && (a.extreme == nil || compareSortValues(v, a.extreme) > 0) {
+			a.extreme = v
+		}
+	}
+}
+
+func (a *frameAccumulator) remove(i int) {
+	v := a.values[i]
+	if v == nil {
+		return
+	}
+	a.count--
+
+	switch a.fn {
+	case "SUM", "AVG":
+		if d, ok := v.(types.Decimal); ok {
+			a.exact = a.exact.Sub(d)
+			a.sum -= d.Float64()
+			break
+		}
+		f, _ := toFloat64(v)
+		a.sum -= f
+		a.inexact--
+	case "MIN", "MAX":
+		if a.extreme != nil && compareSortValues(v, a.extreme) == 0 {
+			a.stale = true
+		}
+	}
+}
+
+func (a *frameAccumulator) result(start, end int) interface{} {
+	switch a.fn {
+	case "COUNT":
+		return a.count
+	case "SUM":
+		if a.count == 0 {
+			return nil
+		}
+		if a.inexact == 0 {
+			return a.exact
+		}
+		if v, err := function.CastTo(a.sum, a.typ, 0, 0); err == nil {
+			return v
+		}
+		return a.sum
+	case "AVG":
+		if a.count == 0 {
+			return nil
+		}
+		if a.inexact == 0 {
+			return a.exact.Float64() / float64(a.count)
+		}
+		return a.sum / float64(a.count)
+	default: // MIN, MAX
+		if a.stale {
+			a.extreme = nil
+			for _, v := range a.values[start:end] {
+				if v == nil {
+					continue
+				}
+				c := 0
+				if a.extreme != nil {
+					c = compareSortValues(v, a.extreme)
+				}
+				if a.extreme == nil || (a.fn == "MIN" && c < 0) || (a.fn == "MAX" && c > 0) {
+					a.extreme = v
+				}
+			}
+			a.stale = false
+		}
+		return a.extreme
+	}
+}
+
+func evaluateKeys(exprs []plan.Expr, row Row) []interface{} {
+	keys := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		keys[i] = evaluateOrNil(expr, row)
+	}
+
+	return keys
+}
+
+// missing columns and evaluation errors count as NULL
+func evaluateOrNil(expr plan.Expr, row Row) interface{} {
+	val, err := evaluateExpr(expr, row)
+	if err != nil {
+		return nil
+	}
+
+	return val
+}
+
+// compares key tuples, desc flips individual keys when given
+func compareKeys(a, b []interface{}, desc []bool) int {
+	for i := range a {
+		c := compareSortValues(a[i], b[i])
+		if desc != nil && desc[i] {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// orders NULLs last
+func compareSortValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	return function.Compare(a, b)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package function
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+var builtinAggregates = []*AggregateFunc{
+	{Name: "COUNT", Signature: computed(0, 1, returns(catalog.IntType)), Init: countInit, Accumulate: countAccumulate, Merge: countMerge, Finalize: identity},
+	{Name: "SUM", Signature: computed(1, 1, numericType), Init: sumInit, Accumulate: sumAccumulate, Merge: sumMerge, Finalize: sumFinalize},
+	{Name: "AVG", Signature: computed(1, 1, numericType), Init: sumInit, Accumulate: sumAccumulate, Merge: sumMerge, Finalize: avgFinalize},
+	{Name: "MIN", Signature: computed(1, 1, anyType), Init: nilInit, Accumulate: minAccumulate, Merge: minMerge, Finalize: identity},
+	{Name: "MAX", Signature: computed(1, 1, anyType), Init: nilInit, Accumulate: maxAccumulate, Merge: maxMerge, Finalize: identity},
+}
+
+func anyType(args []catalog.DataType) (catalog.DataType, error) {
+	return args[0], nil
+}
+
+func identity(state interface{}) (interface{}, error) {
+	return state, nil
+}
+
+func nilInit() interface{} {
+	return nil
+}
+
+func countInit() interface{} {
+	return 0
+}
+
+func countAccumulate(state interface{}, _ []interface{}) (interface{}, error) {
+	return state.(int) + 1, nil
+}
+
+func countMerge(a, b interface{}) (interface{}, error) {
+	return a.(int) + b.(int), nil
+}
+
+// decimals are summed exactly as long as every input is one
+type sumState struct {
+	sum     float64
+	exact   types.Decimal
+	inexact bool
+	count   int
+}
+
+func sumInit() interface{} {
+	return sumState{}
+}
+
+func sumAccumulate(state interface{}, args []interface{}) (interface{}, error) {
+	s := state.(sumState)
+
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("expected a number, got %T", args[0])
+	}
+	s.sum += f
+	s.count++
+
+	if d, ok := args[0].(types.Decimal); ok {
+		s.exact = s.exact.Add(d)
+	} else {
+		s.inexact = true
+	}
+
+	return s, nil
+}
+
+func sumMerge(a, b interface{}) (interface{}, error) {
+	x, y := a.(sumState), b.(sumState)
+	return sumState{
+		sum:     x.sum + y.sum,
+		exact:   x.exact.Add(y.exact),
+		inexact: x.inexact || y.inexact,
+		count:   x.count + y.count,
+	}, nil
+}
+
+// SUM and AVG of no rows is NULL
+func sumFinalize(state interface{}) (interface{}, error) {
+	s := state.(sumState)
+	if s.count == 0 {
+		return nil, nil
+	}
+	if !s.inexact {
+		return s.exact, nil
+	}
+
+	return s.sum, nil
+}
+
+func avgFinalize(state interface{}) (interface{}, error) {
+	s := state.(sumState)
+	if s.count == 0 {
+		return nil, nil
+	}
+	if !s.inexact {
+		return s.exact.Div(types.DecimalFromInt(s.count))
+	}
+
+	return s.sum / float64(s.count), nil
+}
+
+func minAccumulate(state interface{}, args []interface{}) (interface{}, error) {
+	return minMerge(state, args[0])
+}
+
+func minMerge(a, b interface{}) (interface{}, error) {
+	if a == nil || (b != nil && Compare(b, a) < 0) {
+		return b, nil
+	}
+	return a, nil
+}
+
+func maxAccumulate(state interface{}, args []interface{}) (interface{}, error) {
+	return maxMerge(state, args[0])
+}
+
+func maxMerge(a, b interface{}) (interface{}, error) {
+	if a == nil || (b != nil && Compare(b, a) > 0) {
+		return b, nil
+	}
+	return a, nil
+}
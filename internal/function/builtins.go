@@ -0,0 +1,303 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+var builtins = []*ScalarFunc{
+	// strings
+	{Name: "UPPER", Signature: computed(1, 1, returns(catalog.StringType)), Deterministic: true, Eval: strict(upper)},
+	{Name: "LOWER", Signature: computed(1, 1, returns(catalog.StringType)), Deterministic: true, Eval: strict(lower)},
+	{Name: "LENGTH", Signature: computed(1, 1, returns(catalog.IntType)), Deterministic: true, Eval: strict(length)},
+	{Name: "SUBSTRING", Signature: computed(2, 3, substringType), Deterministic: true, Eval: strict(substring)},
+	{Name: "TRIM", Signature: computed(1, 1, returns(catalog.StringType)), Deterministic: true, Eval: strict(trim)},
+	{Name: "LTRIM", Signature: computed(1, 1, returns(catalog.StringType)), Deterministic: true, Eval: strict(ltrim)},
+	{Name: "RTRIM", Signature: computed(1, 1, returns(catalog.StringType)), Deterministic: true, Eval: strict(rtrim)},
+	{Name: "CONCAT", Signature: computed(1, -1, returns(catalog.StringType)), Deterministic: true, Eval: concat},
+	{Name: "REPLACE", Signature: computed(3, 3, returns(catalog.StringType)), Deterministic: true, Eval: strict(replace)},
+
+	// math
+	{Name: "ABS", Signature: computed(1, 1, numericType), Deterministic: true, Eval: strict(abs)},
+	{Name: "ROUND", Signature: computed(1, 2, numericType), Deterministic: true, Eval: strict(round)},
+	{Name: "FLOOR", Signature: computed(1, 1, numericType), Deterministic: true, Eval: strict(floor)},
+	{Name: "CEIL", Signature: computed(1, 1, numericType), Deterministic: true, Eval: strict(ceil)},
+	{Name: "CEILING", Signature: computed(1, 1, numericType), Deterministic: true, Eval: strict(ceil)},
+	{Name: "MOD", Signature: computed(2, 2, numericType), Deterministic: true, Eval: strict(mod)},
+
+	// dates
+	{Name: "EXTRACT", Signature: computed(2, 2, extractType), Deterministic: true, Eval: strict(extract)},
+	{Name: "DATE_PART", Signature: computed(2, 2, extractType), Deterministic: true, Eval: strict(extract)},
+	{Name: "DATE_TRUNC", Signature: computed(2, 2, dateTruncType), Deterministic: true, Eval: strict(dateTrunc)},
+
+	// json
+	{Name: "JSON_EXTRACT", Signature: computed(2, 2, jsonPathType), Deterministic: true, Eval: strict(jsonExtract)},
+	{Name: "JSON_EXTRACT_TEXT", Signature: computed(2, 2, jsonTextType), Deterministic: true, Eval: strict(jsonExtractText)},
+	{Name: "JSON_ARRAY_LENGTH", Signature: computed(1, 1, jsonLengthType), Deterministic: true, Eval: strict(jsonArrayLength)},
+
+	// nulls
+	{Name: "COALESCE", Signature: computed(1, -1, commonType), Deterministic: true, Eval: coalesce},
+	{Name: "NULLIF", Signature: computed(2, 2, commonType), Deterministic: true, Eval: nullif},
+}
+
+// signature of a builtin whose result type depends on its arguments
+func computed(minArgs, maxArgs int, returnType func([]catalog.DataType) (catalog.DataType, error)) Signature {
+	return Signature{MinArgs: minArgs, MaxArgs: maxArgs, ReturnType: returnType}
+}
+
+func returns(t catalog.DataType) func([]catalog.DataType) (catalog.DataType, error) {
+	return func([]catalog.DataType) (catalog.DataType, error) {
+		return t, nil
+	}
+}
+
+// all arguments numeric, result has the type of the first one. JSON values
+// are checked when evaluated
+func numericType(args []catalog.DataType) (catalog.DataType, error) {
+	for _, t := range args {
+		if !t.IsNumeric() && t != catalog.NullType && t != catalog.JSONType {
+			return catalog.NullType, fmt.Errorf("expected numeric argument, got %s", t)
+		}
+	}
+
+	return args[0], nil
+}
+
+func substringType(args []catalog.DataType) (catalog.DataType, error) {
+	for _, t := range args[1:] {
+		if t != catalog.IntType && t != catalog.NullType {
+			return catalog.NullType, fmt.Errorf("SUBSTRING position and length must be INT, got %s", t)
+		}
+	}
+
+	return catalog.StringType, nil
+}
+
+// first non NULL argument type, the others must match it
+func commonType(args []catalog.DataType) (catalog.DataType, error) {
+	result := catalog.NullType
+	for _, t := range args {
+		if t == catalog.NullType {
+			continue
+		}
+		if result == catalog.NullType {
+			result = t
+		} else if t != result {
+			return catalog.NullType, fmt.Errorf("mismatched argument types %s and %s", result, t)
+		}
+	}
+
+	return result, nil
+}
+
+// NULL in, NULL out
+func strict(fn func([]interface{}) (interface{}, error)) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+		}
+
+		return fn(args)
+	}
+}
+
+func upper(args []interface{}) (interface{}, error) {
+	return strings.ToUpper(FormatValue(args[0])), nil
+}
+
+func lower(args []interface{}) (interface{}, error) {
+	return strings.ToLower(FormatValue(args[0])), nil
+}
+
+func length(args []interface{}) (interface{}, error) {
+	return utf8.RuneCountInString(FormatValue(args[0])), nil
+}
+
+// 1 based, like SQL
+func substring(args []interface{}) (interface{}, error) {
+	runes := []rune(FormatValue(args[0]))
+
+	start, ok := toInt(args[1])
+	if !ok {
+		return nil, fmt.Errorf("SUBSTRING position must be an integer")
+	}
+	from := start - 1
+	to := len(runes)
+
+	if len(args) > 2 {
+		n, ok := toInt(args[2])
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("SUBSTRING length must be a non-negative integer")
+		}
+		to = from + n
+	}
+
+	from = max(0, min(from, len(runes)))
+	to = max(from, min(to, len(runes)))
+
+	return string(runes[from:to]), nil
+}
+
+func trim(args []interface{}) (interface{}, error) {
+	return strings.Trim(FormatValue(args[0]), " "), nil
+}
+
+func ltrim(args []interface{}) (interface{}, error) {
+	return strings.TrimLeft(FormatValue(args[0]), " "), nil
+}
+
+func rtrim(args []interface{}) (interface{}, error) {
+	return strings.TrimRight(FormatValue(args[0]), " "), nil
+}
+
+// NULL arguments are skipped
+func concat(args []interface{}) (interface{}, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		if arg != nil {
+			sb.WriteString(FormatValue(arg))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func replace(args []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(FormatValue(args[0]), FormatValue(args[1]), FormatValue(args[2])), nil
+}
+
+func abs(args []interface{}) (interface{}, error) {
+	if n, ok := args[0].(int); ok {
+		if n < 0 {
+			return -n, nil
+		}
+		return n, nil
+	}
+	if d, ok := args[0].(types.Decimal); ok {
+		if d.Sign() < 0 {
+			return d.Neg(), nil
+		}
+		return d, nil
+	}
+
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("ABS expects a number, got %T", args[0])
+	}
+	return math.Abs(f), nil
+}
+
+func round(args []interface{}) (interface{}, error) {
+	digits := 0
+	if len(args) > 1 {
+		d, ok := toInt(args[1])
+		if !ok {
+			return nil, fmt.Errorf("ROUND digits must be an integer")
+		}
+		digits = d
+	}
+
+	if n, ok := args[0].(int); ok && digits >= 0 {
+		return n, nil
+	}
+	if d, ok := args[0].(types.Decimal); ok && digits >= 0 {
+		return d.Rescale(digits), nil
+	}
+
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("ROUND expects a number, got %T", args[0])
+	}
+	scale := math.Pow(10, float64(digits))
+
+	return math.Round(f*scale) / scale, nil
+}
+
+func floor(args []interface{}) (interface{}, error) {
+	if n, ok := args[0].(int); ok {
+		return n, nil
+	}
+	if d, ok := args[0].(types.Decimal); ok {
+		r := d.Rescale(0)
+		if r.Cmp(d) > 0 {
+			r = r.Sub(types.DecimalFromInt(1))
+		}
+		return r, nil
+	}
+
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("FLOOR expects a number, got %T", args[0])
+	}
+	return math.Floor(f), nil
+}
+
+func ceil(args []interface{}) (interface{}, error) {
+	if n, ok := args[0].(int); ok {
+		return n, nil
+	}
+	if d, ok := args[0].(types.Decimal); ok {
+		r := d.Rescale(0)
+		if r.Cmp(d) < 0 {
+			r = r.Add(types.DecimalFromInt(1))
+		}
+		return r, nil
+	}
+
+	f, ok := toFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("CEIL expects a number, got %T", args[0])
+	}
+	return math.Ceil(f), nil
+}
+
+func mod(args []interface{}) (interface{}, error) {
+	if v, ok, err := decimalArithmetic(args[0], "%", args[1]); ok {
+		return v, err
+	}
+
+	a, aInt := args[0].(int)
+	b, bInt := args[1].(int)
+	if aInt && bInt {
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a % b, nil
+	}
+
+	x, ok := toFloat(args[0])
+	y, ok2 := toFloat(args[1])
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("MOD expects numbers")
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+
+	return math.Mod(x, y), nil
+}
+
+func coalesce(args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func nullif(args []interface{}) (interface{}, error) {
+	if args[0] != nil && Equal(args[0], args[1]) {
+		return nil, nil
+	}
+
+	return args[0], nil
+}
@@ -0,0 +1,310 @@
+package function
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+// CAST(v AS t), NULL stays NULL
+func Cast(v interface{}, t catalog.DataType) (interface{}, error) {
+	return CastTo(v, t, 0, 0)
+}
+
+// CAST(v AS DECIMAL(precision, scale)), other types ignore precision and
+// scale. Decimals are rounded to scale and must fit in precision digits
+func CastTo(v interface{}, t catalog.DataType, precision, scale int) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case catalog.FloatType:
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int:
+			return float64(x), nil
+		case types.Decimal:
+			return x.Float64(), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FLOAT value '%s'", x)
+			}
+			return f, nil
+		}
+
+	case catalog.DecimalType:
+		d, err := toDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		if precision > 0 {
+			d = d.Rescale(scale)
+			if d.Precision() > precision {
+				return nil, fmt.Errorf("value %s out of range for %s", d, catalog.TypeName(t, precision, scale))
+			}
+		}
+		return d, nil
+
+	case catalog.DateType:
+		switch x := v.(type) {
+		case types.Date:
+			return x, nil
+		case types.Timestamp:
+			return x.Date(), nil
+		case string:
+			if d, err := types.ParseDate(x); err == nil {
+				return d, nil
+			}
+			ts, err := types.ParseTimestamp(x)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DATE value '%s'", x)
+			}
+			return ts.Date(), nil
+		}
+
+	case catalog.TimestampType:
+		switch x := v.(type) {
+		case types.Timestamp:
+			return x, nil
+		case types.Date:
+			return x.Timestamp(), nil
+		case string:
+			return types.ParseTimestamp(x)
+		}
+
+	case catalog.IntervalType:
+		switch x := v.(type) {
+		case types.Interval:
+			return x, nil
+		case string:
+			return types.ParseInterval(x)
+		}
+
+	case catalog.IntType:
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case int64:
+			return int(x), nil
+		case types.Decimal:
+			return x.Rescale(0).Int(), nil
+		case float64:
+			return int(math.Round(x)), nil
+		case bool:
+			if x {
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			n, err := strconv.Atoi(strings.TrimSpace(x))
+			if err != nil {
+				return nil, fmt.Errorf("invalid INT value '%s'", x)
+			}
+			return n, nil
+		}
+
+	case catalog.StringType:
+		return FormatValue(v), nil
+
+	case catalog.JSONType:
+		if s, ok := v.(string); ok {
+			return types.ParseJSON(s)
+		}
+		return v, nil
+
+	case catalog.BoolType:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int:
+			return x != 0, nil
+		case float64:
+			return x != 0, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "t", "yes", "1":
+				return true, nil
+			case "false", "f", "no", "0":
+				return false, nil
+			}
+			return nil, fmt.Errorf("invalid BOOL value '%s'", x)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot cast %T to %s", v, t)
+}
+
+// text form of a value, whole floats print without a fraction and JSON
+// objects and arrays print as JSON
+func FormatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		return types.JSONText(x)
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+func toDecimal(v interface{}) (types.Decimal, error) {
+	switch x := v.(type) {
+	case types.Decimal:
+		return x, nil
+	case int:
+		return types.DecimalFromInt(x), nil
+	case float64:
+		return types.ParseDecimal(strconv.FormatFloat(x, 'f', -1, 64))
+	case string:
+		return types.ParseDecimal(x)
+	}
+
+	return types.Decimal{}, fmt.Errorf("cannot cast %T to DECIMAL", v)
+}
+
+// equality that treats ints and floats with the same value as equal
+func Equal(a, b interface{}) bool {
+	if c, ok := compareTyped(a, b); ok {
+		return c == 0
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return x == y
+		}
+	}
+
+	return a == b
+}
+
+// orders two non NULL values, numbers compare by value
+func Compare(a, b interface{}) int {
+	if c, ok := compareTyped(a, b); ok {
+		return c
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return cmp.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0
+			} else if !x {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case types.Decimal:
+		return n.Float64(), true
+	default:
+		return 0, false
+	}
+}
+
+// compares decimals exactly and dates, timestamps and intervals by time.
+// Strings compared with a date or timestamp are read as one
+func compareTyped(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case types.Decimal:
+		switch y := b.(type) {
+		case types.Decimal:
+			return x.Cmp(y), true
+		case int:
+			return x.Cmp(types.DecimalFromInt(y)), true
+		}
+
+	case int:
+		if y, ok := b.(types.Decimal); ok {
+			return types.DecimalFromInt(x).Cmp(y), true
+		}
+
+	case types.Date:
+		switch y := b.(type) {
+		case types.Date:
+			return x.Cmp(y), true
+		case types.Timestamp:
+			return x.Timestamp().Cmp(y), true
+		case string:
+			if d, err := Cast(y, catalog.DateType); err == nil {
+				return x.Cmp(d.(types.Date)), true
+			}
+		}
+
+	case types.Timestamp:
+		switch y := b.(type) {
+		case types.Timestamp:
+			return x.Cmp(y), true
+		case types.Date:
+			return x.Cmp(y.Timestamp()), true
+		case string:
+			if ts, err := types.ParseTimestamp(y); err == nil {
+				return x.Cmp(ts), true
+			}
+		}
+
+	case types.Interval:
+		if y, ok := b.(types.Interval); ok {
+			return x.Cmp(y), true
+		}
+
+	case string:
+		switch b.(type) {
+		case types.Date, types.Timestamp:
+			if c, ok := compareTyped(b, a); ok {
+				return -c, true
+			}
+		}
+	}
+
+	// JSON objects and arrays can't be compared with ==, use their text
+	if types.IsJSONContainer(a) || types.IsJSONContainer(b) {
+		return cmp.Compare(FormatValue(a), FormatValue(b)), true
+	}
+
+	return 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,141 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+// doc -> key and doc ->> key. Keys are object member names or array
+// indexes, a missing member is NULL. ->> returns the value as text
+func jsonArrow(doc interface{}, op string, key interface{}) (interface{}, error) {
+	doc, err := jsonDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var step interface{}
+	switch k := key.(type) {
+	case string:
+		step = k
+	case int:
+		step = k
+	case float64:
+		if k != math.Trunc(k) {
+			return nil, fmt.Errorf("JSON array index must be an integer, got %v", k)
+		}
+		step = int(k)
+	default:
+		return nil, fmt.Errorf("JSON key must be a string or integer, got %T", key)
+	}
+
+	v, ok := types.JSONStep(doc, step)
+	if !ok {
+		return nil, nil
+	}
+	if op == "->>" {
+		return jsonText(v), nil
+	}
+	return v, nil
+}
+
+// JSON values are decoded already, strings holding JSON text are parsed
+func jsonDocument(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return types.ParseJSON(s)
+	}
+
+	return v, nil
+}
+
+// text form for ->>, JSON null stays NULL and strings lose their quotes
+func jsonText(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return FormatValue(x)
+	}
+}
+
+// JSON_EXTRACT(doc, '$.path[0].to')
+func jsonExtract(args []interface{}) (interface{}, error) {
+	doc, err := jsonDocument(args[0])
+	if err != nil {
+		return nil, err
+	}
+	path, err := types.ParseJSONPath(FormatValue(args[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	v, _ := path.Lookup(doc)
+	return v, nil
+}
+
+// JSON_EXTRACT_TEXT(doc, path), JSON_EXTRACT as text like ->>
+func jsonExtractText(args []interface{}) (interface{}, error) {
+	v, err := jsonExtract(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonText(v), nil
+}
+
+// JSON_ARRAY_LENGTH(doc), NULL when doc is not an array
+func jsonArrayLength(args []interface{}) (interface{}, error) {
+	doc, err := jsonDocument(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if arr, ok := doc.([]interface{}); ok {
+		return len(arr), nil
+	}
+	return nil, nil
+}
+
+func jsonPathType(args []catalog.DataType) (catalog.DataType, error) {
+	if err := expectJSON(args[0]); err != nil {
+		return catalog.NullType, err
+	}
+	if args[1] != catalog.StringType && args[1] != catalog.NullType {
+		return catalog.NullType, fmt.Errorf("JSON path must be a string, got %s", args[1])
+	}
+
+	return catalog.JSONType, nil
+}
+
+func jsonTextType(args []catalog.DataType) (catalog.DataType, error) {
+	if _, err := jsonPathType(args); err != nil {
+		return catalog.NullType, err
+	}
+
+	return catalog.StringType, nil
+}
+
+func jsonLengthType(args []catalog.DataType) (catalog.DataType, error) {
+	if err := expectJSON(args[0]); err != nil {
+		return catalog.NullType, err
+	}
+
+	return catalog.IntType, nil
+}
+
+// JSON documents, or strings holding JSON text
+func expectJSON(t catalog.DataType) error {
+	switch t {
+	case catalog.JSONType, catalog.StringType, catalog.NullType:
+		return nil
+	}
+
+	return fmt.Errorf("expected a JSON argument, got %s", t)
+}
@@ -0,0 +1,189 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// evaluates a binary operator. Comparisons and arithmetic with a NULL side
+// are NULL, AND and OR follow SQL three valued logic
+func EvalBinary(left interface{}, op string, right interface{}) (interface{}, error) {
+	switch op {
+	case "AND":
+		l, r := toBool(left), toBool(right)
+		if l == false || r == false {
+			return false, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return true, nil
+	case "OR":
+		l, r := toBool(left), toBool(right)
+		if l == true || r == true {
+			return true, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch op {
+	case "=":
+		return Equal(left, right), nil
+	case "!=", "<>":
+		return !Equal(left, right), nil
+	case ">":
+		return Compare(left, right) > 0, nil
+	case "<":
+		return Compare(left, right) < 0, nil
+	case ">=":
+		return Compare(left, right) >= 0, nil
+	case "<=":
+		return Compare(left, right) <= 0, nil
+	case "+", "-", "*", "/", "%":
+		return arithmetic(left, op, right)
+	case "LIKE":
+		s, ok := left.(string)
+		pattern, ok2 := right.(string)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("LIKE needs string operands")
+		}
+		return Like(s, pattern), nil
+	case "->", "->>":
+		return jsonArrow(left, op, right)
+	default:
+		return nil, fmt.Errorf("unsupoorted operator %s", op)
+	}
+}
+
+// ints stay ints, anything involving a float is computed as float.
+// Decimals with ints or decimals stay exact
+func arithmetic(left interface{}, op string, right interface{}) (interface{}, error) {
+	if v, ok, err := temporalArithmetic(left, op, right); ok {
+		return v, err
+	}
+	if v, ok, err := decimalArithmetic(left, op, right); ok {
+		return v, err
+	}
+
+	a, aInt := left.(int)
+	b, bInt := right.(int)
+	if aInt && bInt {
+		return intArithmetic(a, op, b)
+	}
+
+	x, ok := toFloat(left)
+	y, ok2 := toFloat(right)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("operator %s expects numbers, got %T and %T", op, left, right)
+	}
+
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	}
+	if y == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if op == "/" {
+		return x / y, nil
+	}
+	return math.Mod(x, y), nil
+}
+
+// integer arithmetic, results that don't fit an int are an error rather
+// than wrapping around
+func intArithmetic(a int, op string, b int) (interface{}, error) {
+	var c int
+	overflow := false
+	switch op {
+	case "+":
+		c = a + b
+		overflow = (b > 0 && c < a) || (b < 0 && c > a)
+	case "-":
+		c = a - b
+		overflow = (b > 0 && c > a) || (b < 0 && c < a)
+	case "*":
+		c = a * b
+		overflow = a != 0 && (c/a != b || (a == -1 && b == math.MinInt))
+	default:
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if a == math.MinInt && b == -1 {
+			if op == "%" {
+				return 0, nil
+			}
+			overflow = true
+		} else if op == "/" {
+			c = a / b
+		} else {
+			c = a % b
+		}
+	}
+	if overflow {
+		return nil, fmt.Errorf("integer out of range")
+	}
+
+	return c, nil
+}
+
+// true, false or nil for NULL, non booleans count as false
+func toBool(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	b, _ := v.(bool)
+
+	return b
+}
+
+// matches s against a LIKE pattern, % is any run of characters and _ any
+// single character
+func Like(s, pattern string) bool {
+	str, pat := []rune(s), []rune(pattern)
+
+	// backtracking over the last %, linear for patterns without nesting
+	si, pi := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(pat) && (pat[pi] == '_' || pat[pi] == str[si]):
+			si++
+			pi++
+		case pi < len(pat) && pat[pi] == '%':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			mark++
+			si, pi = mark, star+1
+		default:
+			return false
+		}
+	}
+	for pi < len(pat) && pat[pi] == '%' {
+		pi++
+	}
+
+	return pi == len(pat)
+}
+
+// literal text before the first wildcard of a LIKE pattern
+func LikePrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "%_"); i >= 0 {
+		return pattern[:i]
+	}
+
+	return pattern
+}
@@ -0,0 +1,221 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+)
+
+// argument and result types of a function. User defined functions declare
+// ArgTypes and Result, polymorphic builtins compute the result in ReturnType
+type Signature struct {
+	ArgTypes []catalog.DataType
+	Result   catalog.DataType
+
+	MinArgs    int
+	MaxArgs    int // -1 for variadic
+	ReturnType func(args []catalog.DataType) (catalog.DataType, error)
+}
+
+func (s *Signature) bind(name string, args []catalog.DataType) (catalog.DataType, error) {
+	if s.ReturnType == nil {
+		if len(args) != len(s.ArgTypes) {
+			return catalog.NullType, fmt.Errorf("wrong number of arguments to %s: %d", name, len(args))
+		}
+		for i, t := range args {
+			if t != catalog.NullType && t != s.ArgTypes[i] {
+				return catalog.NullType, fmt.Errorf("argument %d of %s must be %s, got %s", i+1, name, s.ArgTypes[i], t)
+			}
+		}
+
+		return s.Result, nil
+	}
+
+	if len(args) < s.MinArgs || (s.MaxArgs >= 0 && len(args) > s.MaxArgs) {
+		return catalog.NullType, fmt.Errorf("wrong number of arguments to %s: %d", name, len(args))
+	}
+
+	return s.ReturnType(args)
+}
+
+// arguments cast to the types a user defined function declares, builtins
+// take them as they come
+func (s *Signature) Coerce(args []interface{}) ([]interface{}, error) {
+	if s.ReturnType != nil {
+		return args, nil
+	}
+
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := CastTo(arg, s.ArgTypes[i], 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// scalar function, resolved by the planner and called by the executor
+type ScalarFunc struct {
+	Name string
+	Signature
+
+	// same arguments always give the same result, so calls with constant
+	// arguments can be folded at plan time
+	Deterministic bool
+	Eval          func(args []interface{}) (interface{}, error)
+}
+
+// checks arity and argument types, returns the result type
+func (f *ScalarFunc) Bind(args []catalog.DataType) (catalog.DataType, error) {
+	return f.Signature.bind(f.Name, args)
+}
+
+// aggregate function, the executor keeps one state per group. Rows with a
+// NULL argument are skipped before Accumulate is called. Merge is optional,
+// only view maintenance uses it, to fold new rows into a stored MIN or MAX.
+// Maintained views recompute the changed groups of other aggregates
+type AggregateFunc struct {
+	Name string
+	Signature
+
+	Init       func() interface{}
+	Accumulate func(state interface{}, args []interface{}) (interface{}, error)
+	Merge      func(a, b interface{}) (interface{}, error)
+	Finalize   func(state interface{}) (interface{}, error)
+}
+
+func (f *AggregateFunc) Bind(args []catalog.DataType) (catalog.DataType, error) {
+	return f.Signature.bind(f.Name, args)
+}
+
+type Registry struct {
+	mu         sync.RWMutex
+	scalars    map[string]*ScalarFunc
+	aggregates map[string]*AggregateFunc
+}
+
+var registries = struct {
+	sync.Mutex
+	of map[*catalog.Catalog]*Registry
+}{of: make(map[*catalog.Catalog]*Registry)}
+
+// registry of the functions queries on a catalog can call, created on
+// first use. Planning, optimizing and view maintenance all resolve
+// functions through it, so user defined functions work everywhere
+func RegistryOf(cat *catalog.Catalog) *Registry {
+	registries.Lock()
+	defer registries.Unlock()
+
+	r, ok := registries.of[cat]
+	if !ok {
+		r = NewRegistry()
+		registries.of[cat] = r
+	}
+	return r
+}
+
+// registry preloaded with the builtin functions
+func NewRegistry() *Registry {
+	r := &Registry{
+		scalars:    make(map[string]*ScalarFunc),
+		aggregates: make(map[string]*AggregateFunc),
+	}
+
+	for _, fn := range builtins {
+		r.scalars[fn.Name] = fn
+	}
+	for _, fn := range builtinAggregates {
+		r.aggregates[fn.Name] = fn
+	}
+
+	return r
+}
+
+// adds a scalar function, names are case insensitive and must be unused
+func (r *Registry) RegisterScalar(fn *ScalarFunc) error {
+	if fn.Name == "" || fn.Eval == nil {
+		return fmt.Errorf("scalar function needs a name and Eval")
+	}
+	if err := fn.validate(); err != nil {
+		return fmt.Errorf("function %s: %w", fn.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.ToUpper(fn.Name)
+	if r.exists(name) {
+		return fmt.Errorf("function %s already registered", name)
+	}
+	r.scalars[name] = fn
+
+	return nil
+}
+
+func (r *Registry) RegisterAggregate(fn *AggregateFunc) error {
+	if fn.Name == "" || fn.Init == nil || fn.Accumulate == nil || fn.Finalize == nil {
+		return fmt.Errorf("aggregate function needs a name and Init, Accumulate and Finalize")
+	}
+	if err := fn.validate(); err != nil {
+		return fmt.Errorf("function %s: %w", fn.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.ToUpper(fn.Name)
+	if r.exists(name) {
+		return fmt.Errorf("function %s already registered", name)
+	}
+	r.aggregates[name] = fn
+
+	return nil
+}
+
+func (r *Registry) LookupScalar(name string) (*ScalarFunc, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.scalars[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+
+	return fn, nil
+}
+
+func (r *Registry) LookupAggregate(name string) (*AggregateFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.aggregates[strings.ToUpper(name)]
+	return fn, ok
+}
+
+func (r *Registry) exists(name string) bool {
+	_, scalar := r.scalars[name]
+	_, aggregate := r.aggregates[name]
+
+	return scalar || aggregate
+}
+
+// user defined functions must declare their types
+func (s *Signature) validate() error {
+	if s.ReturnType != nil {
+		return nil
+	}
+	if s.Result == catalog.NullType {
+		return fmt.Errorf("result type must be declared")
+	}
+	for i, t := range s.ArgTypes {
+		if t == catalog.NullType {
+			return fmt.Errorf("argument %d has no type", i+1)
+		}
+	}
+
+	return nil
+}
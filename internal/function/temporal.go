@@ -0,0 +1,278 @@
+package function
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+// result type of an arithmetic operator, NULL operands take the type of
+// the other side
+func BinaryType(left catalog.DataType, op string, right catalog.DataType) (catalog.DataType, error) {
+	if left == catalog.NullType {
+		return right, nil
+	}
+	if right == catalog.NullType {
+		return left, nil
+	}
+
+	if left.IsNumeric() && right.IsNumeric() {
+		switch {
+		case left == catalog.FloatType || right == catalog.FloatType:
+			return catalog.FloatType, nil
+		case left == catalog.DecimalType || right == catalog.DecimalType:
+			return catalog.DecimalType, nil
+		default:
+			return catalog.IntType, nil
+		}
+	}
+
+	type operands struct {
+		left  catalog.DataType
+		op    string
+		right catalog.DataType
+	}
+	switch (operands{left, op, right}) {
+	case operands{catalog.DateType, "+", catalog.IntType},
+		operands{catalog.IntType, "+", catalog.DateType},
+		operands{catalog.DateType, "-", catalog.IntType}:
+		return catalog.DateType, nil
+	case operands{catalog.DateType, "-", catalog.DateType}:
+		return catalog.IntType, nil
+	case operands{catalog.DateType, "+", catalog.IntervalType},
+		operands{catalog.IntervalType, "+", catalog.DateType},
+		operands{catalog.DateType, "-", catalog.IntervalType},
+		operands{catalog.TimestampType, "+", catalog.IntervalType},
+		operands{catalog.IntervalType, "+", catalog.TimestampType},
+		operands{catalog.TimestampType, "-", catalog.IntervalType}:
+		return catalog.TimestampType, nil
+	case operands{catalog.TimestampType, "-", catalog.TimestampType},
+		operands{catalog.TimestampType, "-", catalog.DateType},
+		operands{catalog.DateType, "-", catalog.TimestampType},
+		operands{catalog.IntervalType, "+", catalog.IntervalType},
+		operands{catalog.IntervalType, "-", catalog.IntervalType},
+		operands{catalog.IntervalType, "*", catalog.IntType},
+		operands{catalog.IntType, "*", catalog.IntervalType}:
+		return catalog.IntervalType, nil
+	}
+
+	return catalog.NullType, fmt.Errorf("operator %s cannot be applied to %s and %s", op, left, right)
+}
+
+// date, timestamp and interval arithmetic, false when neither side is one
+func temporalArithmetic(left interface{}, op string, right interface{}) (interface{}, bool, error) {
+	switch x := left.(type) {
+	case types.Date:
+		switch y := right.(type) {
+		case int:
+			switch op {
+			case "+":
+				return x.AddDays(y), true, nil
+			case "-":
+				return x.AddDays(-y), true, nil
+			}
+		case types.Date:
+			if op == "-" {
+				return x.Sub(y), true, nil
+			}
+		case types.Interval:
+			return temporalArithmetic(x.Timestamp(), op, y)
+		case types.Timestamp:
+			return temporalArithmetic(x.Timestamp(), op, y)
+		}
+
+	case types.Timestamp:
+		switch y := right.(type) {
+		case types.Interval:
+			switch op {
+			case "+":
+				return types.NewTimestamp(y.AddTo(x.Time())), true, nil
+			case "-":
+				return types.NewTimestamp(y.Neg().AddTo(x.Time())), true, nil
+			}
+		case types.Timestamp:
+			if op == "-" {
+				return x.Sub(y), true, nil
+			}
+		case types.Date:
+			if op == "-" {
+				return x.Sub(y.Timestamp()), true, nil
+			}
+		}
+
+	case types.Interval:
+		switch y := right.(type) {
+		case types.Interval:
+			switch op {
+			case "+":
+				return x.Add(y), true, nil
+			case "-":
+				return x.Add(y.Neg()), true, nil
+			}
+		case int:
+			if op == "*" {
+				return x.Scale(y), true, nil
+			}
+		case types.Date, types.Timestamp:
+			if op == "+" {
+				return temporalArithmetic(right, op, left)
+			}
+		}
+
+	case int:
+		switch right.(type) {
+		case types.Date, types.Interval:
+			if op == "+" || op == "*" {
+				return temporalArithmetic(right, op, left)
+			}
+		}
+
+	default:
+		return nil, false, nil
+	}
+
+	switch right.(type) {
+	case types.Date, types.Timestamp, types.Interval:
+	default:
+		if _, ok := left.(int); ok {
+			return nil, false, nil
+		}
+	}
+
+	return nil, true, fmt.Errorf("operator %s cannot be applied to %T and %T", op, left, right)
+}
+
+// exact arithmetic when a decimal meets an int or decimal
+func decimalArithmetic(left interface{}, op string, right interface{}) (interface{}, bool, error) {
+	_, leftDec := left.(types.Decimal)
+	_, rightDec := right.(types.Decimal)
+	if !leftDec && !rightDec {
+		return nil, false, nil
+	}
+
+	a, err := exactNumber(left)
+	if err != nil {
+		return nil, false, nil
+	}
+	b, err := exactNumber(right)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	switch op {
+	case "+":
+		return a.Add(b), true, nil
+	case "-":
+		return a.Sub(b), true, nil
+	case "*":
+		return a.Mul(b), true, nil
+	case "/":
+		d, err := a.Div(b)
+		return d, true, err
+	default:
+		d, err := a.Mod(b)
+		return d, true, err
+	}
+}
+
+func exactNumber(v interface{}) (types.Decimal, error) {
+	switch x := v.(type) {
+	case types.Decimal:
+		return x, nil
+	case int:
+		return types.DecimalFromInt(x), nil
+	}
+
+	return types.Decimal{}, fmt.Errorf("not exact")
+}
+
+// EXTRACT('field', date or timestamp)
+func extract(args []interface{}) (interface{}, error) {
+	field := FormatValue(args[0])
+
+	switch x := args[1].(type) {
+	case types.Date:
+		return types.Extract(field, x.Time())
+	case types.Timestamp:
+		return types.Extract(field, x.Time())
+	case types.Interval:
+		return extractInterval(field, x)
+	}
+
+	return nil, fmt.Errorf("EXTRACT expects a date, timestamp or interval, got %T", args[1])
+}
+
+func extractInterval(field string, i types.Interval) (interface{}, error) {
+	switch field {
+	case "YEAR":
+		return i.Months / 12, nil
+	case "MONTH":
+		return i.Months % 12, nil
+	case "DAY":
+		return i.Days, nil
+	case "HOUR":
+		return int(i.Micros / 3600e6), nil
+	case "MINUTE":
+		return int(i.Micros/60e6) % 60, nil
+	case "SECOND":
+		return int(i.Micros/1e6) % 60, nil
+	}
+
+	return nil, fmt.Errorf("cannot extract %s from an interval", field)
+}
+
+// DATE_TRUNC('field', date or timestamp), keeps the argument's type
+func dateTrunc(args []interface{}) (interface{}, error) {
+	field := FormatValue(args[0])
+
+	switch x := args[1].(type) {
+	case types.Date:
+		t, err := types.Truncate(field, x.Time())
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTimestamp(t).Date(), nil
+	case types.Timestamp:
+		t, err := types.Truncate(field, x.Time())
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTimestamp(t), nil
+	}
+
+	return nil, fmt.Errorf("DATE_TRUNC expects a date or timestamp, got %T", args[1])
+}
+
+func extractType(args []catalog.DataType) (catalog.DataType, error) {
+	if err := expectTemporal("EXTRACT", args, true); err != nil {
+		return catalog.NullType, err
+	}
+
+	return catalog.IntType, nil
+}
+
+func dateTruncType(args []catalog.DataType) (catalog.DataType, error) {
+	if err := expectTemporal("DATE_TRUNC", args, false); err != nil {
+		return catalog.NullType, err
+	}
+
+	return args[1], nil
+}
+
+func expectTemporal(name string, args []catalog.DataType, interval bool) error {
+	if args[0] != catalog.StringType && args[0] != catalog.NullType {
+		return fmt.Errorf("%s field must be a string, got %s", name, args[0])
+	}
+
+	switch args[1] {
+	case catalog.DateType, catalog.TimestampType, catalog.NullType:
+		return nil
+	case catalog.IntervalType:
+		if interval {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s expects a date or timestamp, got %s", name, args[1])
+}
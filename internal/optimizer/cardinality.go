@@ -0,0 +1,460 @@
+package optimizer
+
+import (
+	"math"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// guesses when statistics have nothing better
+const (
+	defaultRowCount      = 1000
+	defaultEqSelectivity = 0.1
+	rangeSelectivity     = 1.0 / 3
+	otherSelectivity     = 0.5
+	unnestFanout         = 3 // elements per unnested array
+)
+
+// estimated number of rows a plan produces, from table statistics and
+// constraints. Unique keys bound equality filters and joins, foreign keys
+// size joins to their referencing side
+func EstimateRows(node plan.LogicalPlan) float64 {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return tableRows(n)
+
+	case *plan.LogicalFilter:
+		input := EstimateRows(n.Input)
+		rels := relationsOf(n.Input)
+		rows := input * selectivity(n.Predicate, rels)
+
+		// equalities covering a unique key leave at most one row per scan
+		if len(rels) == 1 && pinsUniqueKey(plan.SplitConjuncts(n.Predicate), rels[0]) {
+			rows = math.Min(rows, 1)
+		}
+		return rows
+
+	case *plan.LogicalJoin:
+		return joinRows(n)
+
+	case *plan.LogicalAggregate:
+		input := EstimateRows(n.Input)
+		if len(n.GroupBy) == 0 {
+			return 1
+		}
+
+		groups := 1.0
+		rels := relationsOf(n.Input)
+		for _, g := range n.GroupBy {
+			groups *= distinctValues(g, rels, input)
+		}
+		return math.Min(groups, input)
+
+	case *plan.LogicalUnnest:
+		return EstimateRows(n.Input) * unnestFanout
+
+	case *plan.LogicalEmpty:
+		return 0
+
+	default:
+		children := node.Children()
+		if len(children) == 0 {
+			return 1
+		}
+		return EstimateRows(children[0])
+	}
+}
+
+// estimated work of running a plan, what each of its operators costs
+func EstimateCost(node plan.LogicalPlan) float64 {
+	children := node.Children()
+	inputs := make([]float64, len(children))
+	cost := 0.0
+	for i, child := range children {
+		inputs[i] = EstimateRows(child)
+		cost += EstimateCost(child)
+	}
+
+	return cost + operatorCost(node, EstimateRows(node), inputs)
+}
+
+// work of one operator producing rows from inputs. Every row produced
+// costs one, building a hash table half again per row it holds and
+// sorting n log n
+func operatorCost(node plan.LogicalPlan, rows float64, inputs []float64) float64 {
+	switch n := node.(type) {
+	case *plan.LogicalJoin:
+		left, right := inputs[0], inputs[1]
+		switch n.Method {
+		case plan.HashJoin:
+			return left + 1.5*right + rows
+		case plan.MergeJoin:
+			return left + right + rows
+		default:
+			return left*right + rows
+		}
+
+	case *plan.LogicalAggregate:
+		if n.Sorted {
+			return inputs[0] + rows
+		}
+		return 1.5*inputs[0] + rows
+
+	case *plan.LogicalSort:
+		return sortCost(rows)
+
+	default:
+		return rows
+	}
+}
+
+func sortCost(rows float64) float64 {
+	return rows * math.Log2(math.Max(rows, 2))
+}
+
+func tableRows(scan *plan.LogicalScan) float64 {
+	stats := scan.Table.Stats()
+	if stats == nil || stats.RowCount == 0 {
+		return defaultRowCount
+	}
+
+	return float64(stats.RowCount)
+}
+
+func joinRows(join *plan.LogicalJoin) float64 {
+	left, right := EstimateRows(join.Left), EstimateRows(join.Right)
+	leftRels, rightRels := relationsOf(join.Left), relationsOf(join.Right)
+	all := append(append([]relation{}, leftRels...), rightRels...)
+
+	rows := left * right
+	var rest []plan.Expr
+	fkJoin := false
+
+	for _, conjunct := range plan.SplitConjuncts(join.Condition) {
+		l, r, ok := equiJoinColumns(conjunct, leftRels, rightRels)
+		if !ok {
+			rest = append(rest, conjunct)
+			continue
+		}
+
+		switch {
+		case !fkJoin && references(l, r):
+			rows = foreignKeyRows(join.Left, l, left, right, r)
+			fkJoin = true
+		case !fkJoin && references(r, l):
+			rows = foreignKeyRows(join.Right, r, right, left, l)
+			fkJoin = true
+		default:
+			da, ok1 := distributionOf(l)
+			db, ok2 := distributionOf(r)
+			if ok1 && ok2 {
+				rows *= joinSelectivity(da, db)
+				continue
+			}
+
+			ndv := math.Max(distinctValues(l.col, all, left), distinctValues(r.col, all, right))
+			rows /= math.Max(ndv, 1)
+		}
+	}
+	rows *= selectivity(plan.CombineConjuncts(rest), all)
+
+	switch join.JoinType {
+	case plan.LeftJoin:
+		rows = math.Max(rows, left)
+	case plan.RightJoin:
+		rows = math.Max(rows, right)
+	}
+
+	return rows
+}
+
+// every referencing row finds exactly its referenced row, filters on the
+// referenced side keep their fraction of them. When the key is pinned to a
+// constant on the referencing side the fraction is already in its estimate
+func foreignKeyRows(child plan.LogicalPlan, fk joinColumn, childRows, parentRows float64, key joinColumn) float64 {
+	if pinsColumn(child, fk) {
+		return childRows * math.Min(parentRows, 1)
+	}
+
+	return childRows * parentRows / tableRows(key.rel.scan)
+}
+
+// reports whether a filter below node compares the column to a constant
+func pinsColumn(node plan.LogicalPlan, jc joinColumn) bool {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		for _, c := range plan.SplitConjuncts(n.Predicate) {
+			b, ok := c.(*plan.BinaryExpr)
+			if !ok || b.Operator != "=" {
+				continue
+			}
+			col, ok := b.Left.(*plan.ColumnExpr)
+			if _, lit := b.Right.(*plan.LiteralExpr); ok && lit && col.Column == jc.col.Column {
+				if name, ok := resolveColumn(col, []relation{jc.rel}); ok && name == jc.rel.name {
+					return true
+				}
+			}
+		}
+		return pinsColumn(n.Input, jc)
+	case *plan.LogicalJoin:
+		return pinsColumn(n.Left, jc) || pinsColumn(n.Right, jc)
+	default:
+		return false
+	}
+}
+
+type joinColumn struct {
+	col *plan.ColumnExpr
+	rel relation
+}
+
+// sides of a column = column conjunct between the two inputs
+func equiJoinColumns(expr plan.Expr, leftRels, rightRels []relation) (joinColumn, joinColumn, bool) {
+	b, ok := expr.(*plan.BinaryExpr)
+	if !ok || b.Operator != "=" {
+		return joinColumn{}, joinColumn{}, false
+	}
+	a, ok1 := b.Left.(*plan.ColumnExpr)
+	c, ok2 := b.Right.(*plan.ColumnExpr)
+	if !ok1 || !ok2 {
+		return joinColumn{}, joinColumn{}, false
+	}
+
+	if l, ok := columnOf(a, leftRels); ok {
+		if r, ok := columnOf(c, rightRels); ok {
+			return l, r, true
+		}
+	}
+	if l, ok := columnOf(c, leftRels); ok {
+		if r, ok := columnOf(a, rightRels); ok {
+			return l, r, true
+		}
+	}
+
+	return joinColumn{}, joinColumn{}, false
+}
+
+func columnOf(col *plan.ColumnExpr, rels []relation) (joinColumn, bool) {
+	name, ok := resolveColumn(col, rels)
+	if !ok {
+		return joinColumn{}, false
+	}
+	for _, rel := range rels {
+		if rel.name == name {
+			return joinColumn{col: col, rel: rel}, true
+		}
+	}
+
+	return joinColumn{}, false
+}
+
+// reports whether from is a NOT NULL single column foreign key to the
+// key column to
+func references(from, to joinColumn) bool {
+	table := from.rel.scan.Table
+	fk, ok := table.ForeignKeyTo([]string{from.col.Column}, to.rel.scan.TableName)
+
+	return ok && table.IsNotNull(from.col.Column) && fk.RefColumns[0] == to.col.Column
+}
+
+// fraction of rows a predicate keeps
+func selectivity(pred plan.Expr, rels []relation) float64 {
+	switch e := pred.(type) {
+	case nil:
+		return 1
+
+	case *plan.LiteralExpr:
+		if e.Value == true {
+			return 1
+		}
+		return 0
+
+	case *plan.NotExpr:
+		return 1 - selectivity(e.Expr, rels)
+
+	case *plan.IsNullExpr:
+		frac := nullFraction(e.Expr, rels)
+		if e.Not {
+			return 1 - frac
+		}
+		return frac
+
+	case *plan.BinaryExpr:
+		switch e.Operator {
+		case "AND":
+			return selectivity(e.Left, rels) * selectivity(e.Right, rels)
+		case "OR":
+			l, r := selectivity(e.Left, rels), selectivity(e.Right, rels)
+			return l + r - l*r
+		case "=":
+			return eqSelectivity(e, rels)
+		case "!=", "<>":
+			return 1 - eqSelectivity(e, rels)
+		case "<", "<=", ">", ">=":
+			return rangeFraction(e, rels)
+		case "LIKE":
+			return likeSelectivity(e, rels)
+		}
+	}
+
+	return otherSelectivity
+}
+
+func eqSelectivity(e *plan.BinaryExpr, rels []relation) float64 {
+	col, ok := e.Left.(*plan.ColumnExpr)
+	if !ok {
+		return defaultEqSelectivity
+	}
+	rel, ok := columnOf(col, rels)
+	if !ok {
+		return defaultEqSelectivity
+	}
+
+	if lit, ok := e.Right.(*plan.LiteralExpr); ok && lit.Value != nil {
+		if d, ok := distributionOf(rel); ok {
+			return d.eq(lit.Value)
+		}
+	}
+
+	return 1 / math.Max(distinctValues(col, rels, tableRows(rel.rel.scan)), 1)
+}
+
+// estimated distinct values of an expression over rows input rows
+func distinctValues(expr plan.Expr, rels []relation, rows float64) float64 {
+	col, ok := expr.(*plan.ColumnExpr)
+	if !ok {
+		return math.Max(rows*defaultEqSelectivity, 1)
+	}
+	jc, ok := columnOf(col, rels)
+	if !ok {
+		return math.Max(rows*defaultEqSelectivity, 1)
+	}
+
+	table := jc.rel.scan.Table
+	if table.IsUnique([]string{col.Column}) {
+		return math.Min(tableRows(jc.rel.scan), math.Max(rows, 1))
+	}
+	if stats := table.Stats(); stats != nil {
+		if ndv, ok := stats.DistinctCount[col.Column]; ok && ndv > 0 {
+			return math.Min(float64(ndv), math.Max(rows, 1))
+		}
+	}
+
+	return math.Max(rows*defaultEqSelectivity, 1)
+}
+
+func nullFraction(expr plan.Expr, rels []relation) float64 {
+	col, ok := expr.(*plan.ColumnExpr)
+	if !ok {
+		return defaultEqSelectivity
+	}
+	jc, ok := columnOf(col, rels)
+	if !ok {
+		return defaultEqSelectivity
+	}
+
+	table := jc.rel.scan.Table
+	if table.IsNotNull(col.Column) {
+		return 0
+	}
+	if stats := table.Stats(); stats != nil && stats.RowCount > 0 {
+		if nulls, ok := stats.NullCount[col.Column]; ok {
+			return float64(nulls) / float64(stats.RowCount)
+		}
+	}
+
+	return defaultEqSelectivity
+}
+
+// reports whether column = constant conjuncts cover a unique key of rel
+func pinsUniqueKey(conjuncts []plan.Expr, rel relation) bool {
+	var cols []string
+	for _, c := range conjuncts {
+		b, ok := c.(*plan.BinaryExpr)
+		if !ok || b.Operator != "=" {
+			continue
+		}
+		col, ok := b.Left.(*plan.ColumnExpr)
+		if _, lit := b.Right.(*plan.LiteralExpr); ok && lit {
+			cols = append(cols, col.Column)
+		}
+	}
+
+	return len(cols) > 0 && rel.scan.Table.IsUnique(cols)
+}
+
+// fraction of a numeric column on the matching side of a constant, by
+// interpolating between the analyzed min and max
+func rangeFraction(e *plan.BinaryExpr, rels []relation) float64 {
+	col, ok := e.Left.(*plan.ColumnExpr)
+	lit, ok2 := e.Right.(*plan.LiteralExpr)
+	if !ok || !ok2 {
+		return rangeSelectivity
+	}
+	jc, ok := columnOf(col, rels)
+	if !ok || jc.rel.scan.Table.Stats() == nil {
+		return rangeSelectivity
+	}
+
+	if d, ok := distributionOf(jc); ok && lit.Value != nil {
+		switch e.Operator {
+		case "<":
+			return d.below(lit.Value, false)
+		case "<=":
+			return d.below(lit.Value, true)
+		case ">":
+			return math.Max(1-d.nullFrac-d.below(lit.Value, true), 0)
+		default:
+			return math.Max(1-d.nullFrac-d.below(lit.Value, false), 0)
+		}
+	}
+
+	stats := jc.rel.scan.Table.Stats()
+	lo, ok1 := numeric(stats.Min[col.Column])
+	hi, ok2 := numeric(stats.Max[col.Column])
+	v, ok3 := numeric(lit.Value)
+	if !ok1 || !ok2 || !ok3 || hi <= lo {
+		return rangeSelectivity
+	}
+
+	below := math.Max(0, math.Min(1, (v-lo)/(hi-lo)))
+	if e.Operator == ">" || e.Operator == ">=" {
+		return 1 - below
+	}
+	return below
+}
+
+func likeSelectivity(e *plan.BinaryExpr, rels []relation) float64 {
+	col, ok := e.Left.(*plan.ColumnExpr)
+	lit, ok2 := e.Right.(*plan.LiteralExpr)
+	if !ok || !ok2 {
+		return otherSelectivity
+	}
+	pattern, ok := lit.Value.(string)
+	if !ok {
+		return otherSelectivity
+	}
+
+	if jc, ok := columnOf(col, rels); ok {
+		if d, ok := distributionOf(jc); ok {
+			return d.like(pattern)
+		}
+	}
+	if function.LikePrefix(pattern) != "" {
+		return defaultEqSelectivity
+	}
+
+	return otherSelectivity
+}
+
+func numeric(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,98 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// table instance in a plan, named by its alias or table name
+type relation struct {
+	name string
+	scan *plan.LogicalScan
+}
+
+// scans reachable through filters and joins
+func relationsOf(node plan.LogicalPlan) []relation {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return []relation{{name: n.QualifiedName(), scan: n}}
+	case *plan.LogicalFilter:
+		return relationsOf(n.Input)
+	case *plan.LogicalJoin:
+		return append(relationsOf(n.Left), relationsOf(n.Right)...)
+	default:
+		return nil
+	}
+}
+
+// relation a column belongs to, unqualified names must be unambiguous
+func resolveColumn(col *plan.ColumnExpr, rels []relation) (string, bool) {
+	if col.Table != "" {
+		for _, rel := range rels {
+			if rel.name == col.Table || (rel.scan.Alias == "" && rel.scan.TableName == col.Table) {
+				return rel.name, true
+			}
+		}
+		return "", false
+	}
+
+	found := ""
+	for _, rel := range rels {
+		if _, err := rel.scan.Table.GetColumn(col.Column); err == nil {
+			if found != "" {
+				return "", false
+			}
+			found = rel.name
+		}
+	}
+
+	return found, found != ""
+}
+
+// relations an expression reads, false when a column cannot be resolved
+func referencedRelations(expr plan.Expr, rels []relation) (map[string]bool, bool) {
+	refs := make(map[string]bool)
+	ok := true
+
+	plan.WalkExpr(expr, func(e plan.Expr) bool {
+		col, isCol := e.(*plan.ColumnExpr)
+		if !isCol {
+			return true
+		}
+
+		name, resolved := resolveColumn(col, rels)
+		if !resolved {
+			ok = false
+		}
+		refs[name] = true
+		return true
+	})
+
+	return refs, ok
+}
+
+// column qualified with its relation, as the executor names it
+func qualifiedColumn(col *plan.ColumnExpr, rels []relation) (*plan.ColumnExpr, bool) {
+	name, ok := resolveColumn(col, rels)
+	if !ok {
+		return nil, false
+	}
+
+	return &plan.ColumnExpr{Table: name, Column: col.Column}, true
+}
+
+func subsetOf(refs map[string]bool, rels []relation) bool {
+	for name := range refs {
+		found := false
+		for _, rel := range rels {
+			if rel.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,46 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// folds IS NULL tests on NOT NULL columns in filters directly over a scan.
+// Higher up an outer join may have padded the column with NULLs
+type ApplyNotNull struct{}
+
+func (r *ApplyNotNull) Name() string { return "ApplyNotNull" }
+
+func (r *ApplyNotNull) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	filter, ok := node.(*plan.LogicalFilter)
+	if !ok {
+		return node
+	}
+	scan, ok := filter.Input.(*plan.LogicalScan)
+	if !ok {
+		return node
+	}
+	rels := relationsOf(scan)
+
+	changed := false
+	pred := plan.TransformExprUp(filter.Predicate, func(e plan.Expr) plan.Expr {
+		isNull, ok := e.(*plan.IsNullExpr)
+		if !ok {
+			return e
+		}
+		col, ok := isNull.Expr.(*plan.ColumnExpr)
+		if !ok {
+			return e
+		}
+		if _, ok := resolveColumn(col, rels); !ok || !scan.Table.IsNotNull(col.Column) {
+			return e
+		}
+
+		changed = true
+		return newLiteral(isNull.Not)
+	})
+	if !changed {
+		return node
+	}
+
+	return &plan.LogicalFilter{Input: scan, Predicate: pred}
+}
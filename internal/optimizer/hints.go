@@ -0,0 +1,381 @@
+package optimizer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// hints of the SELECTs in a plan. Relations are named as the query names
+// them, by alias or table name
+type hints struct {
+	joins      []*joinHint
+	leading    *parser.Hint // relations joined first, in this order
+	access     []*accessHint
+	noPushdown bool // predicates stay where the query wrote them
+
+	warnings []string
+}
+
+// HASH_JOIN, MERGE_JOIN or NL_JOIN, the join that brings the relations
+// together uses the method
+type joinHint struct {
+	hint   *parser.Hint
+	method plan.JoinMethod
+}
+
+// INDEX(t idx) scans t through idx, FULL(t) reads all of it
+type accessHint struct {
+	hint     *parser.Hint
+	relation string
+	index    string
+	honored  bool
+	reason   string
+}
+
+var joinMethods = map[string]plan.JoinMethod{
+	"HASH_JOIN":  plan.HashJoin,
+	"MERGE_JOIN": plan.MergeJoin,
+	"NL_JOIN":    plan.NestedLoopJoin,
+}
+
+// hints the SELECTs of root give, warning about the ones that name
+// nothing in the plan
+func collectHints(root plan.LogicalPlan) *hints {
+	h := &hints{}
+	names := relationNames(root)
+
+	var walk func(plan.LogicalPlan)
+	walk = func(node plan.LogicalPlan) {
+		if project, ok := node.(*plan.LogicalProject); ok {
+			for _, hint := range project.Hints {
+				h.add(hint, names)
+			}
+		}
+		for _, child := range node.Children() {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return h
+}
+
+func (h *hints) add(hint *parser.Hint, names map[string]string) {
+	switch hint.Name {
+	case "HASH_JOIN", "MERGE_JOIN", "NL_JOIN", "LEADING", "INDEX", "FULL", "NO_PUSHDOWN":
+	default:
+		h.warn(hint, "unknown hint")
+		return
+	}
+
+	args := make([]string, len(hint.Args))
+	for i, arg := range hint.Args {
+		args[i] = arg
+		if hint.Name == "INDEX" && i > 0 {
+			continue // the index
+		}
+		if name, ok := names[strings.ToLower(arg)]; ok {
+			args[i] = name
+		} else {
+			h.warn(hint, fmt.Sprintf("no relation is named %s", arg))
+			return
+		}
+	}
+	resolved := &parser.Hint{Name: hint.Name, Args: args}
+
+	switch hint.Name {
+	case "HASH_JOIN", "MERGE_JOIN", "NL_JOIN":
+		if len(args) < 2 {
+			h.warn(hint, "it needs two or more relations")
+			return
+		}
+		h.joins = append(h.joins, &joinHint{hint: resolved, method: joinMethods[hint.Name]})
+
+	case "LEADING":
+		if len(args) < 2 {
+			h.warn(hint, "it needs two or more relations")
+			return
+		}
+		if h.leading != nil {
+			h.warn(hint, fmt.Sprintf("%s came first", h.leading))
+			return
+		}
+		h.leading = resolved
+
+	case "INDEX":
+		if len(args) != 2 {
+			h.warn(hint, "it needs a relation and an index")
+			return
+		}
+		h.access = append(h.access, &accessHint{hint: resolved, relation: args[0], index: args[1]})
+
+	case "FULL":
+		if len(args) != 1 {
+			h.warn(hint, "it needs one relation")
+			return
+		}
+		h.access = append(h.access, &accessHint{hint: resolved, relation: args[0]})
+
+	case "NO_PUSHDOWN":
+		h.noPushdown = true
+	}
+}
+
+func (h *hints) warn(hint *parser.Hint, reason string) {
+	h.warnings = append(h.warnings, fmt.Sprintf("hint %s ignored: %s", hint, reason))
+}
+
+// relations of a plan by lowercased alias or table name, to the name
+// their columns are qualified with
+func relationNames(root plan.LogicalPlan) map[string]string {
+	names := make(map[string]string)
+	var walk func(plan.LogicalPlan)
+	walk = func(node plan.LogicalPlan) {
+		switch n := node.(type) {
+		case *plan.LogicalScan:
+			names[strings.ToLower(n.QualifiedName())] = n.QualifiedName()
+			if _, ok := names[strings.ToLower(n.TableName)]; !ok {
+				names[strings.ToLower(n.TableName)] = n.QualifiedName()
+			}
+		case *plan.LogicalView:
+			names[strings.ToLower(n.QualifiedName())] = n.QualifiedName()
+			return
+		}
+		for _, child := range node.Children() {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return names
+}
+
+// names of the relations a plan reads, views count as one
+func scanNames(node plan.LogicalPlan) []string {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return []string{n.QualifiedName()}
+	case *plan.LogicalView:
+		return []string{n.QualifiedName()}
+	}
+
+	var names []string
+	for _, child := range node.Children() {
+		names = append(names, scanNames(child)...)
+	}
+	return names
+}
+
+// whether a join of left and right brings names together, with some of
+// them on each side
+func splits(names, left, right []string) bool {
+	inLeft, inRight := false, false
+	for _, name := range names {
+		switch {
+		case slices.Contains(left, name):
+			inLeft = true
+		case slices.Contains(right, name):
+			inRight = true
+		default:
+			return false
+		}
+	}
+	return inLeft && inRight
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// method a hint asks of the join of left and right
+func (h *hints) method(left, right []string) (plan.JoinMethod, bool) {
+	if h == nil {
+		return 0, false
+	}
+	for _, jh := range h.joins {
+		if splits(jh.hint.Args, left, right) {
+			return jh.method, true
+		}
+	}
+	return 0, false
+}
+
+// whether the join of left and right fits LEADING: the leading relations
+// are joined to each other first, in order, and stay on the left of
+// later joins
+func (h *hints) leads(left, right []string) bool {
+	if h == nil || h.leading == nil {
+		return true
+	}
+	leading := h.leading.Args
+
+	in := 0
+	for _, name := range leading {
+		if slices.Contains(left, name) || slices.Contains(right, name) {
+			in++
+		}
+	}
+	all := len(left) + len(right)
+
+	switch {
+	case in == 0:
+		return true
+	case in == len(leading) && all > in:
+		return subset(leading, left)
+	default:
+		// this join builds a prefix of them
+		return all == in && sameSet(left, leading[:in-1]) && sameSet(right, leading[in-1:in])
+	}
+}
+
+func subset(names, of []string) bool {
+	for _, name := range names {
+		if !slices.Contains(of, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// hints without LEADING, join methods alone always leave a plan
+func (h *hints) withoutLeading() *hints {
+	if h == nil || h.leading == nil {
+		return nil
+	}
+	c := *h
+	c.leading = nil
+	return &c
+}
+
+// warnings for join hints the chosen plan doesn't follow
+func (h *hints) check(root plan.LogicalPlan) {
+	var joins []*plan.LogicalJoin
+	var walk func(plan.LogicalPlan)
+	walk = func(node plan.LogicalPlan) {
+		for _, child := range node.Children() {
+			walk(child)
+		}
+		if j, ok := node.(*plan.LogicalJoin); ok {
+			joins = append(joins, j)
+		}
+	}
+	walk(root)
+
+	// lowest join first
+	for _, jh := range h.joins {
+		var found *plan.LogicalJoin
+		for _, j := range joins {
+			if splits(jh.hint.Args, scanNames(j.Left), scanNames(j.Right)) {
+				found = j
+				break
+			}
+		}
+		switch {
+		case found == nil:
+			h.warnings = append(h.warnings, fmt.Sprintf("hint %s can't be honored: no join brings them together", jh.hint))
+		case found.Method != jh.method:
+			h.warnings = append(h.warnings, fmt.Sprintf("hint %s can't be honored: the join on %s runs as %s", jh.hint, found.Condition, found.Method))
+		}
+	}
+
+	if h.leading != nil && !slices.ContainsFunc(joins, h.leftDeep) {
+		h.warnings = append(h.warnings, fmt.Sprintf("hint %s can't be honored: the joins can't take that order", h.leading))
+	}
+
+	for _, ah := range h.access {
+		if !ah.honored {
+			reason := ah.reason
+			if reason == "" {
+				reason = fmt.Sprintf("%s isn't a table scan", ah.relation)
+			}
+			h.warnings = append(h.warnings, fmt.Sprintf("hint %s can't be honored: %s", ah.hint, reason))
+		}
+	}
+}
+
+// whether join joins the leading relations, one at a time in order
+func (h *hints) leftDeep(join *plan.LogicalJoin) bool {
+	leading := h.leading.Args
+	var node plan.LogicalPlan = join
+	for k := len(leading); k > 1; k-- {
+		// filters and sorts keep the join below
+		for {
+			if _, ok := node.(*plan.LogicalFilter); !ok {
+				if _, ok := node.(*plan.LogicalSort); !ok {
+					break
+				}
+			}
+			node = node.Children()[0]
+		}
+		j, ok := node.(*plan.LogicalJoin)
+		if !ok || !sameSet(scanNames(j.Left), leading[:k-1]) || !sameSet(scanNames(j.Right), leading[k-1:k]) {
+			return false
+		}
+		node = j.Left
+	}
+	return true
+}
+
+// scans the access hints name get the index they ask for, when its
+// columns are pinned with =, or no index
+type ApplyAccessHints struct {
+	hints *hints
+}
+
+func (r *ApplyAccessHints) Name() string { return "ApplyAccessHints" }
+
+func (r *ApplyAccessHints) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	scan, ok := node.(*plan.LogicalScan)
+	if !ok || scan.FromView {
+		return node
+	}
+
+	for _, ah := range r.hints.access {
+		if ah.relation != scan.QualifiedName() {
+			continue
+		}
+
+		c := *scan
+		if ah.index == "" {
+			c.Index = ""
+			ah.honored = true
+			return &c
+		}
+
+		idx := slices.IndexFunc(scan.Table.Indexes, func(i catalog.Index) bool { return strings.EqualFold(i.Name, ah.index) })
+		if idx < 0 {
+			ah.reason = fmt.Sprintf("%s has no index %s", scan.TableName, ah.index)
+			continue
+		}
+		if r.hints.noPushdown {
+			ah.reason = "NO_PUSHDOWN keeps predicates out of scans"
+			return node
+		}
+		index := scan.Table.Indexes[idx]
+		for _, col := range index.Columns {
+			if !slices.ContainsFunc(scan.Pushed, func(p storage.Predicate) bool { return p.Column == col && p.Op == "=" }) {
+				ah.reason = fmt.Sprintf("no equality on %s.%s to look up", ah.relation, col)
+				return node
+			}
+		}
+		c.Index = index.Name
+		ah.honored = true
+		return &c
+	}
+
+	return node
+}
@@ -0,0 +1,165 @@
+package optimizer
+
+import (
+	"math"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+)
+
+// value distribution of a column from the most common values and histogram
+// ANALYZE collected. Fractions are of all rows of the table
+type distribution struct {
+	nullFrac float64
+	ndv      float64
+	mcv      []catalog.ValueFrequency
+	hist     *catalog.Histogram
+}
+
+func distributionOf(jc joinColumn) (*distribution, bool) {
+	stats := jc.rel.scan.Table.Stats()
+	if stats == nil || stats.RowCount == 0 {
+		return nil, false
+	}
+
+	name := jc.col.Column
+	d := &distribution{
+		nullFrac: float64(stats.NullCount[name]) / float64(stats.RowCount),
+		ndv:      float64(stats.DistinctCount[name]),
+		mcv:      stats.MostCommon[name],
+		hist:     stats.Histograms[name],
+	}
+	if len(d.mcv) == 0 && d.hist == nil {
+		return nil, false
+	}
+
+	return d, true
+}
+
+// fraction of rows that are neither NULL nor a most common value
+func (d *distribution) rest() float64 {
+	frac := 1 - d.nullFrac
+	for _, m := range d.mcv {
+		frac -= m.Frequency
+	}
+
+	return math.Max(frac, 0)
+}
+
+func (d *distribution) restDistinct() float64 {
+	return math.Max(d.ndv-float64(len(d.mcv)), 1)
+}
+
+// fraction of rows equal to v
+func (d *distribution) eq(v interface{}) float64 {
+	for _, m := range d.mcv {
+		if function.Equal(m.Value, v) {
+			return m.Frequency
+		}
+	}
+
+	return d.eqRest(v)
+}
+
+// fraction of rows equal to v, for a v that is not a most common value
+func (d *distribution) eqRest(v interface{}) float64 {
+	if d.hist != nil && !d.hist.Covers(v) {
+		return 0
+	}
+
+	return d.rest() / d.restDistinct()
+}
+
+// fraction of rows below v, or at most v when inclusive
+func (d *distribution) below(v interface{}, inclusive bool) float64 {
+	frac := 0.0
+	for _, m := range d.mcv {
+		if c := function.Compare(m.Value, v); c < 0 || (c == 0 && inclusive) {
+			frac += m.Frequency
+		}
+	}
+
+	histFrac := 0.5
+	if d.hist != nil {
+		histFrac = d.hist.Below(v, inclusive)
+	}
+
+	return frac + d.rest()*histFrac
+}
+
+// fraction of rows matching a LIKE pattern, the rest of the column is
+// estimated from the range of strings starting with the pattern's prefix
+func (d *distribution) like(pattern string) float64 {
+	frac := 0.0
+	for _, m := range d.mcv {
+		if s, ok := m.Value.(string); ok && function.Like(s, pattern) {
+			frac += m.Frequency
+		}
+	}
+
+	prefix := function.LikePrefix(pattern)
+	if prefix == "" {
+		return frac + d.rest()*otherSelectivity
+	}
+	if prefix == pattern {
+		return frac + d.eqRest(prefix)
+	}
+	if d.hist == nil {
+		return frac + d.rest()*defaultEqSelectivity
+	}
+
+	inRange := d.hist.Below(prefixEnd(prefix), false) - d.hist.Below(prefix, false)
+	return frac + d.rest()*inRange
+}
+
+// smallest string above every string starting with prefix
+func prefixEnd(prefix string) string {
+	runes := []rune(prefix)
+	runes[len(runes)-1]++
+
+	return string(runes)
+}
+
+// fraction of row pairs that join on equal values. Most common values are
+// matched exactly, the rest assumes uniform values over the overlap of the
+// two histograms
+func joinSelectivity(a, b *distribution) float64 {
+	sel := 0.0
+	matchedB := make([]bool, len(b.mcv))
+
+	for _, ma := range a.mcv {
+		found := false
+		for j, mb := range b.mcv {
+			if function.Equal(ma.Value, mb.Value) {
+				sel += ma.Frequency * mb.Frequency
+				matchedB[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			sel += ma.Frequency * b.eqRest(ma.Value)
+		}
+	}
+	for j, mb := range b.mcv {
+		if !matchedB[j] {
+			sel += mb.Frequency * a.eqRest(mb.Value)
+		}
+	}
+
+	overlapA, overlapB := overlap(a.hist, b.hist), overlap(b.hist, a.hist)
+	distinct := math.Max(a.restDistinct()*overlapA, b.restDistinct()*overlapB)
+	sel += a.rest() * overlapA * b.rest() * overlapB / math.Max(distinct, 1)
+
+	return sel
+}
+
+// fraction of h's rows inside the range other covers
+func overlap(h, other *catalog.Histogram) float64 {
+	if h == nil || other == nil || len(h.Bounds) < 2 || len(other.Bounds) < 2 {
+		return 1
+	}
+
+	lo, hi := other.Bounds[0], other.Bounds[len(other.Bounds)-1]
+	return math.Max(h.Below(hi, true)-h.Below(lo, false), 0)
+}
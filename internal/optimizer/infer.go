@@ -0,0 +1,219 @@
+package optimizer
+
+import (
+	"sort"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// derives predicates implied by equalities across inner joins. With
+// users.id = orders.user_id AND users.id = 5 it adds orders.user_id = 5,
+// which PushDownPredicates can then move below the join
+type InferPredicates struct{}
+
+func (r *InferPredicates) Name() string { return "InferPredicates" }
+
+func (r *InferPredicates) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		if !joinBelow(n.Input) {
+			return node
+		}
+
+		rels := relationsOf(n.Input)
+		conjuncts := qualifyAll(plan.SplitConjuncts(n.Predicate), rels)
+		below := qualifyAll(innerConditions(n.Input), rels)
+		derived := inferEqualities(append(append([]plan.Expr{}, conjuncts...), below...), rels)
+
+		return &plan.LogicalFilter{Input: n.Input, Predicate: plan.CombineConjuncts(append(conjuncts, derived...))}
+
+	case *plan.LogicalJoin:
+		if n.JoinType != plan.InnerJoin {
+			return node
+		}
+
+		rels := relationsOf(n)
+		conjuncts := qualifyAll(plan.SplitConjuncts(n.Condition), rels)
+		below := qualifyAll(append(innerConditions(n.Left), innerConditions(n.Right)...), rels)
+		derived := inferEqualities(append(append([]plan.Expr{}, conjuncts...), below...), rels)
+
+		out := *n
+		out.Condition = plan.CombineConjuncts(append(conjuncts, derived...))
+		return &out
+	}
+
+	return node
+}
+
+func joinBelow(node plan.LogicalPlan) bool {
+	switch n := node.(type) {
+	case *plan.LogicalJoin:
+		return true
+	case *plan.LogicalFilter:
+		return joinBelow(n.Input)
+	default:
+		return false
+	}
+}
+
+// conjuncts of filters and inner join conditions below node, outer joins
+// are not crossed since their conditions do not hold for padded rows
+func innerConditions(node plan.LogicalPlan) []plan.Expr {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		return append(plan.SplitConjuncts(n.Predicate), innerConditions(n.Input)...)
+	case *plan.LogicalJoin:
+		if n.JoinType != plan.InnerJoin {
+			return nil
+		}
+		conds := append(plan.SplitConjuncts(n.Condition), innerConditions(n.Left)...)
+		return append(conds, innerConditions(n.Right)...)
+	default:
+		return nil
+	}
+}
+
+// qualifies column references so the same column always prints the same
+func qualifyAll(conjuncts []plan.Expr, rels []relation) []plan.Expr {
+	out := make([]plan.Expr, 0, len(conjuncts))
+	for _, c := range conjuncts {
+		out = append(out, plan.TransformExprUp(c, func(e plan.Expr) plan.Expr {
+			if col, ok := e.(*plan.ColumnExpr); ok {
+				if q, ok := qualifiedColumn(col, rels); ok {
+					return q
+				}
+			}
+			return e
+		}))
+	}
+
+	return out
+}
+
+// equality predicates implied by conjuncts and not already among them
+func inferEqualities(conjuncts []plan.Expr, rels []relation) []plan.Expr {
+	classes := newEquivalence()
+	constants := make(map[string]*plan.LiteralExpr)
+	columns := make(map[string]*plan.ColumnExpr)
+
+	for _, c := range conjuncts {
+		b, ok := c.(*plan.BinaryExpr)
+		if !ok || b.Operator != "=" {
+			continue
+		}
+		left, ok := b.Left.(*plan.ColumnExpr)
+		if !ok {
+			continue
+		}
+		left, ok = qualifiedColumn(left, rels)
+		if !ok {
+			continue
+		}
+		columns[left.String()] = left
+
+		switch right := b.Right.(type) {
+		case *plan.ColumnExpr:
+			if q, ok := qualifiedColumn(right, rels); ok {
+				columns[q.String()] = q
+				classes.union(left.String(), q.String())
+			}
+		case *plan.LiteralExpr:
+			if right.Value != nil {
+				constants[left.String()] = right
+			}
+		}
+	}
+
+	var derived []plan.Expr
+	for _, members := range classes.groups() {
+		// a constant on one member holds for every member
+		var constant *plan.LiteralExpr
+		for _, m := range members {
+			if lit, ok := constants[m]; ok {
+				constant = lit
+				break
+			}
+		}
+
+		for i, m := range members {
+			if constant != nil {
+				derived = append(derived, &plan.BinaryExpr{Left: columns[m], Operator: "=", Right: constant})
+				continue
+			}
+			for _, other := range members[i+1:] {
+				derived = append(derived, &plan.BinaryExpr{Left: columns[m], Operator: "=", Right: columns[other]})
+			}
+		}
+	}
+
+	return dedupe(derived, conjuncts)
+}
+
+// exprs that are not in existing, compared by printed form either way round
+func dedupe(exprs, existing []plan.Expr) []plan.Expr {
+	seen := make(map[string]bool)
+	for _, e := range existing {
+		seen[e.String()] = true
+		if b, ok := e.(*plan.BinaryExpr); ok && b.Operator == "=" {
+			seen[(&plan.BinaryExpr{Left: b.Right, Operator: "=", Right: b.Left}).String()] = true
+		}
+	}
+
+	var out []plan.Expr
+	for _, e := range exprs {
+		if !seen[e.String()] {
+			seen[e.String()] = true
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// union find over qualified column names
+type equivalence struct {
+	parent map[string]string
+}
+
+func newEquivalence() *equivalence {
+	return &equivalence{parent: make(map[string]string)}
+}
+
+func (e *equivalence) find(x string) string {
+	if _, ok := e.parent[x]; !ok {
+		e.parent[x] = x
+	}
+	for e.parent[x] != x {
+		e.parent[x] = e.parent[e.parent[x]]
+		x = e.parent[x]
+	}
+
+	return x
+}
+
+func (e *equivalence) union(a, b string) {
+	ra, rb := e.find(a), e.find(b)
+	if ra != rb {
+		e.parent[rb] = ra
+	}
+}
+
+// classes with more than one column, sorted so output is stable
+func (e *equivalence) groups() [][]string {
+	byRoot := make(map[string][]string)
+	for x := range e.parent {
+		root := e.find(x)
+		byRoot[root] = append(byRoot[root], x)
+	}
+
+	var out [][]string
+	for _, members := range byRoot {
+		if len(members) > 1 {
+			sort.Strings(members)
+			out = append(out, members)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+
+	return out
+}
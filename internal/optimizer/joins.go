@@ -0,0 +1,320 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// turns outer joins into inner joins when a predicate above them rejects
+// the NULL padded rows, like WHERE orders.amount > 10 over users LEFT JOIN
+// orders. The inner join can then take part in predicate pushdown
+type SimplifyOuterJoins struct{}
+
+func (r *SimplifyOuterJoins) Name() string { return "SimplifyOuterJoins" }
+
+func (r *SimplifyOuterJoins) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		input := simplifyOuter(n.Input, plan.SplitConjuncts(n.Predicate))
+		if input != n.Input {
+			return &plan.LogicalFilter{Input: input, Predicate: n.Predicate}
+		}
+	case *plan.LogicalJoin:
+		if n.JoinType == plan.InnerJoin {
+			return simplifyOuter(n, nil)
+		}
+	}
+
+	return node
+}
+
+// rewrites outer joins below node that preds reject NULLs for
+func simplifyOuter(node plan.LogicalPlan, preds []plan.Expr) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		input := simplifyOuter(n.Input, append(preds, plan.SplitConjuncts(n.Predicate)...))
+		if input == n.Input {
+			return node
+		}
+		return &plan.LogicalFilter{Input: input, Predicate: n.Predicate}
+
+	case *plan.LogicalJoin:
+		out := *n
+		switch {
+		case n.JoinType == plan.LeftJoin && rejectsNulls(preds, relationsOf(n.Right)):
+			out.JoinType = plan.InnerJoin
+		case n.JoinType == plan.RightJoin && rejectsNulls(preds, relationsOf(n.Left)):
+			out.JoinType = plan.InnerJoin
+		}
+
+		// predicates keep applying to the side that is never padded, an
+		// inner join also filters with its own condition
+		leftPreds, rightPreds := preds, preds
+		switch out.JoinType {
+		case plan.InnerJoin:
+			conds := append(append([]plan.Expr{}, preds...), plan.SplitConjuncts(out.Condition)...)
+			leftPreds, rightPreds = conds, conds
+		case plan.LeftJoin:
+			rightPreds = nil
+		case plan.RightJoin:
+			leftPreds = nil
+		}
+
+		out.Left = simplifyOuter(n.Left, leftPreds)
+		out.Right = simplifyOuter(n.Right, rightPreds)
+		if out.Left == n.Left && out.Right == n.Right && out.JoinType == n.JoinType {
+			return node
+		}
+		return &out
+
+	default:
+		return node
+	}
+}
+
+// reports whether some conjunct is NULL or FALSE whenever every column of
+// rels is NULL. Found by substituting NULL and folding the conjunct
+func rejectsNulls(preds []plan.Expr, rels []relation) bool {
+	for _, pred := range preds {
+		refs, ok := referencedRelations(pred, rels)
+		if !ok || len(refs) == 0 || !deterministic(pred) {
+			continue
+		}
+
+		// columns of other relations do not resolve against rels
+		nulled := plan.TransformExprUp(pred, func(e plan.Expr) plan.Expr {
+			if col, ok := e.(*plan.ColumnExpr); ok {
+				if _, ok := resolveColumn(col, rels); ok {
+					return newLiteral(nil)
+				}
+			}
+			return e
+		})
+
+		if lit, ok := SimplifyExpr(nulled).(*plan.LiteralExpr); ok && (lit.Value == nil || lit.Value == false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// drops a LEFT JOIN when nothing above reads the right side and the join
+// condition matches at most one right row, each left row then comes out
+// exactly once either way. An inner join along a foreign key to an
+// unfiltered table is dropped the same way, every referencing row has
+// exactly one match
+type EliminateJoins struct{}
+
+func (r *EliminateJoins) Name() string { return "EliminateJoins" }
+
+func (r *EliminateJoins) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	if project, ok := node.(*plan.LogicalProject); ok {
+		input := eliminateJoins(project.Input, project.Projections)
+		if input != project.Input {
+			out := *project
+			out.Input = input
+			return &out
+		}
+	}
+
+	return node
+}
+
+// used holds every expression evaluated above node
+func eliminateJoins(node plan.LogicalPlan, used []plan.Expr) plan.LogicalPlan {
+	if join, ok := node.(*plan.LogicalJoin); ok {
+		all := relationsOf(join)
+
+		switch join.JoinType {
+		case plan.LeftJoin:
+			if !readsRelations(used, all, relationsOf(join.Right)) && uniqueMatch(join) {
+				return eliminateJoins(join.Left, used)
+			}
+		case plan.InnerJoin:
+			if !readsRelations(used, all, relationsOf(join.Right)) {
+				if kept, ok := foreignKeyJoin(join.Left, join.Right, join.Condition); ok {
+					return eliminateJoins(kept, used)
+				}
+			}
+			if !readsRelations(used, all, relationsOf(join.Left)) {
+				if kept, ok := foreignKeyJoin(join.Right, join.Left, join.Condition); ok {
+					return eliminateJoins(kept, used)
+				}
+			}
+		}
+	}
+
+	children := node.Children()
+	if len(children) == 0 {
+		return node
+	}
+
+	used = append(append([]plan.Expr{}, used...), nodeExprs(node)...)
+	rewritten := make([]plan.LogicalPlan, len(children))
+	changed := false
+	for i, child := range children {
+		rewritten[i] = eliminateJoins(child, used)
+		changed = changed || rewritten[i] != child
+	}
+	if !changed {
+		return node
+	}
+
+	return plan.WithChildren(node, rewritten)
+}
+
+// reports whether exprs may read a column of target. Columns that do not
+// resolve count as read when target has a column of that name
+func readsRelations(exprs []plan.Expr, all, target []relation) bool {
+	for _, expr := range exprs {
+		read := plan.ContainsExpr(expr, func(e plan.Expr) bool {
+			switch e := e.(type) {
+			case *plan.StarExpr:
+				return true
+			case *plan.ColumnExpr:
+				if name, ok := resolveColumn(e, all); ok {
+					return subsetOf(map[string]bool{name: true}, target)
+				}
+				if e.Table != "" {
+					return false
+				}
+				_, ok := resolveColumn(e, target)
+				return ok
+			}
+			return false
+		})
+		if read {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reports whether the join condition pins a unique key of a single table
+// on the right, so a left row matches at most one right row
+func uniqueMatch(join *plan.LogicalJoin) bool {
+	rightRels := relationsOf(join.Right)
+	if len(rightRels) != 1 || !onlyFilters(join.Right) {
+		return false
+	}
+	rel := rightRels[0]
+	leftRels := relationsOf(join.Left)
+	all := append(append([]relation{}, leftRels...), rel)
+
+	var keyCols []string
+	for _, conjunct := range plan.SplitConjuncts(join.Condition) {
+		b, ok := conjunct.(*plan.BinaryExpr)
+		if !ok || b.Operator != "=" {
+			continue
+		}
+
+		for _, pair := range [][2]plan.Expr{{b.Left, b.Right}, {b.Right, b.Left}} {
+			col, ok := pair[0].(*plan.ColumnExpr)
+			if !ok {
+				continue
+			}
+			if name, ok := resolveColumn(col, all); !ok || name != rel.name {
+				continue
+			}
+
+			// the other side must not depend on the right table
+			refs, ok := referencedRelations(pair[1], all)
+			if ok && !refs[rel.name] && deterministic(pair[1]) {
+				keyCols = append(keyCols, col.Column)
+			}
+		}
+	}
+
+	return rel.scan.Table.IsUnique(keyCols)
+}
+
+// child with the join to parent removed, when the condition is exactly a
+// foreign key of child to an unfiltered parent table. Rows with a NULL key
+// match nothing, so a nullable key keeps an IS NOT NULL filter
+func foreignKeyJoin(child, parent plan.LogicalPlan, cond plan.Expr) (plan.LogicalPlan, bool) {
+	parentScan, ok := parent.(*plan.LogicalScan)
+	if !ok {
+		return nil, false
+	}
+	childRels, parentRels := relationsOf(child), relationsOf(parent)
+
+	var childCol *joinColumn
+	pairs := make(map[string]string)
+	for _, conjunct := range plan.SplitConjuncts(cond) {
+		c, p, ok := equiJoinColumns(conjunct, childRels, parentRels)
+		if !ok || (childCol != nil && childCol.rel.name != c.rel.name) {
+			return nil, false
+		}
+		childCol = &c
+		pairs[c.col.Column] = p.col.Column
+	}
+	if childCol == nil {
+		return nil, false
+	}
+
+	table := childCol.rel.scan.Table
+	cols := make([]string, 0, len(pairs))
+	for col := range pairs {
+		cols = append(cols, col)
+	}
+	fk, ok := table.ForeignKeyTo(cols, parentScan.TableName)
+	if !ok {
+		return nil, false
+	}
+
+	var nullable []plan.Expr
+	for i, col := range fk.Columns {
+		if pairs[col] != fk.RefColumns[i] {
+			return nil, false
+		}
+		if !table.IsNotNull(col) {
+			column := &plan.ColumnExpr{Table: childCol.rel.name, Column: col}
+			nullable = append(nullable, &plan.IsNullExpr{Expr: column, Not: true})
+		}
+	}
+
+	return withFilter(child, nullable), true
+}
+
+func onlyFilters(node plan.LogicalPlan) bool {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return true
+	case *plan.LogicalFilter:
+		return onlyFilters(n.Input)
+	default:
+		return false
+	}
+}
+
+// expressions a node evaluates
+func nodeExprs(node plan.LogicalPlan) []plan.Expr {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		return []plan.Expr{n.Predicate}
+	case *plan.LogicalProject:
+		return n.Projections
+	case *plan.LogicalJoin:
+		return []plan.Expr{n.Condition}
+	case *plan.LogicalUnnest:
+		if n.Condition != nil {
+			return []plan.Expr{n.Expr, n.Condition}
+		}
+		return []plan.Expr{n.Expr}
+	case *plan.LogicalAggregate:
+		exprs := append([]plan.Expr{}, n.GroupBy...)
+		for _, agg := range n.Aggregates {
+			exprs = append(exprs, agg)
+		}
+		return exprs
+	case *plan.LogicalWindow:
+		var exprs []plan.Expr
+		for _, fn := range n.Functions {
+			exprs = append(exprs, fn)
+		}
+		return exprs
+	default:
+		return nil
+	}
+}
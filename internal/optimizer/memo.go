@@ -0,0 +1,621 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// exploration stops adding expressions past this many
+const maxExprs = 2000
+
+// cascades style search over the rewritten plan. The memo holds groups of
+// equivalent expressions, each an operator over child groups instead of
+// over plans. Transformation rules add expressions to groups until nothing
+// new comes up, then each expression is implemented every way the executor
+// can run it and costed bottom up for the order its parent needs.
+// Alternatives that can't beat the cheapest plan found so far are pruned
+type memo struct {
+	groups    []*group
+	exprs     map[string]*groupExpr // by fingerprint, each expression is added once
+	joins     map[string]*group     // inner join groups by the relations they join
+	functions *function.Registry
+
+	trace  *Trace
+	firing *firing // rule adding expressions, when tracing
+	hints  *hints  // join methods and order the query asks for
+}
+
+// equivalent expressions, all producing the same rows
+type group struct {
+	id       int
+	exprs    []*groupExpr
+	plan     plan.LogicalPlan // built from the first expression, for estimates
+	rows     float64
+	rels     []relation
+	block    bool     // joins and filters over scans
+	scans    []string // every relation below, for hints
+	explored bool
+	winners  map[string]*winner // by required order
+}
+
+// operator over child groups, the node's own children are ignored
+type groupExpr struct {
+	node     plan.LogicalPlan
+	children []*group
+	group    *group
+	fixed    bool // join whose inputs can't be reordered
+
+	keyed               bool // equi join keys found, when there are any
+	leftKeys, rightKeys []plan.Expr
+}
+
+// cheapest plan of a group for a required order. A nil plan records that
+// nothing cheaper than bound exists
+type winner struct {
+	plan  plan.LogicalPlan
+	cost  float64
+	bound float64
+}
+
+// stands for an existing group in trees built by rules
+type groupRef struct {
+	group *group
+}
+
+func (r *groupRef) Children() []plan.LogicalPlan { return nil }
+func (r *groupRef) Schema() []catalog.Column     { return r.group.plan.Schema() }
+func (r *groupRef) String() string               { return fmt.Sprintf("Group(%d)", r.group.id) }
+
+func newMemo(functions *function.Registry) *memo {
+	return &memo{
+		exprs:     make(map[string]*groupExpr),
+		joins:     make(map[string]*group),
+		functions: functions,
+	}
+}
+
+// cheapest plan for root, or root itself when the search finds nothing
+func (m *memo) search(root plan.LogicalPlan) plan.LogicalPlan {
+	g := m.insert(root, false)
+	m.explore(g)
+
+	if w := m.optimize(g, nil, math.Inf(1)); w != nil {
+		return w.plan
+	}
+
+	// the order hinted ruled out every plan, search without it
+	if m.hints = m.hints.withoutLeading(); m.hints != nil {
+		for _, g := range m.groups {
+			g.winners = make(map[string]*winner)
+		}
+		if w := m.optimize(g, nil, math.Inf(1)); w != nil {
+			return w.plan
+		}
+	}
+	return root
+}
+
+// adds a plan, children first. reorder says whether joins at the top of
+// node may be reordered, which depends on the nodes above
+func (m *memo) insert(node plan.LogicalPlan, reorder bool) *group {
+	return m.insertInto(node, reorder, nil)
+}
+
+func (m *memo) insertInto(node plan.LogicalPlan, reorder bool, into *group) *group {
+	if ref, ok := node.(*groupRef); ok {
+		return ref.group
+	}
+
+	node = qualifyColumns(node)
+	e := &groupExpr{node: node}
+	if expandable(node) {
+		below := reorder
+		switch node.(type) {
+		case *plan.LogicalProject, *plan.LogicalAggregate:
+			below = true
+		case *plan.LogicalFilter, *plan.LogicalJoin:
+		default:
+			below = false
+		}
+
+		for _, child := range node.Children() {
+			e.children = append(e.children, m.insert(child, below))
+		}
+	}
+	if _, ok := node.(*plan.LogicalJoin); ok {
+		e.fixed = !reorder || !joinBlock(node)
+	}
+
+	return m.add(e, into)
+}
+
+// node types the memo looks through, others are kept whole
+func expandable(node plan.LogicalPlan) bool {
+	switch node.(type) {
+	case *plan.LogicalFilter, *plan.LogicalProject, *plan.LogicalAggregate,
+		*plan.LogicalJoin, *plan.LogicalWindow, *plan.LogicalUnnest,
+		*plan.LogicalInsert, *plan.LogicalView, *plan.LogicalRefresh, *plan.LogicalCreateView:
+		return true
+	default:
+		return false
+	}
+}
+
+// joins and filters over scans only, every column a row holds belongs
+// to one of its relations
+func joinBlock(node plan.LogicalPlan) bool {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return true
+	case *plan.LogicalFilter:
+		return joinBlock(n.Input)
+	case *plan.LogicalJoin:
+		return joinBlock(n.Left) && joinBlock(n.Right)
+	default:
+		return false
+	}
+}
+
+// adds an expression to into, or to the group it belongs to. Returns the
+// group of an equal expression already in the memo
+func (m *memo) add(e *groupExpr, into *group) *group {
+	key := fingerprint(e)
+	if old, ok := m.exprs[key]; ok {
+		return old.group
+	}
+
+	g := into
+	joined := joinKey(e)
+	if g == nil && joined != "" {
+		g = m.joins[joined]
+	}
+	if g == nil {
+		g = &group{id: len(m.groups), winners: make(map[string]*winner)}
+		g.plan = e.node
+		if len(e.children) > 0 {
+			children := make([]plan.LogicalPlan, len(e.children))
+			for i, child := range e.children {
+				children[i] = child.plan
+			}
+			g.plan = plan.WithChildren(e.node, children)
+		}
+		g.rows = EstimateRows(g.plan)
+		g.rels, g.block, g.scans = relationsOf(g.plan), joinBlock(g.plan), scanNames(g.plan)
+		m.groups = append(m.groups, g)
+	}
+	if joined != "" && m.joins[joined] == nil {
+		m.joins[joined] = g
+	}
+
+	e.group = g
+	g.exprs = append(g.exprs, e)
+	m.exprs[key] = e
+	if m.trace != nil && m.firing != nil {
+		m.trace.add(TraceEvent{Kind: RuleApplied, Rule: m.firing.rule, Group: g.id, Before: m.firing.source.String(), After: e.String()})
+	}
+
+	// rules already ran on the group, run them on the newcomer
+	if g.explored {
+		m.exploreExpr(e)
+	}
+	return g
+}
+
+// identity of an expression, joins by their conjuncts in any order
+func fingerprint(e *groupExpr) string {
+	ids := make([]string, len(e.children))
+	for i, child := range e.children {
+		ids[i] = fmt.Sprint(child.id)
+	}
+	children := strings.Join(ids, ",")
+
+	if join, ok := e.node.(*plan.LogicalJoin); ok {
+		conjuncts := make([]string, 0)
+		for _, c := range plan.SplitConjuncts(join.Condition) {
+			conjuncts = append(conjuncts, c.String())
+		}
+		sort.Strings(conjuncts)
+		return fmt.Sprintf("join %d %t [%s] (%s)", join.JoinType, e.fixed, strings.Join(conjuncts, " AND "), children)
+	}
+
+	return fmt.Sprintf("%p (%s)", e.node, children)
+}
+
+// relations a reorderable inner join produces, joins of the same
+// relations are equivalent whatever their order
+func joinKey(e *groupExpr) string {
+	join, ok := e.node.(*plan.LogicalJoin)
+	if !ok || join.JoinType != plan.InnerJoin || e.fixed {
+		return ""
+	}
+
+	var names []string
+	for _, child := range e.children {
+		if !child.block {
+			return ""
+		}
+		for _, rel := range child.rels {
+			names = append(names, rel.name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// applies transformation rules until the group has every expression they
+// produce
+func (m *memo) explore(g *group) {
+	if g.explored {
+		return
+	}
+
+	// rules append to exprs while it is walked
+	for i := 0; i < len(g.exprs); i++ {
+		m.exploreExpr(g.exprs[i])
+	}
+	g.explored = true
+}
+
+func (m *memo) exploreExpr(e *groupExpr) {
+	for _, child := range e.children {
+		m.explore(child)
+	}
+	if len(m.exprs) >= maxExprs {
+		return
+	}
+
+	for _, rule := range transformations {
+		prev := m.firing
+		m.firing = &firing{rule: rule.name(), source: e}
+		rule.apply(m, e)
+		m.firing = prev
+	}
+}
+
+// one way to run an expression, the node gets its children once their
+// plans are chosen
+type physical struct {
+	node    plan.LogicalPlan
+	require [][]plan.Expr // order each child must arrive in
+}
+
+// cheapest plan producing g's rows in order, nil when none costs less
+// than bound
+func (m *memo) optimize(g *group, order []plan.Expr, bound float64) *winner {
+	key := orderKey(order)
+	if w, ok := g.winners[key]; ok {
+		if w.plan != nil {
+			if w.cost < bound {
+				return w
+			}
+			return nil
+		}
+		if bound <= w.bound {
+			return nil
+		}
+	}
+
+	best := &winner{cost: bound, bound: bound}
+	for _, e := range g.exprs {
+		if join, ok := e.node.(*plan.LogicalJoin); ok && join.JoinType == plan.InnerJoin && !e.fixed &&
+			!m.hints.leads(e.children[0].scans, e.children[1].scans) {
+			continue
+		}
+		for _, alt := range m.implementations(e, order) {
+			m.consider(e, alt, order, best)
+		}
+	}
+
+	// or whatever is cheapest in any order, sorted
+	if len(order) > 0 {
+		sorting := sortCost(g.rows)
+		fragment := fmt.Sprintf("Sort(%s)\n  Group(%d)\n", key, g.id)
+		if sorting >= best.cost {
+			m.prune(g, key, "Sort", fragment, fmt.Sprintf("sorting alone costs %.2f, %s", sorting, limit(best)))
+		} else if w := m.optimize(g, nil, best.cost-sorting); w == nil {
+			m.prune(g, key, "Sort", fragment, fmt.Sprintf("nothing to sort costs under %.2f", best.cost-sorting))
+		} else {
+			best.plan = &plan.LogicalSort{Input: w.plan, Keys: order}
+			best.cost = w.cost + sorting
+			m.trace.add(TraceEvent{Kind: Considered, Rule: "Sort", Group: g.id, Order: key, After: fragment, Cost: best.cost})
+		}
+	}
+
+	g.winners[key] = best
+	if best.plan == nil {
+		return nil
+	}
+	m.trace.add(TraceEvent{Kind: Chosen, Group: g.id, Order: key, After: plan.FormatPlan(best.plan, 0), Cost: best.cost})
+	return best
+}
+
+// costs alt and keeps it in best when cheaper, giving up as soon as the
+// cost so far reaches best
+func (m *memo) consider(e *groupExpr, alt physical, order []plan.Expr, best *winner) {
+	inputs := make([]float64, len(e.children))
+	for i, child := range e.children {
+		inputs[i] = child.rows
+	}
+
+	cost := operatorCost(alt.node, e.group.rows, inputs)
+	if len(e.children) == 0 {
+		// kept whole, its own subtree runs too
+		cost = EstimateCost(alt.node)
+	}
+	pruned := func(reason string) {
+		if m.trace != nil {
+			name, fragment := describe(alt, e)
+			m.prune(e.group, orderKey(order), name, fragment, reason)
+		}
+	}
+	if cost >= best.cost {
+		pruned(fmt.Sprintf("the operator alone costs %.2f, %s", cost, limit(best)))
+		return
+	}
+
+	children := make([]plan.LogicalPlan, len(e.children))
+	for i, child := range e.children {
+		w := m.optimize(child, alt.require[i], best.cost-cost)
+		if w == nil {
+			pruned(fmt.Sprintf("Group(%d) has no plan under the %.2f left", child.id, best.cost-cost))
+			return
+		}
+		children[i] = w.plan
+		cost += w.cost
+	}
+	if cost >= best.cost {
+		pruned(fmt.Sprintf("costs %.2f, %s", cost, limit(best)))
+		return
+	}
+	if m.trace != nil {
+		name, fragment := describe(alt, e)
+		m.trace.add(TraceEvent{Kind: Considered, Rule: name, Group: e.group.id, Order: orderKey(order), After: fragment, Cost: cost})
+	}
+
+	best.plan = alt.node
+	if len(children) > 0 {
+		best.plan = plan.WithChildren(alt.node, children)
+	}
+	best.cost = cost
+}
+
+func (m *memo) prune(g *group, order, name, fragment, reason string) {
+	m.trace.add(TraceEvent{Kind: Pruned, Rule: name, Group: g.id, Order: order, After: fragment, Reason: reason})
+}
+
+// what an alternative had to cost less than
+func limit(best *winner) string {
+	if best.plan != nil {
+		return fmt.Sprintf("the cheapest so far costs %.2f", best.cost)
+	}
+	return fmt.Sprintf("the bound is %.2f", best.cost)
+}
+
+// ways to run e producing rows in order
+func (m *memo) implementations(e *groupExpr, order []plan.Expr) []physical {
+	unordered := func(node plan.LogicalPlan) []physical {
+		if len(order) > 0 {
+			return nil
+		}
+		return []physical{{node: node, require: make([][]plan.Expr, len(e.children))}}
+	}
+
+	switch n := e.node.(type) {
+	case *plan.LogicalJoin:
+		var alts []physical
+		nested := *n
+		nested.Method, nested.LeftKeys, nested.RightKeys = plan.NestedLoopJoin, nil, nil
+		alts = append(alts, unordered(&nested)...)
+
+		if !e.keyed {
+			e.leftKeys, e.rightKeys = joinKeys(n.Condition, e.children[0], e.children[1])
+			e.keyed = true
+		}
+		leftKeys, rightKeys := e.leftKeys, e.rightKeys
+		if len(leftKeys) == 0 {
+			return m.hinted(alts, e)
+		}
+
+		hash := nested
+		hash.Method, hash.LeftKeys, hash.RightKeys = plan.HashJoin, leftKeys, rightKeys
+		alts = append(alts, unordered(&hash)...)
+
+		// merging delivers the order of its keys
+		if n.JoinType == plan.InnerJoin && (prefixOf(order, leftKeys) || prefixOf(order, rightKeys)) {
+			merge := hash
+			merge.Method = plan.MergeJoin
+			alts = append(alts, physical{node: &merge, require: [][]plan.Expr{leftKeys, rightKeys}})
+		}
+		return m.hinted(alts, e)
+
+	case *plan.LogicalAggregate:
+		hashed := *n
+		hashed.Sorted = false
+		alts := unordered(&hashed)
+
+		if len(n.GroupBy) > 0 && len(order) == 0 && columnsOnly(n.GroupBy) {
+			streamed := hashed
+			streamed.Sorted = true
+			alts = append(alts, physical{node: &streamed, require: [][]plan.Expr{n.GroupBy}})
+		}
+		return alts
+
+	case *plan.LogicalFilter:
+		// filtering keeps the input's order
+		return []physical{{node: n, require: [][]plan.Expr{order}}}
+
+	default:
+		return unordered(n)
+	}
+}
+
+// the join alternatives a hint asks for, all of them when it asks for one
+// that can't run the join
+func (m *memo) hinted(alts []physical, e *groupExpr) []physical {
+	method, ok := m.hints.method(e.children[0].scans, e.children[1].scans)
+	if !ok {
+		return alts
+	}
+
+	var kept []physical
+	for _, alt := range alts {
+		if alt.node.(*plan.LogicalJoin).Method == method {
+			kept = append(kept, alt)
+		}
+	}
+	if len(kept) == 0 {
+		return alts
+	}
+	return kept
+}
+
+// equi join keys between the two sides, qualified
+func joinKeys(cond plan.Expr, left, right *group) ([]plan.Expr, []plan.Expr) {
+	var leftKeys, rightKeys []plan.Expr
+	for _, c := range plan.SplitConjuncts(cond) {
+		b, ok := c.(*plan.BinaryExpr)
+		if !ok || b.Operator != "=" {
+			continue
+		}
+		l, lok := b.Left.(*plan.ColumnExpr)
+		r, rok := b.Right.(*plan.ColumnExpr)
+		if !lok || !rok {
+			continue
+		}
+
+		if lk, ok := sideColumn(l, left); ok {
+			if rk, ok := sideColumn(r, right); ok {
+				leftKeys, rightKeys = append(leftKeys, lk), append(rightKeys, rk)
+			}
+			continue
+		}
+		if lk, ok := sideColumn(r, left); ok {
+			if rk, ok := sideColumn(l, right); ok {
+				leftKeys, rightKeys = append(leftKeys, lk), append(rightKeys, rk)
+			}
+		}
+	}
+
+	return leftKeys, rightKeys
+}
+
+// col qualified when g's rows hold it
+func sideColumn(col *plan.ColumnExpr, g *group) (plan.Expr, bool) {
+	if q, ok := qualifiedColumn(col, g.rels); ok && g.block {
+		return q, true
+	}
+
+	// partial aggregates name their columns after the relation's
+	if col.Table != "" {
+		for _, c := range g.plan.Schema() {
+			if c.Name == col.String() {
+				return col, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func columnsOnly(exprs []plan.Expr) bool {
+	for _, e := range exprs {
+		if _, ok := e.(*plan.ColumnExpr); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// whether rows ordered by keys are also ordered by order
+func prefixOf(order, keys []plan.Expr) bool {
+	if len(order) > len(keys) {
+		return false
+	}
+	for i, o := range order {
+		if o.String() != keys[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+func orderKey(order []plan.Expr) string {
+	keys := make([]string, len(order))
+	for i, o := range order {
+		keys[i] = o.String()
+	}
+	return strings.Join(keys, ", ")
+}
+
+// bare column names over a join read the last relation that has the
+// column, as the executor builds joined rows. Naming the relation keeps
+// that when the joins below are reordered
+func qualifyColumns(node plan.LogicalPlan) plan.LogicalPlan {
+	var input plan.LogicalPlan
+	switch n := node.(type) {
+	case *plan.LogicalJoin:
+		input = n
+	case *plan.LogicalFilter, *plan.LogicalProject, *plan.LogicalAggregate:
+		input = n.Children()[0]
+	default:
+		return node
+	}
+
+	rels := relationsOf(input)
+	if len(rels) < 2 || !joinBlock(input) {
+		return node
+	}
+
+	qualify := func(expr plan.Expr) plan.Expr {
+		return plan.TransformExpr(expr, func(e plan.Expr) (plan.Expr, bool) {
+			col, ok := e.(*plan.ColumnExpr)
+			if !ok || col.Table != "" {
+				return e, false
+			}
+			for _, rel := range slices.Backward(rels) {
+				if _, err := rel.scan.Table.GetColumn(col.Column); err == nil {
+					return &plan.ColumnExpr{Table: rel.name, Column: col.Column}, true
+				}
+			}
+			return e, true
+		})
+	}
+	each := func(exprs []plan.Expr) []plan.Expr {
+		out := make([]plan.Expr, len(exprs))
+		for i, e := range exprs {
+			out[i] = qualify(e)
+		}
+		return out
+	}
+
+	switch n := node.(type) {
+	case *plan.LogicalJoin:
+		c := *n
+		c.Condition = qualify(n.Condition)
+		return &c
+	case *plan.LogicalFilter:
+		c := *n
+		c.Predicate = qualify(n.Predicate)
+		return &c
+	case *plan.LogicalProject:
+		c := *n
+		c.Projections = each(n.Projections)
+		return &c
+	case *plan.LogicalAggregate:
+		c := *n
+		c.GroupBy = each(n.GroupBy)
+		c.Aggregates = make([]*plan.AggregateExpr, len(n.Aggregates))
+		for i, a := range n.Aggregates {
+			c.Aggregates[i] = qualify(a).(*plan.AggregateExpr)
+		}
+		return &c
+	}
+	return node
+}
@@ -0,0 +1,118 @@
+package optimizer
+
+import (
+	"slices"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// rewrite rule, Apply sees every node bottom up and returns the node
+// unchanged when it does not match
+type Rule interface {
+	Name() string
+	Apply(node plan.LogicalPlan) plan.LogicalPlan
+}
+
+// logical plan rewrites, then a cost based search over join orders, join
+// methods and aggregation
+type Optimizer struct {
+	catalog   *catalog.Catalog
+	rules     []Rule
+	functions *function.Registry
+	warnings  []string
+}
+
+func NewOptimizer(cat *catalog.Catalog) *Optimizer {
+	functions := function.RegistryOf(cat)
+	return &Optimizer{
+		catalog:   cat,
+		functions: functions,
+		rules: []Rule{
+			&SimplifyExpressions{},
+			&SimplifyOuterJoins{},
+			&UseMaterializedViews{catalog: cat, functions: functions},
+			&InferPredicates{},
+			&PushDownPredicates{},
+			&ApplyNotNull{},
+			&SimplifyExpressions{},
+			&EliminateJoins{},
+			&PropagateEmpty{},
+			&PushIntoScans{},
+		},
+	}
+}
+
+func (o *Optimizer) Optimize(root plan.LogicalPlan) plan.LogicalPlan {
+	return o.optimize(root, nil)
+}
+
+// optimizes root recording every rule applied and every alternative
+// costed or pruned on the way
+func (o *Optimizer) OptimizeTraced(root plan.LogicalPlan) (plan.LogicalPlan, *Trace) {
+	trace := &Trace{}
+	return o.optimize(root, trace), trace
+}
+
+// hints of the last plan optimized that were ignored or couldn't be
+// honored
+func (o *Optimizer) Warnings() []string {
+	return o.warnings
+}
+
+func (o *Optimizer) optimize(root plan.LogicalPlan, trace *Trace) plan.LogicalPlan {
+	hints := collectHints(root)
+	rules := o.rules
+	if len(hints.access) > 0 {
+		rules = append(slices.Clone(rules), &ApplyAccessHints{hints: hints})
+	}
+
+	for _, rule := range rules {
+		// a refresh recomputes a view from its tables
+		if _, ok := rule.(*UseMaterializedViews); ok && isRefresh(root) {
+			continue
+		}
+		if _, ok := rule.(*PushDownPredicates); ok && hints.noPushdown {
+			continue
+		}
+		if _, ok := rule.(*PushIntoScans); ok && hints.noPushdown {
+			rule = &PushIntoScans{keepPredicates: true}
+		}
+		if trace != nil {
+			rule = &tracedRule{Rule: rule, trace: trace}
+		}
+		root = applyBottomUp(root, rule)
+	}
+
+	m := newMemo(o.functions)
+	m.trace, m.hints = trace, hints
+	root = m.search(root)
+
+	hints.check(root)
+	o.warnings = hints.warnings
+	return root
+}
+
+func isRefresh(root plan.LogicalPlan) bool {
+	_, ok := root.(*plan.LogicalRefresh)
+	return ok
+}
+
+func applyBottomUp(node plan.LogicalPlan, rule Rule) plan.LogicalPlan {
+	children := node.Children()
+	if len(children) > 0 {
+		rewritten := make([]plan.LogicalPlan, len(children))
+		changed := false
+
+		for i, child := range children {
+			rewritten[i] = applyBottomUp(child, rule)
+			changed = changed || rewritten[i] != child
+		}
+		if changed {
+			node = plan.WithChildren(node, rewritten)
+		}
+	}
+
+	return rule.Apply(node)
+}
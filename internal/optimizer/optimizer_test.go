@@ -0,0 +1,858 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+func newTestCatalog() *catalog.Catalog {
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "users",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "name", Type: catalog.StringType},
+			{Name: "age", Type: catalog.IntType},
+		},
+		Statistics: &catalog.Statistics{RowCount: 100},
+		PrimaryKey: []string{"id"},
+	})
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "orders",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "user_id", Type: catalog.IntType},
+			{Name: "amount", Type: catalog.IntType},
+		},
+		Statistics: &catalog.Statistics{RowCount: 1000},
+		PrimaryKey: []string{"id"},
+		ForeignKeys: []catalog.ForeignKey{
+			{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}},
+		},
+	})
+
+	return cat
+}
+
+func optimize(t *testing.T, cat *catalog.Catalog, query string) plan.LogicalPlan {
+	t.Helper()
+
+	return optimizeWith(t, NewOptimizer(cat), query)
+}
+
+func optimizeWith(t *testing.T, o *Optimizer, query string) plan.LogicalPlan {
+	t.Helper()
+
+	p := parser.NewParser(query)
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	logicalPlan, err := plan.NewPlanner(o.catalog).CreateLogicalPlan(stmt)
+	if err != nil {
+		t.Fatalf("planning failed: %v", err)
+	}
+
+	return o.Optimize(logicalPlan)
+}
+
+// the single filter below the projection
+func findFilter(node plan.LogicalPlan) *plan.LogicalFilter {
+	if f, ok := node.(*plan.LogicalFilter); ok {
+		return f
+	}
+	for _, child := range node.Children() {
+		if f := findFilter(child); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func TestSimplifyPredicates(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`SELECT id FROM users WHERE 1 = 1 AND age > 20 + 5`, `(age > 25)`},
+		{`SELECT id FROM users WHERE 30 <= age`, `(age >= 30)`},
+		{`SELECT id FROM users WHERE NOT (age < 18) OR FALSE`, `(age >= 18)`},
+		{`SELECT id FROM users WHERE age = age`, `(age IS NOT NULL)`},
+		{`SELECT id FROM users WHERE LENGTH(UPPER('ab')) * 10 = age`, `(age = 20)`},
+		{`SELECT id FROM users WHERE CASE WHEN 1 > 2 THEN 'x' ELSE name END = 'bob'`, `(name = 'bob')`},
+	}
+
+	cat := newTestCatalog()
+	for _, tt := range tests {
+		filter := findFilter(optimize(t, cat, tt.query))
+		if filter == nil {
+			t.Fatalf("%s: filter was removed", tt.query)
+		}
+		if filter.Predicate.String() != tt.expected {
+			t.Fatalf("%s: expected %s, got %s", tt.query, tt.expected, filter.Predicate)
+		}
+	}
+}
+
+func TestAlwaysTrueFilterRemoved(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT id FROM users WHERE 1 = 1 OR age > 3`)
+	if findFilter(optimized) != nil {
+		t.Fatalf("expected filter to be removed:\n%s", optimized)
+	}
+}
+
+func TestContradictionsBecomeEmpty(t *testing.T) {
+	queries := []string{
+		`SELECT id FROM users WHERE age = 1 AND age = 2`,
+		`SELECT id FROM users WHERE age > 10 AND age < 5`,
+		`SELECT id FROM users WHERE age >= 10 AND age < 10`,
+		`SELECT id FROM users WHERE age = 3 AND age != 3`,
+		`SELECT id FROM users WHERE name IS NULL AND name = 'bob'`,
+		`SELECT id FROM users WHERE 1 = 2`,
+		`SELECT users.id FROM users JOIN orders ON users.id = orders.user_id WHERE orders.amount > 5 AND orders.amount < 1`,
+	}
+
+	cat := newTestCatalog()
+	for _, query := range queries {
+		optimized := optimize(t, cat, query)
+		if _, ok := optimized.(*plan.LogicalEmpty); !ok {
+			t.Fatalf("%s: expected empty plan, got %s", query, optimized)
+		}
+	}
+}
+
+func TestNonContradictionsKept(t *testing.T) {
+	queries := []string{
+		`SELECT id FROM users WHERE age >= 10 AND age <= 10`,
+		`SELECT id FROM users WHERE age = 3 AND name = 'x'`,
+		`SELECT id FROM users WHERE age > 10 OR age < 5`,
+	}
+
+	cat := newTestCatalog()
+	for _, query := range queries {
+		optimized := optimize(t, cat, query)
+		if _, ok := optimized.(*plan.LogicalEmpty); ok {
+			t.Fatalf("%s: unexpected empty plan", query)
+		}
+	}
+}
+
+func TestEmptyKeepsUngroupedAggregate(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT COUNT(*) FROM users WHERE age = 1 AND age = 2`)
+	project, ok := optimized.(*plan.LogicalProject)
+	if !ok {
+		t.Fatalf("expected projection on top, got %s", optimized)
+	}
+	if _, ok := project.Input.(*plan.LogicalAggregate); !ok {
+		t.Fatalf("expected COUNT(*) over an empty input to be kept, got %s", project.Input)
+	}
+}
+
+// filter directly above the scan of table
+func scanFilter(node plan.LogicalPlan, table string) *plan.LogicalFilter {
+	if f, ok := node.(*plan.LogicalFilter); ok {
+		if scan, ok := f.Input.(*plan.LogicalScan); ok && scan.TableName == table {
+			return f
+		}
+	}
+	for _, child := range node.Children() {
+		if f := scanFilter(child, table); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func TestInferredPredicatePushedDown(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT users.name FROM users JOIN orders ON users.id = orders.user_id WHERE users.id = 5 AND orders.amount > 10`)
+
+	users := scanFilter(optimized, "users")
+	if users == nil || users.Predicate.String() != "(users.id = 5)" {
+		t.Fatalf("expected users.id = 5 above the users scan:\n%s", format(optimized))
+	}
+	orders := scanFilter(optimized, "orders")
+	if orders == nil || orders.Predicate.String() != "((orders.amount > 10) AND (orders.user_id = 5))" {
+		t.Fatalf("expected inferred orders.user_id = 5 above the orders scan:\n%s", format(optimized))
+	}
+}
+
+func TestTransitiveContradiction(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT users.name FROM users JOIN orders ON users.id = orders.user_id WHERE users.id = 5 AND orders.user_id = 6`)
+	if _, ok := optimized.(*plan.LogicalEmpty); !ok {
+		t.Fatalf("expected empty plan, got:\n%s", format(optimized))
+	}
+}
+
+func TestPushDownKeepsOuterJoinSemantics(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id AND orders.amount > 10 WHERE users.age > 30 AND orders.id IS NULL`)
+
+	if f := scanFilter(optimized, "users"); f == nil || f.Predicate.String() != "(users.age > 30)" {
+		t.Fatalf("expected users.age > 30 pushed to the users scan:\n%s", format(optimized))
+	}
+	if f := scanFilter(optimized, "orders"); f == nil || f.Predicate.String() != "(orders.amount > 10)" {
+		t.Fatalf("expected ON conjunct pushed to the orders scan:\n%s", format(optimized))
+	}
+
+	project := optimized.(*plan.LogicalProject)
+	above, ok := project.Input.(*plan.LogicalFilter)
+	if !ok || above.Predicate.String() != "(orders.id IS NULL)" {
+		t.Fatalf("expected orders.id IS NULL to stay above the join:\n%s", format(optimized))
+	}
+}
+
+func findJoin(node plan.LogicalPlan) *plan.LogicalJoin {
+	if j, ok := node.(*plan.LogicalJoin); ok {
+		return j
+	}
+	for _, child := range node.Children() {
+		if j := findJoin(child); j != nil {
+			return j
+		}
+	}
+
+	return nil
+}
+
+func TestOuterJoinSimplification(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected plan.JoinType
+	}{
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.amount > 10`, plan.InnerJoin},
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.id IS NOT NULL`, plan.InnerJoin},
+		{`SELECT users.name FROM orders RIGHT JOIN users ON users.id = orders.user_id WHERE orders.amount = 3`, plan.InnerJoin},
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.id IS NULL`, plan.LeftJoin},
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.amount > 10 OR users.age > 3`, plan.LeftJoin},
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE users.age > 3`, plan.LeftJoin},
+	}
+
+	cat := newTestCatalog()
+	for _, tt := range tests {
+		optimized := optimize(t, cat, tt.query)
+		join := findJoin(optimized)
+		if join == nil || join.JoinType != tt.expected {
+			t.Fatalf("%s: expected %s join:\n%s", tt.query, tt.expected, format(optimized))
+		}
+	}
+}
+
+func TestJoinElimination(t *testing.T) {
+	tests := []struct {
+		query      string
+		eliminated bool
+	}{
+		{`SELECT orders.amount FROM orders LEFT JOIN users ON orders.user_id = users.id`, true},
+		{`SELECT amount FROM orders LEFT JOIN users ON orders.user_id = users.id AND users.age > 30`, true},
+		{`SELECT SUM(orders.amount) FROM orders LEFT JOIN users ON orders.user_id = users.id GROUP BY orders.user_id`, true},
+		// reads the right side
+		{`SELECT orders.amount, users.name FROM orders LEFT JOIN users ON orders.user_id = users.id`, false},
+		{`SELECT orders.amount FROM orders LEFT JOIN users ON orders.user_id = users.id WHERE users.name IS NULL`, false},
+		// user_id is not unique in orders
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id`, false},
+		// every order references exactly one user
+		{`SELECT orders.amount FROM orders JOIN users ON orders.user_id = users.id`, true},
+		// inner joins drop unmatched rows
+		{`SELECT users.name FROM users JOIN orders ON users.id = orders.user_id`, false},
+		{`SELECT orders.amount FROM orders JOIN users ON orders.user_id = users.id WHERE users.age > 3`, false},
+	}
+
+	cat := newTestCatalog()
+	for _, tt := range tests {
+		optimized := optimize(t, cat, tt.query)
+		if eliminated := findJoin(optimized) == nil; eliminated != tt.eliminated {
+			t.Fatalf("%s: expected eliminated=%v:\n%s", tt.query, tt.eliminated, format(optimized))
+		}
+	}
+}
+
+func TestForeignKeyJoinKeepsNullCheck(t *testing.T) {
+	cat := newTestCatalog()
+
+	optimized := optimize(t, cat, `SELECT orders.amount FROM orders JOIN users ON orders.user_id = users.id`)
+	filter := scanFilter(optimized, "orders")
+	if findJoin(optimized) != nil || filter == nil || filter.Predicate.String() != "(orders.user_id IS NOT NULL)" {
+		t.Fatalf("expected join replaced by a NULL check on user_id:\n%s", format(optimized))
+	}
+
+	orders, _ := cat.GetTable("orders")
+	orders.Columns[1].NotNull = true
+	optimized = optimize(t, cat, `SELECT orders.amount FROM orders JOIN users ON orders.user_id = users.id`)
+	if findFilter(optimized) != nil {
+		t.Fatalf("expected no filter for a NOT NULL key:\n%s", format(optimized))
+	}
+}
+
+func TestNotNullFolding(t *testing.T) {
+	cat := newTestCatalog()
+
+	if _, ok := optimize(t, cat, `SELECT name FROM users WHERE id IS NULL`).(*plan.LogicalEmpty); !ok {
+		t.Fatalf("primary key can't be NULL")
+	}
+	if findFilter(optimize(t, cat, `SELECT name FROM users WHERE id IS NOT NULL`)) != nil {
+		t.Fatalf("expected IS NOT NULL on the primary key to be dropped")
+	}
+}
+
+func TestEstimateRows(t *testing.T) {
+	cat := newTestCatalog()
+	orders, _ := cat.GetTable("orders")
+	orders.Columns[1].NotNull = true
+
+	tests := []struct {
+		query    string
+		expected float64
+	}{
+		{`SELECT name FROM users`, 100},
+		// unique key bounds the result
+		{`SELECT name FROM users WHERE id = 5`, 1},
+		{`SELECT id FROM orders WHERE amount > 5 AND id = 3`, 1.0 / 3},
+		// foreign key join keeps one row per order, times the user filter
+		{`SELECT users.name, orders.amount FROM orders JOIN users ON orders.user_id = users.id`, 1000},
+		{`SELECT users.name, orders.amount FROM orders JOIN users ON orders.user_id = users.id WHERE users.id = 7`, 10},
+		{`SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id AND orders.id = 1`, 100},
+	}
+
+	for _, tt := range tests {
+		optimized := optimize(t, cat, tt.query)
+		if got := EstimateRows(optimized); math.Abs(got-tt.expected) > 1e-9 {
+			t.Fatalf("%s: expected %v rows, got %v:\n%s", tt.query, tt.expected, got, format(optimized))
+		}
+	}
+}
+
+func format(node plan.LogicalPlan) string {
+	var b strings.Builder
+	var walk func(plan.LogicalPlan, int)
+	walk = func(n plan.LogicalPlan, depth int) {
+		b.WriteString(strings.Repeat("  ", depth) + n.String() + "\n")
+		for _, child := range n.Children() {
+			walk(child, depth+1)
+		}
+	}
+	walk(node, 0)
+
+	return b.String()
+}
+
+func TestRangeEstimateUsesMinMax(t *testing.T) {
+	cat := newTestCatalog()
+	users, _ := cat.GetTable("users")
+	users.Statistics.Min = map[string]interface{}{"age": 20.0}
+	users.Statistics.Max = map[string]interface{}{"age": 60.0}
+
+	tests := []struct {
+		query    string
+		expected float64
+	}{
+		{`SELECT name FROM users WHERE age < 30`, 25},
+		{`SELECT name FROM users WHERE age >= 50`, 25},
+		{`SELECT name FROM users WHERE age > 70`, 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateRows(optimize(t, cat, tt.query)); math.Abs(got-tt.expected) > 1e-9 {
+			t.Fatalf("%s: expected %v rows, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+// orders.status is skewed: 90% delivered, the rest spread over 9 values
+func skewedCatalog() *catalog.Catalog {
+	cat := newTestCatalog()
+
+	orders, _ := cat.GetTable("orders")
+	orders.Columns = append(orders.Columns, catalog.Column{Name: "status", Type: catalog.StringType})
+	orders.Statistics = &catalog.Statistics{
+		RowCount:      1000,
+		DistinctCount: map[string]int{"status": 10, "amount": 500, "user_id": 100},
+		NullCount:     map[string]int{},
+		MostCommon: map[string][]catalog.ValueFrequency{
+			"status":  {{Value: "delivered", Frequency: 0.9}},
+			"user_id": {{Value: 1.0, Frequency: 0.5}},
+		},
+		Histograms: map[string]*catalog.Histogram{
+			"status":  {Bounds: []interface{}{"a", "m", "z"}},
+			"amount":  {Bounds: []interface{}{0.0, 10.0, 20.0, 100.0, 1000.0}},
+			"user_id": {Bounds: []interface{}{2.0, 50.0, 100.0}},
+		},
+	}
+
+	users, _ := cat.GetTable("users")
+	users.Statistics = &catalog.Statistics{
+		RowCount:      100,
+		DistinctCount: map[string]int{"id": 100},
+		NullCount:     map[string]int{},
+		Histograms: map[string]*catalog.Histogram{
+			"id": {Bounds: []interface{}{1.0, 50.0, 100.0}},
+		},
+	}
+
+	return cat
+}
+
+func TestHistogramSelectivity(t *testing.T) {
+	cat := skewedCatalog()
+
+	tests := []struct {
+		query    string
+		expected float64
+	}{
+		// most common value
+		{`SELECT id FROM orders WHERE status = 'delivered'`, 900},
+		// the other 10% spread over 9 values
+		{`SELECT id FROM orders WHERE status = 'pending'`, 100.0 / 9},
+		// outside the histogram
+		{`SELECT id FROM orders WHERE status = '~'`, 0},
+		// three of four equi-depth buckets
+		{`SELECT id FROM orders WHERE amount < 100`, 750},
+		{`SELECT id FROM orders WHERE amount >= 15`, 625},
+		// d to e is a twelfth of the a to m bucket
+		{`SELECT id FROM orders WHERE status LIKE 'd%'`, 900 + 100*0.5/12},
+	}
+	for _, tt := range tests {
+		if got := EstimateRows(optimize(t, cat, tt.query)); math.Abs(got-tt.expected) > 1e-6 {
+			t.Fatalf("%s: expected %v rows, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestHistogramJoinEstimate(t *testing.T) {
+	cat := skewedCatalog()
+	orders, _ := cat.GetTable("orders")
+	orders.ForeignKeys = nil
+
+	// user 1 has half of the orders, the other half spreads over ids 2 to
+	// 100 which overlap all of the users histogram except id 1
+	optimized := optimize(t, cat, `SELECT users.name FROM orders JOIN users ON orders.user_id = users.id`)
+	got := EstimateRows(optimized)
+	if got < 990 || got > 1010 {
+		t.Fatalf("expected about 1000 rows, got %v:\n%s", got, format(optimized))
+	}
+}
+
+func TestPushDownBelowUnnest(t *testing.T) {
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "docs",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "items", Type: catalog.JSONType},
+		},
+	})
+
+	optimized := optimize(t, cat, `SELECT d.id FROM docs d CROSS JOIN UNNEST(d.items) AS item WHERE d.id = 1 AND item->'qty' > 1`)
+
+	if f := scanFilter(optimized, "docs"); f == nil || f.Predicate.String() != "(d.id = 1)" {
+		t.Fatalf("expected d.id = 1 below the unnest:\n%s", format(optimized))
+	}
+	above, ok := optimized.(*plan.LogicalProject).Input.(*plan.LogicalFilter)
+	if !ok || above.Predicate.String() != "((item -> 'qty') > 1)" {
+		t.Fatalf("expected the element filter to stay above the unnest:\n%s", format(optimized))
+	}
+}
+
+func findScan(node plan.LogicalPlan, table string) *plan.LogicalScan {
+	if s, ok := node.(*plan.LogicalScan); ok && s.TableName == table {
+		return s
+	}
+	for _, child := range node.Children() {
+		if s := findScan(child, table); s != nil {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func TestPushIntoScans(t *testing.T) {
+	cat := newTestCatalog()
+	orders, _ := cat.GetTable("orders")
+	orders.Indexes = []catalog.Index{{Name: "idx_user_id", Columns: []string{"user_id"}}}
+
+	optimized := optimize(t, cat, `SELECT users.name FROM users JOIN orders ON users.id = orders.user_id WHERE orders.user_id = 7 AND orders.amount + 1 > 10`)
+
+	scan := findScan(optimized, "orders")
+	if scan.Index != "idx_user_id" || fmt.Sprint(scan.Columns) != "[user_id amount]" {
+		t.Fatalf("expected an index scan reading user_id and amount:\n%s", format(optimized))
+	}
+	if len(scan.Pushed) != 1 || scan.Pushed[0].String() != "user_id = 7" {
+		t.Fatalf("expected only user_id = 7 pushed:\n%s", format(optimized))
+	}
+
+	users := findScan(optimized, "users")
+	if fmt.Sprint(users.Columns) != "[id name]" || users.Index != "" {
+		t.Fatalf("expected users to read id and name without an index:\n%s", format(optimized))
+	}
+}
+
+// test catalog with materialized views of order totals per user and of
+// large orders
+func viewCatalog() *catalog.Catalog {
+	cat := newTestCatalog()
+	orders, _ := cat.GetTable("orders")
+	orders.Columns[1].NotNull = true
+
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "user_totals",
+		Columns: []catalog.Column{
+			{Name: "user_id", Type: catalog.IntType},
+			{Name: "name", Type: catalog.StringType},
+			{Name: "total", Type: catalog.IntType},
+			{Name: "n", Type: catalog.IntType},
+		},
+		Statistics:   &catalog.Statistics{RowCount: 100},
+		View:         "SELECT o.user_id, u.name, SUM(o.amount) AS total, COUNT(*) AS n FROM orders o JOIN users u ON o.user_id = u.id GROUP BY o.user_id, u.name",
+		Materialized: true,
+		Reads:        []string{"orders", "users"},
+	})
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "big_orders",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "user_id", Type: catalog.IntType},
+			{Name: "amount", Type: catalog.IntType},
+		},
+		Statistics:   &catalog.Statistics{RowCount: 50},
+		View:         "SELECT id, user_id, amount FROM orders WHERE amount > 100",
+		Materialized: true,
+		Reads:        []string{"orders"},
+	})
+
+	return cat
+}
+
+func TestMaterializedViewRewrite(t *testing.T) {
+	tests := []struct {
+		query string
+		view  string // empty when no view answers the query
+		plan  string // part of the rewritten plan
+	}{
+		// the join to users drops no orders, so it doesn't matter here
+		{`SELECT user_id, SUM(amount) AS total FROM orders GROUP BY user_id`, "user_totals", "SUM(user_totals.total)"},
+		{`SELECT u.name, COUNT(*) FROM users u JOIN orders o ON u.id = o.user_id WHERE u.name = 'bob' GROUP BY u.name`, "user_totals", "Filter((user_totals.name = 'bob'))"},
+		{`SELECT o.user_id, u.name, SUM(o.amount) FROM orders o JOIN users u ON u.id = o.user_id GROUP BY o.user_id, u.name`, "user_totals", "Project([user_id name SUM(o.amount)])\n    MaterializedViewScan"},
+		{`SELECT COUNT(*) FROM orders`, "user_totals", "SUM(user_totals.n)"},
+		{`SELECT id, amount FROM orders WHERE amount > 500`, "big_orders", "Filter((big_orders.amount > 500))"},
+		{`SELECT user_id, MAX(amount) FROM orders WHERE amount >= 200 GROUP BY user_id`, "big_orders", "Aggregate(group=[big_orders.user_id]"},
+		// the views lack rows or columns these need
+		{`SELECT id FROM orders WHERE amount > 50`, "", ""},
+		{`SELECT user_id, SUM(amount) FROM orders WHERE amount > 10 GROUP BY user_id`, "", ""},
+		{`SELECT user_id, AVG(amount) FROM orders GROUP BY user_id`, "", ""},
+		{`SELECT u.name FROM users u LEFT JOIN orders o ON u.id = o.user_id`, "", ""},
+	}
+
+	for _, tt := range tests {
+		optimized := optimize(t, viewCatalog(), tt.query)
+		out := format(optimized)
+
+		if tt.view == "" {
+			if strings.Contains(out, "MaterializedView") {
+				t.Errorf("%s: expected no view to be used:\n%s", tt.query, out)
+			}
+			continue
+		}
+		scan := findScan(optimized, tt.view)
+		if scan == nil || !scan.FromView || !strings.Contains(out, tt.plan) {
+			t.Errorf("%s: expected %s to answer it with %s:\n%s", tt.query, tt.view, tt.plan, out)
+		}
+	}
+}
+
+func TestStaleViewNotUsed(t *testing.T) {
+	cat := viewCatalog()
+	view, _ := cat.GetTable("big_orders")
+	view.Stale = true
+
+	out := format(optimize(t, cat, `SELECT id FROM orders WHERE amount > 500`))
+	if strings.Contains(out, "MaterializedView") {
+		t.Fatalf("expected the stale view to be skipped:\n%s", out)
+	}
+}
+
+func joinsOf(node plan.LogicalPlan) []*plan.LogicalJoin {
+	var joins []*plan.LogicalJoin
+	if j, ok := node.(*plan.LogicalJoin); ok {
+		joins = append(joins, j)
+	}
+	for _, child := range node.Children() {
+		joins = append(joins, joinsOf(child)...)
+	}
+
+	return joins
+}
+
+func searchCatalog() *catalog.Catalog {
+	cat := newTestCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "items",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "order_id", Type: catalog.IntType},
+			{Name: "qty", Type: catalog.IntType},
+		},
+		Statistics: &catalog.Statistics{RowCount: 5000},
+		PrimaryKey: []string{"id"},
+	})
+
+	return cat
+}
+
+func TestJoinOrderAndMethods(t *testing.T) {
+	cat := searchCatalog()
+
+	// written as a cross product of items and users
+	optimized := optimize(t, cat, `SELECT u.name, i.qty FROM items i JOIN users u ON 1 = 1 JOIN orders o ON i.order_id = o.id AND o.user_id = u.id`)
+	out := format(optimized)
+	joins := joinsOf(optimized)
+	if len(joins) != 2 {
+		t.Fatalf("expected two joins:\n%s", out)
+	}
+	for _, j := range joins {
+		if j.Method != plan.HashJoin || len(j.LeftKeys) == 0 {
+			t.Fatalf("expected hash joins on equi join keys:\n%s", out)
+		}
+		// the smaller input is built
+		if EstimateRows(j.Right) > EstimateRows(j.Left) {
+			t.Fatalf("expected the larger input to probe:\n%s", out)
+		}
+	}
+
+	// bare names over joins read the last relation that has them, the
+	// reordered plan names it
+	optimized = optimize(t, cat, `SELECT id FROM users JOIN orders ON users.id = orders.user_id`)
+	project := optimized.(*plan.LogicalProject)
+	if got := project.Projections[0].String(); got != "orders.id" {
+		t.Fatalf("expected id to be read from orders, got %s:\n%s", got, format(optimized))
+	}
+
+	// without equi join keys only a nested loop works
+	optimized = optimize(t, cat, `SELECT u.name FROM users u JOIN orders o ON o.amount > u.age`)
+	if j := findJoin(optimized); j == nil || j.Method != plan.NestedLoopJoin {
+		t.Fatalf("expected a nested loop join:\n%s", format(optimized))
+	}
+}
+
+func TestAggregatePushdown(t *testing.T) {
+	cat := searchCatalog()
+
+	optimized := optimize(t, cat, `SELECT u.name, SUM(o.amount), COUNT(*) FROM orders o JOIN users u ON o.user_id = u.id GROUP BY u.name`)
+	out := format(optimized)
+	join := findJoin(optimized)
+	if join == nil {
+		t.Fatalf("expected a join:\n%s", out)
+	}
+
+	var partial *plan.LogicalAggregate
+	for _, side := range join.Children() {
+		if agg, ok := side.(*plan.LogicalAggregate); ok {
+			partial = agg
+		}
+	}
+	if partial == nil || len(partial.GroupBy) != 1 || partial.GroupBy[0].String() != "o.user_id" {
+		t.Fatalf("expected orders aggregated by user_id below the join:\n%s", out)
+	}
+	if !strings.Contains(out, "Aggregate(group=[u.name]") {
+		t.Fatalf("expected the partial results combined above the join:\n%s", out)
+	}
+
+	// aggregates of both sides have to wait for the join
+	optimized = optimize(t, cat, `SELECT SUM(o.amount + u.age) FROM orders o JOIN users u ON o.user_id = u.id`)
+	if _, ok := findJoin(optimized).Left.(*plan.LogicalAggregate); ok {
+		t.Fatalf("expected no partial aggregate:\n%s", format(optimized))
+	}
+}
+
+// cheapest plan of the join in query with its rows ordered by order
+func orderedJoin(t *testing.T, cat *catalog.Catalog, query string, order ...plan.Expr) plan.LogicalPlan {
+	t.Helper()
+
+	m := newMemo(function.NewRegistry())
+	m.explore(m.insert(optimize(t, cat, query), false))
+	for _, g := range m.groups {
+		if _, ok := g.exprs[0].node.(*plan.LogicalJoin); ok {
+			if w := m.optimize(g, order, math.Inf(1)); w != nil {
+				return w.plan
+			}
+		}
+	}
+
+	t.Fatalf("no join in %s", query)
+	return nil
+}
+
+func TestRequiredOrder(t *testing.T) {
+	cat := searchCatalog()
+	name := &plan.ColumnExpr{Table: "u", Column: "name"}
+	amount := &plan.ColumnExpr{Table: "a", Column: "amount"}
+
+	// joins don't deliver the order of other columns, a sort does
+	sorted := orderedJoin(t, cat, `SELECT u.name FROM orders o JOIN users u ON o.user_id = u.id`, name)
+	if s, ok := sorted.(*plan.LogicalSort); !ok || s.Keys[0].String() != "u.name" {
+		t.Fatalf("expected a sort on u.name:\n%s", format(sorted))
+	}
+
+	// sorting both inputs is cheaper than sorting what a many to many
+	// join produces, so the merge join gives the order
+	merged := orderedJoin(t, cat, `SELECT a.id FROM orders a JOIN orders b ON a.amount = b.amount`, amount)
+	join, ok := merged.(*plan.LogicalJoin)
+	if !ok || join.Method != plan.MergeJoin {
+		t.Fatalf("expected a merge join:\n%s", format(merged))
+	}
+	for i, side := range join.Children() {
+		if s, ok := side.(*plan.LogicalSort); !ok || s.Keys[0].String() != []string{"a.amount", "b.amount"}[i] {
+			t.Fatalf("expected both inputs sorted on their keys:\n%s", format(merged))
+		}
+	}
+}
+
+func TestOptimizerTrace(t *testing.T) {
+	cat := searchCatalog()
+	query := `SELECT u.name, SUM(o.amount) FROM orders o JOIN users u ON o.user_id = u.id GROUP BY u.name`
+	logicalPlan, err := plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(query).Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traced, trace := NewOptimizer(cat).OptimizeTraced(logicalPlan)
+	if format(traced) != format(optimize(t, cat, query)) {
+		t.Fatalf("tracing changed the plan:\n%s", format(traced))
+	}
+
+	rules := make(map[string]bool)
+	for _, ev := range trace.Filter(RuleApplied) {
+		if ev.Before == ev.After {
+			t.Fatalf("%s recorded without a change:\n%s", ev.Rule, ev)
+		}
+		rules[ev.Rule] = true
+	}
+	for _, rule := range []string{"PushIntoScans", "JoinCommute", "AggregatePushdown"} {
+		if !rules[rule] {
+			t.Fatalf("expected %s in the trace:\n%s", rule, trace)
+		}
+	}
+
+	methods := make(map[string]bool)
+	for _, ev := range trace.Filter(Considered, Pruned) {
+		methods[ev.Rule] = true
+		if ev.Kind == Pruned && ev.Reason == "" {
+			t.Fatalf("expected a reason for pruning:\n%s", ev)
+		}
+	}
+	for _, method := range []string{"Join", "HashJoin", "MergeJoin", "StreamAggregate", "Sort"} {
+		if !methods[method] {
+			t.Fatalf("expected %s to be costed:\n%s", method, trace)
+		}
+	}
+
+	if !strings.Contains(trace.String(), "pruned MergeJoin group") {
+		t.Fatalf("expected pruned merge joins in the trace:\n%s", trace)
+	}
+
+	// the root is chosen last, at the plan's cost
+	logicalPlan, err = plan.NewPlanner(cat).CreateLogicalPlan(parser.NewParser(`SELECT u.name FROM orders o JOIN users u ON o.user_id = u.id`).Parse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	traced, trace = NewOptimizer(cat).OptimizeTraced(logicalPlan)
+	chosen := trace.Filter(Chosen)
+	last := chosen[len(chosen)-1]
+	if last.After != format(traced) || math.Abs(last.Cost-EstimateCost(traced)) > 1e-6 {
+		t.Fatalf("expected the plan at cost %v to be chosen last, got:\n%s", EstimateCost(traced), last)
+	}
+}
+
+func TestHints(t *testing.T) {
+	cat := searchCatalog()
+	orders, _ := cat.GetTable("orders")
+	orders.Indexes = []catalog.Index{{Name: "idx_user_id", Columns: []string{"user_id"}}}
+
+	// join methods
+	for hint, method := range map[string]plan.JoinMethod{"HASH_JOIN": plan.HashJoin, "MERGE_JOIN": plan.MergeJoin, "NL_JOIN": plan.NestedLoopJoin} {
+		o := NewOptimizer(cat)
+		optimized := optimizeWith(t, o, `SELECT /*+ `+hint+`(u o) */ u.name FROM users u JOIN orders o ON u.id = o.user_id`)
+		if j := findJoin(optimized); j == nil || j.Method != method {
+			t.Fatalf("expected a %s:\n%s", method, format(optimized))
+		}
+		if len(o.Warnings()) > 0 {
+			t.Fatalf("unexpected warnings: %v", o.Warnings())
+		}
+	}
+
+	// the leading relations are joined first, in order
+	o := NewOptimizer(cat)
+	optimized := optimizeWith(t, o, `SELECT /*+ LEADING(u o i) MERGE_JOIN(o i) */ u.name FROM items i JOIN orders o ON i.order_id = o.id JOIN users u ON o.user_id = u.id`)
+	joins := joinsOf(optimized)
+	if len(joins) != 2 || joins[0].Method != plan.MergeJoin {
+		t.Fatalf("expected a merge join on top:\n%s", format(optimized))
+	}
+	if left := scanNames(joins[0].Left); fmt.Sprint(left) != "[u o]" {
+		t.Fatalf("expected u and o joined first, got %v:\n%s", left, format(optimized))
+	}
+	if len(o.Warnings()) > 0 {
+		t.Fatalf("unexpected warnings: %v", o.Warnings())
+	}
+
+	// access paths, table names work for aliased tables
+	o = NewOptimizer(cat)
+	optimized = optimizeWith(t, o, `SELECT /*+ INDEX(orders idx_user_id) */ u.name FROM users u JOIN orders o ON u.id = o.user_id WHERE o.user_id = 3`)
+	if scan := findScan(optimized, "orders"); scan.Index != "idx_user_id" || len(o.Warnings()) > 0 {
+		t.Fatalf("expected an index scan, warnings %v:\n%s", o.Warnings(), format(optimized))
+	}
+	optimized = optimize(t, cat, `SELECT /*+ FULL(o) */ u.name FROM users u JOIN orders o ON u.id = o.user_id WHERE o.user_id = 3`)
+	if scan := findScan(optimized, "orders"); scan.Index != "" {
+		t.Fatalf("expected a full scan:\n%s", format(optimized))
+	}
+
+	// predicates stay above the join
+	optimized = optimize(t, cat, `SELECT /*+ NO_PUSHDOWN */ u.name FROM users u JOIN orders o ON u.id = o.user_id WHERE o.amount > 10`)
+	if scanFilter(optimized, "orders") != nil || len(findScan(optimized, "orders").Pushed) > 0 {
+		t.Fatalf("expected the filter to stay above the join:\n%s", format(optimized))
+	}
+	// and out of scans and index lookups
+	o = NewOptimizer(cat)
+	optimized = optimizeWith(t, o, `SELECT /*+ NO_PUSHDOWN INDEX(o idx_user_id) */ o.id FROM orders o WHERE o.user_id = 3`)
+	explain := plan.FormatPlan(optimized, 0)
+	if !strings.Contains(explain, "  Filter((o.user_id = 3))\n    Scan(orders AS o, columns: [id, user_id])\n") {
+		t.Fatalf("expected the filter to stay above a plain scan:\n%s", explain)
+	}
+	if len(o.Warnings()) != 1 || !strings.Contains(o.Warnings()[0], "NO_PUSHDOWN") {
+		t.Fatalf("expected the index hint to give way to NO_PUSHDOWN, got %v", o.Warnings())
+	}
+
+	// hints that can't be honored warn, the query still plans
+	for query, warning := range map[string]string{
+		`SELECT /*+ BOGUS(u) */ u.name FROM users u`:                                                                           "hint BOGUS(u) ignored: unknown hint",
+		`SELECT /*+ FULL(zz) */ u.name FROM users u`:                                                                           "hint FULL(zz) ignored: no relation is named zz",
+		`SELECT /*+ HASH_JOIN(u o) */ u.name FROM users u JOIN orders o ON o.amount > u.age`:                                   "hint HASH_JOIN(u o) can't be honored",
+		`SELECT /*+ INDEX(o idx_user_id) */ u.name FROM users u JOIN orders o ON u.id = o.user_id`:                             "hint INDEX(o idx_user_id) can't be honored: no equality on o.user_id",
+		`SELECT /*+ INDEX(o idx_amount) */ o.id FROM orders o WHERE o.amount = 3`:                                              "hint INDEX(o idx_amount) can't be honored: orders has no index idx_amount",
+		`SELECT /*+ LEADING(i u o) */ u.name FROM items i JOIN orders o ON i.order_id = o.id JOIN users u ON o.user_id = u.id`: "hint LEADING(i u o) can't be honored",
+	} {
+		o := NewOptimizer(cat)
+		if optimizeWith(t, o, query) == nil {
+			t.Fatalf("expected a plan for %s", query)
+		}
+		if len(o.Warnings()) != 1 || !strings.HasPrefix(o.Warnings()[0], warning) {
+			t.Fatalf("expected %q for %s, got %v", warning, query, o.Warnings())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// moves filter conjuncts as close to the scans as they can go. Conjuncts
+// over one side of an inner join move into that side, conjuncts over both
+// sides become part of the join condition
+type PushDownPredicates struct{}
+
+func (r *PushDownPredicates) Name() string { return "PushDownPredicates" }
+
+func (r *PushDownPredicates) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		switch n.Input.(type) {
+		case *plan.LogicalJoin, *plan.LogicalFilter, *plan.LogicalUnnest:
+			return pushDown(n.Input, plan.SplitConjuncts(n.Predicate))
+		}
+	case *plan.LogicalJoin:
+		return pushDown(n, nil)
+	}
+
+	return node
+}
+
+// places preds at or below node
+func pushDown(node plan.LogicalPlan, preds []plan.Expr) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		return pushDown(n.Input, append(preds, plan.SplitConjuncts(n.Predicate)...))
+
+	case *plan.LogicalJoin:
+		return pushDownJoin(n, preds)
+
+	case *plan.LogicalUnnest:
+		return pushDownUnnest(n, preds)
+
+	default:
+		return withFilter(node, preds)
+	}
+}
+
+// conjuncts that don't read the unnested element filter the input rows
+// before they are repeated
+func pushDownUnnest(unnest *plan.LogicalUnnest, preds []plan.Expr) plan.LogicalPlan {
+	rels := relationsOf(unnest.Input)
+
+	var below, above []plan.Expr
+	for _, pred := range preds {
+		readsElem := plan.ContainsExpr(pred, func(e plan.Expr) bool {
+			col, ok := e.(*plan.ColumnExpr)
+			return ok && col.Column == unnest.Alias && (col.Table == "" || col.Table == unnest.Alias)
+		})
+		if _, ok := referencedRelations(pred, rels); ok && !readsElem {
+			below = append(below, pred)
+		} else {
+			above = append(above, pred)
+		}
+	}
+
+	c := *unnest
+	c.Input = pushDown(unnest.Input, below)
+	return withFilter(&c, above)
+}
+
+func pushDownJoin(join *plan.LogicalJoin, preds []plan.Expr) plan.LogicalPlan {
+	leftRels, rightRels := relationsOf(join.Left), relationsOf(join.Right)
+	rels := append(append([]relation{}, leftRels...), rightRels...)
+
+	var leftPreds, rightPreds, joinPreds, abovePreds []plan.Expr
+
+	// conjuncts from above the join
+	for _, pred := range preds {
+		refs, ok := referencedRelations(pred, rels)
+
+		switch {
+		case ok && subsetOf(refs, leftRels) && join.JoinType != plan.RightJoin:
+			leftPreds = append(leftPreds, pred)
+		case ok && subsetOf(refs, rightRels) && join.JoinType != plan.LeftJoin:
+			rightPreds = append(rightPreds, pred)
+		case ok && join.JoinType == plan.InnerJoin:
+			joinPreds = append(joinPreds, pred)
+		default:
+			// filters on the NULL padded side of an outer join stay above it
+			abovePreds = append(abovePreds, pred)
+		}
+	}
+
+	// conjuncts of the join condition, for outer joins only the side that
+	// gets NULL padded can be filtered early
+	for _, pred := range plan.SplitConjuncts(join.Condition) {
+		if lit, ok := pred.(*plan.LiteralExpr); ok && lit.Value == true {
+			continue
+		}
+		refs, ok := referencedRelations(pred, rels)
+
+		switch {
+		case ok && subsetOf(refs, leftRels) && join.JoinType != plan.LeftJoin:
+			leftPreds = append(leftPreds, pred)
+		case ok && subsetOf(refs, rightRels) && join.JoinType != plan.RightJoin:
+			rightPreds = append(rightPreds, pred)
+		default:
+			joinPreds = append(joinPreds, pred)
+		}
+	}
+
+	out := *join
+	out.Left = pushDown(join.Left, leftPreds)
+	out.Right = pushDown(join.Right, rightPreds)
+	out.Condition = plan.CombineConjuncts(joinPreds)
+	if out.Condition == nil {
+		out.Condition = newLiteral(true)
+	}
+
+	return withFilter(&out, abovePreds)
+}
+
+func withFilter(node plan.LogicalPlan, preds []plan.Expr) plan.LogicalPlan {
+	if len(preds) == 0 {
+		return node
+	}
+
+	return &plan.LogicalFilter{Input: node, Predicate: plan.CombineConjuncts(preds)}
+}
@@ -0,0 +1,192 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// tells each scan's source what it has to produce: the simple conjuncts of
+// the filter right above it, an index those conjuncts pin with =, and the
+// columns the rest of the plan reads
+type PushIntoScans struct {
+	keepPredicates bool // only columns are pushed, NO_PUSHDOWN
+}
+
+func (r *PushIntoScans) Name() string { return "PushIntoScans" }
+
+func (r *PushIntoScans) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		scan, ok := n.Input.(*plan.LogicalScan)
+		if !ok || r.keepPredicates {
+			return node
+		}
+
+		c := *scan
+		c.Pushed = scanPredicates(n.Predicate, scan)
+		c.Index = pinnedIndex(&c)
+		return &plan.LogicalFilter{Input: &c, Predicate: n.Predicate}
+
+	case *plan.LogicalProject:
+		// the root, every column reference is visible from here
+		if used, ok := usedColumns(n); ok {
+			return pruneScans(n, used)
+		}
+	}
+
+	return node
+}
+
+// conjuncts of pred a source can evaluate, with columns of scan
+func scanPredicates(pred plan.Expr, scan *plan.LogicalScan) []storage.Predicate {
+	var preds []storage.Predicate
+
+	for _, conj := range plan.SplitConjuncts(pred) {
+		switch e := conj.(type) {
+		case *plan.IsNullExpr:
+			if col, ok := scanColumn(e.Expr, scan); ok {
+				op := "IS NULL"
+				if e.Not {
+					op = "IS NOT NULL"
+				}
+				preds = append(preds, storage.Predicate{Column: col, Op: op})
+			}
+
+		case *plan.BinaryExpr:
+			if !storage.IsPredicateOp(e.Operator) {
+				continue
+			}
+			col, ok := scanColumn(e.Left, scan)
+			lit, isLit := e.Right.(*plan.LiteralExpr)
+			if ok && isLit && lit.Value != nil {
+				preds = append(preds, storage.Predicate{Column: col, Op: e.Operator, Value: lit.Value})
+			}
+		}
+	}
+
+	return preds
+}
+
+// name of the scan's column expr refers to
+func scanColumn(expr plan.Expr, scan *plan.LogicalScan) (string, bool) {
+	col, ok := expr.(*plan.ColumnExpr)
+	if !ok {
+		return "", false
+	}
+	if col.Table != "" && col.Table != scan.QualifiedName() {
+		return "", false
+	}
+	if _, err := scan.Table.GetColumn(col.Column); err != nil {
+		return "", false
+	}
+
+	return col.Column, true
+}
+
+// index whose columns all have an equality among the pushed predicates,
+// the one with the most columns wins
+func pinnedIndex(scan *plan.LogicalScan) string {
+	pinned := make(map[string]bool)
+	for _, p := range scan.Pushed {
+		if p.Op == "=" {
+			pinned[p.Column] = true
+		}
+	}
+
+	best, width := "", 0
+	for _, idx := range scan.Table.Indexes {
+		covered := len(idx.Columns) > 0
+		for _, col := range idx.Columns {
+			covered = covered && pinned[col]
+		}
+		if covered && len(idx.Columns) > width {
+			best, width = idx.Name, len(idx.Columns)
+		}
+	}
+
+	return best
+}
+
+// columns each relation has to produce, keyed by relation name. False when
+// some column can't be told apart from a document key the catalog doesn't
+// know about
+func usedColumns(root plan.LogicalPlan) (map[string]map[string]bool, bool) {
+	var scans []*plan.LogicalScan
+	produced := make(map[string]bool)
+	var exprs []plan.Expr
+
+	var walk func(node plan.LogicalPlan)
+	walk = func(node plan.LogicalPlan) {
+		switch n := node.(type) {
+		case *plan.LogicalScan:
+			scans = append(scans, n)
+		case *plan.LogicalProject:
+			for _, name := range n.ColumnNames {
+				produced[name] = true
+			}
+		case *plan.LogicalAggregate, *plan.LogicalWindow, *plan.LogicalUnnest, *plan.LogicalView:
+			for _, col := range n.Schema() {
+				produced[col.Name] = true
+			}
+		}
+		exprs = append(exprs, nodeExprs(node)...)
+		for _, child := range node.Children() {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	used := make(map[string]map[string]bool)
+	for _, scan := range scans {
+		used[scan.QualifiedName()] = make(map[string]bool)
+	}
+
+	ok := true
+	for _, expr := range exprs {
+		plan.WalkExpr(expr, func(e plan.Expr) bool {
+			col, isCol := e.(*plan.ColumnExpr)
+			if !isCol {
+				return true
+			}
+
+			found := false
+			for _, scan := range scans {
+				name, isScanCol := scanColumn(col, scan)
+				if isScanCol {
+					used[scan.QualifiedName()][name] = true
+					found = true
+				}
+			}
+			if !found && !produced[col.Column] {
+				ok = false
+			}
+			return true
+		})
+	}
+
+	return used, ok
+}
+
+func pruneScans(node plan.LogicalPlan, used map[string]map[string]bool) plan.LogicalPlan {
+	if scan, ok := node.(*plan.LogicalScan); ok {
+		c := *scan
+		c.Columns = []string{}
+		for _, col := range scan.Table.Columns {
+			if used[scan.QualifiedName()][col.Name] {
+				c.Columns = append(c.Columns, col.Name)
+			}
+		}
+		return &c
+	}
+
+	children := node.Children()
+	if len(children) == 0 {
+		return node
+	}
+	rewritten := make([]plan.LogicalPlan, len(children))
+	for i, child := range children {
+		rewritten[i] = pruneScans(child, used)
+	}
+
+	return plan.WithChildren(node, rewritten)
+}
@@ -0,0 +1,494 @@
+package optimizer
+
+import (
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+// folds constants, simplifies boolean identities and moves columns to the
+// left of comparisons. Filters that always pass are dropped and filters
+// that never pass become an empty result
+type SimplifyExpressions struct{}
+
+func (r *SimplifyExpressions) Name() string { return "SimplifyExpressions" }
+
+func (r *SimplifyExpressions) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	switch n := node.(type) {
+	case *plan.LogicalFilter:
+		pred, contradiction := SimplifyPredicate(n.Predicate)
+		if contradiction {
+			return &plan.LogicalEmpty{Columns: n.Schema(), Reason: "contradiction " + n.Predicate.String()}
+		}
+		if pred == nil {
+			return n.Input
+		}
+		if pred.String() == n.Predicate.String() {
+			return n
+		}
+		return &plan.LogicalFilter{Input: n.Input, Predicate: pred}
+
+	case *plan.LogicalJoin:
+		if n.Condition == nil {
+			return n
+		}
+
+		cond, contradiction := SimplifyPredicate(n.Condition)
+		if contradiction && n.JoinType == plan.InnerJoin {
+			return &plan.LogicalEmpty{Columns: n.Schema(), Reason: "contradiction " + n.Condition.String()}
+		}
+		if cond == nil {
+			cond = newLiteral(!contradiction)
+		}
+		if cond.String() == n.Condition.String() {
+			return n
+		}
+
+		join := *n
+		join.Condition = cond
+		return &join
+
+	case *plan.LogicalProject:
+		project := *n
+		project.Projections = make([]plan.Expr, len(n.Projections))
+		for i, expr := range n.Projections {
+			project.Projections[i] = SimplifyExpr(expr)
+		}
+		return &project
+
+	default:
+		return node
+	}
+}
+
+// simplifies a filter predicate. Always true conjuncts are dropped, so nil
+// means the predicate always passes, the bool reports a contradiction
+func SimplifyPredicate(pred plan.Expr) (plan.Expr, bool) {
+	pred = SimplifyExpr(pred)
+
+	var kept []plan.Expr
+	seen := make(map[string]bool)
+
+	for _, conjunct := range plan.SplitConjuncts(pred) {
+		if lit, ok := conjunct.(*plan.LiteralExpr); ok {
+			if lit.Value == true {
+				continue
+			}
+			// FALSE and NULL never pass a filter
+			return nil, true
+		}
+
+		if seen[conjunct.String()] {
+			continue
+		}
+		seen[conjunct.String()] = true
+		kept = append(kept, conjunct)
+	}
+
+	if contradicts(kept) {
+		return nil, true
+	}
+
+	return plan.CombineConjuncts(kept), false
+}
+
+func SimplifyExpr(expr plan.Expr) plan.Expr {
+	return plan.TransformExprUp(expr, simplifyNode)
+}
+
+// children of expr are already simplified
+func simplifyNode(expr plan.Expr) plan.Expr {
+	switch e := expr.(type) {
+	case *plan.BinaryExpr:
+		return simplifyBinary(e)
+
+	case *plan.NotExpr:
+		if lit, ok := e.Expr.(*plan.LiteralExpr); ok {
+			if lit.Value == nil {
+				return lit
+			}
+			b, _ := lit.Value.(bool)
+			return newLiteral(!b)
+		}
+		if inner, ok := e.Expr.(*plan.NotExpr); ok {
+			return inner.Expr
+		}
+		if inner, ok := e.Expr.(*plan.IsNullExpr); ok {
+			return &plan.IsNullExpr{Expr: inner.Expr, Not: !inner.Not}
+		}
+		if inner, ok := e.Expr.(*plan.BinaryExpr); ok {
+			if op, ok := negatedOps[inner.Operator]; ok {
+				return &plan.BinaryExpr{Left: inner.Left, Operator: op, Right: inner.Right}
+			}
+		}
+
+	case *plan.IsNullExpr:
+		if lit, ok := e.Expr.(*plan.LiteralExpr); ok {
+			return newLiteral((lit.Value == nil) != e.Not)
+		}
+
+	case *plan.FuncCallExpr:
+		if !e.Func.Deterministic || !allLiterals(e.Args) {
+			return e
+		}
+
+		args := make([]interface{}, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = arg.(*plan.LiteralExpr).Value
+		}
+		// errors are left for the executor to report
+		args, err := e.Func.Coerce(args)
+		if err != nil {
+			return e
+		}
+		if val, err := e.Func.Eval(args); err == nil {
+			return typedLiteral(val, e.Type)
+		}
+
+	case *plan.CastExpr:
+		if lit, ok := e.Expr.(*plan.LiteralExpr); ok {
+			if val, err := function.CastTo(lit.Value, e.Type, e.Precision, e.Scale); err == nil {
+				return typedLiteral(val, e.Type)
+			}
+		}
+
+	case *plan.CaseExpr:
+		return simplifyCase(e)
+	}
+
+	return expr
+}
+
+var flippedOps = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	"<>": "<>",
+	"<":  ">",
+	">":  "<",
+	"<=": ">=",
+	">=": "<=",
+}
+
+var negatedOps = map[string]string{
+	"=":  "!=",
+	"!=": "=",
+	"<>": "=",
+	"<":  ">=",
+	">":  "<=",
+	"<=": ">",
+	">=": "<",
+}
+
+func simplifyBinary(b *plan.BinaryExpr) plan.Expr {
+	left, leftLit := b.Left.(*plan.LiteralExpr)
+	right, rightLit := b.Right.(*plan.LiteralExpr)
+
+	if leftLit && rightLit {
+		if val, err := function.EvalBinary(left.Value, b.Operator, right.Value); err == nil {
+			return newLiteral(val)
+		}
+		return b
+	}
+
+	switch b.Operator {
+	case "AND":
+		switch {
+		case isBool(left, false) || isBool(right, false):
+			return newLiteral(false)
+		case isBool(left, true):
+			return b.Right
+		case isBool(right, true):
+			return b.Left
+		case b.Left.String() == b.Right.String():
+			return b.Left
+		}
+		return b
+
+	case "OR":
+		switch {
+		case isBool(left, true) || isBool(right, true):
+			return newLiteral(true)
+		case isBool(left, false):
+			return b.Right
+		case isBool(right, false):
+			return b.Left
+		case b.Left.String() == b.Right.String():
+			return b.Left
+		}
+		return b
+	}
+
+	// comparisons and arithmetic with NULL are NULL
+	if (leftLit && left.Value == nil) || (rightLit && right.Value == nil) {
+		return &plan.LiteralExpr{Value: nil, Type: catalog.NullType}
+	}
+
+	flipped, isComparison := flippedOps[b.Operator]
+	if !isComparison {
+		return b
+	}
+
+	// x = x holds for every non NULL x
+	if b.Left.String() == b.Right.String() && deterministic(b.Left) {
+		switch b.Operator {
+		case "=", "<=", ">=":
+			return &plan.IsNullExpr{Expr: b.Left, Not: true}
+		}
+	}
+
+	_, leftCol := b.Left.(*plan.ColumnExpr)
+	_, rightCol := b.Right.(*plan.ColumnExpr)
+	if (rightCol && !leftCol) || (leftLit && !rightLit) {
+		return &plan.BinaryExpr{Left: b.Right, Operator: flipped, Right: b.Left}
+	}
+
+	return b
+}
+
+// drops WHEN branches with constant conditions
+func simplifyCase(c *plan.CaseExpr) plan.Expr {
+	if c.Operand != nil {
+		return c
+	}
+
+	out := &plan.CaseExpr{Else: c.Else, Type: c.Type}
+	for _, w := range c.Whens {
+		lit, ok := w.Condition.(*plan.LiteralExpr)
+		if !ok {
+			out.Whens = append(out.Whens, w)
+			continue
+		}
+
+		if lit.Value == true {
+			out.Else = w.Result
+			break
+		}
+		// FALSE and NULL conditions never match
+	}
+
+	if len(out.Whens) == 0 {
+		if out.Else == nil {
+			return &plan.LiteralExpr{Value: nil, Type: c.Type}
+		}
+		return out.Else
+	}
+
+	return out
+}
+
+// per column bounds implied by col op literal conjuncts
+type columnBounds struct {
+	eq      []interface{}
+	neq     []interface{}
+	lo, hi  interface{}
+	loIncl  bool
+	hiIncl  bool
+	isNull  bool
+	notNull bool
+}
+
+// reports whether ANDed conjuncts can never all hold, like a = 1 AND a = 2
+func contradicts(conjuncts []plan.Expr) bool {
+	for _, b := range boundsOf(conjuncts, (*plan.ColumnExpr).String) {
+		if b.empty() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bounds of the columns conjuncts compare to literals, keyed by key
+func boundsOf(conjuncts []plan.Expr, key func(*plan.ColumnExpr) string) map[string]*columnBounds {
+	bounds := make(map[string]*columnBounds)
+	get := func(col *plan.ColumnExpr) *columnBounds {
+		k := key(col)
+		if bounds[k] == nil {
+			bounds[k] = &columnBounds{}
+		}
+		return bounds[k]
+	}
+
+	for _, conjunct := range conjuncts {
+		switch c := conjunct.(type) {
+		case *plan.IsNullExpr:
+			if col, ok := c.Expr.(*plan.ColumnExpr); ok {
+				b := get(col)
+				if c.Not {
+					b.notNull = true
+				} else {
+					b.isNull = true
+				}
+			}
+
+		case *plan.BinaryExpr:
+			col, ok := c.Left.(*plan.ColumnExpr)
+			lit, ok2 := c.Right.(*plan.LiteralExpr)
+			if !ok || !ok2 || lit.Value == nil {
+				continue
+			}
+
+			b := get(col)
+			b.notNull = true
+			v := lit.Value
+
+			switch c.Operator {
+			case "=":
+				b.eq = append(b.eq, v)
+			case "!=", "<>":
+				b.neq = append(b.neq, v)
+			case ">", ">=":
+				if b.lo == nil || !sameKind(b.lo, v) || function.Compare(v, b.lo) > 0 {
+					b.lo, b.loIncl = v, c.Operator == ">="
+				} else if function.Compare(v, b.lo) == 0 && c.Operator == ">" {
+					b.loIncl = false
+				}
+			case "<", "<=":
+				if b.hi == nil || !sameKind(b.hi, v) || function.Compare(v, b.hi) < 0 {
+					b.hi, b.hiIncl = v, c.Operator == "<="
+				} else if function.Compare(v, b.hi) == 0 && c.Operator == "<" {
+					b.hiIncl = false
+				}
+			}
+		}
+	}
+
+	return bounds
+}
+
+func (b *columnBounds) empty() bool {
+	if b.isNull && b.notNull {
+		return true
+	}
+
+	if b.lo != nil && b.hi != nil && sameKind(b.lo, b.hi) {
+		c := function.Compare(b.lo, b.hi)
+		if c > 0 || (c == 0 && !(b.loIncl && b.hiIncl)) {
+			return true
+		}
+	}
+
+	for i, v := range b.eq {
+		if i > 0 && sameKind(v, b.eq[0]) && !function.Equal(v, b.eq[0]) {
+			return true
+		}
+		for _, n := range b.neq {
+			if sameKind(v, n) && function.Equal(v, n) {
+				return true
+			}
+		}
+		if b.lo != nil && sameKind(v, b.lo) {
+			if c := function.Compare(v, b.lo); c < 0 || (c == 0 && !b.loIncl) {
+				return true
+			}
+		}
+		if b.hi != nil && sameKind(v, b.hi) {
+			if c := function.Compare(v, b.hi); c > 0 || (c == 0 && !b.hiIncl) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// both numbers, both strings or both booleans
+func sameKind(a, b interface{}) bool {
+	return kindOf(a) == kindOf(b)
+}
+
+func kindOf(v interface{}) catalog.DataType {
+	switch v.(type) {
+	case int, int64:
+		return catalog.IntType
+	case float64:
+		return catalog.FloatType
+	case types.Decimal:
+		return catalog.DecimalType
+	case types.Date:
+		return catalog.DateType
+	case types.Timestamp:
+		return catalog.TimestampType
+	case types.Interval:
+		return catalog.IntervalType
+	case string:
+		return catalog.StringType
+	case bool:
+		return catalog.BoolType
+	default:
+		return catalog.NullType
+	}
+}
+
+func newLiteral(v interface{}) *plan.LiteralExpr {
+	return &plan.LiteralExpr{Value: v, Type: kindOf(v)}
+}
+
+// literal keeping the type the binder inferred
+func typedLiteral(v interface{}, t catalog.DataType) *plan.LiteralExpr {
+	if v == nil {
+		return &plan.LiteralExpr{Value: nil, Type: t}
+	}
+	return newLiteral(v)
+}
+
+func isBool(lit *plan.LiteralExpr, want bool) bool {
+	return lit != nil && lit.Value == want
+}
+
+func allLiterals(exprs []plan.Expr) bool {
+	for _, e := range exprs {
+		if _, ok := e.(*plan.LiteralExpr); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// no calls to functions that may return different results
+func deterministic(expr plan.Expr) bool {
+	return !plan.ContainsExpr(expr, func(e plan.Expr) bool {
+		call, ok := e.(*plan.FuncCallExpr)
+		return ok && !call.Func.Deterministic
+	})
+}
+
+// an empty input makes filters, inner joins, windows, projections and
+// grouped aggregates empty as well
+type PropagateEmpty struct{}
+
+func (r *PropagateEmpty) Name() string { return "PropagateEmpty" }
+
+func (r *PropagateEmpty) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	var empty *plan.LogicalEmpty
+
+	switch n := node.(type) {
+	case *plan.LogicalFilter, *plan.LogicalProject, *plan.LogicalWindow, *plan.LogicalView:
+		empty, _ = node.Children()[0].(*plan.LogicalEmpty)
+
+	case *plan.LogicalAggregate:
+		// without GROUP BY an empty input still gives one row
+		if len(n.GroupBy) > 0 {
+			empty, _ = n.Input.(*plan.LogicalEmpty)
+		}
+
+	case *plan.LogicalJoin:
+		left, leftEmpty := n.Left.(*plan.LogicalEmpty)
+		right, rightEmpty := n.Right.(*plan.LogicalEmpty)
+
+		switch {
+		case leftEmpty && n.JoinType != plan.RightJoin:
+			empty = left
+		case rightEmpty && n.JoinType != plan.LeftJoin:
+			empty = right
+		}
+	}
+
+	if empty == nil {
+		return node
+	}
+
+	return &plan.LogicalEmpty{Columns: node.Schema(), Reason: empty.Reason}
+}
@@ -0,0 +1,170 @@
+package optimizer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+type TraceKind int
+
+const (
+	RuleApplied TraceKind = iota // a rewrite or memo rule changed the plan
+	Considered                   // an alternative was costed in full
+	Pruned                       // an alternative was given up
+	Chosen                       // cheapest plan of a group for an order
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case RuleApplied:
+		return "rule"
+	case Considered:
+		return "considered"
+	case Pruned:
+		return "pruned"
+	case Chosen:
+		return "chosen"
+	default:
+		return "unknown"
+	}
+}
+
+// one step of the optimizer. Memo fragments show child groups as
+// Group(n)
+type TraceEvent struct {
+	Kind   TraceKind
+	Rule   string // rule applied, or the operator considered
+	Group  int    // memo group, -1 for rewrites before the search
+	Order  string // order required of the group, empty for any
+	Before string
+	After  string
+	Cost   float64
+	Reason string // why an alternative was pruned
+}
+
+// what the optimizer did with one plan, in order
+type Trace struct {
+	Events []TraceEvent
+}
+
+func (t *Trace) add(ev TraceEvent) {
+	if t != nil {
+		t.Events = append(t.Events, ev)
+	}
+}
+
+// events of kind, every event without kinds
+func (t *Trace) Filter(kinds ...TraceKind) []TraceEvent {
+	var out []TraceEvent
+	for _, ev := range t.Events {
+		if len(kinds) == 0 || slices.Contains(kinds, ev.Kind) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func (t *Trace) String() string {
+	var b strings.Builder
+	for _, ev := range t.Events {
+		b.WriteString(ev.String())
+	}
+	return b.String()
+}
+
+func (ev TraceEvent) String() string {
+	var b strings.Builder
+
+	where := ""
+	if ev.Group >= 0 {
+		where = fmt.Sprintf(" group %d", ev.Group)
+		if ev.Order != "" {
+			where += fmt.Sprintf(" ordered by %s", ev.Order)
+		}
+	}
+
+	switch ev.Kind {
+	case RuleApplied:
+		fmt.Fprintf(&b, "rule %s%s\n", ev.Rule, where)
+		b.WriteString(indent("before:", ev.Before))
+		b.WriteString(indent("after:", ev.After))
+	case Considered:
+		fmt.Fprintf(&b, "considered %s%s, cost %.2f\n", ev.Rule, where, ev.Cost)
+		b.WriteString(indent("", ev.After))
+	case Pruned:
+		fmt.Fprintf(&b, "pruned %s%s: %s\n", ev.Rule, where, ev.Reason)
+		b.WriteString(indent("", ev.After))
+	case Chosen:
+		fmt.Fprintf(&b, "chosen%s, cost %.2f\n", where, ev.Cost)
+		b.WriteString(indent("", ev.After))
+	}
+
+	return b.String()
+}
+
+// plan fragment under a label, indented below the event's line
+func indent(label, fragment string) string {
+	var b strings.Builder
+	if label != "" {
+		b.WriteString("  " + label + "\n")
+	}
+	for _, line := range strings.Split(strings.TrimRight(fragment, "\n"), "\n") {
+		if line != "" {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// records the nodes a rewrite rule changes
+type tracedRule struct {
+	Rule
+	trace *Trace
+}
+
+func (r *tracedRule) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	out := r.Rule.Apply(node)
+
+	before, after := plan.FormatPlan(node, 0), plan.FormatPlan(out, 0)
+	if before != after {
+		r.trace.add(TraceEvent{Kind: RuleApplied, Rule: r.Name(), Group: -1, Before: before, After: after})
+	}
+	return out
+}
+
+// memo rule being applied, to the expressions it adds
+type firing struct {
+	rule   string
+	source *groupExpr
+}
+
+// operator of a physical alternative and its fragment over child groups
+func describe(alt physical, e *groupExpr) (string, string) {
+	node := alt.node
+	if len(e.children) > 0 {
+		refs := make([]plan.LogicalPlan, len(e.children))
+		for i, child := range e.children {
+			refs[i] = &groupRef{child}
+		}
+		node = plan.WithChildren(node, refs)
+	}
+
+	name, _, _ := strings.Cut(alt.node.String(), "(")
+	return name, plan.FormatPlan(node, 0)
+}
+
+// expression with its child groups, as a plan fragment
+func (e *groupExpr) String() string {
+	if len(e.children) == 0 {
+		return plan.FormatPlan(e.node, 0)
+	}
+
+	refs := make([]plan.LogicalPlan, len(e.children))
+	for i, child := range e.children {
+		refs[i] = &groupRef{child}
+	}
+	return plan.FormatPlan(plan.WithChildren(e.node, refs), 0)
+}
@@ -0,0 +1,222 @@
+package optimizer
+
+import (
+	"math"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// memo rewrite, adds the expressions equivalent to e that it knows of
+type transformation interface {
+	name() string
+	apply(m *memo, e *groupExpr)
+}
+
+var transformations = []transformation{
+	joinCommute{},
+	joinAssociate{},
+	aggregatePushdown{},
+}
+
+// A ⋈ B to B ⋈ A
+type joinCommute struct{}
+
+func (joinCommute) name() string { return "JoinCommute" }
+
+func (joinCommute) apply(m *memo, e *groupExpr) {
+	join, ok := e.node.(*plan.LogicalJoin)
+	if !ok || join.JoinType != plan.InnerJoin || e.fixed {
+		return
+	}
+
+	m.add(&groupExpr{node: join, children: []*group{e.children[1], e.children[0]}}, e.group)
+}
+
+// (A ⋈ B) ⋈ C to A ⋈ (B ⋈ C), conditions move to the lowest join that
+// has their relations. Skipped when B ⋈ C would be a cross product
+type joinAssociate struct{}
+
+func (joinAssociate) name() string { return "JoinAssociate" }
+
+func (joinAssociate) apply(m *memo, e *groupExpr) {
+	join, ok := e.node.(*plan.LogicalJoin)
+	if !ok || join.JoinType != plan.InnerJoin || e.fixed {
+		return
+	}
+
+	c := e.children[1]
+	for _, x := range e.children[0].exprs {
+		lower, ok := x.node.(*plan.LogicalJoin)
+		if !ok || lower.JoinType != plan.InnerJoin || x.fixed {
+			continue
+		}
+		a, b := x.children[0], x.children[1]
+
+		inner := append(append([]relation{}, b.rels...), c.rels...)
+		all := append(append([]relation{}, a.rels...), inner...)
+		var below, above []plan.Expr
+		for _, conj := range append(plan.SplitConjuncts(lower.Condition), plan.SplitConjuncts(join.Condition)...) {
+			if refs, ok := referencedRelations(conj, all); ok && len(refs) > 0 && subsetOf(refs, inner) {
+				below = append(below, conj)
+			} else {
+				above = append(above, conj)
+			}
+		}
+		if len(below) == 0 {
+			continue
+		}
+
+		bc := m.add(&groupExpr{
+			node:     &plan.LogicalJoin{JoinType: plan.InnerJoin, Condition: plan.CombineConjuncts(below)},
+			children: []*group{b, c},
+		}, nil)
+
+		cond := plan.CombineConjuncts(above)
+		if cond == nil {
+			cond = newLiteral(true)
+		}
+		m.add(&groupExpr{
+			node:     &plan.LogicalJoin{JoinType: plan.InnerJoin, Condition: cond},
+			children: []*group{a, bc},
+		}, e.group)
+	}
+}
+
+// Aggregate(L ⋈ R) to Aggregate(Aggregate(L) ⋈ R) when the aggregates
+// only read L. L is grouped by the columns of it the join and the grouping
+// read, the aggregate above combines the partial results: sums, minimums
+// and maximums again, counts are summed
+type aggregatePushdown struct{}
+
+func (aggregatePushdown) name() string { return "AggregatePushdown" }
+
+func (aggregatePushdown) apply(m *memo, e *groupExpr) {
+	agg, ok := e.node.(*plan.LogicalAggregate)
+	if !ok {
+		return
+	}
+
+	for _, x := range e.children[0].exprs {
+		join, ok := x.node.(*plan.LogicalJoin)
+		if !ok || join.JoinType != plan.InnerJoin || x.fixed {
+			continue
+		}
+		project, ok := m.pushAggregate(agg, join, x.children[0], x.children[1])
+		if !ok {
+			continue
+		}
+
+		top := project.Input.(*plan.LogicalAggregate)
+		joined := top.Input.(*plan.LogicalJoin)
+		partial := m.insert(joined.Left, true)
+		below := m.add(&groupExpr{node: joined, children: []*group{partial, x.children[1]}}, nil)
+		// each group of L's rows joins where its rows did, estimates can't
+		// see through the partial aggregate
+		below.rows = math.Min(below.rows, x.group.rows*partial.rows/math.Max(x.children[0].rows, 1))
+
+		top.Input = &groupRef{below}
+		above := m.insert(top, true)
+		above.rows = e.group.rows
+		m.insertInto(&plan.LogicalProject{Input: &groupRef{above}, Projections: project.Projections, ColumnNames: project.ColumnNames}, true, e.group)
+	}
+}
+
+func (m *memo) pushAggregate(agg *plan.LogicalAggregate, join *plan.LogicalJoin, left, right *group) (*plan.LogicalProject, bool) {
+	leftRels := left.rels
+	all := append(append([]relation{}, left.rels...), right.rels...)
+	if len(leftRels) == 0 || !left.block || !right.block {
+		return nil, false
+	}
+
+	// columns of L read above the partial aggregate
+	partial := &plan.LogicalAggregate{Input: &groupRef{left}}
+	seen := make(map[string]bool)
+	collect := func(expr plan.Expr) bool {
+		ok := true
+		plan.WalkExpr(expr, func(x plan.Expr) bool {
+			col, isCol := x.(*plan.ColumnExpr)
+			if !isCol {
+				return true
+			}
+			q, resolved := qualifiedColumn(col, all)
+			if !resolved {
+				ok = false
+				return false
+			}
+			if subsetOf(map[string]bool{q.Table: true}, leftRels) && !seen[q.String()] {
+				seen[q.String()] = true
+				partial.GroupBy = append(partial.GroupBy, q)
+				partial.GroupNames = append(partial.GroupNames, q.String())
+			}
+			return true
+		})
+		return ok
+	}
+	for _, conj := range plan.SplitConjuncts(join.Condition) {
+		if !collect(conj) {
+			return nil, false
+		}
+	}
+	for _, g := range agg.GroupBy {
+		if !collect(g) {
+			return nil, false
+		}
+	}
+
+	sum, ok := m.functions.LookupAggregate("SUM")
+	if !ok {
+		return nil, false
+	}
+
+	top := &plan.LogicalAggregate{
+		Input:      &plan.LogicalJoin{Left: partial, Right: &groupRef{right}, JoinType: plan.InnerJoin, Condition: join.Condition},
+		GroupBy:    agg.GroupBy,
+		GroupNames: agg.GroupNames,
+	}
+	project := &plan.LogicalProject{Input: top, ColumnNames: append(append([]string{}, agg.GroupNames...), agg.AggregateNames...)}
+	for _, name := range agg.GroupNames {
+		project.Projections = append(project.Projections, &plan.ColumnExpr{Column: name})
+	}
+
+	for i, a := range agg.Aggregates {
+		for _, arg := range a.Args {
+			if _, star := arg.(*plan.StarExpr); star {
+				continue
+			}
+			if refs, ok := referencedRelations(arg, all); !ok || !subsetOf(refs, leftRels) {
+				return nil, false
+			}
+		}
+
+		name := agg.AggregateNames[i]
+		partial.Aggregates = append(partial.Aggregates, a)
+		partial.AggregateNames = append(partial.AggregateNames, name)
+		col := &plan.ColumnExpr{Column: name}
+
+		var expr plan.Expr = col
+		switch a.Name {
+		case "SUM", "MIN", "MAX":
+			top.Aggregates = append(top.Aggregates, &plan.AggregateExpr{Name: a.Name, Args: []plan.Expr{col}, Func: a.Func, Type: a.Type})
+
+		case "COUNT":
+			top.Aggregates = append(top.Aggregates, &plan.AggregateExpr{Name: "SUM", Args: []plan.Expr{col}, Func: sum, Type: catalog.IntType})
+			// without groups an empty join still gives a row, counting 0
+			if len(agg.GroupBy) == 0 {
+				coalesce, err := m.functions.LookupScalar("COALESCE")
+				if err != nil {
+					return nil, false
+				}
+				expr = &plan.FuncCallExpr{Name: "COALESCE", Args: []plan.Expr{expr, newLiteral(0)}, Func: coalesce, Type: catalog.IntType}
+			}
+			expr = &plan.CastExpr{Expr: expr, Type: catalog.IntType}
+
+		default:
+			return nil, false
+		}
+		top.AggregateNames = append(top.AggregateNames, name)
+		project.Projections = append(project.Projections, expr)
+	}
+
+	return project, true
+}
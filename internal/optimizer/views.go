@@ -0,0 +1,644 @@
+package optimizer
+
+import (
+	"slices"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+)
+
+// answers an aggregate or projection over joins and filters from a
+// materialized view holding its rows. The view has to join the same tables,
+// or more along foreign keys that drop no rows, and its filters have to
+// keep every row the query's keep. Filters the view lacks are applied to
+// its rows and a view grouped more finely than the query is aggregated
+// again, SUM of its sums or of its counts. The view is read when that is
+// estimated cheaper, stale views are never used
+type UseMaterializedViews struct {
+	catalog   *catalog.Catalog
+	functions *function.Registry
+}
+
+func (r *UseMaterializedViews) Name() string { return "UseMaterializedViews" }
+
+func (r *UseMaterializedViews) Apply(node plan.LogicalPlan) plan.LogicalPlan {
+	q, ok := viewQueryOf(node)
+	if !ok {
+		return node
+	}
+
+	best, cost := node, EstimateCost(node)
+	for _, view := range r.views() {
+		answer, ok := view.answer(q, r.functions)
+		if !ok {
+			continue
+		}
+		if c := EstimateCost(answer); c < cost {
+			best, cost = answer, c
+		}
+	}
+
+	return best
+}
+
+// materialized views that are up to date with their tables. Views from
+// before their tables were recorded can't tell and are left out
+func (r *UseMaterializedViews) views() []*viewDef {
+	var views []*viewDef
+	for _, table := range r.catalog.Views() {
+		if !table.Materialized || table.Stale || len(table.Reads) == 0 {
+			continue
+		}
+		if view, ok := defineView(r.catalog, table); ok {
+			views = append(views, view)
+		}
+	}
+
+	return views
+}
+
+// inner joins and filters over scans of distinct tables. Conjuncts have
+// their columns qualified with table names, which queries and views agree on
+type block struct {
+	tables    map[string]*catalog.TableInfo
+	conjuncts []plan.Expr
+	classes   *equivalence             // columns the conjuncts make equal
+	bounds    map[string]*columnBounds // by class
+	keys      map[string]bool          // conjuncts by key
+}
+
+func blockOf(node plan.LogicalPlan) (*block, []relation, bool) {
+	if !innerJoinsOnly(node) {
+		return nil, nil, false
+	}
+
+	rels := relationsOf(node)
+	b := &block{tables: make(map[string]*catalog.TableInfo), classes: newEquivalence(), keys: make(map[string]bool)}
+	for _, rel := range rels {
+		if _, ok := b.tables[rel.scan.TableName]; ok {
+			return nil, nil, false
+		}
+		b.tables[rel.scan.TableName] = rel.scan.Table
+	}
+
+	for _, c := range innerConditions(node) {
+		if lit, ok := c.(*plan.LiteralExpr); ok && lit.Value == true {
+			continue
+		}
+		c, ok := canonical(c, rels)
+		if !ok || !deterministic(c) {
+			return nil, nil, false
+		}
+		if l, r, ok := columnEquality(c); ok {
+			b.classes.union(l.String(), r.String())
+		}
+		b.conjuncts = append(b.conjuncts, c)
+	}
+
+	b.bounds = boundsOf(b.conjuncts, func(col *plan.ColumnExpr) string { return b.classes.find(col.String()) })
+	for _, c := range b.conjuncts {
+		b.keys[b.key(c)] = true
+	}
+	return b, rels, true
+}
+
+func innerJoinsOnly(node plan.LogicalPlan) bool {
+	switch n := node.(type) {
+	case *plan.LogicalScan:
+		return true
+	case *plan.LogicalFilter:
+		return innerJoinsOnly(n.Input)
+	case *plan.LogicalJoin:
+		return n.JoinType == plan.InnerJoin && innerJoinsOnly(n.Left) && innerJoinsOnly(n.Right)
+	default:
+		return false
+	}
+}
+
+// expr with each column qualified by its table's name, false when one
+// doesn't resolve
+func canonical(expr plan.Expr, rels []relation) (plan.Expr, bool) {
+	ok := true
+	out := plan.TransformExprUp(expr, func(e plan.Expr) plan.Expr {
+		col, isCol := e.(*plan.ColumnExpr)
+		if !isCol {
+			return e
+		}
+		jc, found := columnOf(col, rels)
+		if !found {
+			ok = false
+			return e
+		}
+		return &plan.ColumnExpr{Table: jc.rel.scan.TableName, Column: col.Column}
+	})
+
+	return out, ok
+}
+
+// printed form of expr with each column replaced by its class, so
+// expressions over equal columns compare equal
+func (b *block) key(expr plan.Expr) string {
+	return plan.TransformExprUp(expr, func(e plan.Expr) plan.Expr {
+		if col, ok := e.(*plan.ColumnExpr); ok {
+			return &plan.ColumnExpr{Column: b.classes.find(col.String())}
+		}
+		return e
+	}).String()
+}
+
+// reports whether every row passing the block's conjuncts passes c
+func (b *block) implies(c plan.Expr) bool {
+	if l, r, ok := columnEquality(c); ok {
+		return b.classes.find(l.String()) == b.classes.find(r.String())
+	}
+
+	switch e := c.(type) {
+	case *plan.BinaryExpr:
+		col, ok := e.Left.(*plan.ColumnExpr)
+		lit, ok2 := e.Right.(*plan.LiteralExpr)
+		if ok && ok2 && lit.Value != nil && b.bounds[b.classes.find(col.String())].implies(e.Operator, lit.Value) {
+			return true
+		}
+	case *plan.IsNullExpr:
+		if col, ok := e.Expr.(*plan.ColumnExpr); ok && e.Not {
+			if bounds := b.bounds[b.classes.find(col.String())]; bounds != nil && bounds.notNull {
+				return true
+			}
+		}
+	}
+
+	return b.keys[b.key(c)]
+}
+
+// reports whether every value within the bounds satisfies col op v
+func (b *columnBounds) implies(op string, v interface{}) bool {
+	if b == nil {
+		return false
+	}
+	for _, e := range b.eq {
+		if sameKind(e, v) && holds(function.Compare(e, v), op) {
+			return true
+		}
+	}
+
+	switch op {
+	case ">", ">=":
+		if b.lo != nil && sameKind(b.lo, v) {
+			c := function.Compare(b.lo, v)
+			return c > 0 || (c == 0 && (op == ">=" || !b.loIncl))
+		}
+	case "<", "<=":
+		if b.hi != nil && sameKind(b.hi, v) {
+			c := function.Compare(b.hi, v)
+			return c < 0 || (c == 0 && (op == "<=" || !b.hiIncl))
+		}
+	}
+
+	return false
+}
+
+// whether a comparison holds for sides that compare as c
+func holds(c int, op string) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// sides of a column = column conjunct
+func columnEquality(expr plan.Expr) (*plan.ColumnExpr, *plan.ColumnExpr, bool) {
+	b, ok := expr.(*plan.BinaryExpr)
+	if !ok || b.Operator != "=" {
+		return nil, nil, false
+	}
+	l, ok1 := b.Left.(*plan.ColumnExpr)
+	r, ok2 := b.Right.(*plan.ColumnExpr)
+
+	return l, r, ok1 && ok2
+}
+
+// materialized view as the rewrite sees it. Its columns are keyed by the
+// block key of what they hold, grouped views offer their group keys as
+// columns and their aggregates apart
+type viewDef struct {
+	table   *catalog.TableInfo
+	block   *block
+	grouped bool
+	groupBy map[string]bool
+	columns map[string]string
+	aggs    map[string]string
+}
+
+// the view's query taken apart, false when it isn't an aggregate or a
+// projection over inner joins and filters
+func defineView(cat *catalog.Catalog, table *catalog.TableInfo) (*viewDef, bool) {
+	p := parser.NewParser(table.View)
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		return nil, false
+	}
+	root, err := plan.NewPlanner(cat).CreateLogicalPlan(stmt)
+	if err != nil {
+		return nil, false
+	}
+	// simplified like queries are before they are matched
+	for _, rule := range []Rule{&SimplifyExpressions{}, &SimplifyOuterJoins{}} {
+		root = applyBottomUp(root, rule)
+	}
+
+	project, ok := root.(*plan.LogicalProject)
+	if !ok || len(project.Projections) != len(table.Columns) {
+		return nil, false
+	}
+	input := project.Input
+	agg, grouped := input.(*plan.LogicalAggregate)
+	if grouped {
+		input = agg.Input
+	}
+	b, rels, ok := blockOf(input)
+	if !ok {
+		return nil, false
+	}
+
+	v := &viewDef{
+		table:   table,
+		block:   b,
+		grouped: grouped,
+		groupBy: make(map[string]bool),
+		columns: make(map[string]string),
+		aggs:    make(map[string]string),
+	}
+	if !grouped {
+		for i, expr := range project.Projections {
+			if c, ok := canonical(expr, rels); ok {
+				v.columns[b.key(c)] = table.Columns[i].Name
+			}
+		}
+		return v, true
+	}
+
+	// projections name the aggregate's outputs
+	outputs := make(map[string]plan.Expr)
+	for i, g := range agg.GroupBy {
+		c, ok := canonical(g, rels)
+		if !ok {
+			return nil, false
+		}
+		v.groupBy[b.key(c)] = true
+		outputs[agg.GroupNames[i]] = c
+	}
+	for i, a := range agg.Aggregates {
+		if c, ok := canonical(a, rels); ok {
+			outputs[agg.AggregateNames[i]] = c
+		}
+	}
+	for i, expr := range project.Projections {
+		col, ok := expr.(*plan.ColumnExpr)
+		if !ok || col.Table != "" {
+			continue
+		}
+		switch out := outputs[col.Column].(type) {
+		case nil:
+		case *plan.AggregateExpr:
+			v.aggs[b.key(out)] = table.Columns[i].Name
+		default:
+			v.columns[b.key(out)] = table.Columns[i].Name
+		}
+	}
+
+	return v, true
+}
+
+// part of a query a view may answer, an aggregate or a projection over a
+// block with its expressions canonical
+type viewQuery struct {
+	block       *block
+	agg         *plan.LogicalAggregate
+	groupBy     []plan.Expr
+	aggs        []*plan.AggregateExpr
+	project     *plan.LogicalProject
+	projections []plan.Expr
+}
+
+func viewQueryOf(node plan.LogicalPlan) (*viewQuery, bool) {
+	var input plan.LogicalPlan
+	switch n := node.(type) {
+	case *plan.LogicalAggregate:
+		input = n.Input
+	case *plan.LogicalProject:
+		input = n.Input
+	default:
+		return nil, false
+	}
+	b, rels, ok := blockOf(input)
+	if !ok {
+		return nil, false
+	}
+
+	q := &viewQuery{block: b}
+	canon := func(e plan.Expr) plan.Expr {
+		c, resolved := canonical(e, rels)
+		ok = ok && resolved
+		return c
+	}
+	switch n := node.(type) {
+	case *plan.LogicalAggregate:
+		q.agg = n
+		for _, g := range n.GroupBy {
+			q.groupBy = append(q.groupBy, canon(g))
+		}
+		for _, a := range n.Aggregates {
+			q.aggs = append(q.aggs, canon(a).(*plan.AggregateExpr))
+		}
+	case *plan.LogicalProject:
+		q.project = n
+		for _, p := range n.Projections {
+			q.projections = append(q.projections, canon(p))
+		}
+	}
+
+	return q, ok
+}
+
+// q computed from the view's rows, false when the view lacks some of them
+func (v *viewDef) answer(q *viewQuery, functions *function.Registry) (plan.LogicalPlan, bool) {
+	if !v.contains(q.block) {
+		return nil, false
+	}
+
+	// query filters the view's don't imply, over its columns
+	var preds []plan.Expr
+	for _, c := range q.block.conjuncts {
+		if v.block.implies(c) {
+			continue
+		}
+		pred, ok := v.column(c)
+		if !ok {
+			return nil, false
+		}
+		preds = append(preds, pred)
+	}
+	input := withFilter(&plan.LogicalScan{TableName: v.table.Name, Table: v.table, FromView: true}, preds)
+
+	switch {
+	case q.project != nil:
+		if v.grouped {
+			return nil, false
+		}
+		out := *q.project
+		out.Input = input
+		out.Projections = make([]plan.Expr, len(q.projections))
+		for i, expr := range q.projections {
+			mapped, ok := v.column(expr)
+			if !ok {
+				return nil, false
+			}
+			out.Projections[i] = mapped
+		}
+		return &out, true
+
+	case !v.grouped:
+		out := *q.agg
+		out.Input = input
+		out.GroupBy = make([]plan.Expr, len(q.groupBy))
+		for i, g := range q.groupBy {
+			mapped, ok := v.column(g)
+			if !ok {
+				return nil, false
+			}
+			out.GroupBy[i] = mapped
+		}
+		out.Aggregates = make([]*plan.AggregateExpr, len(q.aggs))
+		for i, a := range q.aggs {
+			call := *a
+			call.Args = make([]plan.Expr, len(a.Args))
+			for j, arg := range a.Args {
+				mapped, ok := v.column(arg)
+				if !ok {
+					return nil, false
+				}
+				call.Args[j] = mapped
+			}
+			out.Aggregates[i] = &call
+		}
+		return &out, true
+
+	default:
+		return v.rollUp(q, input, functions)
+	}
+}
+
+// reports whether the view holds every row of the query's block
+func (v *viewDef) contains(q *block) bool {
+	for name := range q.tables {
+		if _, ok := v.block.tables[name]; !ok {
+			return false
+		}
+	}
+
+	conjuncts, ok := v.lossless(q.tables)
+	if !ok {
+		return false
+	}
+	for _, c := range conjuncts {
+		if !q.implies(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// conjuncts of the view without the joins to tables the query doesn't
+// read. Each of those has to be joined once, by a NOT NULL foreign key to
+// it, so every row finds exactly one match and none is dropped
+func (v *viewDef) lossless(tables map[string]*catalog.TableInfo) ([]plan.Expr, bool) {
+	extra := make(map[string]bool)
+	for name := range v.block.tables {
+		if _, ok := tables[name]; !ok {
+			extra[name] = true
+		}
+	}
+
+	conjuncts := v.block.conjuncts
+	for removed := true; removed; {
+		removed = false
+		for name := range extra {
+			var uses []int
+			for i, c := range conjuncts {
+				if readsTable(c, name) {
+					uses = append(uses, i)
+				}
+			}
+			if len(uses) != 1 || !v.block.foreignKeyJoin(conjuncts[uses[0]], name) {
+				continue
+			}
+
+			conjuncts = slices.Delete(slices.Clone(conjuncts), uses[0], uses[0]+1)
+			delete(extra, name)
+			removed = true
+		}
+	}
+
+	return conjuncts, len(extra) == 0
+}
+
+func readsTable(expr plan.Expr, table string) bool {
+	return plan.ContainsExpr(expr, func(e plan.Expr) bool {
+		col, ok := e.(*plan.ColumnExpr)
+		return ok && col.Table == table
+	})
+}
+
+// reports whether c equates a NOT NULL foreign key of another table with
+// the column of parent it references
+func (b *block) foreignKeyJoin(c plan.Expr, parent string) bool {
+	child, key, ok := columnEquality(c)
+	if !ok {
+		return false
+	}
+	if child.Table == parent {
+		child, key = key, child
+	}
+	if key.Table != parent || child.Table == parent {
+		return false
+	}
+
+	table := b.tables[child.Table]
+	fk, ok := table.ForeignKeyTo([]string{child.Column}, parent)
+	return ok && fk.RefColumns[0] == key.Column && table.IsNotNull(child.Column)
+}
+
+// expr over the view's columns, false when it reads a column the view
+// doesn't have
+func (v *viewDef) column(expr plan.Expr) (plan.Expr, bool) {
+	ok := true
+	out := plan.TransformExpr(expr, func(e plan.Expr) (plan.Expr, bool) {
+		if name, found := v.columns[v.block.key(e)]; found {
+			return &plan.ColumnExpr{Table: v.table.Name, Column: name}, true
+		}
+		switch e.(type) {
+		case *plan.ColumnExpr, *plan.AggregateExpr:
+			ok = false
+			return e, true
+		}
+		return e, false
+	})
+
+	return out, ok
+}
+
+// query aggregated from a grouped view. With the view's grouping its rows
+// are the answer, otherwise they are aggregated again: sums, minimums and
+// maximums combine, counts are summed and averages divide a summed sum by
+// a summed count
+func (v *viewDef) rollUp(q *viewQuery, input plan.LogicalPlan, functions *function.Registry) (plan.LogicalPlan, bool) {
+	agg := &plan.LogicalAggregate{Input: input, GroupNames: q.agg.GroupNames}
+	same := true
+	keys := make(map[string]bool)
+	for _, g := range q.groupBy {
+		mapped, ok := v.column(g)
+		if !ok {
+			return nil, false
+		}
+		agg.GroupBy = append(agg.GroupBy, mapped)
+		keys[v.block.key(g)] = true
+		same = same && v.groupBy[v.block.key(g)]
+	}
+	same = same && len(keys) == len(v.groupBy)
+
+	names := append(append([]string{}, q.agg.GroupNames...), q.agg.AggregateNames...)
+	stored := func(name string, args []plan.Expr) (plan.Expr, bool) {
+		col, ok := v.aggs[v.block.key(&plan.AggregateExpr{Name: name, Args: args})]
+		return &plan.ColumnExpr{Table: v.table.Name, Column: col}, ok
+	}
+
+	if same {
+		project := &plan.LogicalProject{Input: input, Projections: append([]plan.Expr{}, agg.GroupBy...), ColumnNames: names}
+		direct := true
+		for _, a := range q.aggs {
+			col, ok := stored(a.Name, a.Args)
+			direct = direct && ok
+			project.Projections = append(project.Projections, col)
+		}
+		if direct {
+			return project, true
+		}
+	}
+
+	added := make(map[string]bool)
+	add := func(a *plan.AggregateExpr) plan.Expr {
+		name := a.String()
+		if !added[name] {
+			added[name] = true
+			agg.Aggregates = append(agg.Aggregates, a)
+			agg.AggregateNames = append(agg.AggregateNames, name)
+		}
+		return &plan.ColumnExpr{Column: name}
+	}
+	sum, _ := functions.LookupAggregate("SUM")
+
+	project := &plan.LogicalProject{Input: agg, ColumnNames: names}
+	for _, name := range q.agg.GroupNames {
+		project.Projections = append(project.Projections, &plan.ColumnExpr{Column: name})
+	}
+	for _, a := range q.aggs {
+		var expr plan.Expr
+		switch a.Name {
+		case "SUM", "MIN", "MAX":
+			col, ok := stored(a.Name, a.Args)
+			if !ok {
+				return nil, false
+			}
+			expr = add(&plan.AggregateExpr{Name: a.Name, Args: []plan.Expr{col}, Func: a.Func, Type: a.Type})
+
+		case "COUNT":
+			col, ok := stored(a.Name, a.Args)
+			if !ok {
+				return nil, false
+			}
+			expr = add(&plan.AggregateExpr{Name: "SUM", Args: []plan.Expr{col}, Func: sum, Type: catalog.IntType})
+			// without groups an empty input still gives a row, counting 0
+			if len(agg.GroupBy) == 0 {
+				coalesce, err := functions.LookupScalar("COALESCE")
+				if err != nil {
+					return nil, false
+				}
+				expr = &plan.FuncCallExpr{Name: "COALESCE", Args: []plan.Expr{expr, newLiteral(0)}, Func: coalesce, Type: catalog.IntType}
+			}
+			expr = &plan.CastExpr{Expr: expr, Type: catalog.IntType}
+
+		case "AVG":
+			total, ok := stored("SUM", a.Args)
+			count, ok2 := stored("COUNT", a.Args)
+			if !ok || !ok2 || a.Type == catalog.DecimalType {
+				return nil, false
+			}
+			expr = &plan.BinaryExpr{
+				Left:     add(&plan.AggregateExpr{Name: "SUM", Args: []plan.Expr{total}, Func: sum, Type: a.Type}),
+				Operator: "/",
+				Right:    add(&plan.AggregateExpr{Name: "SUM", Args: []plan.Expr{count}, Func: sum, Type: catalog.IntType}),
+			}
+
+		default:
+			return nil, false
+		}
+		project.Projections = append(project.Projections, expr)
+	}
+
+	return project, true
+}
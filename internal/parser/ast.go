@@ -1,5 +1,11 @@
 package parser
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // basic interface of ast nodes
 type Node interface {
 	String() string
@@ -23,8 +29,23 @@ type SelectStatement struct {
 	Joins   []*JoinClause
 	OrderBy []*OrderByExpr
 	GroupBy []Expression
+	Having  Expression
 	Limit   *int
 	Offset  *int
+	Hints   []*Hint
+}
+
+// optimizer hint from a /*+ */ comment after SELECT, like HASH_JOIN(u o)
+type Hint struct {
+	Name string
+	Args []string
+}
+
+func (h *Hint) String() string {
+	if len(h.Args) == 0 {
+		return h.Name
+	}
+	return fmt.Sprintf("%s(%s)", h.Name, strings.Join(h.Args, " "))
 }
 
 func (s *SelectStatement) statementNode() {}
@@ -32,6 +53,239 @@ func (s *SelectStatement) String() string {
 	return "SELECT"
 }
 
+// ANALYZE [table] [SAMPLE n], an empty Table analyzes every table and a
+// zero Sample reads every row
+type AnalyzeStatement struct {
+	Table  string
+	Sample int
+}
+
+func (s *AnalyzeStatement) statementNode() {}
+func (s *AnalyzeStatement) String() string {
+	out := "ANALYZE"
+	if s.Table != "" {
+		out += " " + s.Table
+	}
+	if s.Sample > 0 {
+		out += " SAMPLE " + strconv.Itoa(s.Sample)
+	}
+
+	return out
+}
+
+// COPY table TO 'path' FORMAT format, writes every row of the table to a
+// file
+type CopyStatement struct {
+	Table  string
+	Path   string
+	Format string
+}
+
+func (s *CopyStatement) statementNode() {}
+func (s *CopyStatement) String() string {
+	return "COPY " + s.Table + " TO '" + s.Path + "' FORMAT " + s.Format
+}
+
+// INSERT INTO table [(columns)] with either Values rows or a Select
+type InsertStatement struct {
+	Table   string
+	Columns []string // empty for every column in table order
+	Values  [][]Expression
+	Select  *SelectStatement
+}
+
+func (s *InsertStatement) statementNode() {}
+func (s *InsertStatement) String() string {
+	return "INSERT INTO " + s.Table
+}
+
+// UPDATE table SET assignments [WHERE], a nil Where updates every row
+type UpdateStatement struct {
+	Table       *TableRef
+	Assignments []*Assignment
+	Where       Expression
+}
+
+type Assignment struct {
+	Column string
+	Value  Expression
+}
+
+func (s *UpdateStatement) statementNode() {}
+func (s *UpdateStatement) String() string {
+	return "UPDATE " + s.Table.Name
+}
+
+// DELETE FROM table [WHERE], a nil Where deletes every row
+type DeleteStatement struct {
+	Table *TableRef
+	Where Expression
+}
+
+func (s *DeleteStatement) statementNode() {}
+func (s *DeleteStatement) String() string {
+	return "DELETE FROM " + s.Table.Name
+}
+
+// CREATE TABLE [IF NOT EXISTS] table (columns and constraints) with an
+// optional storage kind, data file and source options. Column constraints
+// are folded into the table ones
+type CreateTableStatement struct {
+	Table       string
+	IfNotExists bool
+	Columns     []*ColumnDef
+	PrimaryKey  []string
+	Unique      [][]string
+	ForeignKeys []*ForeignKeyDef
+	Using       string // source kind, empty for the default
+	Location    string // data file, empty for one named after the table
+	Options     map[string]string
+}
+
+type ColumnDef struct {
+	Name     string
+	TypeName string
+	NotNull  bool
+}
+
+// RefColumns is empty when the primary key of RefTable is meant
+type ForeignKeyDef struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+func (s *CreateTableStatement) statementNode() {}
+func (s *CreateTableStatement) String() string {
+	return "CREATE TABLE " + s.Table
+}
+
+// DROP TABLE [IF EXISTS] table, or DROP [MATERIALIZED] VIEW when View is
+// set
+type DropTableStatement struct {
+	Table        string
+	IfExists     bool
+	View         bool
+	Materialized bool
+}
+
+func (s *DropTableStatement) statementNode() {}
+func (s *DropTableStatement) String() string {
+	switch {
+	case s.Materialized:
+		return "DROP MATERIALIZED VIEW " + s.Table
+	case s.View:
+		return "DROP VIEW " + s.Table
+	}
+	return "DROP TABLE " + s.Table
+}
+
+// CREATE [MATERIALIZED] VIEW [IF NOT EXISTS] name [(col, ...)] AS SELECT ...,
+// Query is the text of the SELECT as written
+type CreateViewStatement struct {
+	Name         string
+	Columns      []string
+	Select       *SelectStatement
+	Query        string
+	Materialized bool
+	IfNotExists  bool
+}
+
+func (s *CreateViewStatement) statementNode() {}
+func (s *CreateViewStatement) String() string {
+	if s.Materialized {
+		return "CREATE MATERIALIZED VIEW " + s.Name
+	}
+	return "CREATE VIEW " + s.Name
+}
+
+// REFRESH MATERIALIZED VIEW name
+type RefreshStatement struct {
+	Name string
+}
+
+func (s *RefreshStatement) statementNode() {}
+func (s *RefreshStatement) String() string {
+	return "REFRESH MATERIALIZED VIEW " + s.Name
+}
+
+// CREATE INDEX [IF NOT EXISTS] name ON table (columns)
+type CreateIndexStatement struct {
+	Name        string
+	Table       string
+	Columns     []string
+	IfNotExists bool
+}
+
+func (s *CreateIndexStatement) statementNode() {}
+func (s *CreateIndexStatement) String() string {
+	return "CREATE INDEX " + s.Name + " ON " + s.Table
+}
+
+// DROP INDEX [IF EXISTS] name [ON table], Table is needed when tables
+// share the index name
+type DropIndexStatement struct {
+	Name     string
+	Table    string
+	IfExists bool
+}
+
+func (s *DropIndexStatement) statementNode() {}
+func (s *DropIndexStatement) String() string {
+	if s.Table != "" {
+		return "DROP INDEX " + s.Name + " ON " + s.Table
+	}
+	return "DROP INDEX " + s.Name
+}
+
+type TransactionAction int
+
+const (
+	Begin    TransactionAction = iota // BEGIN [TRANSACTION | WORK] or START TRANSACTION
+	Commit                            // COMMIT [TRANSACTION | WORK]
+	Rollback                          // ROLLBACK [TRANSACTION | WORK]
+)
+
+type TransactionStatement struct {
+	Action TransactionAction
+}
+
+func (s *TransactionStatement) statementNode() {}
+func (s *TransactionStatement) String() string {
+	switch s.Action {
+	case Commit:
+		return "COMMIT"
+	case Rollback:
+		return "ROLLBACK"
+	}
+	return "BEGIN"
+}
+
+type AlterAction int
+
+const (
+	AddColumn       AlterAction = iota // ADD [COLUMN] definition
+	DropColumn                         // DROP [COLUMN] name
+	RenameColumn                       // RENAME [COLUMN] name TO new name
+	RenameTable                        // RENAME TO new name
+	AlterColumnType                    // ALTER [COLUMN] name [SET DATA] TYPE type
+)
+
+// ALTER TABLE table with one action. Column is the definition added or the
+// new type, Name the column changed and NewName the new column or table name
+type AlterTableStatement struct {
+	Table   string
+	Action  AlterAction
+	Column  *ColumnDef
+	Name    string
+	NewName string
+}
+
+func (s *AlterTableStatement) statementNode() {}
+func (s *AlterTableStatement) String() string {
+	return "ALTER TABLE " + s.Table
+}
+
 func (t *TableRef) expressionNode() {}
 func (t *TableRef) String() string {
 	return t.Name
@@ -41,8 +295,12 @@ type JoinType int
 type TableRef struct { //repersents table ref
 	Name  string
 	Alias string
+
+	// UNNEST(expr) AS alias in FROM, Name is empty
+	Unnest Expression
 }
 
+// Condition is nil for CROSS JOIN, comma joins and UNNEST without ON
 type JoinClause struct {
 	Type      JoinType
 	Table     *TableRef
@@ -92,7 +350,7 @@ func (s *StarExpr) String() string {
 
 type BinaryExpr struct { //binary expression
 	Left     Expression
-	Operator string // =, !=, <, >, <=, >=, AND, OR
+	Operator string // =, !=, <, >, <=, >=, +, -, *, /, %, AND, OR
 	Right    Expression
 }
 
@@ -111,16 +369,232 @@ type LiteralType int
 const (
 	IntLiteral LiteralType = iota
 	StringLiteral
+	NullLiteral
+	BoolLiteral
+	DecimalLiteral   // exact number like 1.25, Value is its text
+	FloatLiteral     // number with an exponent like 1e3, Value is a float64
+	DateLiteral      // DATE '2024-01-31', Value is the string
+	TimestampLiteral // TIMESTAMP '2024-01-31 10:00:00'
+	IntervalLiteral  // INTERVAL '3 days'
 )
 
 func (l *Literal) expressionNode() {}
 func (l *Literal) String() string {
 	switch l.Type {
 	case IntLiteral:
-		return string(rune(l.Value.(int)))
+		return strconv.Itoa(l.Value.(int))
 
 	case StringLiteral:
-		return "'" + l.Value.(string) + "'"	
+		return "'" + l.Value.(string) + "'"
+
+	case NullLiteral:
+		return "NULL"
+
+	case BoolLiteral:
+		if l.Value.(bool) {
+			return "TRUE"
+		}
+		return "FALSE"
+
+	case DecimalLiteral:
+		return l.Value.(string)
+	case FloatLiteral:
+		return strconv.FormatFloat(l.Value.(float64), 'g', -1, 64)
+	case DateLiteral:
+		return "DATE '" + l.Value.(string) + "'"
+	case TimestampLiteral:
+		return "TIMESTAMP '" + l.Value.(string) + "'"
+	case IntervalLiteral:
+		return "INTERVAL '" + l.Value.(string) + "'"
 	}
 	return ""
 }
+
+type NotExpr struct {
+	Expr Expression
+}
+
+func (n *NotExpr) expressionNode() {}
+func (n *NotExpr) String() string {
+	return "(NOT " + n.Expr.String() + ")"
+}
+
+// expr IS [NOT] NULL
+type IsNullExpr struct {
+	Expr Expression
+	Not  bool
+}
+
+func (i *IsNullExpr) expressionNode() {}
+func (i *IsNullExpr) String() string {
+	if i.Not {
+		return "(" + i.Expr.String() + " IS NOT NULL)"
+	}
+	return "(" + i.Expr.String() + " IS NULL)"
+}
+
+// CASE [operand] WHEN ... THEN ... [ELSE ...] END
+type CaseExpr struct {
+	Operand Expression // nil for searched CASE
+	Whens   []*WhenClause
+	Else    Expression
+}
+
+type WhenClause struct {
+	Condition Expression
+	Result    Expression
+}
+
+func (c *CaseExpr) expressionNode() {}
+func (c *CaseExpr) String() string {
+	s := "CASE"
+	if c.Operand != nil {
+		s += " " + c.Operand.String()
+	}
+	for _, w := range c.Whens {
+		s += " WHEN " + w.Condition.String() + " THEN " + w.Result.String()
+	}
+	if c.Else != nil {
+		s += " ELSE " + c.Else.String()
+	}
+
+	return s + " END"
+}
+
+// CAST(expr AS type)
+type CastExpr struct {
+	Expr     Expression
+	TypeName string
+}
+
+func (c *CastExpr) expressionNode() {}
+func (c *CastExpr) String() string {
+	return "CAST(" + c.Expr.String() + " AS " + c.TypeName + ")"
+}
+
+// select list item with an alias (expr AS name)
+type AliasExpr struct {
+	Expr  Expression
+	Alias string
+}
+
+func (a *AliasExpr) expressionNode() {}
+func (a *AliasExpr) String() string {
+	return a.Expr.String() + " AS " + a.Alias
+}
+
+// function call, a window function when Over is set
+type FunctionCall struct {
+	Name string
+	Args []Expression
+	Over *WindowSpec
+}
+
+func (f *FunctionCall) expressionNode() {}
+func (f *FunctionCall) String() string {
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = arg.String()
+	}
+
+	s := f.Name + "(" + strings.Join(args, ", ") + ")"
+	if f.Over != nil {
+		s += " OVER (" + f.Over.String() + ")"
+	}
+
+	return s
+}
+
+// OVER (PARTITION BY ... ORDER BY ... frame)
+type WindowSpec struct {
+	PartitionBy []Expression
+	OrderBy     []*OrderByExpr
+	Frame       *WindowFrame
+}
+
+func (w *WindowSpec) String() string {
+	var parts []string
+
+	if len(w.PartitionBy) > 0 {
+		exprs := make([]string, len(w.PartitionBy))
+		for i, e := range w.PartitionBy {
+			exprs[i] = e.String()
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(exprs, ", "))
+	}
+
+	if len(w.OrderBy) > 0 {
+		exprs := make([]string, len(w.OrderBy))
+		for i, o := range w.OrderBy {
+			exprs[i] = o.String()
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(exprs, ", "))
+	}
+
+	if w.Frame != nil {
+		parts = append(parts, w.Frame.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (o *OrderByExpr) String() string {
+	if o.Desc {
+		return o.Expr.String() + " DESC"
+	}
+	return o.Expr.String()
+}
+
+type FrameUnit int
+
+const (
+	RowsFrame FrameUnit = iota
+	RangeFrame
+)
+
+type FrameBoundType int
+
+const (
+	UnboundedPreceding FrameBoundType = iota
+	Preceding
+	CurrentRow
+	Following
+	UnboundedFollowing
+)
+
+type FrameBound struct {
+	Type   FrameBoundType
+	Offset int // only for Preceding and Following
+}
+
+func (b FrameBound) String() string {
+	switch b.Type {
+	case UnboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case Preceding:
+		return strconv.Itoa(b.Offset) + " PRECEDING"
+	case CurrentRow:
+		return "CURRENT ROW"
+	case Following:
+		return strconv.Itoa(b.Offset) + " FOLLOWING"
+	case UnboundedFollowing:
+		return "UNBOUNDED FOLLOWING"
+	}
+	return ""
+}
+
+// ROWS|RANGE BETWEEN start AND end
+type WindowFrame struct {
+	Unit  FrameUnit
+	Start FrameBound
+	End   FrameBound
+}
+
+func (f *WindowFrame) String() string {
+	unit := "ROWS"
+	if f.Unit == RangeFrame {
+		unit = "RANGE"
+	}
+
+	return unit + " BETWEEN " + f.Start.String() + " AND " + f.End.String()
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 	"unicode"
 )
@@ -12,6 +13,7 @@ type Lexer struct {
 	ch           byte //current char
 	line         int
 	column       int
+	errors       []string
 }
 
 func NewLexer(input string) *Lexer {
@@ -26,6 +28,10 @@ func NewLexer(input string) *Lexer {
 	return l
 }
 
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
 func (l *Lexer) readChar() {
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
@@ -51,10 +57,21 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
+// reports whether the next token is the keyword t without consuming it
+func (l *Lexer) peekKeyword(t TokenType) bool {
+	clone := *l
+	tok := clone.NextToken()
+	for tok.Type == HINT {
+		tok = clone.NextToken()
+	}
+	return tok.Type == t
+}
+
 func (l *Lexer) NextToken() Token {
 	var tok Token
 	l.skipWhitespace()
 
+	start := l.position
 	tok.Line = l.line
 	tok.Column = l.column
 
@@ -73,6 +90,31 @@ func (l *Lexer) NextToken() Token {
 		tok = l.newToken(ASTERISK, string(l.ch))
 	case '.':
 		tok = l.newToken(DOT, string(l.ch))
+	case '+':
+		tok = l.newToken(PLUS, string(l.ch))
+	case '-':
+		if l.peekChar() == '>' {
+			l.readChar()
+			if l.peekChar() == '>' {
+				l.readChar()
+				tok = l.newToken(LONGARROW, "->>")
+			} else {
+				tok = l.newToken(ARROW, "->")
+			}
+		} else {
+			tok = l.newToken(MINUS, string(l.ch))
+		}
+	case '/':
+		if l.peekChar() == '*' {
+			// comments were skipped, this one holds hints
+			tok.Type = HINT
+			tok.Literal = strings.TrimPrefix(l.readComment(), "+")
+			tok.Pos = start
+			return tok
+		}
+		tok = l.newToken(SLASH, string(l.ch))
+	case '%':
+		tok = l.newToken(PERCENT, string(l.ch))
 
 	case '<':
 		if l.peekChar() == '=' {
@@ -115,10 +157,15 @@ func (l *Lexer) NextToken() Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(strings.ToUpper(tok.Literal))
+			tok.Pos = start
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = INT
 			tok.Literal = l.readNumber()
+			if strings.ContainsAny(tok.Literal, ".eE") {
+				tok.Type = NUMBER
+			}
+			tok.Pos = start
 			return tok
 		} else {
 			tok = l.newToken(ILLEGAL, string(l.ch))
@@ -126,6 +173,7 @@ func (l *Lexer) NextToken() Token {
 	}
 
 	l.readChar()
+	tok.Pos = start
 	return tok
 }
 
@@ -150,11 +198,33 @@ func (l *Lexer) readString(quote byte) string {
 	return l.input[position:l.position]
 }
 
+// integers, decimals like 1.25 and exponents like 2e-3
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '-' || next == '+') && l.readPosition+1 < len(l.input) && isDigit(l.input[l.readPosition+1])) {
+			l.readChar()
+			if l.ch == '-' || l.ch == '+' {
+				l.readChar()
+			}
+			for isDigit(l.ch) {
+				l.readChar()
+			}
+		}
+	}
+
 	return l.input[position:l.position]
 }
 
@@ -167,10 +237,48 @@ func (l *Lexer) newToken(tokenType TokenType, literal string) Token {
 	}
 }
 
+// skips whitespace and comments, -- to the end of the line and /* */.
+// Comments starting /*+ hold optimizer hints and are tokens
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '-' && l.peekChar() == '-':
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		case l.ch == '/' && l.peekChar() == '*' && !l.hintAhead():
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) hintAhead() bool {
+	return l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '+'
+}
+
+// text of the /* */ comment at the current position, which it moves past.
+// An unterminated comment is an error, it would hide the rest of the input
+func (l *Lexer) readComment() string {
+	line, column := l.line, l.column
+	l.readChar()
+	l.readChar()
+	position := l.position
+	for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
 		l.readChar()
 	}
+	text := l.input[position:l.position]
+
+	if l.ch == 0 {
+		l.errors = append(l.errors, fmt.Sprintf("Line %d, Col %d: unterminated comment", line, column))
+		return text
+	}
+	l.readChar()
+	l.readChar()
+	return text
 }
 
 func isLetter(ch byte) bool {
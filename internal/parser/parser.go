@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -10,6 +11,7 @@ type Parser struct {
 	curToken  Token
 	peekToken Token
 	errors    []string
+	hints     []*Hint // of the SELECT just read
 }
 
 func NewParser(input string) *Parser {
@@ -29,10 +31,46 @@ func NewParser(input string) *Parser {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+
+	// hints belong to the SELECT right before them, elsewhere they are
+	// plain comments
+	for p.peekToken.Type == HINT {
+		if p.curToken.Type == SELECT {
+			p.hints = append(p.hints, parseHints(p.peekToken.Literal)...)
+		}
+		p.peekToken = p.lexer.NextToken()
+	}
+}
+
+// hints like NAME or NAME(arg arg), arguments apart by spaces or commas.
+// Names the optimizer doesn't know are kept for it to warn about
+func parseHints(text string) []*Hint {
+	var hints []*Hint
+	l := NewLexer(text)
+	for tok := l.NextToken(); tok.Type != EOF; {
+		hint := &Hint{Name: strings.ToUpper(tok.Literal)}
+		hints = append(hints, hint)
+
+		tok = l.NextToken()
+		if tok.Type != LPAREN {
+			continue
+		}
+		for tok = l.NextToken(); tok.Type != RPAREN && tok.Type != EOF; tok = l.NextToken() {
+			if tok.Type != COMMA {
+				hint.Args = append(hint.Args, tok.Literal)
+			}
+		}
+		if tok.Type == RPAREN {
+			tok = l.NextToken()
+		}
+	}
+
+	return hints
 }
 
+// errors of the lexer, then of the parser
 func (p *Parser) Errors() []string {
-	return p.errors
+	return append(append([]string{}, p.lexer.Errors()...), p.errors...)
 }
 
 func (p *Parser) addError(msg string) {
@@ -60,15 +98,829 @@ func (p *Parser) Parse() Statement {
 	if p.curTokenIs(SELECT) {
 		return p.parseSelectStatement()
 	}
+	if p.curTokenIs(ANALYZE) {
+		return p.parseAnalyzeStatement()
+	}
+	if p.curTokenIs(COPY) {
+		return p.parseCopyStatement()
+	}
+	if p.curTokenIs(INSERT) {
+		return p.parseInsertStatement()
+	}
+	if p.curTokenIs(UPDATE) {
+		return p.parseUpdateStatement()
+	}
+	if p.curTokenIs(DELETE) {
+		return p.parseDeleteStatement()
+	}
+	if p.curTokenIs(CREATE) {
+		return p.parseCreateStatement()
+	}
+	if p.curTokenIs(DROP) {
+		return p.parseDropStatement()
+	}
+	if p.curTokenIs(ALTER) {
+		return p.parseAlterTableStatement()
+	}
+	if p.curTokenIs(IDENT) {
+		switch strings.ToUpper(p.curToken.Literal) {
+		case "BEGIN", "START", "COMMIT", "ROLLBACK":
+			return p.parseTransactionStatement()
+		case "REFRESH":
+			return p.parseRefreshStatement()
+		}
+	}
 	p.addError(fmt.Sprintf("unexpcted token %s", p.curToken.Type))
 
 	return nil
 }
 
+func (p *Parser) parseAnalyzeStatement() *AnalyzeStatement {
+	stmt := &AnalyzeStatement{}
+
+	if p.peekTokenIs(IDENT) {
+		p.nextToken()
+		stmt.Table = p.curToken.Literal
+	}
+
+	if p.peekTokenIs(SAMPLE) {
+		p.nextToken()
+		if !p.expectPeek(INT) {
+			return nil
+		}
+
+		n, err := strconv.Atoi(p.curToken.Literal)
+		if err != nil || n <= 0 {
+			p.addError(fmt.Sprintf("invalid sample size %s", p.curToken.Literal))
+			return nil
+		}
+		stmt.Sample = n
+	}
+
+	if p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.peekTokenIs(EOF) {
+		p.addError(fmt.Sprintf("unexpected token %s after ANALYZE", p.peekToken.Type))
+		return nil
+	}
+
+	return stmt
+}
+
+// COPY table TO 'path' [WITH] [(] FORMAT name [)], TO and FORMAT aren't
+// keywords so they stay usable as column names
+func (p *Parser) parseCopyStatement() *CopyStatement {
+	stmt := &CopyStatement{}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Table = p.curToken.Literal
+
+	if !p.expectWord("TO") || !p.expectPeek(STRING) {
+		return nil
+	}
+	stmt.Path = p.curToken.Literal
+
+	if p.peekWord("WITH") {
+		p.nextToken()
+	}
+	parens := p.peekTokenIs(LPAREN)
+	if parens {
+		p.nextToken()
+	}
+	if !p.expectWord("FORMAT") || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Format = strings.ToLower(p.curToken.Literal)
+	if parens && !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	if p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.peekTokenIs(EOF) {
+		p.addError(fmt.Sprintf("unexpected token %s after COPY", p.peekToken.Type))
+		return nil
+	}
+
+	return stmt
+}
+
+// advances past an identifier spelled word, in any case
+func (p *Parser) expectWord(word string) bool {
+	if p.peekTokenIs(IDENT) && strings.EqualFold(p.peekToken.Literal, word) {
+		p.nextToken()
+		return true
+	}
+	p.addError(fmt.Sprintf("expected %s, got %s", word, p.peekToken.Literal))
+	return false
+}
+
+// INSERT INTO table [(col, ...)] VALUES (expr, ...), ... or
+// INSERT INTO table [(col, ...)] SELECT ...
+func (p *Parser) parseInsertStatement() *InsertStatement {
+	stmt := &InsertStatement{}
+
+	if !p.expectPeek(INTO) || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Table = p.curToken.Literal
+
+	if p.peekTokenIs(LPAREN) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(IDENT) {
+				return nil
+			}
+			stmt.Columns = append(stmt.Columns, p.curToken.Literal)
+			if !p.peekTokenIs(COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(RPAREN) {
+			return nil
+		}
+	}
+
+	switch {
+	case p.peekTokenIs(VALUES):
+		p.nextToken()
+		for {
+			if !p.expectPeek(LPAREN) {
+				return nil
+			}
+			var row []Expression
+			for {
+				p.nextToken()
+				row = append(row, p.parseExpression())
+				if !p.peekTokenIs(COMMA) {
+					break
+				}
+				p.nextToken()
+			}
+			if !p.expectPeek(RPAREN) {
+				return nil
+			}
+			stmt.Values = append(stmt.Values, row)
+
+			if !p.peekTokenIs(COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+
+	case p.peekTokenIs(SELECT):
+		p.nextToken()
+		if stmt.Select = p.parseSelectStatement(); stmt.Select == nil {
+			return nil
+		}
+
+	default:
+		p.addError(fmt.Sprintf("expected VALUES or SELECT, got %s", p.peekToken.Type))
+		return nil
+	}
+
+	if !p.expectEnd("INSERT") {
+		return nil
+	}
+	return stmt
+}
+
+// UPDATE table [[AS] alias] SET col = expr, ... [WHERE expr]
+func (p *Parser) parseUpdateStatement() *UpdateStatement {
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt := &UpdateStatement{Table: p.parseTableRef()}
+	if stmt.Table == nil {
+		return nil
+	}
+
+	if !p.expectPeek(SET) {
+		return nil
+	}
+	for {
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		assignment := &Assignment{Column: p.curToken.Literal}
+		if !p.expectPeek(EQ) {
+			return nil
+		}
+		p.nextToken()
+		assignment.Value = p.parseExpression()
+		stmt.Assignments = append(stmt.Assignments, assignment)
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if p.peekTokenIs(WHERE) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Where = p.parseExpression()
+	}
+
+	if !p.expectEnd("UPDATE") {
+		return nil
+	}
+	return stmt
+}
+
+// DELETE FROM table [[AS] alias] [WHERE expr]
+func (p *Parser) parseDeleteStatement() *DeleteStatement {
+	if !p.expectPeek(FROM) || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt := &DeleteStatement{Table: p.parseTableRef()}
+	if stmt.Table == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(WHERE) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Where = p.parseExpression()
+	}
+
+	if !p.expectEnd("DELETE") {
+		return nil
+	}
+	return stmt
+}
+
+// an optional semicolon and the end of input after a statement
+func (p *Parser) expectEnd(statement string) bool {
+	if p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.peekTokenIs(EOF) {
+		p.addError(fmt.Sprintf("unexpected token %s after %s", p.peekToken.Type, statement))
+		return false
+	}
+
+	return true
+}
+
+// BEGIN, START TRANSACTION, COMMIT or ROLLBACK, each optionally followed
+// by TRANSACTION or WORK
+func (p *Parser) parseTransactionStatement() *TransactionStatement {
+	stmt := &TransactionStatement{}
+	word := strings.ToUpper(p.curToken.Literal)
+
+	switch word {
+	case "BEGIN":
+		stmt.Action = Begin
+	case "START":
+		if !p.expectWord("TRANSACTION") {
+			return nil
+		}
+		stmt.Action = Begin
+	case "COMMIT":
+		stmt.Action = Commit
+	case "ROLLBACK":
+		stmt.Action = Rollback
+	}
+	if word != "START" && (p.peekWord("TRANSACTION") || p.peekWord("WORK")) {
+		p.nextToken()
+	}
+
+	if !p.expectEnd(word) {
+		return nil
+	}
+	return stmt
+}
+
+// reports whether the next token is an identifier spelled word, in any case
+func (p *Parser) peekWord(word string) bool {
+	return p.peekTokenIs(IDENT) && strings.EqualFold(p.peekToken.Literal, word)
+}
+
+// advances past IF NOT EXISTS when it comes next
+func (p *Parser) parseIfNotExists() (bool, bool) {
+	if !p.peekWord("IF") {
+		return false, true
+	}
+	p.nextToken()
+	if !p.expectPeek(NOT) || !p.expectWord("EXISTS") {
+		return false, false
+	}
+
+	return true, true
+}
+
+// advances past IF EXISTS when it comes next
+func (p *Parser) parseIfExists() (bool, bool) {
+	if !p.peekWord("IF") {
+		return false, true
+	}
+	p.nextToken()
+	if !p.expectWord("EXISTS") {
+		return false, false
+	}
+
+	return true, true
+}
+
+// (name, ...) coming next
+func (p *Parser) parseNameList() []string {
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+
+	var names []string
+	for {
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		names = append(names, p.curToken.Literal)
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return names
+}
+
+// type name starting at the current token, like INT or DECIMAL(10,2).
+// Length modifiers like VARCHAR(20) are accepted and dropped
+func (p *Parser) parseTypeName() string {
+	name := strings.ToUpper(p.curToken.Literal)
+	if name == "DOUBLE" && p.peekWord("PRECISION") {
+		p.nextToken()
+		name += " PRECISION"
+	}
+
+	if p.peekTokenIs(LPAREN) {
+		p.nextToken()
+		var mods []string
+		for !p.peekTokenIs(RPAREN) && !p.peekTokenIs(EOF) {
+			p.nextToken()
+			mods = append(mods, p.curToken.Literal)
+		}
+		p.nextToken()
+
+		if name == "DECIMAL" || name == "NUMERIC" {
+			name += "(" + strings.Join(mods, "") + ")"
+		}
+	}
+
+	return name
+}
+
+// CREATE TABLE ... or CREATE INDEX ...
+func (p *Parser) parseCreateStatement() Statement {
+	switch {
+	case p.peekWord("TABLE"):
+		p.nextToken()
+		return p.parseCreateTableStatement()
+	case p.peekWord("INDEX"):
+		p.nextToken()
+		return p.parseCreateIndexStatement()
+	case p.peekWord("VIEW"):
+		p.nextToken()
+		return p.parseCreateViewStatement(false)
+	case p.peekWord("MATERIALIZED"):
+		p.nextToken()
+		if !p.expectWord("VIEW") {
+			return nil
+		}
+		return p.parseCreateViewStatement(true)
+	}
+
+	p.addError(fmt.Sprintf("expected TABLE, INDEX or VIEW after CREATE, got %s", p.peekToken.Literal))
+	return nil
+}
+
+// the rest of CREATE [MATERIALIZED] VIEW, after VIEW
+func (p *Parser) parseCreateViewStatement(materialized bool) *CreateViewStatement {
+	stmt := &CreateViewStatement{Materialized: materialized}
+
+	var ok bool
+	if stmt.IfNotExists, ok = p.parseIfNotExists(); !ok {
+		return nil
+	}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Name = p.curToken.Literal
+
+	if p.peekTokenIs(LPAREN) {
+		if stmt.Columns = p.parseNameList(); stmt.Columns == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(AS) || !p.expectPeek(SELECT) {
+		return nil
+	}
+
+	start := p.curToken.Pos
+	if stmt.Select = p.parseSelectStatement(); stmt.Select == nil {
+		return nil
+	}
+	end := min(p.peekToken.Pos, len(p.lexer.input))
+	stmt.Query = strings.TrimSpace(p.lexer.input[start:end])
+
+	if !p.expectEnd(stmt.String()) {
+		return nil
+	}
+	return stmt
+}
+
+// REFRESH MATERIALIZED VIEW name
+func (p *Parser) parseRefreshStatement() *RefreshStatement {
+	if !p.expectWord("MATERIALIZED") || !p.expectWord("VIEW") || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt := &RefreshStatement{Name: p.curToken.Literal}
+
+	if !p.expectEnd("REFRESH") {
+		return nil
+	}
+	return stmt
+}
+
+// CREATE TABLE [IF NOT EXISTS] name (column type [constraints], ...,
+// [PRIMARY KEY (cols)], [UNIQUE (cols)], [FOREIGN KEY (cols) REFERENCES
+// table [(cols)]]) [USING kind] [LOCATION 'path'] [WITH (option = value, ...)]
+func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
+	stmt := &CreateTableStatement{}
+
+	var ok bool
+	if stmt.IfNotExists, ok = p.parseIfNotExists(); !ok {
+		return nil
+	}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Table = p.curToken.Literal
+
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+	for {
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		if !p.parseTableElement(stmt) {
+			return nil
+		}
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	for {
+		switch {
+		case p.peekWord("USING"):
+			p.nextToken()
+			if !p.expectPeek(IDENT) {
+				return nil
+			}
+			stmt.Using = strings.ToLower(p.curToken.Literal)
+		case p.peekWord("LOCATION"):
+			p.nextToken()
+			if !p.expectPeek(STRING) {
+				return nil
+			}
+			stmt.Location = p.curToken.Literal
+		case p.peekWord("WITH"):
+			p.nextToken()
+			if stmt.Options = p.parseOptions(); stmt.Options == nil {
+				return nil
+			}
+		default:
+			if !p.expectEnd("CREATE TABLE") {
+				return nil
+			}
+			return stmt
+		}
+	}
+}
+
+// a column definition or table constraint, starting at its first token
+func (p *Parser) parseTableElement(stmt *CreateTableStatement) bool {
+	word := strings.ToUpper(p.curToken.Literal)
+	switch {
+	case word == "PRIMARY" && p.peekWord("KEY"):
+		p.nextToken()
+		if stmt.PrimaryKey != nil {
+			p.addError("table has more than one primary key")
+			return false
+		}
+		stmt.PrimaryKey = p.parseNameList()
+		return stmt.PrimaryKey != nil
+
+	case word == "UNIQUE" && p.peekTokenIs(LPAREN):
+		cols := p.parseNameList()
+		stmt.Unique = append(stmt.Unique, cols)
+		return cols != nil
+
+	case word == "FOREIGN" && p.peekWord("KEY"):
+		p.nextToken()
+		fk := &ForeignKeyDef{Columns: p.parseNameList()}
+		if fk.Columns == nil || !p.parseReferences(fk) {
+			return false
+		}
+		stmt.ForeignKeys = append(stmt.ForeignKeys, fk)
+		return true
+	}
+
+	col := p.parseColumnDef(stmt)
+	if col == nil {
+		return false
+	}
+	stmt.Columns = append(stmt.Columns, col)
+	return true
+}
+
+// column name, type and constraints starting at the name. Constraints
+// other than NOT NULL are added to stmt, and are errors when it is nil
+func (p *Parser) parseColumnDef(stmt *CreateTableStatement) *ColumnDef {
+	col := &ColumnDef{Name: p.curToken.Literal}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	col.TypeName = p.parseTypeName()
+
+	for {
+		switch {
+		case p.peekTokenIs(NOT):
+			p.nextToken()
+			if !p.expectPeek(NULL) {
+				return nil
+			}
+			col.NotNull = true
+			continue
+		case p.peekTokenIs(NULL):
+			p.nextToken()
+			continue
+		case !p.peekWord("PRIMARY") && !p.peekWord("UNIQUE") && !p.peekWord("REFERENCES"):
+			return col
+		}
+
+		if stmt == nil {
+			p.addError(fmt.Sprintf("unexpected %s, only NOT NULL can be given here", p.peekToken.Literal))
+			return nil
+		}
+		p.nextToken()
+		switch strings.ToUpper(p.curToken.Literal) {
+		case "PRIMARY":
+			if !p.expectWord("KEY") {
+				return nil
+			}
+			if stmt.PrimaryKey != nil {
+				p.addError("table has more than one primary key")
+				return nil
+			}
+			stmt.PrimaryKey = []string{col.Name}
+		case "UNIQUE":
+			stmt.Unique = append(stmt.Unique, []string{col.Name})
+		case "REFERENCES":
+			fk := &ForeignKeyDef{Columns: []string{col.Name}}
+			if !p.parseReferencedTable(fk) {
+				return nil
+			}
+			stmt.ForeignKeys = append(stmt.ForeignKeys, fk)
+		}
+	}
+}
+
+// REFERENCES table [(cols)] coming next
+func (p *Parser) parseReferences(fk *ForeignKeyDef) bool {
+	return p.expectWord("REFERENCES") && p.parseReferencedTable(fk)
+}
+
+// table [(cols)] after REFERENCES
+func (p *Parser) parseReferencedTable(fk *ForeignKeyDef) bool {
+	if !p.expectPeek(IDENT) {
+		return false
+	}
+	fk.RefTable = p.curToken.Literal
+	if p.peekTokenIs(LPAREN) {
+		if fk.RefColumns = p.parseNameList(); fk.RefColumns == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// (name = value, ...) of source options, names are lowercased and values
+// are strings, numbers, booleans or words
+func (p *Parser) parseOptions() map[string]string {
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+
+	options := make(map[string]string)
+	for {
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		name := strings.ToLower(p.curToken.Literal)
+		if !p.expectPeek(EQ) {
+			return nil
+		}
+		p.nextToken()
+		switch p.curToken.Type {
+		case STRING, INT, NUMBER, IDENT, TRUE, FALSE:
+			options[name] = p.curToken.Literal
+		default:
+			p.addError(fmt.Sprintf("expected a value for option %s, got %s", name, p.curToken.Type))
+			return nil
+		}
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return options
+}
+
+// CREATE INDEX [IF NOT EXISTS] name ON table (cols)
+func (p *Parser) parseCreateIndexStatement() *CreateIndexStatement {
+	stmt := &CreateIndexStatement{}
+
+	var ok bool
+	if stmt.IfNotExists, ok = p.parseIfNotExists(); !ok {
+		return nil
+	}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Name = p.curToken.Literal
+
+	if !p.expectPeek(ON) || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Table = p.curToken.Literal
+	if stmt.Columns = p.parseNameList(); stmt.Columns == nil {
+		return nil
+	}
+
+	if !p.expectEnd("CREATE INDEX") {
+		return nil
+	}
+	return stmt
+}
+
+// DROP TABLE [IF EXISTS] name, DROP [MATERIALIZED] VIEW [IF EXISTS] name or
+// DROP INDEX [IF EXISTS] name [ON table]
+func (p *Parser) parseDropStatement() Statement {
+	index := p.peekWord("INDEX")
+	view, materialized := p.peekWord("VIEW"), p.peekWord("MATERIALIZED")
+	switch {
+	case index, view:
+		p.nextToken()
+	case materialized:
+		p.nextToken()
+		if !p.expectWord("VIEW") {
+			return nil
+		}
+		view = true
+	case !p.expectWord("TABLE"):
+		return nil
+	}
+
+	ifExists, ok := p.parseIfExists()
+	if !ok || !p.expectPeek(IDENT) {
+		return nil
+	}
+	name := p.curToken.Literal
+
+	if index {
+		stmt := &DropIndexStatement{Name: name, IfExists: ifExists}
+		if p.peekTokenIs(ON) {
+			p.nextToken()
+			if !p.expectPeek(IDENT) {
+				return nil
+			}
+			stmt.Table = p.curToken.Literal
+		}
+		if !p.expectEnd("DROP INDEX") {
+			return nil
+		}
+		return stmt
+	}
+	stmt := &DropTableStatement{Table: name, IfExists: ifExists, View: view, Materialized: materialized}
+	if !p.expectEnd(stmt.String()) {
+		return nil
+	}
+	return stmt
+}
+
+// ALTER TABLE name followed by one of
+//
+//	ADD [COLUMN] name type [NOT NULL]
+//	DROP [COLUMN] name
+//	RENAME [COLUMN] name TO new_name
+//	RENAME TO new_name
+//	ALTER [COLUMN] name [SET DATA] TYPE type
+func (p *Parser) parseAlterTableStatement() *AlterTableStatement {
+	if !p.expectWord("TABLE") || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt := &AlterTableStatement{Table: p.curToken.Literal}
+
+	// COLUMN is optional after the action word
+	column := func() bool {
+		if p.peekWord("COLUMN") {
+			p.nextToken()
+		}
+		if !p.expectPeek(IDENT) {
+			return false
+		}
+		stmt.Name = p.curToken.Literal
+		return true
+	}
+
+	switch {
+	case p.peekWord("ADD"):
+		p.nextToken()
+		if !column() {
+			return nil
+		}
+		stmt.Action = AddColumn
+		if stmt.Column = p.parseColumnDef(nil); stmt.Column == nil {
+			return nil
+		}
+
+	case p.peekTokenIs(DROP):
+		p.nextToken()
+		if !column() {
+			return nil
+		}
+		stmt.Action = DropColumn
+
+	case p.peekWord("RENAME"):
+		p.nextToken()
+		if p.peekWord("TO") {
+			p.nextToken()
+			stmt.Action = RenameTable
+		} else {
+			if !column() || !p.expectWord("TO") {
+				return nil
+			}
+			stmt.Action = RenameColumn
+		}
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.NewName = p.curToken.Literal
+
+	case p.peekTokenIs(ALTER):
+		p.nextToken()
+		if !column() {
+			return nil
+		}
+		stmt.Action = AlterColumnType
+		if p.peekTokenIs(SET) {
+			p.nextToken()
+			if !p.expectWord("DATA") {
+				return nil
+			}
+		}
+		if !p.expectWord("TYPE") || !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.Column = &ColumnDef{Name: stmt.Name, TypeName: p.parseTypeName()}
+
+	default:
+		p.addError(fmt.Sprintf("expected ADD, DROP, RENAME or ALTER, got %s", p.peekToken.Literal))
+		return nil
+	}
+
+	if !p.expectEnd("ALTER TABLE") {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseSelectStatement() *SelectStatement {
-	stmt := &SelectStatement{}
+	stmt := &SelectStatement{Hints: p.hints}
+	p.hints = nil
 
-	if !(p.peekTokenIs(ASTERISK) || p.peekTokenIs(IDENT)) {
+	if p.peekTokenIs(FROM) || p.peekTokenIs(EOF) {
 		p.addError("expected column name or '*'")
 		return nil
 	}
@@ -84,14 +936,16 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 	p.nextToken()
 	stmt.From = p.parseTableRef()
 
-	// parsing optional JOINs
-	for p.peekTokenIs(JOIN) || p.peekTokenIs(INNER) || p.peekTokenIs(LEFT) || p.peekTokenIs(RIGHT) {
+	// parsing optional JOINs, a comma is a cross join
+	for p.peekTokenIs(JOIN) || p.peekTokenIs(INNER) || p.peekTokenIs(LEFT) || p.peekTokenIs(RIGHT) ||
+		p.peekTokenIs(CROSS) || p.peekTokenIs(COMMA) {
 		p.nextToken()
 
 		join := p.parseJoinClause()
-		if join != nil {
-			stmt.Joins = append(stmt.Joins, join)
+		if join == nil {
+			return nil
 		}
+		stmt.Joins = append(stmt.Joins, join)
 	}
 
 	// parse optional WHERE clause
@@ -102,22 +956,139 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 		stmt.Where = p.parseExpression()
 	}
 
-	return stmt
-}
-
-func (p *Parser) parseExpression() Expression {
-	return p.parseOrExpression()
+	// parse optional GROUP BY and HAVING
+	if p.peekTokenIs(GROUP) {
+		p.nextToken()
+		if !p.expectPeek(BY) {
+			return nil
+		}
+		p.nextToken()
+
+		stmt.GroupBy = append(stmt.GroupBy, p.parseExpression())
+		for p.peekTokenIs(COMMA) {
+			p.nextToken()
+			p.nextToken()
+			stmt.GroupBy = append(stmt.GroupBy, p.parseExpression())
+		}
+	}
+
+	if p.peekTokenIs(HAVING) {
+		p.nextToken()
+		p.nextToken()
+
+		stmt.Having = p.parseExpression()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExpression() Expression {
+	return p.parseOrExpression()
+}
+
+func (p *Parser) parseOrExpression() Expression {
+	left := p.parseAndExpression()
+
+	for p.peekTokenIs(OR) {
+		p.nextToken()
+
+		op := p.curToken.Literal
+		p.nextToken()
+		right := p.parseAndExpression()
+		left = &BinaryExpr{
+			Left:     left,
+			Operator: op,
+			Right:    right,
+		}
+	}
+
+	return left
+}
+
+func (p *Parser) parseAndExpression() Expression {
+	left := p.parseNotExpression()
+
+	for p.peekTokenIs(AND) {
+		p.nextToken()
+		op := p.curToken.Literal
+		p.nextToken()
+		right := p.parseNotExpression()
+		left = &BinaryExpr{
+			Left:     left,
+			Operator: op,
+			Right:    right,
+		}
+	}
+
+	return left
+}
+
+func (p *Parser) parseNotExpression() Expression {
+	if p.curTokenIs(NOT) {
+		p.nextToken()
+		return &NotExpr{Expr: p.parseNotExpression()}
+	}
+
+	return p.parseComparisionExpression()
+}
+
+func (p *Parser) parseComparisionExpression() Expression {
+	left := p.parseAdditiveExpression()
+
+	if p.peekTokenIs(IS) {
+		p.nextToken()
+		expr := &IsNullExpr{Expr: left}
+		if p.peekTokenIs(NOT) {
+			p.nextToken()
+			expr.Not = true
+		}
+		if !p.expectPeek(NULL) {
+			return nil
+		}
+
+		return expr
+	}
+
+	// expr [NOT] LIKE pattern
+	if p.peekTokenIs(LIKE) || (p.peekTokenIs(NOT) && p.lexer.peekKeyword(LIKE)) {
+		p.nextToken()
+		not := p.curTokenIs(NOT)
+		if not {
+			p.nextToken()
+		}
+		p.nextToken()
+
+		var expr Expression = &BinaryExpr{Left: left, Operator: "LIKE", Right: p.parseAdditiveExpression()}
+		if not {
+			expr = &NotExpr{Expr: expr}
+		}
+		return expr
+	}
+
+	if p.peekTokenIs(EQ) || p.peekTokenIs(NEQ) || p.peekTokenIs(LT) || p.peekTokenIs(LTE) || p.peekTokenIs(GT) || p.peekTokenIs(GTE) {
+		p.nextToken()
+		op := p.curToken.Literal
+		p.nextToken()
+		right := p.parseAdditiveExpression()
+
+		return &BinaryExpr{
+			Left:     left,
+			Operator: op,
+			Right:    right,
+		}
+	}
+
+	return left
 }
 
-func (p *Parser) parseOrExpression() Expression {
-	left := p.parseAndExpression()
+func (p *Parser) parseAdditiveExpression() Expression {
+	left := p.parseMultiplicativeExpression()
 
-	for p.peekTokenIs(OR) {
+	for p.peekTokenIs(PLUS) || p.peekTokenIs(MINUS) {
 		p.nextToken()
-
 		op := p.curToken.Literal
 		p.nextToken()
-		right := p.parseAndExpression()
+		right := p.parseMultiplicativeExpression()
 		left = &BinaryExpr{
 			Left:     left,
 			Operator: op,
@@ -128,14 +1099,14 @@ func (p *Parser) parseOrExpression() Expression {
 	return left
 }
 
-func (p *Parser) parseAndExpression() Expression {
-	left := p.parseComparisionExpression()
+func (p *Parser) parseMultiplicativeExpression() Expression {
+	left := p.parseUnaryExpression()
 
-	for p.peekTokenIs(AND) {
+	for p.peekTokenIs(ASTERISK) || p.peekTokenIs(SLASH) || p.peekTokenIs(PERCENT) {
 		p.nextToken()
 		op := p.curToken.Literal
 		p.nextToken()
-		right := p.parseComparisionExpression()
+		right := p.parseUnaryExpression()
 		left = &BinaryExpr{
 			Left:     left,
 			Operator: op,
@@ -145,19 +1116,57 @@ func (p *Parser) parseAndExpression() Expression {
 
 	return left
 }
-func (p *Parser) parseComparisionExpression() Expression {
+
+// unary minus, negative integer literals are folded right away
+func (p *Parser) parseUnaryExpression() Expression {
+	if !p.curTokenIs(MINUS) {
+		return p.parsePathExpression()
+	}
+	p.nextToken()
+
+	operand := p.parseUnaryExpression()
+	if lit, ok := operand.(*Literal); ok {
+		switch lit.Type {
+		case IntLiteral:
+			return &Literal{Type: IntLiteral, Value: -lit.Value.(int)}
+		case FloatLiteral:
+			return &Literal{Type: FloatLiteral, Value: -lit.Value.(float64)}
+		case DecimalLiteral:
+			if v := lit.Value.(string); !strings.HasPrefix(v, "-") {
+				return &Literal{Type: DecimalLiteral, Value: "-" + v}
+			}
+		}
+	}
+
+	return &BinaryExpr{
+		Left:     &Literal{Type: IntLiteral, Value: 0},
+		Operator: "-",
+		Right:    operand,
+	}
+}
+
+// JSON access, doc -> 'key' -> 0 ->> 'name', binds tighter than arithmetic
+func (p *Parser) parsePathExpression() Expression {
 	left := p.parsePrimaryExpression()
 
-	if p.peekTokenIs(EQ) || p.peekTokenIs(NEQ) || p.peekTokenIs(LT) || p.peekTokenIs(LTE) || p.peekTokenIs(GT) || p.peekTokenIs(GTE) {
+	for p.peekTokenIs(ARROW) || p.peekTokenIs(LONGARROW) {
 		p.nextToken()
 		op := p.curToken.Literal
 		p.nextToken()
-		right := p.parsePrimaryExpression()
 
-		return &BinaryExpr{
+		var key Expression
+		if p.curTokenIs(MINUS) && p.peekTokenIs(INT) {
+			p.nextToken()
+			n, _ := strconv.Atoi(p.curToken.Literal)
+			key = &Literal{Type: IntLiteral, Value: -n}
+		} else {
+			key = p.parsePrimaryExpression()
+		}
+
+		left = &BinaryExpr{
 			Left:     left,
 			Operator: op,
-			Right:    right,
+			Right:    key,
 		}
 	}
 
@@ -167,14 +1176,46 @@ func (p *Parser) parseComparisionExpression() Expression {
 func (p *Parser) parsePrimaryExpression() Expression {
 	switch p.curToken.Type {
 	case IDENT:
+		if p.peekTokenIs(STRING) {
+			return p.parseTypedLiteral()
+		}
+		if strings.EqualFold(p.curToken.Literal, "EXTRACT") && p.peekTokenIs(LPAREN) {
+			return p.parseExtract()
+		}
+		if p.peekTokenIs(LPAREN) {
+			return p.parseFunctionCall()
+		}
 		return p.parseColumnRef()
 
 	case INT:
-		val, _ := strconv.Atoi(p.curToken.Literal)
+		val, err := strconv.Atoi(p.curToken.Literal)
+		if err != nil {
+			// too large for INT, keep it exact
+			return &Literal{Type: DecimalLiteral, Value: p.curToken.Literal}
+		}
 		return &Literal{Type: IntLiteral, Value: val}
 
+	case NUMBER:
+		if strings.ContainsAny(p.curToken.Literal, "eE") {
+			f, err := strconv.ParseFloat(p.curToken.Literal, 64)
+			if err != nil {
+				p.addError(fmt.Sprintf("invalid number %s", p.curToken.Literal))
+				return nil
+			}
+			return &Literal{Type: FloatLiteral, Value: f}
+		}
+		return &Literal{Type: DecimalLiteral, Value: p.curToken.Literal}
+
 	case STRING:
 		return &Literal{Type: StringLiteral, Value: p.curToken.Literal}
+	case NULL:
+		return &Literal{Type: NullLiteral}
+	case TRUE, FALSE:
+		return &Literal{Type: BoolLiteral, Value: p.curTokenIs(TRUE)}
+	case CASE:
+		return p.parseCaseExpression()
+	case CAST:
+		return p.parseCastExpression()
 	case LPAREN:
 		p.nextToken()
 		expr := p.parseExpression()
@@ -190,6 +1231,124 @@ func (p *Parser) parsePrimaryExpression() Expression {
 	}
 }
 
+func (p *Parser) parseCaseExpression() Expression {
+	expr := &CaseExpr{}
+
+	if !p.peekTokenIs(WHEN) {
+		p.nextToken()
+		expr.Operand = p.parseExpression()
+	}
+
+	for p.peekTokenIs(WHEN) {
+		p.nextToken()
+		p.nextToken()
+		when := &WhenClause{Condition: p.parseExpression()}
+
+		if !p.expectPeek(THEN) {
+			return nil
+		}
+		p.nextToken()
+		when.Result = p.parseExpression()
+
+		expr.Whens = append(expr.Whens, when)
+	}
+
+	if len(expr.Whens) == 0 {
+		p.addError("CASE requires at least one WHEN")
+		return nil
+	}
+
+	if p.peekTokenIs(ELSE) {
+		p.nextToken()
+		p.nextToken()
+		expr.Else = p.parseExpression()
+	}
+
+	if !p.expectPeek(END) {
+		return nil
+	}
+
+	return expr
+}
+
+// DATE '2024-01-31', TIMESTAMP '...' and INTERVAL '3 days' or '3' DAY
+func (p *Parser) parseTypedLiteral() Expression {
+	var litType LiteralType
+	switch strings.ToUpper(p.curToken.Literal) {
+	case "DATE":
+		litType = DateLiteral
+	case "TIMESTAMP":
+		litType = TimestampLiteral
+	case "INTERVAL":
+		litType = IntervalLiteral
+	default:
+		p.addError(fmt.Sprintf("unexpected string after %s", p.curToken.Literal))
+		return nil
+	}
+
+	p.nextToken()
+	value := p.curToken.Literal
+	if litType == IntervalLiteral && p.peekTokenIs(IDENT) && isIntervalUnit(p.peekToken.Literal) {
+		p.nextToken()
+		value += " " + strings.ToLower(p.curToken.Literal)
+	}
+
+	return &Literal{Type: litType, Value: value}
+}
+
+func isIntervalUnit(s string) bool {
+	switch strings.TrimSuffix(strings.ToUpper(s), "S") {
+	case "YEAR", "MONTH", "WEEK", "DAY", "HOUR", "MINUTE", "SECOND":
+		return true
+	}
+
+	return false
+}
+
+// EXTRACT(field FROM expr), parsed as a call to EXTRACT('field', expr)
+func (p *Parser) parseExtract() Expression {
+	p.nextToken()
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	field := &Literal{Type: StringLiteral, Value: strings.ToUpper(p.curToken.Literal)}
+
+	if !p.expectPeek(FROM) {
+		return nil
+	}
+	p.nextToken()
+	expr := p.parseExpression()
+
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return &FunctionCall{Name: "EXTRACT", Args: []Expression{field, expr}}
+}
+
+func (p *Parser) parseCastExpression() Expression {
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	expr := &CastExpr{Expr: p.parseExpression()}
+
+	if !p.expectPeek(AS) {
+		return nil
+	}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	expr.TypeName = p.parseTypeName()
+
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return expr
+}
+
 func (p *Parser) parseJoinClause() *JoinClause {
 	join := &JoinClause{}
 
@@ -209,6 +1368,17 @@ func (p *Parser) parseJoinClause() *JoinClause {
 		if !p.expectPeek(JOIN) {
 			return nil
 		}
+	} else if p.curTokenIs(CROSS) || p.curTokenIs(COMMA) {
+		join.Type = InnerJoin
+		if p.curTokenIs(CROSS) && !p.expectPeek(JOIN) {
+			return nil
+		}
+
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		join.Table = p.parseTableRef()
+		return join
 	} else {
 		join.Type = InnerJoin
 	}
@@ -218,8 +1388,14 @@ func (p *Parser) parseJoinClause() *JoinClause {
 		return nil
 	}
 	join.Table = p.parseTableRef()
+	if join.Table == nil {
+		return nil
+	}
 
-	// parse on condition
+	// parse on condition, optional for UNNEST
+	if join.Table.Unnest != nil && !p.peekTokenIs(ON) {
+		return join
+	}
 	if !p.expectPeek(ON) {
 		return nil
 	}
@@ -229,10 +1405,25 @@ func (p *Parser) parseJoinClause() *JoinClause {
 	return join
 }
 
+// table name or [LATERAL] UNNEST(expr), with an optional alias
 func (p *Parser) parseTableRef() *TableRef {
+	if strings.EqualFold(p.curToken.Literal, "LATERAL") && p.peekTokenIs(IDENT) {
+		p.nextToken()
+	}
+
 	table := &TableRef{
 		Name: p.curToken.Literal,
 	}
+	if strings.EqualFold(p.curToken.Literal, "UNNEST") && p.peekTokenIs(LPAREN) {
+		p.nextToken()
+		p.nextToken()
+		table.Name = ""
+		table.Alias = "unnest"
+		table.Unnest = p.parseExpression()
+		if !p.expectPeek(RPAREN) {
+			return nil
+		}
+	}
 
 	if p.peekTokenIs(AS) {
 		p.nextToken()
@@ -259,12 +1450,13 @@ func (p *Parser) parseColumnRef() Expression {
 		col.Table = col.Column
 		p.nextToken()
 
-		if !p.expectPeek(IDENT) && !p.expectPeek(ASTERISK) {
-			return nil
-		}
-		if p.curTokenIs(ASTERISK) {
+		if p.peekTokenIs(ASTERISK) {
+			p.nextToken()
 			return &StarExpr{Table: col.Table}
 		}
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
 
 		col.Column = p.curToken.Literal
 	}
@@ -275,20 +1467,229 @@ func (p *Parser) parseColumnRef() Expression {
 func (p *Parser) parseSelectColumns() []Expression {
 	var cols []Expression
 
-	if p.curTokenIs(ASTERISK) {
-		cols = append(cols, &StarExpr{})
-		return cols
-	}
-
 	// parsin first column
-	cols = append(cols, p.parseColumnRef())
+	cols = append(cols, p.parseSelectItem())
 
 	for p.peekTokenIs(COMMA) {
 		p.nextToken()
 		p.nextToken()
 
-		cols = append(cols, p.parseColumnRef())
+		cols = append(cols, p.parseSelectItem())
 	}
 
 	return cols
 }
+
+// single select list entry with an optional alias
+func (p *Parser) parseSelectItem() Expression {
+	if p.curTokenIs(ASTERISK) {
+		return &StarExpr{}
+	}
+
+	expr := p.parseExpression()
+
+	if p.peekTokenIs(AS) {
+		p.nextToken()
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+
+		return &AliasExpr{Expr: expr, Alias: p.curToken.Literal}
+	} else if p.peekTokenIs(IDENT) {
+		p.nextToken()
+		return &AliasExpr{Expr: expr, Alias: p.curToken.Literal}
+	}
+
+	return expr
+}
+
+// name(args) [OVER (...)], current token is the function name
+func (p *Parser) parseFunctionCall() Expression {
+	fn := &FunctionCall{Name: strings.ToUpper(p.curToken.Literal)}
+	p.nextToken() // (
+
+	if p.peekTokenIs(RPAREN) {
+		p.nextToken()
+	} else {
+		p.nextToken()
+		fn.Args = append(fn.Args, p.parseFunctionArg())
+
+		for p.peekTokenIs(COMMA) {
+			p.nextToken()
+			p.nextToken()
+			fn.Args = append(fn.Args, p.parseFunctionArg())
+		}
+
+		if !p.expectPeek(RPAREN) {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(OVER) {
+		p.nextToken()
+		fn.Over = p.parseWindowSpec()
+		if fn.Over == nil {
+			return nil
+		}
+	}
+
+	return fn
+}
+
+func (p *Parser) parseFunctionArg() Expression {
+	if p.curTokenIs(ASTERISK) { // COUNT(*)
+		return &StarExpr{}
+	}
+
+	return p.parseExpression()
+}
+
+func (p *Parser) parseWindowSpec() *WindowSpec {
+	spec := &WindowSpec{}
+
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+
+	if p.peekTokenIs(PARTITION) {
+		p.nextToken()
+		if !p.expectPeek(BY) {
+			return nil
+		}
+		p.nextToken()
+
+		spec.PartitionBy = append(spec.PartitionBy, p.parseExpression())
+		for p.peekTokenIs(COMMA) {
+			p.nextToken()
+			p.nextToken()
+			spec.PartitionBy = append(spec.PartitionBy, p.parseExpression())
+		}
+	}
+
+	if p.peekTokenIs(ORDER) {
+		p.nextToken()
+		if !p.expectPeek(BY) {
+			return nil
+		}
+		p.nextToken()
+
+		spec.OrderBy = p.parseOrderByList()
+	}
+
+	if p.peekTokenIs(ROWS) || p.peekTokenIs(RANGE) {
+		p.nextToken()
+
+		spec.Frame = p.parseWindowFrame()
+		if spec.Frame == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return spec
+}
+
+func (p *Parser) parseOrderByList() []*OrderByExpr {
+	var items []*OrderByExpr
+
+	for {
+		item := &OrderByExpr{Expr: p.parseExpression()}
+
+		if p.peekTokenIs(ASC) {
+			p.nextToken()
+		} else if p.peekTokenIs(DESC) {
+			p.nextToken()
+			item.Desc = true
+		}
+		items = append(items, item)
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+		p.nextToken()
+	}
+
+	return items
+}
+
+// ROWS|RANGE bound or ROWS|RANGE BETWEEN bound AND bound
+func (p *Parser) parseWindowFrame() *WindowFrame {
+	frame := &WindowFrame{Unit: RowsFrame, End: FrameBound{Type: CurrentRow}}
+	if p.curTokenIs(RANGE) {
+		frame.Unit = RangeFrame
+	}
+
+	if p.peekTokenIs(BETWEEN) {
+		p.nextToken()
+		p.nextToken()
+
+		start, ok := p.parseFrameBound()
+		if !ok {
+			return nil
+		}
+		if !p.expectPeek(AND) {
+			return nil
+		}
+		p.nextToken()
+
+		end, ok := p.parseFrameBound()
+		if !ok {
+			return nil
+		}
+		frame.Start, frame.End = start, end
+	} else {
+		p.nextToken()
+
+		start, ok := p.parseFrameBound()
+		if !ok {
+			return nil
+		}
+		frame.Start = start
+	}
+
+	if frame.Start.Type == UnboundedFollowing || frame.End.Type == UnboundedPreceding || frame.Start.Type > frame.End.Type {
+		p.addError(fmt.Sprintf("invalid window frame: %s", frame))
+		return nil
+	}
+
+	return frame
+}
+
+func (p *Parser) parseFrameBound() (FrameBound, bool) {
+	switch p.curToken.Type {
+	case UNBOUNDED:
+		if p.peekTokenIs(PRECEDING) {
+			p.nextToken()
+			return FrameBound{Type: UnboundedPreceding}, true
+		}
+		if !p.expectPeek(FOLLOWING) {
+			return FrameBound{}, false
+		}
+		return FrameBound{Type: UnboundedFollowing}, true
+
+	case CURRENT:
+		if !p.expectPeek(ROW) {
+			return FrameBound{}, false
+		}
+		return FrameBound{Type: CurrentRow}, true
+
+	case INT:
+		offset, _ := strconv.Atoi(p.curToken.Literal)
+		if p.peekTokenIs(PRECEDING) {
+			p.nextToken()
+			return FrameBound{Type: Preceding, Offset: offset}, true
+		}
+		if !p.expectPeek(FOLLOWING) {
+			return FrameBound{}, false
+		}
+		return FrameBound{Type: Following, Offset: offset}, true
+
+	default:
+		p.addError(fmt.Sprintf("unexpected token in window frame: %s", p.curToken.Type))
+		return FrameBound{}, false
+	}
+}
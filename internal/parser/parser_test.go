@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -115,4 +117,509 @@ func TestParseComplexWhere(t *testing.T) {
 	if andExpr.Operator != "AND" {
 		t.Fatalf("expected AND operator, got '%s'", andExpr.Operator)
 	}
-}
\ No newline at end of file
+}
+func TestParseWindowFunction(t *testing.T) {
+	input := `SELECT name, SUM(amount) OVER (PARTITION BY city ORDER BY age DESC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) AS running FROM users`
+
+	p := NewParser(input)
+	stmt := p.Parse()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	selectStmt := stmt.(*SelectStatement)
+	if len(selectStmt.Columns) != 2 {
+		t.Fatalf("expected 2 columns, got %d", len(selectStmt.Columns))
+	}
+
+	alias, ok := selectStmt.Columns[1].(*AliasExpr)
+	if !ok || alias.Alias != "running" {
+		t.Fatalf("expected alias 'running', got %v", selectStmt.Columns[1])
+	}
+
+	fn, ok := alias.Expr.(*FunctionCall)
+	if !ok || fn.Name != "SUM" || fn.Over == nil {
+		t.Fatalf("expected SUM window function, got %v", alias.Expr)
+	}
+
+	if len(fn.Over.PartitionBy) != 1 || len(fn.Over.OrderBy) != 1 || !fn.Over.OrderBy[0].Desc {
+		t.Fatalf("unexpected window spec: %s", fn.Over)
+	}
+
+	frame := fn.Over.Frame
+	if frame == nil || frame.Unit != RowsFrame || frame.Start.Type != Preceding || frame.Start.Offset != 2 || frame.End.Type != CurrentRow {
+		t.Fatalf("unexpected frame: %v", frame)
+	}
+}
+
+func TestParseInvalidWindowFrame(t *testing.T) {
+	input := `SELECT ROW_NUMBER() OVER (ORDER BY id ROWS BETWEEN CURRENT ROW AND 1 PRECEDING) FROM users`
+
+	p := NewParser(input)
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatal("expected error for frame ending before it starts")
+	}
+}
+
+func TestParseCaseAndCast(t *testing.T) {
+	input := `SELECT CASE WHEN age > 30 THEN 'senior' ELSE 'junior' END AS band, CAST(age AS VARCHAR(10)), COALESCE(city, NULL) FROM users`
+
+	p := NewParser(input)
+	stmt := p.Parse()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	selectStmt := stmt.(*SelectStatement)
+	if len(selectStmt.Columns) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(selectStmt.Columns))
+	}
+
+	caseExpr, ok := selectStmt.Columns[0].(*AliasExpr).Expr.(*CaseExpr)
+	if !ok || caseExpr.Operand != nil || len(caseExpr.Whens) != 1 || caseExpr.Else == nil {
+		t.Fatalf("unexpected CASE: %v", selectStmt.Columns[0])
+	}
+
+	castExpr, ok := selectStmt.Columns[1].(*CastExpr)
+	if !ok || castExpr.TypeName != "VARCHAR" {
+		t.Fatalf("unexpected CAST: %v", selectStmt.Columns[1])
+	}
+
+	fn, ok := selectStmt.Columns[2].(*FunctionCall)
+	if !ok || fn.Name != "COALESCE" || len(fn.Args) != 2 {
+		t.Fatalf("unexpected function call: %v", selectStmt.Columns[2])
+	}
+	if lit, ok := fn.Args[1].(*Literal); !ok || lit.Type != NullLiteral {
+		t.Fatalf("expected NULL literal, got %v", fn.Args[1])
+	}
+}
+
+func TestParseGroupByHaving(t *testing.T) {
+	input := `SELECT city, COUNT(*) FROM users GROUP BY city, age HAVING COUNT(*) > 1`
+
+	p := NewParser(input)
+	stmt := p.Parse()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	selectStmt := stmt.(*SelectStatement)
+	if len(selectStmt.GroupBy) != 2 {
+		t.Fatalf("expected 2 GROUP BY expressions, got %d", len(selectStmt.GroupBy))
+	}
+	if selectStmt.Having == nil {
+		t.Fatal("expected HAVING clause, got nil")
+	}
+}
+
+func TestParseArithmeticPrecedence(t *testing.T) {
+	input := `SELECT id FROM users WHERE NOT age + 2 * 3 > -1 AND name IS NOT NULL`
+
+	p := NewParser(input)
+	stmt := p.Parse()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	where := stmt.(*SelectStatement).Where.String()
+	expected := "((NOT ((age + (2 * 3)) > -1)) AND (name IS NOT NULL))"
+	if where != expected {
+		t.Fatalf("expected %s, got %s", expected, where)
+	}
+}
+
+func TestParseAnalyze(t *testing.T) {
+	tests := []struct {
+		input  string
+		table  string
+		sample int
+	}{
+		{"ANALYZE", "", 0},
+		{"ANALYZE users", "users", 0},
+		{"ANALYZE orders SAMPLE 100;", "orders", 100},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: parser has errors: %v", tt.input, p.Errors())
+		}
+
+		analyze := stmt.(*AnalyzeStatement)
+		if analyze.Table != tt.table || analyze.Sample != tt.sample {
+			t.Fatalf("%s: got table %q sample %d", tt.input, analyze.Table, analyze.Sample)
+		}
+	}
+
+	p := NewParser("ANALYZE users SAMPLE 0")
+	p.Parse()
+	if len(p.Errors()) == 0 {
+		t.Fatal("expected error for sample size 0")
+	}
+}
+
+func TestParseLike(t *testing.T) {
+	p := NewParser(`SELECT name FROM users WHERE name LIKE 'a%' AND city NOT LIKE '_b'`)
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	where := stmt.(*SelectStatement).Where.(*BinaryExpr)
+	if like, ok := where.Left.(*BinaryExpr); !ok || like.Operator != "LIKE" {
+		t.Fatalf("expected LIKE, got %s", where.Left)
+	}
+	not, ok := where.Right.(*NotExpr)
+	if !ok {
+		t.Fatalf("expected NOT LIKE, got %s", where.Right)
+	}
+	if like, ok := not.Expr.(*BinaryExpr); !ok || like.Operator != "LIKE" {
+		t.Fatalf("expected LIKE under NOT, got %s", not.Expr)
+	}
+}
+
+func TestParseTypedLiterals(t *testing.T) {
+	tests := map[string]LiteralType{
+		"SELECT 1.50 FROM t":                            DecimalLiteral,
+		"SELECT 1e3 FROM t":                             FloatLiteral,
+		"SELECT DATE '2024-01-01' FROM t":               DateLiteral,
+		"SELECT TIMESTAMP '2024-01-01 10:00:00' FROM t": TimestampLiteral,
+		"SELECT INTERVAL '3 days' FROM t":               IntervalLiteral,
+		"SELECT INTERVAL '3' DAY FROM t":                IntervalLiteral,
+	}
+
+	for query, expected := range tests {
+		p := NewParser(query)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: parser has errors: %v", query, p.Errors())
+		}
+
+		lit, ok := stmt.(*SelectStatement).Columns[0].(*Literal)
+		if !ok || lit.Type != expected {
+			t.Fatalf("%s: expected literal of type %d, got %s", query, expected, stmt.(*SelectStatement).Columns[0])
+		}
+	}
+
+	p := NewParser("SELECT EXTRACT(YEAR FROM created_at) FROM t")
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+	call, ok := stmt.(*SelectStatement).Columns[0].(*FunctionCall)
+	if !ok || call.Name != "EXTRACT" || len(call.Args) != 2 {
+		t.Fatalf("expected EXTRACT call, got %s", stmt.(*SelectStatement).Columns[0])
+	}
+}
+
+func TestParseJSONAccessAndUnnest(t *testing.T) {
+	p := NewParser(`SELECT doc->'a'->>'b' FROM t CROSS JOIN UNNEST(t.items) AS item LEFT JOIN LATERAL UNNEST(doc->'tags') tag ON tag->>'x' = 'y'`)
+	stmt := p.Parse()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	sel := stmt.(*SelectStatement)
+	outer, ok := sel.Columns[0].(*BinaryExpr)
+	if !ok || outer.Operator != "->>" {
+		t.Fatalf("expected ->> at the top, got %s", sel.Columns[0])
+	}
+	if inner, ok := outer.Left.(*BinaryExpr); !ok || inner.Operator != "->" {
+		t.Fatalf("expected -> on the left, got %s", outer.Left)
+	}
+
+	if len(sel.Joins) != 2 {
+		t.Fatalf("expected 2 joins, got %d", len(sel.Joins))
+	}
+	cross, left := sel.Joins[0], sel.Joins[1]
+	if cross.Table.Unnest == nil || cross.Table.Alias != "item" || cross.Condition != nil {
+		t.Fatalf("unexpected cross join %+v", cross.Table)
+	}
+	if left.Type != LeftJoin || left.Table.Unnest == nil || left.Table.Alias != "tag" || left.Condition == nil {
+		t.Fatalf("unexpected left join %+v", left.Table)
+	}
+}
+
+func TestParseCopy(t *testing.T) {
+	for _, input := range []string{
+		"COPY orders TO 'orders.col' FORMAT columnar",
+		"copy orders to 'orders.col' with (format COLUMNAR);",
+	} {
+		p := NewParser(input)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: unexpected errors %v", input, p.Errors())
+		}
+
+		copyStmt, ok := stmt.(*CopyStatement)
+		if !ok {
+			t.Fatalf("%s: expected a COPY statement, got %T", input, stmt)
+		}
+		if copyStmt.Table != "orders" || copyStmt.Path != "orders.col" || copyStmt.Format != "columnar" {
+			t.Errorf("%s: unexpected statement %+v", input, copyStmt)
+		}
+	}
+
+	p := NewParser("COPY orders TO 'orders.col'")
+	p.Parse()
+	if len(p.Errors()) == 0 {
+		t.Fatal("expected an error for a missing FORMAT")
+	}
+}
+
+func TestParseInsertUpdateDelete(t *testing.T) {
+	p := NewParser("INSERT INTO orders (id, amount) VALUES (6, 10), (7, 20 * 2);")
+	insert, ok := p.Parse().(*InsertStatement)
+	if !ok || len(p.Errors()) > 0 {
+		t.Fatalf("expected an INSERT statement, errors %v", p.Errors())
+	}
+	if insert.Table != "orders" || len(insert.Columns) != 2 || len(insert.Values) != 2 || len(insert.Values[1]) != 2 {
+		t.Fatalf("unexpected insert %+v", insert)
+	}
+
+	p = NewParser("INSERT INTO archive SELECT * FROM orders WHERE status = 'delivered'")
+	insert, ok = p.Parse().(*InsertStatement)
+	if !ok || len(p.Errors()) > 0 || insert.Select == nil || insert.Values != nil {
+		t.Fatalf("expected an INSERT ... SELECT, got %+v, errors %v", insert, p.Errors())
+	}
+
+	p = NewParser("UPDATE orders o SET amount = o.amount * 2, status = 'late' WHERE o.status = 'pending'")
+	update, ok := p.Parse().(*UpdateStatement)
+	if !ok || len(p.Errors()) > 0 {
+		t.Fatalf("expected an UPDATE statement, errors %v", p.Errors())
+	}
+	if update.Table.Name != "orders" || update.Table.Alias != "o" || len(update.Assignments) != 2 || update.Where == nil {
+		t.Fatalf("unexpected update %+v", update)
+	}
+	if update.Assignments[0].Column != "amount" || update.Assignments[0].Value.String() != "(o.amount * 2)" {
+		t.Errorf("unexpected assignment %+v", update.Assignments[0])
+	}
+
+	p = NewParser("DELETE FROM orders")
+	del, ok := p.Parse().(*DeleteStatement)
+	if !ok || len(p.Errors()) > 0 || del.Table.Name != "orders" || del.Where != nil {
+		t.Fatalf("expected a DELETE without WHERE, got %+v, errors %v", del, p.Errors())
+	}
+
+	for _, input := range []string{
+		"INSERT INTO orders VALUES",
+		"UPDATE orders WHERE id = 1",
+		"DELETE orders WHERE id = 1",
+		"DELETE FROM orders WHERE id = 1 extra",
+	} {
+		p := NewParser(input)
+		p.Parse()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	input := `CREATE TABLE IF NOT EXISTS items (
+		id INT PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users,
+		price DECIMAL(10, 2),
+		ratio DOUBLE PRECISION NULL,
+		name VARCHAR(20) UNIQUE,
+		UNIQUE (user_id, name),
+		FOREIGN KEY (user_id, name) REFERENCES owners (id, name)
+	) USING csv LOCATION 'data/items.csv' WITH (delimiter = ';', header = false)`
+
+	p := NewParser(input)
+	stmt, ok := p.Parse().(*CreateTableStatement)
+	if !ok || len(p.Errors()) > 0 {
+		t.Fatalf("expected a CREATE TABLE statement, errors %v", p.Errors())
+	}
+
+	if stmt.Table != "items" || !stmt.IfNotExists || len(stmt.Columns) != 5 {
+		t.Fatalf("unexpected statement %+v", stmt)
+	}
+	var types []string
+	for _, col := range stmt.Columns {
+		types = append(types, col.TypeName)
+	}
+	if strings.Join(types, " ") != "INT INT DECIMAL(10,2) DOUBLE PRECISION VARCHAR" || !stmt.Columns[1].NotNull {
+		t.Errorf("unexpected columns %v", types)
+	}
+	if len(stmt.PrimaryKey) != 1 || len(stmt.Unique) != 2 || len(stmt.ForeignKeys) != 2 {
+		t.Fatalf("unexpected constraints %+v", stmt)
+	}
+	if fk := stmt.ForeignKeys[0]; fk.RefTable != "users" || fk.RefColumns != nil {
+		t.Errorf("unexpected column foreign key %+v", fk)
+	}
+	if stmt.Using != "csv" || stmt.Location != "data/items.csv" || stmt.Options["delimiter"] != ";" || stmt.Options["header"] != "false" {
+		t.Errorf("unexpected storage %q %q %v", stmt.Using, stmt.Location, stmt.Options)
+	}
+
+	for _, input := range []string{
+		"CREATE TABLE t",
+		"CREATE TABLE t (id)",
+		"CREATE TABLE t (id INT PRIMARY KEY, PRIMARY KEY (id))",
+		"CREATE VIEW v",
+	} {
+		p := NewParser(input)
+		p.Parse()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseDefinitionStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Statement
+	}{
+		{"DROP TABLE IF EXISTS items", &DropTableStatement{Table: "items", IfExists: true}},
+		{"CREATE INDEX idx_user ON orders (user_id, status)", &CreateIndexStatement{Name: "idx_user", Table: "orders", Columns: []string{"user_id", "status"}}},
+		{"DROP INDEX idx_id ON users;", &DropIndexStatement{Name: "idx_id", Table: "users"}},
+		{"ALTER TABLE users ADD COLUMN score DECIMAL(5,1) NOT NULL", &AlterTableStatement{Table: "users", Action: AddColumn, Name: "score", Column: &ColumnDef{Name: "score", TypeName: "DECIMAL(5,1)", NotNull: true}}},
+		{"ALTER TABLE users DROP age", &AlterTableStatement{Table: "users", Action: DropColumn, Name: "age"}},
+		{"ALTER TABLE users RENAME COLUMN city TO town", &AlterTableStatement{Table: "users", Action: RenameColumn, Name: "city", NewName: "town"}},
+		{"ALTER TABLE users RENAME TO people", &AlterTableStatement{Table: "users", Action: RenameTable, NewName: "people"}},
+		{"ALTER TABLE users ALTER COLUMN age SET DATA TYPE FLOAT", &AlterTableStatement{Table: "users", Action: AlterColumnType, Name: "age", Column: &ColumnDef{Name: "age", TypeName: "FLOAT"}}},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: unexpected errors %v", tt.input, p.Errors())
+		}
+		if !reflect.DeepEqual(stmt, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.want, stmt)
+		}
+	}
+
+	for _, input := range []string{
+		"ALTER TABLE users ADD COLUMN score INT PRIMARY KEY",
+		"ALTER TABLE users TRUNCATE",
+		"DROP SEQUENCE s",
+	} {
+		p := NewParser(input)
+		p.Parse()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseTransactionStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  TransactionAction
+	}{
+		{"BEGIN", Begin},
+		{"begin transaction;", Begin},
+		{"START TRANSACTION", Begin},
+		{"COMMIT WORK", Commit},
+		{"ROLLBACK", Rollback},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: unexpected errors %v", tt.input, p.Errors())
+		}
+		if got, ok := stmt.(*TransactionStatement); !ok || got.Action != tt.want {
+			t.Errorf("%s: expected action %d, got %+v", tt.input, tt.want, stmt)
+		}
+	}
+
+	for _, input := range []string{"START", "COMMIT users", "BEGIN WORK TRANSACTION"} {
+		p := NewParser(input)
+		p.Parse()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseViewStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Statement
+	}{
+		{"DROP VIEW IF EXISTS v", &DropTableStatement{Table: "v", IfExists: true, View: true}},
+		{"DROP MATERIALIZED VIEW mv;", &DropTableStatement{Table: "mv", View: true, Materialized: true}},
+		{"REFRESH MATERIALIZED VIEW mv", &RefreshStatement{Name: "mv"}},
+	}
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		stmt := p.Parse()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: unexpected errors %v", tt.input, p.Errors())
+		}
+		if !reflect.DeepEqual(stmt, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.want, stmt)
+		}
+	}
+
+	p := NewParser("CREATE MATERIALIZED VIEW IF NOT EXISTS totals (user_id, total) AS\n  SELECT user_id, SUM(amount) FROM orders GROUP BY user_id ;")
+	stmt, ok := p.Parse().(*CreateViewStatement)
+	if len(p.Errors()) > 0 || !ok {
+		t.Fatalf("unexpected errors %v", p.Errors())
+	}
+	if !stmt.Materialized || !stmt.IfNotExists || stmt.Name != "totals" || !reflect.DeepEqual(stmt.Columns, []string{"user_id", "total"}) {
+		t.Fatalf("unexpected statement %+v", stmt)
+	}
+	if stmt.Query != "SELECT user_id, SUM(amount) FROM orders GROUP BY user_id" {
+		t.Fatalf("expected the query text, got %q", stmt.Query)
+	}
+
+	for _, input := range []string{
+		"CREATE VIEW v SELECT * FROM users",
+		"CREATE VIEW v AS SELECT * FROM users extra tokens",
+		"REFRESH VIEW v",
+	} {
+		p := NewParser(input)
+		p.Parse()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseCommentsAndHints(t *testing.T) {
+	input := "SELECT /*+ HASH_JOIN(u o) INDEX(orders, idx_user_id) leading(u o) NO_PUSHDOWN */ u.name -- the name\n" +
+		"FROM users u /* plain comment */ JOIN orders o ON u.id = o.user_id /*+ FULL(u) */ WHERE o.amount > 10 - 1"
+	p := NewParser(input)
+	stmt, ok := p.Parse().(*SelectStatement)
+	if len(p.Errors()) > 0 || !ok {
+		t.Fatalf("unexpected errors %v", p.Errors())
+	}
+
+	want := []*Hint{
+		{Name: "HASH_JOIN", Args: []string{"u", "o"}},
+		{Name: "INDEX", Args: []string{"orders", "idx_user_id"}},
+		{Name: "LEADING", Args: []string{"u", "o"}},
+		{Name: "NO_PUSHDOWN"},
+	}
+	if !reflect.DeepEqual(stmt.Hints, want) {
+		t.Fatalf("expected hints %v, got %v", want, stmt.Hints)
+	}
+	if len(stmt.Columns) != 1 || len(stmt.Joins) != 1 || stmt.Where == nil {
+		t.Fatalf("comments changed the statement: %+v", stmt)
+	}
+	if got := stmt.Where.String(); !strings.Contains(got, "10") {
+		t.Fatalf("expected the predicate to survive, got %s", got)
+	}
+
+	// an unterminated comment would swallow the rest of the statement
+	for _, input := range []string{"SELECT id FROM users /* WHERE id = 1", "SELECT /*+ FULL(users) FROM users"} {
+		p = NewParser(input)
+		p.Parse()
+		if errs := p.Errors(); len(errs) == 0 || !strings.Contains(errs[0], "unterminated comment") {
+			t.Fatalf("%s: expected an unterminated comment error, got %v", input, errs)
+		}
+	}
+}
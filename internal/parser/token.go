@@ -7,7 +7,9 @@ const (
 	EOF
 	IDENT
 	INT
+	NUMBER // decimal or exponent literal
 	STRING
+	HINT // /*+ ... */ comment, the literal is its text
 
 	// keywords
 	SELECT
@@ -27,6 +29,43 @@ const (
 	HAVING
 	LIMIT
 	OFFSET
+	ASC
+	DESC
+	OVER
+	PARTITION
+	ROWS
+	RANGE
+	BETWEEN
+	UNBOUNDED
+	PRECEDING
+	FOLLOWING
+	CURRENT
+	ROW
+	CASE
+	WHEN
+	THEN
+	ELSE
+	END
+	CAST
+	NULL
+	TRUE
+	FALSE
+	NOT
+	IS
+	ANALYZE
+	SAMPLE
+	LIKE
+	CROSS
+	COPY
+	INSERT
+	INTO
+	VALUES
+	UPDATE
+	SET
+	DELETE
+	CREATE
+	DROP
+	ALTER
 
 	// operators
 	EQ
@@ -35,6 +74,12 @@ const (
 	GT
 	LTE
 	GTE
+	PLUS
+	MINUS
+	SLASH
+	PERCENT
+	ARROW     // ->
+	LONGARROW // ->>
 	// delimiters
 	COMMA
 	SEMICOLON
@@ -45,23 +90,60 @@ const (
 )
 
 var keywords = map[string]TokenType{
-	"SELECT": SELECT,
-	"FROM":   FROM,
-	"WHERE":  WHERE,
-	"JOIN":   JOIN,
-	"INNER":  INNER,
-	"LEFT":   LEFT,
-	"RIGHT":  RIGHT,
-	"ON":     ON,
-	"AND":    AND,
-	"OR":     OR,
-	"AS":     AS,
-	"ORDER":  ORDER,
-	"BY":     BY,
-	"GROUP":  GROUP,
-	"HAVING": HAVING,
-	"LIMIT":  LIMIT,
-	"OFFSET": OFFSET,
+	"SELECT":    SELECT,
+	"FROM":      FROM,
+	"WHERE":     WHERE,
+	"JOIN":      JOIN,
+	"INNER":     INNER,
+	"LEFT":      LEFT,
+	"RIGHT":     RIGHT,
+	"ON":        ON,
+	"AND":       AND,
+	"OR":        OR,
+	"AS":        AS,
+	"ORDER":     ORDER,
+	"BY":        BY,
+	"GROUP":     GROUP,
+	"HAVING":    HAVING,
+	"LIMIT":     LIMIT,
+	"OFFSET":    OFFSET,
+	"ASC":       ASC,
+	"DESC":      DESC,
+	"OVER":      OVER,
+	"PARTITION": PARTITION,
+	"ROWS":      ROWS,
+	"RANGE":     RANGE,
+	"BETWEEN":   BETWEEN,
+	"UNBOUNDED": UNBOUNDED,
+	"PRECEDING": PRECEDING,
+	"FOLLOWING": FOLLOWING,
+	"CURRENT":   CURRENT,
+	"ROW":       ROW,
+	"CASE":      CASE,
+	"WHEN":      WHEN,
+	"THEN":      THEN,
+	"ELSE":      ELSE,
+	"END":       END,
+	"CAST":      CAST,
+	"NULL":      NULL,
+	"TRUE":      TRUE,
+	"FALSE":     FALSE,
+	"NOT":       NOT,
+	"IS":        IS,
+	"ANALYZE":   ANALYZE,
+	"SAMPLE":    SAMPLE,
+	"LIKE":      LIKE,
+	"CROSS":     CROSS,
+	"COPY":      COPY,
+	"INSERT":    INSERT,
+	"INTO":      INTO,
+	"VALUES":    VALUES,
+	"UPDATE":    UPDATE,
+	"SET":       SET,
+	"DELETE":    DELETE,
+	"CREATE":    CREATE,
+	"DROP":      DROP,
+	"ALTER":     ALTER,
 }
 
 type Token struct {
@@ -69,6 +151,7 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+	Pos     int // byte offset in the input where the token starts
 }
 
 // checkin if identifier is keyword
@@ -83,10 +166,12 @@ func LookupIdent(ident string) TokenType {
 // string representation of toekn type
 func (t TokenType) String() string {
 	switch t {
-		case ILLEGAL:
+	case ILLEGAL:
 		return "ILLEGAL"
 	case EOF:
 		return "EOF"
+	case NUMBER:
+		return "NUMBER"
 	case IDENT:
 		return "IDENT"
 	case INT:
@@ -127,6 +212,80 @@ func (t TokenType) String() string {
 		return "LIMIT"
 	case OFFSET:
 		return "OFFSET"
+	case ASC:
+		return "ASC"
+	case DESC:
+		return "DESC"
+	case OVER:
+		return "OVER"
+	case PARTITION:
+		return "PARTITION"
+	case ROWS:
+		return "ROWS"
+	case RANGE:
+		return "RANGE"
+	case BETWEEN:
+		return "BETWEEN"
+	case UNBOUNDED:
+		return "UNBOUNDED"
+	case PRECEDING:
+		return "PRECEDING"
+	case FOLLOWING:
+		return "FOLLOWING"
+	case CURRENT:
+		return "CURRENT"
+	case ROW:
+		return "ROW"
+	case CASE:
+		return "CASE"
+	case WHEN:
+		return "WHEN"
+	case THEN:
+		return "THEN"
+	case ELSE:
+		return "ELSE"
+	case END:
+		return "END"
+	case CAST:
+		return "CAST"
+	case NULL:
+		return "NULL"
+	case TRUE:
+		return "TRUE"
+	case FALSE:
+		return "FALSE"
+	case NOT:
+		return "NOT"
+	case IS:
+		return "IS"
+	case ANALYZE:
+		return "ANALYZE"
+	case SAMPLE:
+		return "SAMPLE"
+	case LIKE:
+		return "LIKE"
+	case CROSS:
+		return "CROSS"
+	case COPY:
+		return "COPY"
+	case INSERT:
+		return "INSERT"
+	case INTO:
+		return "INTO"
+	case VALUES:
+		return "VALUES"
+	case UPDATE:
+		return "UPDATE"
+	case SET:
+		return "SET"
+	case DELETE:
+		return "DELETE"
+	case CREATE:
+		return "CREATE"
+	case DROP:
+		return "DROP"
+	case ALTER:
+		return "ALTER"
 	case EQ:
 		return "="
 	case NEQ:
@@ -139,6 +298,18 @@ func (t TokenType) String() string {
 		return "<="
 	case GTE:
 		return ">="
+	case PLUS:
+		return "+"
+	case MINUS:
+		return "-"
+	case SLASH:
+		return "/"
+	case PERCENT:
+		return "%"
+	case ARROW:
+		return "->"
+	case LONGARROW:
+		return "->>"
 	case COMMA:
 		return ","
 	case SEMICOLON:
@@ -154,4 +325,4 @@ func (t TokenType) String() string {
 	default:
 		return "UNKNOWN"
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,224 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+func (p *Planner) planCreateTable(stmt *parser.CreateTableStatement) (LogicalPlan, error) {
+	if _, err := p.catalog.GetTable(stmt.Table); err == nil && !stmt.IfNotExists {
+		return nil, fmt.Errorf("table %s already exists", stmt.Table)
+	}
+	if len(stmt.Columns) == 0 {
+		return nil, fmt.Errorf("table %s has no columns", stmt.Table)
+	}
+
+	table := &catalog.TableInfo{
+		Name:       stmt.Table,
+		PrimaryKey: stmt.PrimaryKey,
+		Unique:     stmt.Unique,
+		Options:    stmt.Options,
+		DataFile:   stmt.Location,
+	}
+	for _, def := range stmt.Columns {
+		col, err := columnOf(def)
+		if err != nil {
+			return nil, err
+		}
+		table.Columns = append(table.Columns, col)
+	}
+
+	kind := strings.ToLower(stmt.Using)
+	switch {
+	case kind == "memory":
+		return nil, fmt.Errorf("memory tables are registered from Go values")
+	case kind != "" && !isKind(kind):
+		return nil, fmt.Errorf("unknown source kind %q, expected one of %s", kind, strings.Join(storage.Kinds(), ", "))
+	case kind != "json":
+		table.Source = kind
+	}
+	if table.DataFile == "" {
+		table.DataFile = p.catalog.DataPath(table.Name, storage.DataExtension(kind))
+	}
+
+	for _, def := range stmt.ForeignKeys {
+		fk := catalog.ForeignKey{Columns: def.Columns, RefTable: def.RefTable, RefColumns: def.RefColumns}
+		if len(fk.RefColumns) == 0 {
+			// the primary key of the referenced table, which can be this one
+			ref := table
+			if def.RefTable != table.Name {
+				t, err := p.catalog.GetTable(def.RefTable)
+				if err != nil {
+					return nil, err
+				}
+				ref = t
+			}
+			if len(ref.PrimaryKey) == 0 {
+				return nil, fmt.Errorf("foreign key (%s): %s has no primary key", strings.Join(fk.Columns, ", "), ref.Name)
+			}
+			fk.RefColumns = ref.PrimaryKey
+		}
+		table.ForeignKeys = append(table.ForeignKeys, fk)
+	}
+	table.Statistics = storage.StatisticsOf(table, nil, 0)
+
+	return &LogicalCreateTable{Table: table, IfNotExists: stmt.IfNotExists}, nil
+}
+
+func columnOf(def *parser.ColumnDef) (catalog.Column, error) {
+	t, precision, scale, err := catalog.ParseTypeName(def.TypeName)
+	if err != nil {
+		return catalog.Column{}, fmt.Errorf("column %s: %w", def.Name, err)
+	}
+
+	return catalog.Column{Name: def.Name, Type: t, Precision: precision, Scale: scale, NotNull: def.NotNull}, nil
+}
+
+func isKind(kind string) bool {
+	for _, k := range storage.Kinds() {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Planner) planDropTable(stmt *parser.DropTableStatement) (LogicalPlan, error) {
+	table, err := p.catalog.GetTable(stmt.Table)
+	if err != nil && !stmt.IfExists {
+		return nil, err
+	}
+	if err == nil {
+		if err := checkKind(table, stmt.View, stmt.Materialized); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LogicalDropTable{Name: stmt.Table, IfExists: stmt.IfExists}, nil
+}
+
+func (p *Planner) planCreateIndex(stmt *parser.CreateIndexStatement) (LogicalPlan, error) {
+	table, err := p.catalog.GetTable(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range stmt.Columns {
+		if _, err := table.GetColumn(col); err != nil {
+			return nil, err
+		}
+	}
+	for _, idx := range table.Indexes {
+		if idx.Name == stmt.Name && !stmt.IfNotExists {
+			return nil, fmt.Errorf("index %s already exists on %s", stmt.Name, table.Name)
+		}
+	}
+
+	index := catalog.Index{Name: stmt.Name, Columns: stmt.Columns}
+	return &LogicalCreateIndex{Table: table, Index: index, IfNotExists: stmt.IfNotExists}, nil
+}
+
+func (p *Planner) planDropIndex(stmt *parser.DropIndexStatement) (LogicalPlan, error) {
+	if _, err := p.catalog.FindIndex(stmt.Name, stmt.Table); err != nil && !stmt.IfExists {
+		return nil, err
+	}
+
+	return &LogicalDropIndex{Name: stmt.Name, Table: stmt.Table, IfExists: stmt.IfExists}, nil
+}
+
+// tables ANALYZE reads, every one with data when the statement names none
+func (p *Planner) planAnalyze(stmt *parser.AnalyzeStatement) (LogicalPlan, error) {
+	if stmt.Table == "" {
+		var tables []*catalog.TableInfo
+		for _, table := range p.catalog.Tables() {
+			if table.HasData() {
+				tables = append(tables, table)
+			}
+		}
+		return &LogicalAnalyze{Tables: tables, Sample: stmt.Sample}, nil
+	}
+
+	table, err := p.catalog.GetTable(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	if !table.HasData() {
+		return nil, fmt.Errorf("table %s has no data file", table.Name)
+	}
+	return &LogicalAnalyze{Tables: []*catalog.TableInfo{table}, Sample: stmt.Sample}, nil
+}
+
+func (p *Planner) planAlterTable(stmt *parser.AlterTableStatement) (LogicalPlan, error) {
+	table, err := p.catalog.GetTable(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	if table.Temporary {
+		return nil, fmt.Errorf("table %s holds Go values and can't be altered", table.Name)
+	}
+	if table.View != "" {
+		return nil, fmt.Errorf("%s is a view and can't be altered, drop and create it again", table.Name)
+	}
+
+	altered := table.Clone()
+	node := &LogicalAlterTable{Table: table, Altered: altered}
+
+	switch stmt.Action {
+	case parser.AddColumn:
+		col, err := columnOf(stmt.Column)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := table.GetColumn(col.Name); err == nil {
+			return nil, fmt.Errorf("column %s already exists in table %s", col.Name, table.Name)
+		}
+		altered.Columns = append(altered.Columns, col)
+		node.Action = "add column " + col.Name + " " + col.TypeName()
+
+	case parser.DropColumn:
+		if err := altered.DropColumn(stmt.Name); err != nil {
+			return nil, err
+		}
+		node.Action = "drop column " + stmt.Name
+
+	case parser.RenameColumn:
+		if err := altered.RenameColumn(stmt.Name, stmt.NewName); err != nil {
+			return nil, err
+		}
+		node.Renamed = map[string]string{stmt.Name: stmt.NewName}
+		node.Action = "rename column " + stmt.Name + " to " + stmt.NewName
+
+	case parser.RenameTable:
+		if _, err := p.catalog.GetTable(stmt.NewName); err == nil {
+			return nil, fmt.Errorf("table %s already exists", stmt.NewName)
+		}
+		altered.Name = stmt.NewName
+		for i, fk := range altered.ForeignKeys {
+			if fk.RefTable == table.Name {
+				altered.ForeignKeys[i].RefTable = stmt.NewName
+			}
+		}
+		node.Action = "rename to " + stmt.NewName
+
+	case parser.AlterColumnType:
+		col, err := altered.GetColumn(stmt.Name)
+		if err != nil {
+			return nil, err
+		}
+		if col.Path != "" {
+			return nil, fmt.Errorf("column %s has a path, its type can't change", col.Name)
+		}
+		changed, err := columnOf(stmt.Column)
+		if err != nil {
+			return nil, err
+		}
+		col.Type, col.Precision, col.Scale = changed.Type, changed.Precision, changed.Scale
+		node.Action = "alter column " + col.Name + " type " + col.TypeName()
+	}
+
+	return node, nil
+}
@@ -0,0 +1,173 @@
+package plan
+
+// rewrites an expression tree top down. fn returns the replacement and
+// whether it is final, otherwise the children of the original are visited
+func TransformExpr(expr Expr, fn func(Expr) (Expr, bool)) Expr {
+	if expr == nil {
+		return nil
+	}
+	if out, done := fn(expr); done {
+		return out
+	}
+
+	return mapChildren(expr, func(child Expr) Expr {
+		return TransformExpr(child, fn)
+	})
+}
+
+// rewrites an expression tree bottom up, fn sees nodes whose children
+// were already rewritten
+func TransformExprUp(expr Expr, fn func(Expr) Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+
+	return fn(mapChildren(expr, func(child Expr) Expr {
+		return TransformExprUp(child, fn)
+	}))
+}
+
+// copy of expr with f applied to each direct child
+func mapChildren(expr Expr, f func(Expr) Expr) Expr {
+	mapExpr := func(e Expr) Expr {
+		if e == nil {
+			return nil
+		}
+		return f(e)
+	}
+	mapExprs := func(exprs []Expr) []Expr {
+		if exprs == nil {
+			return nil
+		}
+		out := make([]Expr, len(exprs))
+		for i, e := range exprs {
+			out[i] = f(e)
+		}
+		return out
+	}
+
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return &BinaryExpr{Left: f(e.Left), Operator: e.Operator, Right: f(e.Right)}
+
+	case *NotExpr:
+		return &NotExpr{Expr: f(e.Expr)}
+
+	case *IsNullExpr:
+		return &IsNullExpr{Expr: f(e.Expr), Not: e.Not}
+
+	case *FuncCallExpr:
+		return &FuncCallExpr{Name: e.Name, Args: mapExprs(e.Args), Func: e.Func, Type: e.Type}
+
+	case *AggregateExpr:
+		return &AggregateExpr{Name: e.Name, Args: mapExprs(e.Args), Func: e.Func, Type: e.Type}
+
+	case *CastExpr:
+		return &CastExpr{Expr: f(e.Expr), Type: e.Type, Precision: e.Precision, Scale: e.Scale}
+
+	case *CaseExpr:
+		c := &CaseExpr{Operand: mapExpr(e.Operand), Else: mapExpr(e.Else), Type: e.Type}
+		for _, w := range e.Whens {
+			c.Whens = append(c.Whens, WhenClause{Condition: f(w.Condition), Result: f(w.Result)})
+		}
+		return c
+
+	case *WindowFuncExpr:
+		w := &WindowFuncExpr{
+			Name:        e.Name,
+			Args:        mapExprs(e.Args),
+			PartitionBy: mapExprs(e.PartitionBy),
+			Frame:       e.Frame,
+			Type:        e.Type,
+		}
+		for _, key := range e.OrderBy {
+			w.OrderBy = append(w.OrderBy, SortKey{Expr: f(key.Expr), Desc: key.Desc})
+		}
+		return w
+
+	default:
+		return expr
+	}
+}
+
+// visits an expression tree top down, fn returns false to skip the children
+func WalkExpr(expr Expr, fn func(Expr) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		WalkExpr(e.Left, fn)
+		WalkExpr(e.Right, fn)
+	case *NotExpr:
+		WalkExpr(e.Expr, fn)
+	case *IsNullExpr:
+		WalkExpr(e.Expr, fn)
+	case *FuncCallExpr:
+		walkExprs(e.Args, fn)
+	case *AggregateExpr:
+		walkExprs(e.Args, fn)
+	case *CastExpr:
+		WalkExpr(e.Expr, fn)
+	case *CaseExpr:
+		WalkExpr(e.Operand, fn)
+		for _, w := range e.Whens {
+			WalkExpr(w.Condition, fn)
+			WalkExpr(w.Result, fn)
+		}
+		WalkExpr(e.Else, fn)
+	case *WindowFuncExpr:
+		walkExprs(e.Args, fn)
+		walkExprs(e.PartitionBy, fn)
+		for _, key := range e.OrderBy {
+			WalkExpr(key.Expr, fn)
+		}
+	}
+}
+
+func walkExprs(exprs []Expr, fn func(Expr) bool) {
+	for _, e := range exprs {
+		WalkExpr(e, fn)
+	}
+}
+
+// reports whether any node of the tree satisfies pred
+func ContainsExpr(expr Expr, pred func(Expr) bool) bool {
+	found := false
+	WalkExpr(expr, func(e Expr) bool {
+		if found || pred(e) {
+			found = true
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
+// splits a predicate on AND
+func SplitConjuncts(expr Expr) []Expr {
+	if b, ok := expr.(*BinaryExpr); ok && b.Operator == "AND" {
+		return append(SplitConjuncts(b.Left), SplitConjuncts(b.Right)...)
+	}
+	if expr == nil {
+		return nil
+	}
+
+	return []Expr{expr}
+}
+
+// ANDs predicates together, nil when there are none
+func CombineConjuncts(exprs []Expr) Expr {
+	var result Expr
+	for _, e := range exprs {
+		if result == nil {
+			result = e
+			continue
+		}
+		result = &BinaryExpr{Left: result, Operator: "AND", Right: e}
+	}
+
+	return result
+}
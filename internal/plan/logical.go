@@ -2,8 +2,13 @@ package plan
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
 )
 
 type LogicalPlan interface {
@@ -17,6 +22,17 @@ type LogicalScan struct {
 	TableName string
 	Table     *catalog.TableInfo
 	Alias     string
+
+	// set by the optimizer and handed to the table's source. Columns nil
+	// reads every column, Pushed conjuncts are still checked by the filter
+	// above and Index names an index whose columns Pushed pins with =
+	Columns []string
+	Pushed  []storage.Predicate
+	Index   string
+
+	// a materialized view the optimizer reads in place of the query part
+	// it holds
+	FromView bool
 }
 
 func (l *LogicalScan) Children() []LogicalPlan {
@@ -26,12 +42,65 @@ func (l *LogicalScan) Schema() []catalog.Column {
 	return l.Table.Columns
 }
 
+// name columns of this scan are qualified with, the alias when there is one
+func (l *LogicalScan) QualifiedName() string {
+	if l.Alias != "" {
+		return l.Alias
+	}
+
+	return l.TableName
+}
+
 func (l *LogicalScan) String() string {
+	s := "Scan(" + l.TableName
+	if l.Index != "" {
+		s = "IndexScan(" + l.TableName + " USING " + l.Index
+	}
+	if l.FromView {
+		s = "MaterializedView" + s
+	}
 	if l.Alias != "" {
-		return fmt.Sprintf("Scan(%s AS %s)", l.TableName, l.Alias)
+		s += " AS " + l.Alias
+	}
+	if l.Columns != nil {
+		s += ", columns: [" + strings.Join(l.Columns, ", ") + "]"
 	}
+	if len(l.Pushed) > 0 {
+		preds := make([]string, len(l.Pushed))
+		for i, p := range l.Pushed {
+			preds[i] = p.String()
+		}
+		s += ", pushed: [" + strings.Join(preds, ", ") + "]"
+	}
+
+	return s + ")"
+}
 
-	return fmt.Sprintf("Scan(%s)", l.TableName)
+// view read in FROM, Input is its query. Rows take the view's column names,
+// qualified with the alias or the view's name
+type LogicalView struct {
+	View  *catalog.TableInfo
+	Alias string
+	Input LogicalPlan
+}
+
+func (l *LogicalView) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalView) Schema() []catalog.Column {
+	return l.View.Columns
+}
+func (l *LogicalView) QualifiedName() string {
+	if l.Alias != "" {
+		return l.Alias
+	}
+	return l.View.Name
+}
+func (l *LogicalView) String() string {
+	if l.Alias != "" {
+		return fmt.Sprintf("View(%s AS %s)", l.View.Name, l.Alias)
+	}
+	return fmt.Sprintf("View(%s)", l.View.Name)
 }
 
 type LogicalFilter struct {
@@ -54,11 +123,12 @@ type Expr interface {
 	String() string
 }
 
-// SELECT column list
+// SELECT column list, with the hints the SELECT gave the optimizer
 type LogicalProject struct {
 	Input       LogicalPlan
 	Projections []Expr
 	ColumnNames []string
+	Hints       []*parser.Hint
 }
 
 func (l *LogicalProject) Children() []LogicalPlan {
@@ -78,12 +148,462 @@ func (l *LogicalProject) String() string {
 	return fmt.Sprintf("Project(%v)", l.ColumnNames)
 }
 
+// GROUP BY with aggregate functions, output rows hold the group keys and
+// aggregate results under GroupNames and AggregateNames
+type LogicalAggregate struct {
+	Input          LogicalPlan
+	GroupBy        []Expr
+	GroupNames     []string
+	Aggregates     []*AggregateExpr
+	AggregateNames []string
+
+	// set by the optimizer when the input arrives sorted by GroupBy, each
+	// group is done once the next one starts
+	Sorted bool
+}
+
+func (l *LogicalAggregate) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalAggregate) Schema() []catalog.Column {
+	input := l.Input.Schema()
+	schema := make([]catalog.Column, 0, len(l.GroupBy)+len(l.Aggregates))
+
+	for i, g := range l.GroupBy {
+		schema = append(schema, catalog.Column{Name: l.GroupNames[i], Type: ExprType(g, input)})
+	}
+	for i, a := range l.Aggregates {
+		schema = append(schema, catalog.Column{Name: l.AggregateNames[i], Type: a.Type})
+	}
+
+	return schema
+}
+func (l *LogicalAggregate) String() string {
+	groups := make([]string, len(l.GroupBy))
+	for i, g := range l.GroupBy {
+		groups[i] = g.String()
+	}
+
+	name := "Aggregate"
+	if l.Sorted {
+		name = "StreamAggregate"
+	}
+	return fmt.Sprintf("%s(group=[%s], aggs=[%s])", name, strings.Join(groups, ", "), strings.Join(l.AggregateNames, ", "))
+}
+
+// rows of the input ordered by Keys, ascending with NULLs last. The
+// optimizer adds sorts where an operator needs its input in order
+type LogicalSort struct {
+	Input LogicalPlan
+	Keys  []Expr
+}
+
+func (l *LogicalSort) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalSort) Schema() []catalog.Column {
+	return l.Input.Schema()
+}
+func (l *LogicalSort) String() string {
+	keys := make([]string, len(l.Keys))
+	for i, k := range l.Keys {
+		keys[i] = k.String()
+	}
+
+	return fmt.Sprintf("Sort(%s)", strings.Join(keys, ", "))
+}
+
+// produces no rows, replaces subtrees the optimizer proved empty
+type LogicalEmpty struct {
+	Columns []catalog.Column
+	Reason  string
+}
+
+func (l *LogicalEmpty) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalEmpty) Schema() []catalog.Column {
+	return l.Columns
+}
+func (l *LogicalEmpty) String() string {
+	return fmt.Sprintf("Empty(%s)", l.Reason)
+}
+
+// window functions evaluated over the input, each adds one output column
+type LogicalWindow struct {
+	Input       LogicalPlan
+	Functions   []*WindowFuncExpr
+	ColumnNames []string
+}
+
+func (l *LogicalWindow) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalWindow) Schema() []catalog.Column {
+	schema := append([]catalog.Column{}, l.Input.Schema()...)
+
+	for i, fn := range l.Functions {
+		schema = append(schema, catalog.Column{Name: l.ColumnNames[i], Type: fn.Type})
+	}
+
+	return schema
+}
+func (l *LogicalWindow) String() string {
+	funcs := make([]string, len(l.Functions))
+	for i, fn := range l.Functions {
+		funcs[i] = fmt.Sprintf("%s := %s", l.ColumnNames[i], fn.String())
+	}
+
+	return fmt.Sprintf("Window(%s)", strings.Join(funcs, ", "))
+}
+
+// lateral UNNEST, every input row is repeated once per element of the
+// array Expr evaluates to, with the element in column Alias. Condition
+// (nil for none) filters the elements, Outer keeps rows without any match
+// padded with NULL like a LEFT join
+type LogicalUnnest struct {
+	Input     LogicalPlan
+	Expr      Expr
+	Alias     string
+	Condition Expr
+	Outer     bool
+}
+
+func (l *LogicalUnnest) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalUnnest) Schema() []catalog.Column {
+	return append(append([]catalog.Column{}, l.Input.Schema()...), catalog.Column{Name: l.Alias, Type: catalog.JSONType})
+}
+func (l *LogicalUnnest) String() string {
+	s := fmt.Sprintf("Unnest(%s AS %s", l.Expr, l.Alias)
+	if l.Outer {
+		s = "Left" + s
+	}
+	if l.Condition != nil {
+		s += ", " + l.Condition.String()
+	}
+
+	return s + ")"
+}
+
+// rows of expressions evaluated once each, the input of INSERT ... VALUES
+type LogicalValues struct {
+	Columns []catalog.Column
+	Rows    [][]Expr
+}
+
+func (l *LogicalValues) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalValues) Schema() []catalog.Column {
+	return l.Columns
+}
+func (l *LogicalValues) String() string {
+	return fmt.Sprintf("Values(%d rows)", len(l.Rows))
+}
+
+// column of the one row INSERT, UPDATE and DELETE return, the number of
+// rows they changed
+const AffectedRows = "affected_rows"
+
+var affectedSchema = []catalog.Column{{Name: AffectedRows, Type: catalog.IntType}}
+
+// adds the rows of Input to Table, its i-th column goes to Columns[i]
+type LogicalInsert struct {
+	Table   *catalog.TableInfo
+	Columns []string
+	Input   LogicalPlan
+}
+
+func (l *LogicalInsert) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalInsert) Schema() []catalog.Column {
+	return affectedSchema
+}
+func (l *LogicalInsert) String() string {
+	return fmt.Sprintf("Insert(%s, columns: [%s])", l.Table.Name, strings.Join(l.Columns, ", "))
+}
+
+// sets Columns[i] to Values[i] in rows of Table matching Predicate, every
+// row when it is nil. Expressions see the row under Alias or the table name
+type LogicalUpdate struct {
+	Table     *catalog.TableInfo
+	Alias     string
+	Columns   []string
+	Values    []Expr
+	Predicate Expr
+}
+
+func (l *LogicalUpdate) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalUpdate) Schema() []catalog.Column {
+	return affectedSchema
+}
+func (l *LogicalUpdate) String() string {
+	sets := make([]string, len(l.Columns))
+	for i, col := range l.Columns {
+		sets[i] = col + " = " + l.Values[i].String()
+	}
+
+	s := fmt.Sprintf("Update(%s, set: [%s]", l.Table.Name, strings.Join(sets, ", "))
+	if l.Predicate != nil {
+		s += ", where: " + l.Predicate.String()
+	}
+	return s + ")"
+}
+
+// removes rows of Table matching Predicate, every row when it is nil
+type LogicalDelete struct {
+	Table     *catalog.TableInfo
+	Alias     string
+	Predicate Expr
+}
+
+func (l *LogicalDelete) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalDelete) Schema() []catalog.Column {
+	return affectedSchema
+}
+func (l *LogicalDelete) String() string {
+	if l.Predicate != nil {
+		return fmt.Sprintf("Delete(%s, where: %s)", l.Table.Name, l.Predicate)
+	}
+	return fmt.Sprintf("Delete(%s)", l.Table.Name)
+}
+
+// REFRESH MATERIALIZED VIEW, replaces the rows of Table with those of
+// Input, the view's query
+type LogicalRefresh struct {
+	Table *catalog.TableInfo
+	Input LogicalPlan
+}
+
+func (l *LogicalRefresh) Children() []LogicalPlan {
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalRefresh) Schema() []catalog.Column {
+	return affectedSchema
+}
+func (l *LogicalRefresh) String() string {
+	return fmt.Sprintf("Refresh(%s)", l.Table.Name)
+}
+
+// reports whether a plan changes data instead of reading it
+func IsModification(node LogicalPlan) bool {
+	switch node.(type) {
+	case *LogicalInsert, *LogicalUpdate, *LogicalDelete, *LogicalRefresh:
+		return true
+	}
+
+	return false
+}
+
+// CREATE TABLE, Table is the new definition with its data file set
+type LogicalCreateTable struct {
+	Table       *catalog.TableInfo
+	IfNotExists bool
+}
+
+func (l *LogicalCreateTable) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalCreateTable) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalCreateTable) String() string {
+	cols := make([]string, len(l.Table.Columns))
+	for i, col := range l.Table.Columns {
+		cols[i] = col.Name + " " + col.TypeName()
+	}
+	return fmt.Sprintf("CreateTable(%s, columns: [%s], source: %s)", l.Table.Name, strings.Join(cols, ", "), l.Table.SourceKind())
+}
+
+// DROP TABLE, removes the table and its data
+type LogicalDropTable struct {
+	Name     string
+	IfExists bool
+}
+
+func (l *LogicalDropTable) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalDropTable) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalDropTable) String() string {
+	return fmt.Sprintf("DropTable(%s)", l.Name)
+}
+
+// CREATE INDEX on Table
+type LogicalCreateIndex struct {
+	Table       *catalog.TableInfo
+	Index       catalog.Index
+	IfNotExists bool
+}
+
+func (l *LogicalCreateIndex) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalCreateIndex) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalCreateIndex) String() string {
+	return fmt.Sprintf("CreateIndex(%s ON %s (%s))", l.Index.Name, l.Table.Name, strings.Join(l.Index.Columns, ", "))
+}
+
+// DROP INDEX, Table is empty when the name alone finds the index
+type LogicalDropIndex struct {
+	Name     string
+	Table    string
+	IfExists bool
+}
+
+func (l *LogicalDropIndex) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalDropIndex) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalDropIndex) String() string {
+	if l.Table != "" {
+		return fmt.Sprintf("DropIndex(%s ON %s)", l.Name, l.Table)
+	}
+	return fmt.Sprintf("DropIndex(%s)", l.Name)
+}
+
+// ANALYZE, recomputes the statistics of Tables from their rows. Sample
+// limits the rows distinct counts and min/max are taken from, zero reads
+// every row
+type LogicalAnalyze struct {
+	Tables []*catalog.TableInfo
+	Sample int
+}
+
+func (l *LogicalAnalyze) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalAnalyze) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalAnalyze) String() string {
+	names := make([]string, len(l.Tables))
+	for i, table := range l.Tables {
+		names[i] = table.Name
+	}
+	if l.Sample > 0 {
+		return fmt.Sprintf("Analyze(%s, sample: %d)", strings.Join(names, ", "), l.Sample)
+	}
+	return fmt.Sprintf("Analyze(%s)", strings.Join(names, ", "))
+}
+
+// ALTER TABLE, Altered is the definition Table changes to. Renamed maps
+// old column names to new ones, the rows are rewritten when the columns
+// change
+type LogicalAlterTable struct {
+	Table   *catalog.TableInfo
+	Altered *catalog.TableInfo
+	Renamed map[string]string
+	Action  string
+}
+
+func (l *LogicalAlterTable) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalAlterTable) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalAlterTable) String() string {
+	return fmt.Sprintf("AlterTable(%s, %s)", l.Table.Name, l.Action)
+}
+
+// CREATE [MATERIALIZED] VIEW, View is the new definition. Input is the
+// query filling a materialized view and nil for other views
+type LogicalCreateView struct {
+	View        *catalog.TableInfo
+	Input       LogicalPlan
+	IfNotExists bool
+}
+
+func (l *LogicalCreateView) Children() []LogicalPlan {
+	if l.Input == nil {
+		return nil
+	}
+	return []LogicalPlan{l.Input}
+}
+func (l *LogicalCreateView) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalCreateView) String() string {
+	if l.View.Materialized {
+		return fmt.Sprintf("CreateMaterializedView(%s, columns: %v)", l.View.Name, l.View.GetColumnNames())
+	}
+	return fmt.Sprintf("CreateView(%s, columns: %v)", l.View.Name, l.View.GetColumnNames())
+}
+
+// reports whether a plan changes table definitions or statistics, it
+// returns no rows
+func IsDefinition(node LogicalPlan) bool {
+	switch node.(type) {
+	case *LogicalCreateTable, *LogicalDropTable, *LogicalCreateIndex, *LogicalDropIndex, *LogicalAlterTable, *LogicalCreateView, *LogicalAnalyze:
+		return true
+	}
+
+	return false
+}
+
+// BEGIN, COMMIT or ROLLBACK of the executor's transaction, Action is the
+// statement's keyword
+type LogicalTransaction struct {
+	Action string
+}
+
+func (l *LogicalTransaction) Children() []LogicalPlan {
+	return nil
+}
+func (l *LogicalTransaction) Schema() []catalog.Column {
+	return nil
+}
+func (l *LogicalTransaction) String() string {
+	return fmt.Sprintf("Transaction(%s)", l.Action)
+}
+
 // join operation
 type LogicalJoin struct {
 	Left      LogicalPlan
 	Right     LogicalPlan
 	JoinType  JoinType
 	Condition Expr
+
+	// set by the optimizer. Hash and merge joins match LeftKeys against
+	// RightKeys, pairs of equal conjuncts of Condition, which is still
+	// checked for every match
+	Method    JoinMethod
+	LeftKeys  []Expr
+	RightKeys []Expr
+}
+
+// how a join finds matching rows
+type JoinMethod int
+
+const (
+	NestedLoopJoin JoinMethod = iota // every left row against every right row
+	HashJoin                         // right rows hashed by their keys, left rows look theirs up
+	MergeJoin                        // both sides sorted by their keys, inner joins only
+)
+
+func (m JoinMethod) String() string {
+	switch m {
+	case HashJoin:
+		return "HashJoin"
+	case MergeJoin:
+		return "MergeJoin"
+	default:
+		return "Join"
+	}
 }
 
 type JoinType int
@@ -121,7 +641,7 @@ func (l *LogicalJoin) Schema() []catalog.Column {
 	return schema
 }
 func (l *LogicalJoin) String() string {
-	return fmt.Sprintf("Join(%s, %s)", l.JoinType, l.Condition.String())
+	return fmt.Sprintf("%s(%s, %s)", l.Method, l.JoinType, l.Condition.String())
 }
 
 type ColumnExpr struct { //column reference
@@ -143,6 +663,23 @@ type LiteralExpr struct { //literal value
 }
 
 func (l *LiteralExpr) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + v + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case types.Date:
+		return "DATE '" + v.String() + "'"
+	case types.Timestamp:
+		return "TIMESTAMP '" + v.String() + "'"
+	case types.Interval:
+		return "INTERVAL '" + v.String() + "'"
+	}
 	return fmt.Sprintf("%v", l.Value)
 }
 
@@ -156,6 +693,101 @@ func (b *BinaryExpr) String() string {
 	return fmt.Sprintf("(%s %s %s)", b.Left.String(), b.Operator, b.Right.String())
 }
 
+type NotExpr struct {
+	Expr Expr
+}
+
+func (n *NotExpr) String() string {
+	return fmt.Sprintf("(NOT %s)", n.Expr)
+}
+
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+func (i *IsNullExpr) String() string {
+	if i.Not {
+		return fmt.Sprintf("(%s IS NOT NULL)", i.Expr)
+	}
+	return fmt.Sprintf("(%s IS NULL)", i.Expr)
+}
+
+// scalar function call resolved against the function registry
+type FuncCallExpr struct {
+	Name string
+	Args []Expr
+	Func *function.ScalarFunc
+	Type catalog.DataType
+}
+
+func (f *FuncCallExpr) String() string {
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = arg.String()
+	}
+
+	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ", "))
+}
+
+// aggregate function call, COUNT(*) has a single StarExpr argument
+type AggregateExpr struct {
+	Name string
+	Args []Expr
+	Func *function.AggregateFunc
+	Type catalog.DataType
+}
+
+func (a *AggregateExpr) String() string {
+	args := make([]string, len(a.Args))
+	for i, arg := range a.Args {
+		args[i] = arg.String()
+	}
+
+	return fmt.Sprintf("%s(%s)", a.Name, strings.Join(args, ", "))
+}
+
+// CASE expression, Operand is nil for searched CASE
+type CaseExpr struct {
+	Operand Expr
+	Whens   []WhenClause
+	Else    Expr
+	Type    catalog.DataType
+}
+
+type WhenClause struct {
+	Condition Expr
+	Result    Expr
+}
+
+func (c *CaseExpr) String() string {
+	s := "CASE"
+	if c.Operand != nil {
+		s += " " + c.Operand.String()
+	}
+	for _, w := range c.Whens {
+		s += fmt.Sprintf(" WHEN %s THEN %s", w.Condition, w.Result)
+	}
+	if c.Else != nil {
+		s += " ELSE " + c.Else.String()
+	}
+
+	return s + " END"
+}
+
+type CastExpr struct {
+	Expr Expr
+	Type catalog.DataType
+
+	// DECIMAL(p,s) modifiers, zero otherwise
+	Precision int
+	Scale     int
+}
+
+func (c *CastExpr) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", c.Expr, catalog.TypeName(c.Type, c.Precision, c.Scale))
+}
+
 // SELECT *
 type StarExpr struct {
 	Table string
@@ -167,3 +799,159 @@ func (s *StarExpr) String() string {
 	}
 	return "*"
 }
+
+// ORDER BY key
+type SortKey struct {
+	Expr Expr
+	Desc bool
+}
+
+func (s SortKey) String() string {
+	if s.Desc {
+		return s.Expr.String() + " DESC"
+	}
+	return s.Expr.String()
+}
+
+type FrameUnit int
+
+const (
+	RowsFrame FrameUnit = iota
+	RangeFrame
+)
+
+type FrameBoundType int
+
+const (
+	UnboundedPreceding FrameBoundType = iota
+	Preceding
+	CurrentRow
+	Following
+	UnboundedFollowing
+)
+
+type FrameBound struct {
+	Type   FrameBoundType
+	Offset int
+}
+
+func (b FrameBound) String() string {
+	switch b.Type {
+	case UnboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case Preceding:
+		return fmt.Sprintf("%d PRECEDING", b.Offset)
+	case CurrentRow:
+		return "CURRENT ROW"
+	case Following:
+		return fmt.Sprintf("%d FOLLOWING", b.Offset)
+	case UnboundedFollowing:
+		return "UNBOUNDED FOLLOWING"
+	}
+	return ""
+}
+
+type WindowFrame struct {
+	Unit  FrameUnit
+	Start FrameBound
+	End   FrameBound
+}
+
+func (f *WindowFrame) String() string {
+	unit := "ROWS"
+	if f.Unit == RangeFrame {
+		unit = "RANGE"
+	}
+
+	return fmt.Sprintf("%s BETWEEN %s AND %s", unit, f.Start, f.End)
+}
+
+// window function call, Frame is always set by the planner
+type WindowFuncExpr struct {
+	Name        string
+	Args        []Expr
+	PartitionBy []Expr
+	OrderBy     []SortKey
+	Frame       *WindowFrame
+	Type        catalog.DataType
+}
+
+func (w *WindowFuncExpr) String() string {
+	args := make([]string, len(w.Args))
+	for i, arg := range w.Args {
+		args[i] = arg.String()
+	}
+
+	var spec []string
+	if len(w.PartitionBy) > 0 {
+		keys := make([]string, len(w.PartitionBy))
+		for i, e := range w.PartitionBy {
+			keys[i] = e.String()
+		}
+		spec = append(spec, "PARTITION BY "+strings.Join(keys, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		keys := make([]string, len(w.OrderBy))
+		for i, k := range w.OrderBy {
+			keys[i] = k.String()
+		}
+		spec = append(spec, "ORDER BY "+strings.Join(keys, ", "))
+	}
+	if w.Frame != nil {
+		spec = append(spec, w.Frame.String())
+	}
+
+	return fmt.Sprintf("%s(%s) OVER (%s)", w.Name, strings.Join(args, ", "), strings.Join(spec, " "))
+}
+
+// copy of node with its inputs replaced, children are in Children() order
+func WithChildren(node LogicalPlan, children []LogicalPlan) LogicalPlan {
+	switch n := node.(type) {
+	case *LogicalFilter:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalProject:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalAggregate:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalWindow:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalSort:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalJoin:
+		c := *n
+		c.Left, c.Right = children[0], children[1]
+		return &c
+	case *LogicalUnnest:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalInsert:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalView:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalRefresh:
+		c := *n
+		c.Input = children[0]
+		return &c
+	case *LogicalCreateView:
+		c := *n
+		c.Input = children[0]
+		return &c
+	default:
+		return node
+	}
+}
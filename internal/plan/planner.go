@@ -2,58 +2,233 @@ package plan
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
 	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
 )
 
 type Planner struct { // AST to logical plans
-	catalog *catalog.Catalog
+	catalog   *catalog.Catalog
+	functions *function.Registry
+	expanding map[string]bool // views whose query is being planned
 }
 
 func NewPlanner(cat *catalog.Catalog) *Planner {
-	return &Planner{catalog: cat}
+	return &Planner{
+		catalog:   cat,
+		functions: function.RegistryOf(cat),
+	}
 }
 
 func (p *Planner) CreateLogicalPlan(stmt parser.Statement) (LogicalPlan, error) {
-	selectStmt, ok := stmt.(*parser.SelectStatement)
-	if !ok {
-		return nil, fmt.Errorf("only SELECT statements supported")
+	switch s := stmt.(type) {
+	case *parser.SelectStatement:
+		return p.planSelect(s)
+	case *parser.InsertStatement:
+		return p.planInsert(s)
+	case *parser.UpdateStatement:
+		return p.planUpdate(s)
+	case *parser.DeleteStatement:
+		return p.planDelete(s)
+	case *parser.CreateTableStatement:
+		return p.planCreateTable(s)
+	case *parser.DropTableStatement:
+		return p.planDropTable(s)
+	case *parser.CreateIndexStatement:
+		return p.planCreateIndex(s)
+	case *parser.DropIndexStatement:
+		return p.planDropIndex(s)
+	case *parser.AlterTableStatement:
+		return p.planAlterTable(s)
+	case *parser.CreateViewStatement:
+		return p.planCreateView(s)
+	case *parser.RefreshStatement:
+		return p.planRefresh(s)
+	case *parser.AnalyzeStatement:
+		return p.planAnalyze(s)
+	case *parser.TransactionStatement:
+		return &LogicalTransaction{Action: s.String()}, nil
 	}
 
-	return p.planSelect(selectStmt)
+	return nil, fmt.Errorf("unsupported statement %s", stmt)
 }
 
-func (p *Planner) planSelect(stmt *parser.SelectStatement) (LogicalPlan, error) {
-	table, err := p.catalog.GetTable(stmt.From.Name) //table scan
+func (p *Planner) planInsert(stmt *parser.InsertStatement) (LogicalPlan, error) {
+	table, err := p.writableTable(stmt.Table)
 	if err != nil {
 		return nil, err
 	}
 
-	var plan LogicalPlan = &LogicalScan{
-		TableName: stmt.From.Name,
-		Table:     table,
-		Alias:     stmt.From.Alias,
+	columns := stmt.Columns
+	if len(columns) == 0 {
+		columns = table.GetColumnNames()
+	}
+	seen := make(map[string]bool)
+	for _, col := range columns {
+		if _, err := table.GetColumn(col); err != nil {
+			return nil, err
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("column %s is set more than once", col)
+		}
+		seen[col] = true
 	}
 
-	// joins
-	for _, join := range stmt.Joins {
-		rightTable, err := p.catalog.GetTable(join.Table.Name)
+	var input LogicalPlan
+	if stmt.Select != nil {
+		if input, err = p.planSelect(stmt.Select); err != nil {
+			return nil, err
+		}
+		if n := len(input.Schema()); n != len(columns) {
+			return nil, fmt.Errorf("INSERT has %d target columns but SELECT returns %d", len(columns), n)
+		}
+	} else {
+		values := &LogicalValues{}
+		for i := range columns {
+			values.Columns = append(values.Columns, catalog.Column{Name: fmt.Sprintf("column%d", i+1), Type: catalog.NullType})
+		}
+		for _, row := range stmt.Values {
+			if len(row) != len(columns) {
+				return nil, fmt.Errorf("INSERT has %d target columns but %d values", len(columns), len(row))
+			}
+
+			exprs := make([]Expr, len(row))
+			for i, value := range row {
+				if exprs[i], err = p.convertExpr(value, nil); err != nil {
+					return nil, err
+				}
+				if ContainsExpr(exprs[i], isColumnOrAggregate) {
+					return nil, fmt.Errorf("VALUES can't refer to columns or aggregates")
+				}
+			}
+			values.Rows = append(values.Rows, exprs)
+		}
+		input = values
+	}
+
+	return &LogicalInsert{Table: table, Columns: columns, Input: input}, nil
+}
+
+func isColumnOrAggregate(e Expr) bool {
+	switch e.(type) {
+	case *ColumnExpr, *AggregateExpr, *WindowFuncExpr:
+		return true
+	}
+
+	return false
+}
+
+func (p *Planner) planUpdate(stmt *parser.UpdateStatement) (LogicalPlan, error) {
+	table, err := p.writableTable(stmt.Table.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &LogicalUpdate{Table: table, Alias: stmt.Table.Alias}
+	for _, a := range stmt.Assignments {
+		if _, err := table.GetColumn(a.Column); err != nil {
+			return nil, err
+		}
+		for _, col := range update.Columns {
+			if col == a.Column {
+				return nil, fmt.Errorf("column %s is set more than once", a.Column)
+			}
+		}
+
+		value, err := p.convertExpr(a.Value, table.Columns)
 		if err != nil {
 			return nil, err
 		}
+		if containsAggregate(value) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in UPDATE")
+		}
+		if containsWindowFunc(value) {
+			return nil, fmt.Errorf("window functions are not allowed in UPDATE")
+		}
+		update.Columns = append(update.Columns, a.Column)
+		update.Values = append(update.Values, value)
+	}
 
-		rightScan := &LogicalScan{
-			TableName: join.Table.Name,
-			Table:     rightTable,
-			Alias:     join.Table.Alias,
+	if update.Predicate, err = p.convertWhere(stmt.Where, table); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+func (p *Planner) planDelete(stmt *parser.DeleteStatement) (LogicalPlan, error) {
+	table, err := p.writableTable(stmt.Table.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	predicate, err := p.convertWhere(stmt.Where, table)
+	if err != nil {
+		return nil, err
+	}
+	return &LogicalDelete{Table: table, Alias: stmt.Table.Alias, Predicate: predicate}, nil
+}
+
+// WHERE of an UPDATE or DELETE, nil when there is none
+func (p *Planner) convertWhere(where parser.Expression, table *catalog.TableInfo) (Expr, error) {
+	if where == nil {
+		return nil, nil
+	}
+
+	predicate, err := p.convertExpr(where, table.Columns)
+	if err != nil {
+		return nil, err
+	}
+	if containsAggregate(predicate) {
+		return nil, fmt.Errorf("aggregate functions are not allowed in WHERE")
+	}
+	if containsWindowFunc(predicate) {
+		return nil, fmt.Errorf("window functions are not allowed in WHERE")
+	}
+	return predicate, nil
+}
+
+func (p *Planner) planSelect(stmt *parser.SelectStatement) (LogicalPlan, error) {
+	if stmt.From.Unnest != nil {
+		return nil, fmt.Errorf("UNNEST must follow the table it expands")
+	}
+	plan, err := p.planTableRef(stmt.From) //table scan
+	if err != nil {
+		return nil, err
+	}
+
+	// joins
+	for _, join := range stmt.Joins {
+		if join.Table.Unnest != nil {
+			plan, err = p.planUnnest(plan, join)
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		condition, err := p.convertExpr(join.Condition)
+		rightScan, err := p.planTableRef(join.Table)
 		if err != nil {
 			return nil, err
 		}
 
+		var condition Expr = &LiteralExpr{Value: true, Type: catalog.BoolType}
+		if join.Condition != nil {
+			joinSchema := append(append([]catalog.Column{}, plan.Schema()...), rightScan.Schema()...)
+			condition, err = p.convertExpr(join.Condition, joinSchema)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if containsAggregate(condition) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in JOIN conditions")
+		}
+		if containsWindowFunc(condition) {
+			return nil, fmt.Errorf("window functions are not allowed in JOIN conditions")
+		}
+
 		plan = &LogicalJoin{
 			Left:      plan,
 			Right:     rightScan,
@@ -64,10 +239,16 @@ func (p *Planner) planSelect(stmt *parser.SelectStatement) (LogicalPlan, error)
 
 	// add WEHERE filter
 	if stmt.Where != nil {
-		predicate, err := p.convertExpr(stmt.Where)
+		predicate, err := p.convertExpr(stmt.Where, plan.Schema())
 		if err != nil {
 			return nil, err
 		}
+		if containsAggregate(predicate) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in WHERE")
+		}
+		if containsWindowFunc(predicate) {
+			return nil, fmt.Errorf("window functions are not allowed in WHERE")
+		}
 
 		plan = &LogicalFilter{
 			Input:     plan,
@@ -81,23 +262,97 @@ func (p *Planner) planSelect(stmt *parser.SelectStatement) (LogicalPlan, error)
 		return nil, err
 	}
 
+	var having Expr
+	if stmt.Having != nil {
+		having, err = p.convertExpr(stmt.Having, plan.Schema())
+		if err != nil {
+			return nil, err
+		}
+		if containsWindowFunc(having) {
+			return nil, fmt.Errorf("window functions are not allowed in HAVING")
+		}
+	}
+
+	// grouping and aggregates, HAVING filters the aggregated rows
+	if len(stmt.GroupBy) > 0 || having != nil || containsAggregate(projections...) {
+		var agg *LogicalAggregate
+		agg, having, err = p.planAggregate(stmt.GroupBy, plan, projections, having)
+		if err != nil {
+			return nil, err
+		}
+		plan = agg
+
+		if having != nil {
+			plan = &LogicalFilter{
+				Input:     plan,
+				Predicate: having,
+			}
+		}
+	}
+
+	// window functions run below the projection, which refers to them by name
+	if window := extractWindowFuncs(projections, columnNames); window != nil {
+		window.Input = plan
+		plan = window
+	}
+
 	plan = &LogicalProject{
 		Input:       plan,
 		Projections: projections,
 		ColumnNames: columnNames,
+		Hints:       stmt.Hints,
 	}
 
 	return plan, nil
 }
 
+// JOIN UNNEST(expr) AS alias [ON cond], expr may read columns of input
+func (p *Planner) planUnnest(input LogicalPlan, join *parser.JoinClause) (LogicalPlan, error) {
+	if join.Type == parser.RightJoin {
+		return nil, fmt.Errorf("RIGHT JOIN is not supported with UNNEST")
+	}
+
+	expr, err := p.convertExpr(join.Table.Unnest, input.Schema())
+	if err != nil {
+		return nil, err
+	}
+	if containsAggregate(expr) {
+		return nil, fmt.Errorf("aggregate functions are not allowed in UNNEST")
+	}
+	if containsWindowFunc(expr) {
+		return nil, fmt.Errorf("window functions are not allowed in UNNEST")
+	}
+
+	unnest := &LogicalUnnest{
+		Input: input,
+		Expr:  expr,
+		Alias: join.Table.Alias,
+		Outer: join.Type == parser.LeftJoin,
+	}
+	if join.Condition != nil {
+		unnest.Condition, err = p.convertExpr(join.Condition, unnest.Schema())
+		if err != nil {
+			return nil, err
+		}
+		if containsAggregate(unnest.Condition) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in JOIN conditions")
+		}
+		if containsWindowFunc(unnest.Condition) {
+			return nil, fmt.Errorf("window functions are not allowed in JOIN conditions")
+		}
+	}
+
+	return unnest, nil
+}
+
 func (p *Planner) convertProjections(cols []parser.Expression, input LogicalPlan) ([]Expr, []string, error) {
 	var projections []Expr
 	var columnNames []string
+	schema := input.Schema()
 
 	for _, col := range cols {
 		switch c := col.(type) {
 		case *parser.StarExpr:
-			schema := input.Schema()
 			for _, schemaCol := range schema {
 				projections = append(projections, &ColumnExpr{
 					Column: schemaCol.Name,
@@ -112,8 +367,21 @@ func (p *Planner) convertProjections(cols []parser.Expression, input LogicalPlan
 			})
 			columnNames = append(columnNames, c.Column)
 
+		case *parser.AliasExpr:
+			expr, err := p.convertExpr(c.Expr, schema)
+			if err != nil {
+				return nil, nil, err
+			}
+			projections = append(projections, expr)
+			columnNames = append(columnNames, c.Alias)
+
 		default:
-			return nil, nil, fmt.Errorf("unsupported projection type: %T", col)
+			expr, err := p.convertExpr(col, schema)
+			if err != nil {
+				return nil, nil, err
+			}
+			projections = append(projections, expr)
+			columnNames = append(columnNames, col.String())
 		}
 	}
 
@@ -133,7 +401,8 @@ func convertJoinType(jt parser.JoinType) JoinType {
 	}
 }
 
-func (p *Planner) convertExpr(expr parser.Expression) (Expr, error) {
+// binds an AST expression against the input schema
+func (p *Planner) convertExpr(expr parser.Expression, schema []catalog.Column) (Expr, error) {
 	switch e := expr.(type) {
 	case *parser.ColumnRef:
 		return &ColumnExpr{
@@ -142,26 +411,14 @@ func (p *Planner) convertExpr(expr parser.Expression) (Expr, error) {
 		}, nil
 
 	case *parser.Literal:
-		var dataType catalog.DataType
-
-		switch e.Type {
-		case parser.IntLiteral:
-			dataType = catalog.IntType
-		case parser.StringLiteral:
-			dataType = catalog.StringType
-
-		}
-		return &LiteralExpr{
-			Value: e.Value,
-			Type:  dataType,
-		}, nil
+		return convertLiteral(e)
 
 	case *parser.BinaryExpr:
-		left, err := p.convertExpr(e.Left)
+		left, err := p.convertExpr(e.Left, schema)
 		if err != nil {
 			return nil, err
 		}
-		right, err := p.convertExpr(e.Right)
+		right, err := p.convertExpr(e.Right, schema)
 		if err != nil {
 			return nil, err
 		}
@@ -171,20 +428,570 @@ func (p *Planner) convertExpr(expr parser.Expression) (Expr, error) {
 			Right:    right,
 		}, nil
 
+	case *parser.NotExpr:
+		inner, err := p.convertExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: inner}, nil
+
+	case *parser.IsNullExpr:
+		inner, err := p.convertExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{Expr: inner, Not: e.Not}, nil
+
+	case *parser.StarExpr:
+		return &StarExpr{Table: e.Table}, nil
+
+	case *parser.FunctionCall:
+		if e.Over != nil {
+			return p.convertWindowFunc(e, schema)
+		}
+		if agg, ok := p.functions.LookupAggregate(e.Name); ok {
+			return p.convertAggregate(e, agg, schema)
+		}
+		return p.convertFuncCall(e, schema)
+
+	case *parser.CaseExpr:
+		return p.convertCase(e, schema)
+
+	case *parser.CastExpr:
+		inner, err := p.convertExpr(e.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		dataType, precision, scale, err := catalog.ParseTypeName(e.TypeName)
+		if err != nil {
+			return nil, err
+		}
+		return &CastExpr{Expr: inner, Type: dataType, Precision: precision, Scale: scale}, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported expression type: %T", expr)
 	}
 }
 
-func PrintPlan(plan LogicalPlan, indent int) {
-	prefix := ""
+// typed literals are parsed here so bad dates or intervals fail planning
+func convertLiteral(e *parser.Literal) (Expr, error) {
+	var value interface{} = e.Value
+	var dataType catalog.DataType
+	var err error
+
+	switch e.Type {
+	case parser.IntLiteral:
+		dataType = catalog.IntType
+	case parser.StringLiteral:
+		dataType = catalog.StringType
+	case parser.NullLiteral:
+		dataType = catalog.NullType
+	case parser.BoolLiteral:
+		dataType = catalog.BoolType
+	case parser.FloatLiteral:
+		dataType = catalog.FloatType
+	case parser.DecimalLiteral:
+		dataType = catalog.DecimalType
+		value, err = types.ParseDecimal(e.Value.(string))
+	case parser.DateLiteral:
+		dataType = catalog.DateType
+		value, err = types.ParseDate(e.Value.(string))
+	case parser.TimestampLiteral:
+		dataType = catalog.TimestampType
+		value, err = types.ParseTimestamp(e.Value.(string))
+	case parser.IntervalLiteral:
+		dataType = catalog.IntervalType
+		value, err = types.ParseInterval(e.Value.(string))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &LiteralExpr{
+		Value: value,
+		Type:  dataType,
+	}, nil
+}
+
+// makes a Go function callable from SQL, see function.ScalarFunc
+func (p *Planner) RegisterScalarFunction(fn *function.ScalarFunc) error {
+	return p.functions.RegisterScalar(fn)
+}
 
-	for i := 0; i < indent; i++ {
-		prefix += "  "
+// makes a Go aggregate callable from SQL, see function.AggregateFunc
+func (p *Planner) RegisterAggregateFunction(fn *function.AggregateFunc) error {
+	return p.functions.RegisterAggregate(fn)
+}
+
+// replaces aggregates and group keys in projections and having with
+// references to the aggregate output, anything else must not touch input columns
+func (p *Planner) planAggregate(groupBy []parser.Expression, input LogicalPlan, projections []Expr, having Expr) (*LogicalAggregate, Expr, error) {
+	agg := &LogicalAggregate{Input: input}
+	schema := input.Schema()
+
+	for _, g := range groupBy {
+		expr, err := p.convertExpr(g, schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		if containsAggregate(expr) {
+			return nil, nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY")
+		}
+		if containsWindowFunc(expr) {
+			return nil, nil, fmt.Errorf("window functions are not allowed in GROUP BY")
+		}
+
+		name := expr.String()
+		if col, ok := expr.(*ColumnExpr); ok {
+			name = col.Column
+		}
+		agg.GroupBy = append(agg.GroupBy, expr)
+		agg.GroupNames = append(agg.GroupNames, name)
+	}
+
+	outputs := make(map[string]bool)
+	for _, name := range agg.GroupNames {
+		outputs[name] = true
+	}
+
+	rewrite := func(expr Expr) Expr {
+		return TransformExpr(expr, func(e Expr) (Expr, bool) {
+			if a, ok := e.(*AggregateExpr); ok {
+				name := a.String()
+				if !outputs[name] {
+					outputs[name] = true
+					agg.Aggregates = append(agg.Aggregates, a)
+					agg.AggregateNames = append(agg.AggregateNames, name)
+				}
+				return &ColumnExpr{Column: name}, true
+			}
+
+			for i, g := range agg.GroupBy {
+				if sameExpr(e, g) {
+					return &ColumnExpr{Column: agg.GroupNames[i]}, true
+				}
+			}
+			return e, false
+		})
+	}
+
+	for i := range projections {
+		projections[i] = rewrite(projections[i])
 	}
+	having = rewrite(having)
 
-	fmt.Printf("%s%s\n", prefix, plan.String())
+	for _, expr := range append([]Expr{having}, projections...) {
+		var missing string
+		WalkExpr(expr, func(e Expr) bool {
+			if col, ok := e.(*ColumnExpr); ok && !outputs[col.Column] && missing == "" {
+				missing = col.String()
+			}
+			return true
+		})
+		if missing != "" {
+			return nil, nil, fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate function", missing)
+		}
+	}
+
+	return agg, having, nil
+}
+
+func (p *Planner) convertAggregate(fn *parser.FunctionCall, aggFunc *function.AggregateFunc, schema []catalog.Column) (*AggregateExpr, error) {
+	call := &AggregateExpr{Name: fn.Name, Func: aggFunc}
+	var argTypes []catalog.DataType
+
+	for _, arg := range fn.Args {
+		expr, err := p.convertExpr(arg, schema)
+		if err != nil {
+			return nil, err
+		}
+		if containsAggregate(expr) {
+			return nil, fmt.Errorf("aggregate function calls cannot be nested")
+		}
+		if containsWindowFunc(expr) {
+			return nil, fmt.Errorf("window functions are not allowed in aggregate arguments")
+		}
+
+		call.Args = append(call.Args, expr)
+		if _, ok := expr.(*StarExpr); ok {
+			if fn.Name != "COUNT" || len(fn.Args) != 1 {
+				return nil, fmt.Errorf("%s does not accept *", fn.Name)
+			}
+			continue
+		}
+		argTypes = append(argTypes, ExprType(expr, schema))
+	}
+
+	var err error
+	call.Type, err = aggFunc.Bind(argTypes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn.Name, err)
+	}
+
+	return call, nil
+}
+
+func containsAggregate(exprs ...Expr) bool {
+	for _, expr := range exprs {
+		if ContainsExpr(expr, func(e Expr) bool {
+			_, ok := e.(*AggregateExpr)
+			return ok
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsWindowFunc(exprs ...Expr) bool {
+	for _, expr := range exprs {
+		if ContainsExpr(expr, func(e Expr) bool {
+			_, ok := e.(*WindowFuncExpr)
+			return ok
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// same column, or structurally equal expressions
+func sameExpr(a, b Expr) bool {
+	ca, okA := a.(*ColumnExpr)
+	cb, okB := b.(*ColumnExpr)
+	if okA && okB {
+		return ca.Column == cb.Column && (ca.Table == "" || cb.Table == "" || ca.Table == cb.Table)
+	}
+
+	return a.String() == b.String()
+}
+
+// resolves a scalar function by name and infers its result type
+func (p *Planner) convertFuncCall(fn *parser.FunctionCall, schema []catalog.Column) (*FuncCallExpr, error) {
+	scalar, err := p.functions.LookupScalar(fn.Name)
+	if err != nil {
+		if _, ok := windowFuncArgs[fn.Name]; ok {
+			return nil, fmt.Errorf("function %s requires an OVER clause", fn.Name)
+		}
+		return nil, err
+	}
+
+	call := &FuncCallExpr{Name: fn.Name, Func: scalar}
+	argTypes := make([]catalog.DataType, len(fn.Args))
+
+	for i, arg := range fn.Args {
+		expr, err := p.convertExpr(arg, schema)
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, expr)
+		argTypes[i] = ExprType(expr, schema)
+	}
+
+	call.Type, err = scalar.Bind(argTypes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn.Name, err)
+	}
+
+	return call, nil
+}
+
+func (p *Planner) convertCase(c *parser.CaseExpr, schema []catalog.Column) (*CaseExpr, error) {
+	expr := &CaseExpr{Type: catalog.NullType}
+
+	if c.Operand != nil {
+		operand, err := p.convertExpr(c.Operand, schema)
+		if err != nil {
+			return nil, err
+		}
+		expr.Operand = operand
+	}
+
+	results := make([]Expr, 0, len(c.Whens)+1)
+	for _, w := range c.Whens {
+		cond, err := p.convertExpr(w.Condition, schema)
+		if err != nil {
+			return nil, err
+		}
+		result, err := p.convertExpr(w.Result, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		expr.Whens = append(expr.Whens, WhenClause{Condition: cond, Result: result})
+		results = append(results, result)
+	}
+
+	if c.Else != nil {
+		elseExpr, err := p.convertExpr(c.Else, schema)
+		if err != nil {
+			return nil, err
+		}
+		expr.Else = elseExpr
+		results = append(results, elseExpr)
+	}
+
+	// all branches must agree on a type
+	for _, result := range results {
+		t := ExprType(result, schema)
+		if t == catalog.NullType {
+			continue
+		}
+		if expr.Type == catalog.NullType {
+			expr.Type = t
+		} else if t != expr.Type {
+			return nil, fmt.Errorf("CASE branches have mismatched types %s and %s", expr.Type, t)
+		}
+	}
+
+	return expr, nil
+}
+
+// result type of a bound expression, columns are looked up in schema
+func ExprType(expr Expr, schema []catalog.Column) catalog.DataType {
+	switch e := expr.(type) {
+	case *ColumnExpr:
+		for _, col := range schema {
+			if col.Name == e.Column {
+				return col.Type
+			}
+		}
+		return catalog.NullType
+	case *LiteralExpr:
+		return e.Type
+	case *BinaryExpr:
+		switch e.Operator {
+		case "+", "-", "*", "/", "%":
+			left, right := ExprType(e.Left, schema), ExprType(e.Right, schema)
+			if t, err := function.BinaryType(left, e.Operator, right); err == nil {
+				return t
+			}
+			if left != catalog.NullType {
+				return left
+			}
+			return right
+		case "->":
+			return catalog.JSONType
+		case "->>":
+			return catalog.StringType
+		}
+		return catalog.BoolType
+	case *NotExpr, *IsNullExpr:
+		return catalog.BoolType
+	case *FuncCallExpr:
+		return e.Type
+	case *CaseExpr:
+		return e.Type
+	case *CastExpr:
+		return e.Type
+	case *WindowFuncExpr:
+		return e.Type
+	case *AggregateExpr:
+		return e.Type
+	default:
+		return catalog.NullType
+	}
+}
+
+// allowed argument counts of window functions
+var windowFuncArgs = map[string][2]int{
+	"ROW_NUMBER":  {0, 0},
+	"RANK":        {0, 0},
+	"DENSE_RANK":  {0, 0},
+	"LAG":         {1, 3},
+	"LEAD":        {1, 3},
+	"FIRST_VALUE": {1, 1},
+	"LAST_VALUE":  {1, 1},
+	"SUM":         {1, 1},
+	"AVG":         {1, 1},
+	"COUNT":       {1, 1},
+	"MIN":         {1, 1},
+	"MAX":         {1, 1},
+}
+
+func (p *Planner) convertWindowFunc(fn *parser.FunctionCall, schema []catalog.Column) (*WindowFuncExpr, error) {
+	argRange, ok := windowFuncArgs[fn.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown window function %s", fn.Name)
+	}
+	if len(fn.Args) < argRange[0] || len(fn.Args) > argRange[1] {
+		return nil, fmt.Errorf("wrong number of arguments to %s: %d", fn.Name, len(fn.Args))
+	}
+
+	w := &WindowFuncExpr{Name: fn.Name}
+
+	for i, arg := range fn.Args {
+		if _, ok := arg.(*parser.StarExpr); ok && fn.Name != "COUNT" {
+			return nil, fmt.Errorf("%s does not accept *", fn.Name)
+		}
+		if (fn.Name == "LAG" || fn.Name == "LEAD") && i == 1 {
+			lit, ok := arg.(*parser.Literal)
+			if !ok || lit.Type != parser.IntLiteral {
+				return nil, fmt.Errorf("%s offset must be an integer literal", fn.Name)
+			}
+			if lit.Value.(int) < 0 {
+				return nil, fmt.Errorf("%s offset must not be negative", fn.Name)
+			}
+		}
+
+		expr, err := p.convertExpr(arg, schema)
+		if err != nil {
+			return nil, err
+		}
+		if containsWindowFunc(expr) {
+			return nil, fmt.Errorf("window function calls cannot be nested")
+		}
+		w.Args = append(w.Args, expr)
+	}
+
+	for _, e := range fn.Over.PartitionBy {
+		expr, err := p.convertExpr(e, schema)
+		if err != nil {
+			return nil, err
+		}
+		if containsWindowFunc(expr) {
+			return nil, fmt.Errorf("window function calls cannot be nested")
+		}
+		w.PartitionBy = append(w.PartitionBy, expr)
+	}
+
+	for _, o := range fn.Over.OrderBy {
+		expr, err := p.convertExpr(o.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		if containsWindowFunc(expr) {
+			return nil, fmt.Errorf("window function calls cannot be nested")
+		}
+		w.OrderBy = append(w.OrderBy, SortKey{Expr: expr, Desc: o.Desc})
+	}
+
+	var err error
+	if w.Type, err = p.windowFuncType(w, schema); err != nil {
+		return nil, err
+	}
+
+	w.Frame = convertWindowFrame(fn.Over.Frame, len(w.OrderBy) > 0)
+	offsets := w.Frame.Start.Type == Preceding || w.Frame.Start.Type == Following ||
+		w.Frame.End.Type == Preceding || w.Frame.End.Type == Following
+	if w.Frame.Unit == RangeFrame && offsets {
+		if len(w.OrderBy) != 1 {
+			return nil, fmt.Errorf("RANGE with an offset requires exactly one ORDER BY key")
+		}
+		// offsets move dates and timestamps by days
+		switch t := ExprType(w.OrderBy[0].Expr, schema); {
+		case t.IsNumeric(), t == catalog.DateType, t == catalog.TimestampType:
+		default:
+			return nil, fmt.Errorf("RANGE with an offset requires a numeric, date or timestamp ORDER BY key, got %s", t)
+		}
+	}
+
+	return w, nil
+}
+
+// ranks and counts are INT, AVG is FLOAT, the other aggregates are typed as
+// the aggregate is and value functions have the type of their argument
+func (p *Planner) windowFuncType(w *WindowFuncExpr, schema []catalog.Column) (catalog.DataType, error) {
+	switch w.Name {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK", "COUNT":
+		return catalog.IntType, nil
+	case "LAG", "LEAD", "FIRST_VALUE", "LAST_VALUE":
+		return ExprType(w.Args[0], schema), nil
+	}
+
+	argType := ExprType(w.Args[0], schema)
+	agg, ok := p.functions.LookupAggregate(w.Name)
+	if !ok {
+		return catalog.NullType, fmt.Errorf("unknown window function %s", w.Name)
+	}
+	t, err := agg.Bind([]catalog.DataType{argType})
+	if err != nil {
+		return catalog.NullType, fmt.Errorf("%s: %w", w.Name, err)
+	}
+	if w.Name == "AVG" {
+		return catalog.FloatType, nil
+	}
+	return t, nil
+}
+
+// fills in the SQL default frame when none was given
+func convertWindowFrame(frame *parser.WindowFrame, ordered bool) *WindowFrame {
+	if frame == nil {
+		if ordered {
+			return &WindowFrame{
+				Unit:  RangeFrame,
+				Start: FrameBound{Type: UnboundedPreceding},
+				End:   FrameBound{Type: CurrentRow},
+			}
+		}
+
+		return &WindowFrame{
+			Unit:  RowsFrame,
+			Start: FrameBound{Type: UnboundedPreceding},
+			End:   FrameBound{Type: UnboundedFollowing},
+		}
+	}
+
+	unit := RowsFrame
+	if frame.Unit == parser.RangeFrame {
+		unit = RangeFrame
+	}
+
+	return &WindowFrame{
+		Unit:  unit,
+		Start: FrameBound{Type: FrameBoundType(frame.Start.Type), Offset: frame.Start.Offset},
+		End:   FrameBound{Type: FrameBoundType(frame.End.Type), Offset: frame.End.Offset},
+	}
+}
+
+// moves window function calls out of the projections into a window node
+func extractWindowFuncs(projections []Expr, columnNames []string) *LogicalWindow {
+	window := &LogicalWindow{}
+
+	for i, proj := range projections {
+		if w, ok := proj.(*WindowFuncExpr); ok {
+			window.Functions = append(window.Functions, w)
+			window.ColumnNames = append(window.ColumnNames, columnNames[i])
+			projections[i] = &ColumnExpr{Column: columnNames[i]}
+			continue
+		}
+
+		projections[i] = replaceWindowFuncs(proj, window)
+	}
+
+	if len(window.Functions) == 0 {
+		return nil
+	}
+
+	return window
+}
+
+func replaceWindowFuncs(expr Expr, window *LogicalWindow) Expr {
+	return TransformExpr(expr, func(e Expr) (Expr, bool) {
+		w, ok := e.(*WindowFuncExpr)
+		if !ok {
+			return e, false
+		}
+
+		name := w.String()
+		window.Functions = append(window.Functions, w)
+		window.ColumnNames = append(window.ColumnNames, name)
+		return &ColumnExpr{Column: name}, true
+	})
+}
+
+func PrintPlan(plan LogicalPlan, indent int) {
+	fmt.Print(FormatPlan(plan, indent))
+}
+
+// plan as an indented tree, one node per line
+func FormatPlan(plan LogicalPlan, indent int) string {
+	out := fmt.Sprintf("%s%s\n", strings.Repeat("  ", indent), plan.String())
 	for _, child := range plan.Children() {
-		PrintPlan(child, indent+1)
+		out += FormatPlan(child, indent+1)
 	}
-}
\ No newline at end of file
+
+	return out
+}
@@ -0,0 +1,177 @@
+package plan
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/parser"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// table in FROM or JOIN, views are replaced by their query
+func (p *Planner) planTableRef(ref *parser.TableRef) (LogicalPlan, error) {
+	table, err := p.catalog.GetTable(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !table.IsView() {
+		return &LogicalScan{TableName: ref.Name, Table: table, Alias: ref.Alias}, nil
+	}
+
+	input, err := p.planViewQuery(table)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(input.Schema()); n != len(table.Columns) {
+		return nil, fmt.Errorf("view %s has %d columns but its query now returns %d", table.Name, len(table.Columns), n)
+	}
+	return &LogicalView{View: table, Alias: ref.Alias, Input: input}, nil
+}
+
+// plan of the query a view or materialized view is defined by
+func (p *Planner) planViewQuery(view *catalog.TableInfo) (LogicalPlan, error) {
+	if p.expanding[view.Name] {
+		return nil, fmt.Errorf("view %s refers to itself", view.Name)
+	}
+
+	parse := parser.NewParser(view.View)
+	stmt, ok := parse.Parse().(*parser.SelectStatement)
+	if len(parse.Errors()) > 0 || !ok {
+		return nil, fmt.Errorf("view %s: can't parse its query: %v", view.Name, parse.Errors())
+	}
+
+	if p.expanding == nil {
+		p.expanding = make(map[string]bool)
+	}
+	p.expanding[view.Name] = true
+	defer delete(p.expanding, view.Name)
+
+	input, err := p.planSelect(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("view %s: %w", view.Name, err)
+	}
+	return input, nil
+}
+
+// columns a query returns, named by names when given
+func queryColumns(input LogicalPlan, names []string) ([]catalog.Column, error) {
+	cols := input.Schema()
+	if proj, ok := input.(*LogicalProject); ok {
+		schema := proj.Input.Schema()
+		for i, expr := range proj.Projections {
+			cols[i].Type = ExprType(expr, schema)
+		}
+	}
+
+	if names != nil {
+		if len(names) != len(cols) {
+			return nil, fmt.Errorf("%d column names given but the query returns %d columns", len(names), len(cols))
+		}
+		for i := range cols {
+			cols[i].Name = names[i]
+		}
+	}
+	for i := range cols {
+		cols[i].Path = ""
+		cols[i].NotNull = false
+		if cols[i].Type == catalog.NullType {
+			cols[i].Type = catalog.StringType // NULL literals, a view can't hold other values there
+		}
+	}
+
+	return cols, nil
+}
+
+func (p *Planner) planCreateView(stmt *parser.CreateViewStatement) (LogicalPlan, error) {
+	if _, err := p.catalog.GetTable(stmt.Name); err == nil && !stmt.IfNotExists {
+		return nil, fmt.Errorf("table %s already exists", stmt.Name)
+	}
+
+	input, err := p.planSelect(stmt.Select)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := queryColumns(input, stmt.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("view %s: %w", stmt.Name, err)
+	}
+
+	view := &catalog.TableInfo{Name: stmt.Name, Columns: cols, View: stmt.Query}
+	if !stmt.Materialized {
+		return &LogicalCreateView{View: view, IfNotExists: stmt.IfNotExists}, nil
+	}
+
+	view.Materialized = true
+	view.Reads = tablesRead(input)
+	view.DataFile = p.catalog.DataPath(view.Name, storage.DataExtension("json"))
+	return &LogicalCreateView{View: view, Input: input, IfNotExists: stmt.IfNotExists}, nil
+}
+
+func (p *Planner) planRefresh(stmt *parser.RefreshStatement) (LogicalPlan, error) {
+	view, err := p.catalog.GetTable(stmt.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !view.Materialized {
+		return nil, fmt.Errorf("%s is not a materialized view", view.Name)
+	}
+
+	input, err := p.planViewQuery(view)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(input.Schema()); n != len(view.Columns) {
+		return nil, fmt.Errorf("view %s has %d columns but its query now returns %d", view.Name, len(view.Columns), n)
+	}
+	return &LogicalRefresh{Table: view, Input: input}, nil
+}
+
+// tables a plan scans, views included
+func tablesRead(node LogicalPlan) []string {
+	var names []string
+	var walk func(node LogicalPlan)
+	walk = func(node LogicalPlan) {
+		if scan, ok := node.(*LogicalScan); ok && !slices.Contains(names, scan.TableName) {
+			names = append(names, scan.TableName)
+		}
+		for _, child := range node.Children() {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return names
+}
+
+// what kind of table a DROP or ALTER names has to match
+func checkKind(table *catalog.TableInfo, view, materialized bool) error {
+	switch {
+	case table.Materialized && !materialized:
+		return fmt.Errorf("%s is a materialized view, use DROP MATERIALIZED VIEW", table.Name)
+	case table.IsView() && !view:
+		return fmt.Errorf("%s is a view, use DROP VIEW", table.Name)
+	case table.View == "" && view:
+		return fmt.Errorf("%s is not a view", table.Name)
+	case table.IsView() && materialized:
+		return fmt.Errorf("%s is not a materialized view", table.Name)
+	}
+
+	return nil
+}
+
+// table an INSERT, UPDATE or DELETE changes
+func (p *Planner) writableTable(name string) (*catalog.TableInfo, error) {
+	table, err := p.catalog.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+	if table.IsView() {
+		return nil, fmt.Errorf("can't change %s, it is a view", name)
+	}
+	if table.Materialized {
+		return nil, fmt.Errorf("can't change %s, it is a materialized view, REFRESH it instead", name)
+	}
+
+	return table, nil
+}
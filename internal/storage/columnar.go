@@ -0,0 +1,730 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+func init() {
+	Register("columnar", openColumnar)
+}
+
+// The columnar format stores rows in blocks of up to blockRows rows, each
+// block holding one chunk per column:
+//
+//	"SQOC" chunk... footer footer_length(uint32 LE) "SQOC"
+//
+// A chunk is the NULL count as a uvarint, a bitmap of NULL rows when there
+// are any, then the non NULL values encoded as in encoding.go. The JSON
+// footer has the columns and, for every chunk, its offset, length and zone
+// map (min, max and NULL count), so scans read only the chunks of the
+// columns they need in blocks the pushed predicates can't rule out
+const columnarMagic = "SQOC"
+
+// rows per block when the writer is given no block size
+const DefaultBlockRows = 4096
+
+type columnarFooter struct {
+	Columns []catalog.Column `json:"columns"`
+	Blocks  []columnarBlock  `json:"blocks"`
+}
+
+type columnarBlock struct {
+	Rows   int             `json:"rows"`
+	Chunks []columnarChunk `json:"chunks"` // one per column
+}
+
+type columnarChunk struct {
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+	Encoding string `json:"encoding"`
+	Nulls    int    `json:"nulls,omitempty"`
+
+	// physical min and max of the non NULL values, unset for JSON columns,
+	// NaN floats and chunks with only NULLs
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+}
+
+// writes rows in the columnar format, buffering one block at a time
+type ColumnarWriter struct {
+	w         io.Writer
+	offset    int64
+	columns   []catalog.Column
+	blockRows int
+	pending   []Row
+	footer    columnarFooter
+}
+
+func NewColumnarWriter(w io.Writer, columns []catalog.Column, blockRows int) (*ColumnarWriter, error) {
+	if blockRows <= 0 {
+		blockRows = DefaultBlockRows
+	}
+
+	cw := &ColumnarWriter{w: w, blockRows: blockRows}
+	for _, col := range columns {
+		col.Path = "" // values are stored already extracted
+		cw.columns = append(cw.columns, col)
+	}
+	cw.footer.Columns = cw.columns
+
+	if err := cw.write([]byte(columnarMagic)); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *ColumnarWriter) write(data []byte) error {
+	n, err := cw.w.Write(data)
+	cw.offset += int64(n)
+	return err
+}
+
+func (cw *ColumnarWriter) Write(row Row) error {
+	cw.pending = append(cw.pending, row)
+	if len(cw.pending) >= cw.blockRows {
+		return cw.flush()
+	}
+
+	return nil
+}
+
+func (cw *ColumnarWriter) flush() error {
+	if len(cw.pending) == 0 {
+		return nil
+	}
+
+	block := columnarBlock{Rows: len(cw.pending)}
+	for _, col := range cw.columns {
+		data, chunk, err := encodeChunk(col, cw.pending)
+		if err != nil {
+			return err
+		}
+		chunk.Offset, chunk.Length = cw.offset, int64(len(data))
+		if err := cw.write(data); err != nil {
+			return err
+		}
+		block.Chunks = append(block.Chunks, chunk)
+	}
+
+	cw.footer.Blocks = append(cw.footer.Blocks, block)
+	cw.pending = cw.pending[:0]
+	return nil
+}
+
+// writes the last block and the footer, the writer can't be used after
+func (cw *ColumnarWriter) Close() error {
+	if err := cw.flush(); err != nil {
+		return err
+	}
+
+	footer, err := json.Marshal(cw.footer)
+	if err != nil {
+		return err
+	}
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return cw.write(append(footer, columnarMagic...))
+}
+
+// chunk of one column of rows and its zone map
+func encodeChunk(col catalog.Column, rows []Row) ([]byte, columnarChunk, error) {
+	var chunk columnarChunk
+	bitmap := make([]byte, (len(rows)+7)/8)
+	var lo, hi interface{}
+	var values []interface{}
+
+	for i, row := range rows {
+		v, err := function.CastTo(row[col.Name], col.Type, col.Precision, col.Scale)
+		if err != nil {
+			return nil, chunk, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		if v == nil {
+			chunk.Nulls++
+			bitmap[i/8] |= 1 << (i % 8)
+			continue
+		}
+
+		if col.Type != catalog.JSONType && !isNaN(v) {
+			if lo == nil || function.Compare(v, lo) < 0 {
+				lo = v
+			}
+			if hi == nil || function.Compare(v, hi) > 0 {
+				hi = v
+			}
+		}
+		values = append(values, toPhysical(v, col.Type))
+	}
+
+	data := binary.AppendUvarint(nil, uint64(chunk.Nulls))
+	if chunk.Nulls > 0 {
+		data = append(data, bitmap...)
+	}
+
+	var encoded []byte
+	switch physicalOf(col.Type) {
+	case physicalInt:
+		ints := make([]int64, len(values))
+		for i, v := range values {
+			ints[i] = v.(int64)
+		}
+		encoded = encodeInts(ints)
+	case physicalFloat:
+		floats := make([]float64, len(values))
+		for i, v := range values {
+			floats[i] = v.(float64)
+		}
+		encoded = encodeFloats(floats)
+	default:
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = v.(string)
+		}
+		encoded = encodeStrings(strs)
+	}
+	chunk.Encoding = encoding(encoded[0]).String()
+
+	if lo != nil {
+		chunk.Min, chunk.Max = toPhysical(lo, col.Type), toPhysical(hi, col.Type)
+	}
+	return append(data, encoded...), chunk, nil
+}
+
+func isNaN(v interface{}) bool {
+	f, ok := v.(float64)
+	return ok && (math.IsNaN(f) || math.IsInf(f, 0))
+}
+
+// how values of a type are stored
+type physicalKind int
+
+const (
+	physicalInt physicalKind = iota // INT, BOOL, DATE as days and TIMESTAMP as microseconds since 1970
+	physicalFloat
+	physicalString // text form of everything else, INTERVAL as months, days and microseconds
+)
+
+func physicalOf(t catalog.DataType) physicalKind {
+	switch t {
+	case catalog.IntType, catalog.BoolType, catalog.DateType, catalog.TimestampType:
+		return physicalInt
+	case catalog.FloatType:
+		return physicalFloat
+	}
+
+	return physicalString
+}
+
+// stored form of a non NULL value already cast to t
+func toPhysical(v interface{}, t catalog.DataType) interface{} {
+	if t == catalog.JSONType {
+		return types.JSONText(v)
+	}
+
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case bool:
+		if x {
+			return int64(1)
+		}
+		return int64(0)
+	case types.Date:
+		return x.Time().Unix() / 86400
+	case types.Timestamp:
+		return x.Time().UnixMicro()
+	case types.Interval:
+		// exact, the display form loses the sign of negative clocks
+		return fmt.Sprintf("%d %d %d", x.Months, x.Days, x.Micros)
+	case float64:
+		return x
+	case string:
+		return x
+	}
+
+	return fmt.Sprint(v)
+}
+
+func fromPhysical(v interface{}, t catalog.DataType) (interface{}, error) {
+	switch t {
+	case catalog.IntType:
+		return int(v.(int64)), nil
+	case catalog.BoolType:
+		return v.(int64) != 0, nil
+	case catalog.DateType:
+		days := time.Unix(v.(int64)*86400, 0).UTC()
+		return types.NewDate(days.Year(), days.Month(), days.Day()), nil
+	case catalog.TimestampType:
+		return types.NewTimestamp(time.UnixMicro(v.(int64))), nil
+	case catalog.FloatType, catalog.StringType:
+		return v, nil
+	case catalog.JSONType:
+		return types.ParseJSON(v.(string))
+	case catalog.IntervalType:
+		var i types.Interval
+		if _, err := fmt.Sscan(v.(string), &i.Months, &i.Days, &i.Micros); err != nil {
+			return nil, fmt.Errorf("bad INTERVAL value %q", v)
+		}
+		return i, nil
+	}
+
+	return function.CastTo(v, t, 0, 0)
+}
+
+// footer min or max decoded from JSON back to its physical type
+func zoneValue(v interface{}, t catalog.DataType) (interface{}, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if physicalOf(t) == physicalInt {
+			return x.Int64()
+		}
+		return x.Float64()
+	case string:
+		if physicalOf(t) == physicalString {
+			return x, nil
+		}
+	}
+
+	return nil, fmt.Errorf("bad zone map value %v", v)
+}
+
+// writes every row of table to path in format, replacing path only once the
+// new file is complete. Only the columnar format can be written
+func CopyTable(table *catalog.TableInfo, path, format string) (int, error) {
+	if !strings.EqualFold(format, "columnar") {
+		return 0, fmt.Errorf("unsupported COPY format %s", format)
+	}
+
+	source, err := Open(table)
+	if err != nil {
+		return 0, err
+	}
+	it, err := source.Scan(ScanOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	count := 0
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		writer, err := NewColumnarWriter(w, table.Columns, DefaultBlockRows)
+		if err != nil {
+			return err
+		}
+		for {
+			row, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+			count++
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// table stored in a columnar file, columns are matched to the file's by name
+type columnarSource struct {
+	table *catalog.TableInfo
+}
+
+func openColumnar(table *catalog.TableInfo) (TableSource, error) {
+	if table.DataFile == "" {
+		return nil, fmt.Errorf("table %s has no data file", table.Name)
+	}
+	for key := range table.Options {
+		return nil, fmt.Errorf("table %s: unknown option %q", table.Name, key)
+	}
+
+	return &columnarSource{table: table}, nil
+}
+
+func readColumnarFooter(file *os.File) (*columnarFooter, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	trailer := make([]byte, 8)
+	if size < int64(len(columnarMagic)+len(trailer)) {
+		return nil, fmt.Errorf("%s is not a columnar file", file.Name())
+	}
+	if _, err := file.ReadAt(trailer, size-8); err != nil {
+		return nil, err
+	}
+	if string(trailer[4:]) != columnarMagic {
+		return nil, fmt.Errorf("%s is not a columnar file", file.Name())
+	}
+
+	length := int64(binary.LittleEndian.Uint32(trailer))
+	if length > size-8-int64(len(columnarMagic)) {
+		return nil, fmt.Errorf("%s has a corrupt footer", file.Name())
+	}
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, size-8-length); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var footer columnarFooter
+	if err := decoder.Decode(&footer); err != nil {
+		return nil, fmt.Errorf("%s has a corrupt footer: %w", file.Name(), err)
+	}
+	for _, block := range footer.Blocks {
+		if len(block.Chunks) != len(footer.Columns) {
+			return nil, fmt.Errorf("%s has a corrupt footer", file.Name())
+		}
+	}
+
+	return &footer, nil
+}
+
+func (s *columnarSource) Scan(opts ScanOptions) (RowIterator, error) {
+	file, err := os.Open(s.table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+	footer, err := readColumnarFooter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// columns the scan returns or filters on
+	needed := make(map[string]bool)
+	for _, col := range opts.Columns {
+		needed[col] = true
+	}
+	for _, p := range opts.Predicates {
+		needed[p.Column] = true
+	}
+
+	it := &columnarIterator{file: file, footer: footer, opts: opts}
+	for _, col := range s.table.Columns {
+		if opts.Columns != nil && !needed[col.Name] {
+			continue
+		}
+
+		field := -1
+		for i, stored := range footer.Columns {
+			if strings.EqualFold(stored.Name, col.Name) {
+				field = i
+			}
+		}
+		if field < 0 {
+			file.Close()
+			return nil, fmt.Errorf("%s has no column %s", s.table.DataFile, col.Name)
+		}
+		it.columns = append(it.columns, columnarField{column: col, stored: footer.Columns[field], index: field})
+	}
+
+	return it, nil
+}
+
+// the format has no room to grow in place, inserts rewrite the file
+func (s *columnarSource) Insert(rows []Row) error {
+	existing, err := ReadTable(s.table)
+	if err != nil {
+		return err
+	}
+
+	return s.Replace(append(existing, rows...))
+}
+
+func (s *columnarSource) Replace(rows []Row) error {
+	return writeFileAtomic(s.table.DataFile, func(w io.Writer) error {
+		writer, err := NewColumnarWriter(w, s.table.Columns, DefaultBlockRows)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		return writer.Close()
+	})
+}
+
+func (s *columnarSource) Statistics(sample int) (*catalog.Statistics, error) {
+	it, err := s.Scan(ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+
+	return StatisticsOf(s.table, rows, sample), nil
+}
+
+// table column read from a file column, stored with the type it was
+// written with
+type columnarField struct {
+	column catalog.Column
+	stored catalog.Column
+	index  int
+}
+
+// reads a block at a time, skipping blocks whose zone maps rule out a
+// pushed predicate
+type columnarIterator struct {
+	file    *os.File
+	footer  *columnarFooter
+	opts    ScanOptions
+	columns []columnarField
+
+	block   int // next block to read
+	rows    []Row
+	pos     int
+	skipped int // blocks never read
+}
+
+func (it *columnarIterator) Next() (Row, error) {
+	for {
+		for it.pos < len(it.rows) {
+			row := it.rows[it.pos]
+			it.pos++
+			if MatchesAll(it.opts.Predicates, row) {
+				return Project(row, it.opts.Columns), nil
+			}
+		}
+
+		if it.block >= len(it.footer.Blocks) {
+			return nil, io.EOF
+		}
+		block := it.footer.Blocks[it.block]
+		it.block++
+
+		skip, err := it.canSkip(block)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			it.skipped++
+			continue
+		}
+		if it.rows, err = it.readBlock(block); err != nil {
+			return nil, err
+		}
+		it.pos = 0
+	}
+}
+
+// reports whether no row of block can satisfy every pushed predicate
+func (it *columnarIterator) canSkip(block columnarBlock) (bool, error) {
+	for _, p := range it.opts.Predicates {
+		for _, field := range it.columns {
+			if field.column.Name != p.Column {
+				continue
+			}
+
+			chunk := block.Chunks[field.index]
+			z := zone{rows: block.Rows, nulls: chunk.Nulls}
+			if chunk.Min != nil && chunk.Max != nil {
+				var err error
+				if z.min, err = it.zoneBound(chunk.Min, field.stored); err != nil {
+					return false, err
+				}
+				if z.max, err = it.zoneBound(chunk.Max, field.stored); err != nil {
+					return false, err
+				}
+			}
+			if !z.mayMatch(p) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (it *columnarIterator) zoneBound(v interface{}, col catalog.Column) (interface{}, error) {
+	physical, err := zoneValue(v, col.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", it.file.Name(), err)
+	}
+
+	return fromPhysical(physical, col.Type)
+}
+
+func (it *columnarIterator) readBlock(block columnarBlock) ([]Row, error) {
+	rows := make([]Row, block.Rows)
+	for i := range rows {
+		rows[i] = make(Row, len(it.columns))
+	}
+
+	for _, field := range it.columns {
+		chunk := block.Chunks[field.index]
+		data := make([]byte, chunk.Length)
+		if _, err := it.file.ReadAt(data, chunk.Offset); err != nil {
+			return nil, fmt.Errorf("%s: %w", it.file.Name(), err)
+		}
+
+		values, err := decodeChunk(data, field.stored, block.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: column %s: %w", it.file.Name(), field.column.Name, err)
+		}
+		for i, v := range values {
+			if v != nil && (field.stored.Type != field.column.Type || field.column.Precision > 0) {
+				if v, err = function.CastTo(v, field.column.Type, field.column.Precision, field.column.Scale); err != nil {
+					return nil, fmt.Errorf("column %s: %w", field.column.Name, err)
+				}
+			}
+			rows[i][field.column.Name] = v
+		}
+	}
+
+	return rows, nil
+}
+
+// values of one column of a block, NULLs included
+func decodeChunk(data []byte, col catalog.Column, rows int) ([]interface{}, error) {
+	nulls, size := binary.Uvarint(data)
+	if size <= 0 || nulls > uint64(rows) {
+		return nil, fmt.Errorf("chunk is truncated")
+	}
+	data = data[size:]
+
+	var bitmap []byte
+	if nulls > 0 {
+		n := (rows + 7) / 8
+		if len(data) < n {
+			return nil, fmt.Errorf("chunk is truncated")
+		}
+		bitmap, data = data[:n], data[n:]
+	}
+
+	count := rows - int(nulls)
+	var physical []interface{}
+	switch physicalOf(col.Type) {
+	case physicalInt:
+		ints, err := decodeInts(data, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ints {
+			physical = append(physical, v)
+		}
+	case physicalFloat:
+		floats, err := decodeFloats(data, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range floats {
+			physical = append(physical, v)
+		}
+	default:
+		strs, err := decodeStrings(data, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range strs {
+			physical = append(physical, v)
+		}
+	}
+
+	out := make([]interface{}, rows)
+	next := 0
+	for i := range out {
+		if bitmap != nil && bitmap[i/8]&(1<<(i%8)) != 0 {
+			continue
+		}
+		v, err := fromPhysical(physical[next], col.Type)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+		next++
+	}
+
+	return out, nil
+}
+
+func (it *columnarIterator) Close() error {
+	return it.file.Close()
+}
+
+// zone map of one column of a block, min and max are nil when unknown
+type zone struct {
+	rows, nulls int
+	min, max    interface{}
+}
+
+// reports whether some row of the block might satisfy p, false only when
+// the zone map rules every row out
+func (z zone) mayMatch(p Predicate) bool {
+	switch p.Op {
+	case "IS NULL":
+		return z.nulls > 0
+	case "IS NOT NULL":
+		return z.nulls < z.rows
+	}
+	if z.nulls == z.rows {
+		return false // comparisons with NULL are never true
+	}
+	if z.min == nil || z.max == nil || p.Value == nil {
+		return true
+	}
+
+	v := p.Value
+	switch p.Op {
+	case "=":
+		return holds(z.min, "<=", v) && holds(z.max, ">=", v)
+	case "!=", "<>":
+		return holds(z.min, "!=", v) || holds(z.max, "!=", v)
+	case "<":
+		return holds(z.min, "<", v)
+	case "<=":
+		return holds(z.min, "<=", v)
+	case ">":
+		return holds(z.max, ">", v)
+	case ">=":
+		return holds(z.max, ">=", v)
+	case "LIKE":
+		pattern, ok := v.(string)
+		lo, okLo := z.min.(string)
+		hi, okHi := z.max.(string)
+		if !ok || !okLo || !okHi {
+			return true
+		}
+		// every match starts with the prefix, so sorts between it and the
+		// strings past all those starting with it
+		prefix := function.LikePrefix(pattern)
+		if prefix == "" {
+			return true
+		}
+		return hi >= prefix && (lo <= prefix || strings.HasPrefix(lo, prefix))
+	}
+
+	return true
+}
+
+// false only when a op b is known to be false, errors and NULL leave it open
+func holds(a interface{}, op string, b interface{}) bool {
+	ans, err := function.EvalBinary(a, op, b)
+	return err != nil || ans != false
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+func writeColumnar(t *testing.T, columns []catalog.Column, rows []Row, blockRows int) *catalog.TableInfo {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer, err := NewColumnarWriter(&buf, columns, blockRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dataFile := filepath.Join(t.TempDir(), "table.col")
+	if err := os.WriteFile(dataFile, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &catalog.TableInfo{Name: "t", Columns: columns, DataFile: dataFile, Source: "columnar"}
+}
+
+func TestIntEncodingsRoundTrip(t *testing.T) {
+	inputs := [][]int64{
+		{},
+		{7, 7, 7, 7, 7, 7, 7, 7},
+		{-3, 0, 5, 2, -1, 4},
+		{math.MinInt64, 0, math.MaxInt64},
+	}
+
+	for _, values := range inputs {
+		for _, encoded := range [][]byte{encodePlainInts(values), encodeRLE(values), encodeBitPacked(values)} {
+			decoded, err := decodeInts(encoded, len(values))
+			if err != nil {
+				t.Fatalf("%s: %v", encoding(encoded[0]), err)
+			}
+			for i := range values {
+				if decoded[i] != values[i] {
+					t.Fatalf("%s: expected %v, got %v", encoding(encoded[0]), values, decoded)
+				}
+			}
+		}
+	}
+
+	if enc := encoding(encodeInts([]int64{1, 1, 1, 1, 1, 1, 2, 2, 2, 2})[0]); enc != encRLE {
+		t.Errorf("expected runs to be RLE encoded, got %s", enc)
+	}
+	if enc := encoding(encodeInts([]int64{1000, 1001, 1003, 1002, 1000, 1001})[0]); enc != encBitPacked {
+		t.Errorf("expected a narrow range to be bit packed, got %s", enc)
+	}
+}
+
+func TestColumnarRoundTrip(t *testing.T) {
+	columns := []catalog.Column{
+		{Name: "id", Type: catalog.IntType},
+		{Name: "price", Type: catalog.DecimalType, Precision: 8, Scale: 2},
+		{Name: "ratio", Type: catalog.FloatType},
+		{Name: "city", Type: catalog.StringType},
+		{Name: "active", Type: catalog.BoolType},
+		{Name: "born", Type: catalog.DateType},
+		{Name: "seen", Type: catalog.TimestampType},
+		{Name: "wait", Type: catalog.IntervalType},
+		{Name: "tags", Type: catalog.JSONType},
+	}
+
+	var rows []Row
+	for i := 0; i < 10; i++ {
+		row := Row{
+			"id":     float64(i), // INT values read from JSON are floats
+			"price":  types.NewDecimal(int64(1000+i), 2),
+			"ratio":  float64(i) / 4,
+			"city":   []string{"Berlin", "Paris"}[i%2],
+			"active": i%3 == 0,
+			"born":   types.NewDate(1960+i, time.March, 1),
+			"seen":   types.NewTimestamp(time.Date(2024, 1, 1, i, 30, 0, 0, time.UTC)),
+			"wait":   types.Interval{Months: 1, Days: i, Micros: 1500},
+			"tags":   []interface{}{"a", float64(i)},
+		}
+		if i == 4 {
+			row = Row{"id": 4.0}
+		}
+		rows = append(rows, row)
+	}
+
+	table := writeColumnar(t, columns, rows, 4)
+	got, err := ReadTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(rows) {
+		t.Fatalf("expected %d rows, got %d", len(rows), len(got))
+	}
+	for i, row := range got {
+		if row["id"] != i {
+			t.Fatalf("row %d: expected id %d, got %v", i, i, row["id"])
+		}
+		for _, col := range columns[1:] {
+			want := rows[i][col.Name]
+			if want == nil {
+				if row[col.Name] != nil {
+					t.Errorf("row %d: expected NULL %s, got %v", i, col.Name, row[col.Name])
+				}
+				continue
+			}
+			if function.FormatValue(row[col.Name]) != function.FormatValue(want) {
+				t.Errorf("row %d: expected %s %v, got %v", i, col.Name, want, row[col.Name])
+			}
+		}
+	}
+}
+
+func TestColumnarSkipsBlocks(t *testing.T) {
+	columns := []catalog.Column{
+		{Name: "id", Type: catalog.IntType},
+		{Name: "status", Type: catalog.StringType},
+	}
+	var rows []Row
+	for i := 0; i < 1000; i++ {
+		row := Row{"id": i, "status": "open"}
+		if i >= 900 {
+			row["status"] = nil
+		}
+		rows = append(rows, row)
+	}
+	table := writeColumnar(t, columns, rows, 100)
+
+	source, err := Open(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		preds   []Predicate
+		rows    int
+		skipped int
+	}{
+		{[]Predicate{{Column: "id", Op: ">=", Value: 950}}, 50, 9},
+		{[]Predicate{{Column: "id", Op: "=", Value: 123.0}}, 1, 9},
+		{[]Predicate{{Column: "id", Op: "<", Value: 0}}, 0, 10},
+		{[]Predicate{{Column: "status", Op: "IS NULL"}}, 100, 9},
+		{[]Predicate{{Column: "status", Op: "LIKE", Value: "clo%"}}, 0, 10},
+		{[]Predicate{{Column: "id", Op: "!=", Value: 5}}, 999, 0},
+	}
+
+	for _, tt := range tests {
+		it, err := source.Scan(ScanOptions{Columns: []string{"id"}, Predicates: tt.preds})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadAll(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		skipped := it.(*columnarIterator).skipped
+		if len(got) != tt.rows || skipped != tt.skipped {
+			t.Errorf("%v: expected %d rows with %d blocks skipped, got %d with %d", tt.preds, tt.rows, tt.skipped, len(got), skipped)
+		}
+		if len(got) > 0 && len(got[0]) != 1 {
+			t.Errorf("%v: expected only the id column, got %v", tt.preds, got[0])
+		}
+	}
+}
+
+func TestCopyTableToColumnar(t *testing.T) {
+	table := jsonTable(t)
+	path := filepath.Join(t.TempDir(), "orders.col")
+
+	count, err := CopyTable(table, path, "COLUMNAR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 rows copied, got %d", count)
+	}
+
+	copied := *table
+	copied.Source, copied.DataFile = "columnar", path
+	rows, err := ReadTable(&copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1]["status"] != nil || rows[2]["amount"] != 75 {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	if _, err := CopyTable(table, path, "parquet"); err == nil {
+		t.Fatal("expected an unsupported format to be rejected")
+	}
+}
@@ -0,0 +1,414 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+)
+
+func init() {
+	Register("csv", openCSV)
+}
+
+// delimited text file read one record at a time. Options:
+//
+//	header     "true" (default) when the first record names the columns,
+//	           "false" to map fields to columns by position
+//	delimiter  field separator, "," by default, "\t" for tabs
+//	quote      quote character, `"` by default, doubled inside quotes
+//	null       text read as NULL, the empty string by default. Quoted
+//	           fields are never NULL
+type csvSource struct {
+	table     *catalog.TableInfo
+	header    bool
+	delimiter rune
+	quote     rune
+	null      string
+}
+
+func openCSV(table *catalog.TableInfo) (TableSource, error) {
+	if table.DataFile == "" {
+		return nil, fmt.Errorf("table %s has no data file", table.Name)
+	}
+
+	s := &csvSource{table: table, header: true, delimiter: ',', quote: '"'}
+	for key, value := range table.Options {
+		var err error
+		switch key {
+		case "header":
+			s.header, err = parseBoolOption(key, value)
+		case "delimiter":
+			s.delimiter, err = parseRuneOption(key, value)
+		case "quote":
+			s.quote, err = parseRuneOption(key, value)
+		case "null":
+			s.null = value
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	if s.delimiter == s.quote {
+		return nil, fmt.Errorf("table %s: delimiter and quote must differ", table.Name)
+	}
+
+	return s, nil
+}
+
+func parseBoolOption(key, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("option %s must be true or false, got %q", key, value)
+}
+
+func parseRuneOption(key, value string) (rune, error) {
+	if value == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 || value == "\n" || value == "\r" {
+		return 0, fmt.Errorf("option %s must be a single character, got %q", key, value)
+	}
+
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+func (s *csvSource) Scan(opts ScanOptions) (RowIterator, error) {
+	file, err := os.Open(s.table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	it := &csvIterator{
+		source: s,
+		file:   file,
+		reader: &csvReader{r: bufio.NewReader(file), delimiter: s.delimiter, quote: s.quote, line: 1},
+		opts:   opts,
+	}
+	if err := it.readHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (s *csvSource) Statistics(sample int) (*catalog.Statistics, error) {
+	it, err := s.Scan(ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+
+	return StatisticsOf(s.table, rows, sample), nil
+}
+
+func (s *csvSource) Insert(rows []Row) error {
+	names, err := s.fieldNames()
+	if err != nil {
+		return err
+	}
+
+	return appendLines(s.table.DataFile, func(w io.Writer) error {
+		if names == nil {
+			// empty file, the header comes first
+			names = s.table.GetColumnNames()
+			if s.header {
+				if err := s.writeRecord(w, names, nil); err != nil {
+					return err
+				}
+			}
+		}
+		for _, row := range rows {
+			if err := s.writeRecord(w, names, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *csvSource) Replace(rows []Row) error {
+	names := s.table.GetColumnNames()
+
+	return writeFileAtomic(s.table.DataFile, func(w io.Writer) error {
+		if s.header {
+			if err := s.writeRecord(w, names, nil); err != nil {
+				return err
+			}
+		}
+		for _, row := range rows {
+			if err := s.writeRecord(w, names, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// column of each field of the file, nil for an empty or missing file
+func (s *csvSource) fieldNames() ([]string, error) {
+	if !s.header {
+		return s.table.GetColumnNames(), nil
+	}
+
+	file, err := os.Open(s.table.DataFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+	defer file.Close()
+
+	reader := &csvReader{r: bufio.NewReader(file), delimiter: s.delimiter, quote: s.quote, line: 1}
+	names, _, err := reader.read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s:1: %w", s.table.DataFile, err)
+	}
+	for i := range names {
+		names[i] = strings.TrimSpace(strings.TrimPrefix(names[i], "\uFEFF"))
+	}
+	return names, nil
+}
+
+// writes the values of row in the order of names, or names themselves
+// when row is nil
+func (s *csvSource) writeRecord(w io.Writer, names []string, row Row) error {
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteRune(s.delimiter)
+		}
+		if row == nil {
+			b.WriteString(s.quoteField(name))
+			continue
+		}
+
+		v, ok := row[name]
+		if !ok {
+			// header names match columns in any case
+			for col, val := range row {
+				if strings.EqualFold(col, name) {
+					v = val
+				}
+			}
+		}
+		if v == nil {
+			b.WriteString(s.null)
+			continue
+		}
+		b.WriteString(s.quoteField(function.FormatValue(v)))
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// text quoted when it would otherwise read back differently, empty text
+// is quoted so it isn't NULL
+func (s *csvSource) quoteField(text string) string {
+	if text != "" && text != s.null && !strings.ContainsAny(text, string([]rune{s.delimiter, s.quote, '\n', '\r'})) &&
+		strings.TrimSpace(text) == text {
+		return text
+	}
+
+	q := string(s.quote)
+	return q + strings.ReplaceAll(text, q, q+q) + q
+}
+
+type csvIterator struct {
+	source *csvSource
+	file   *os.File
+	reader *csvReader
+	opts   ScanOptions
+
+	// catalog column of each field, nil for fields no column reads
+	fields []*catalog.Column
+}
+
+// maps fields to columns, by the header names or by position
+func (it *csvIterator) readHeader() error {
+	cols := it.source.table.Columns
+
+	if !it.source.header {
+		for i := range cols {
+			it.fields = append(it.fields, &cols[i])
+		}
+		return nil
+	}
+
+	names, _, err := it.reader.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return it.errorf(err)
+	}
+
+	found := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+		var field *catalog.Column
+		for i := range cols {
+			if strings.EqualFold(cols[i].Name, name) {
+				field = &cols[i]
+				found[cols[i].Name] = true
+			}
+		}
+		it.fields = append(it.fields, field)
+	}
+	for _, col := range cols {
+		if !found[col.Name] {
+			return fmt.Errorf("%s: header has no column %s", it.source.table.DataFile, col.Name)
+		}
+	}
+
+	return nil
+}
+
+func (it *csvIterator) Next() (Row, error) {
+	for {
+		line := it.reader.line
+		values, quoted, err := it.reader.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, it.errorf(err)
+		}
+		if len(values) == 1 && values[0] == "" && !quoted[0] {
+			continue // blank line
+		}
+		if len(values) != len(it.fields) {
+			return nil, fmt.Errorf("%s:%d: expected %d fields, got %d", it.source.table.DataFile, line, len(it.fields), len(values))
+		}
+
+		row := make(Row, len(values))
+		for i, text := range values {
+			col := it.fields[i]
+			if col == nil {
+				continue
+			}
+			if !quoted[i] && text == it.source.null {
+				row[col.Name] = nil
+				continue
+			}
+
+			val, err := function.CastTo(text, col.Type, col.Precision, col.Scale)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: column %s: %w", it.source.table.DataFile, line, col.Name, err)
+			}
+			row[col.Name] = val
+		}
+
+		if MatchesAll(it.opts.Predicates, row) {
+			return Project(row, it.opts.Columns), nil
+		}
+	}
+}
+
+func (it *csvIterator) errorf(err error) error {
+	return fmt.Errorf("%s:%d: %w", it.source.table.DataFile, it.reader.line, err)
+}
+
+func (it *csvIterator) Close() error {
+	return it.file.Close()
+}
+
+// reads records of delimited text, quoted fields may hold delimiters,
+// doubled quotes and line breaks
+type csvReader struct {
+	r         *bufio.Reader
+	delimiter rune
+	quote     rune
+	line      int // line the next record starts on
+}
+
+// next record and which of its fields were quoted, io.EOF at the end
+func (c *csvReader) read() ([]string, []bool, error) {
+	var fields []string
+	var quoted []bool
+	var field strings.Builder
+	inQuotes, wasQuoted, empty := false, false, true
+
+	finish := func() {
+		fields = append(fields, field.String())
+		quoted = append(quoted, wasQuoted)
+		field.Reset()
+		wasQuoted = false
+	}
+
+	for {
+		r, _, err := c.r.ReadRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, nil, fmt.Errorf("unterminated quoted field")
+			}
+			if empty {
+				return nil, nil, io.EOF
+			}
+			finish()
+			return fields, quoted, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		empty = false
+
+		if inQuotes {
+			if r == c.quote {
+				next, _, err := c.r.ReadRune()
+				if err == nil && next == c.quote {
+					field.WriteRune(c.quote)
+					continue
+				}
+				if err == nil {
+					c.r.UnreadRune()
+				}
+				inQuotes = false
+				continue
+			}
+			if r == '\n' {
+				c.line++
+			}
+			field.WriteRune(r)
+			continue
+		}
+
+		switch {
+		case r == c.quote && field.Len() == 0 && !wasQuoted:
+			inQuotes, wasQuoted = true, true
+		case r == c.delimiter:
+			finish()
+		case r == '\r':
+			// CRLF line ends
+		case r == '\n':
+			c.line++
+			finish()
+			return fields, quoted, nil
+		default:
+			field.WriteRune(r)
+		}
+	}
+}
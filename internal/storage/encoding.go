@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// how a block of column values is laid out, the first byte of the encoded
+// values
+type encoding byte
+
+const (
+	encPlain      encoding = iota // varints, 8 byte floats, length prefixed strings
+	encRLE                        // runs of value and count
+	encBitPacked                  // frame of reference, offsets from the min in width bits each
+	encDictionary                 // distinct strings once, then their indexes as ints
+)
+
+func (e encoding) String() string {
+	switch e {
+	case encPlain:
+		return "plain"
+	case encRLE:
+		return "rle"
+	case encBitPacked:
+		return "bitpacked"
+	case encDictionary:
+		return "dictionary"
+	}
+
+	return fmt.Sprintf("encoding(%d)", byte(e))
+}
+
+func zigzag(v int64) uint64   { return uint64(v<<1) ^ uint64(v>>63) }
+func unzigzag(u uint64) int64 { return int64(u>>1) ^ -int64(u&1) }
+
+// smallest of the plain, RLE and bit packed encodings of values
+func encodeInts(values []int64) []byte {
+	best := encodePlainInts(values)
+	for _, candidate := range [][]byte{encodeRLE(values), encodeBitPacked(values)} {
+		if len(candidate) < len(best) {
+			best = candidate
+		}
+	}
+
+	return best
+}
+
+func encodePlainInts(values []int64) []byte {
+	out := []byte{byte(encPlain)}
+	for _, v := range values {
+		out = binary.AppendUvarint(out, zigzag(v))
+	}
+
+	return out
+}
+
+func encodeRLE(values []int64) []byte {
+	out := []byte{byte(encRLE)}
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, zigzag(values[i]))
+		out = binary.AppendUvarint(out, uint64(j-i))
+		i = j
+	}
+
+	return out
+}
+
+func encodeBitPacked(values []int64) []byte {
+	out := []byte{byte(encBitPacked)}
+	if len(values) == 0 {
+		return out
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	width := bits.Len64(uint64(hi) - uint64(lo))
+
+	out = binary.AppendUvarint(out, zigzag(lo))
+	out = append(out, byte(width))
+	return append(out, packBits(values, uint64(lo), width)...)
+}
+
+// offsets of values from base, width bits each, lowest bit first
+func packBits(values []int64, base uint64, width int) []byte {
+	out := make([]byte, (len(values)*width+7)/8)
+	pos := 0
+	for _, v := range values {
+		delta := uint64(v) - base
+		for b := 0; b < width; b++ {
+			if delta&(1<<b) != 0 {
+				out[pos/8] |= 1 << (pos % 8)
+			}
+			pos++
+		}
+	}
+
+	return out
+}
+
+func unpackBits(data []byte, base uint64, width, n int) ([]int64, error) {
+	if len(data)*8 < n*width {
+		return nil, fmt.Errorf("bit packed block is truncated")
+	}
+
+	out := make([]int64, n)
+	pos := 0
+	for i := range out {
+		var delta uint64
+		for b := 0; b < width; b++ {
+			if data[pos/8]&(1<<(pos%8)) != 0 {
+				delta |= 1 << b
+			}
+			pos++
+		}
+		out[i] = int64(base + delta)
+	}
+
+	return out, nil
+}
+
+func decodeInts(data []byte, n int) ([]int64, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty int block")
+	}
+	enc, data := encoding(data[0]), data[1:]
+	out := make([]int64, 0, n)
+
+	switch enc {
+	case encPlain:
+		for len(out) < n {
+			u, size := binary.Uvarint(data)
+			if size <= 0 {
+				return nil, fmt.Errorf("plain int block is truncated")
+			}
+			out = append(out, unzigzag(u))
+			data = data[size:]
+		}
+
+	case encRLE:
+		for len(out) < n {
+			u, size := binary.Uvarint(data)
+			if size <= 0 {
+				return nil, fmt.Errorf("RLE block is truncated")
+			}
+			data = data[size:]
+			count, size := binary.Uvarint(data)
+			if size <= 0 || count > uint64(n-len(out)) {
+				return nil, fmt.Errorf("RLE block has a bad run length")
+			}
+			data = data[size:]
+			for i := uint64(0); i < count; i++ {
+				out = append(out, unzigzag(u))
+			}
+		}
+
+	case encBitPacked:
+		if n == 0 {
+			return out, nil
+		}
+		u, size := binary.Uvarint(data)
+		if size <= 0 || len(data) <= size {
+			return nil, fmt.Errorf("bit packed block is truncated")
+		}
+		width := int(data[size])
+		if width > 64 {
+			return nil, fmt.Errorf("bit packed block has width %d", width)
+		}
+		return unpackBits(data[size+1:], uint64(unzigzag(u)), width, n)
+
+	default:
+		return nil, fmt.Errorf("unknown int encoding %s", enc)
+	}
+
+	return out, nil
+}
+
+func encodeFloats(values []float64) []byte {
+	out := []byte{byte(encPlain)}
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint64(out, math.Float64bits(v))
+	}
+
+	return out
+}
+
+func decodeFloats(data []byte, n int) ([]float64, error) {
+	if len(data) == 0 || encoding(data[0]) != encPlain {
+		return nil, fmt.Errorf("unknown float encoding")
+	}
+	data = data[1:]
+	if len(data) < 8*n {
+		return nil, fmt.Errorf("float block is truncated")
+	}
+
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+
+	return out, nil
+}
+
+// dictionary encoding when it is smaller than the plain one, which it is
+// for columns with few distinct values
+func encodeStrings(values []string) []byte {
+	plain := []byte{byte(encPlain)}
+	for _, s := range values {
+		plain = appendString(plain, s)
+	}
+
+	ids := make(map[string]int64)
+	var dict []string
+	indexes := make([]int64, len(values))
+	for i, s := range values {
+		id, ok := ids[s]
+		if !ok {
+			id = int64(len(dict))
+			ids[s] = id
+			dict = append(dict, s)
+		}
+		indexes[i] = id
+	}
+
+	dictionary := []byte{byte(encDictionary)}
+	dictionary = binary.AppendUvarint(dictionary, uint64(len(dict)))
+	for _, s := range dict {
+		dictionary = appendString(dictionary, s)
+	}
+	dictionary = append(dictionary, encodeInts(indexes)...)
+
+	if len(dictionary) < len(plain) {
+		return dictionary
+	}
+	return plain
+}
+
+func appendString(out []byte, s string) []byte {
+	out = binary.AppendUvarint(out, uint64(len(s)))
+	return append(out, s...)
+}
+
+func readString(data []byte) (string, []byte, error) {
+	length, size := binary.Uvarint(data)
+	if size <= 0 || uint64(len(data)-size) < length {
+		return "", nil, fmt.Errorf("string block is truncated")
+	}
+
+	end := size + int(length)
+	return string(data[size:end]), data[end:], nil
+}
+
+func decodeStrings(data []byte, n int) ([]string, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty string block")
+	}
+	enc, data := encoding(data[0]), data[1:]
+	out := make([]string, n)
+
+	switch enc {
+	case encPlain:
+		for i := range out {
+			var err error
+			if out[i], data, err = readString(data); err != nil {
+				return nil, err
+			}
+		}
+
+	case encDictionary:
+		size, read := binary.Uvarint(data)
+		if read <= 0 || size > uint64(len(data)) {
+			return nil, fmt.Errorf("dictionary block is truncated")
+		}
+		data = data[read:]
+		dict := make([]string, size)
+		for i := range dict {
+			var err error
+			if dict[i], data, err = readString(data); err != nil {
+				return nil, err
+			}
+		}
+
+		indexes, err := decodeInts(data, n)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range indexes {
+			if id < 0 || id >= int64(len(dict)) {
+				return nil, fmt.Errorf("dictionary index %d out of range", id)
+			}
+			out[i] = dict[id]
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown string encoding %s", enc)
+	}
+
+	return out, nil
+}
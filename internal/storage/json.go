@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+func init() {
+	Register("json", openJSON)
+}
+
+// a JSON array of objects in the table's data file, read whole on every scan
+type jsonSource struct {
+	table *catalog.TableInfo
+
+	// index lookups use a hash of the rows, rebuilt when the file changes
+	modTime time.Time
+	rows    []Row
+	indexes map[string]map[string][]Row
+}
+
+func openJSON(table *catalog.TableInfo) (TableSource, error) {
+	if table.DataFile == "" {
+		return nil, fmt.Errorf("table %s has no data file", table.Name)
+	}
+
+	return &jsonSource{table: table}, nil
+}
+
+func (s *jsonSource) Scan(opts ScanOptions) (RowIterator, error) {
+	rows, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSliceIterator(rows, opts), nil
+}
+
+func (s *jsonSource) Statistics(sample int) (*catalog.Statistics, error) {
+	rows, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return StatisticsOf(s.table, rows, sample), nil
+}
+
+func (s *jsonSource) IndexLookup(index catalog.Index, key []interface{}) (RowIterator, error) {
+	if len(key) != len(index.Columns) {
+		return nil, fmt.Errorf("index %s has %d columns, got %d values", index.Name, len(index.Columns), len(key))
+	}
+	if _, err := s.read(); err != nil {
+		return nil, err
+	}
+
+	hash, ok := s.indexes[indexID(index)]
+	if !ok {
+		hash = hashIndex(s.rows, index)
+		s.indexes[indexID(index)] = hash
+	}
+
+	return NewSliceIterator(hash[IndexKey(key)], ScanOptions{}), nil
+}
+
+func (s *jsonSource) Insert(rows []Row) error {
+	if err := checkFlat(s.table); err != nil {
+		return err
+	}
+	existing, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	all := append(append(make([]Row, 0, len(existing)+len(rows)), existing...), rows...)
+	if err := s.write(all); err != nil {
+		return err
+	}
+	addToIndexes(s.indexes, s.table, rows)
+	return nil
+}
+
+func (s *jsonSource) Replace(rows []Row) error {
+	if err := checkFlat(s.table); err != nil {
+		return err
+	}
+	if err := s.write(rows); err != nil {
+		return err
+	}
+
+	s.indexes = rebuildIndexes(s.indexes, s.table, rows)
+	return nil
+}
+
+// writes the data file and keeps rows as the cached contents
+func (s *jsonSource) write(rows []Row) error {
+	err := writeFileAtomic(s.table.DataFile, func(w io.Writer) error {
+		return writeJSONRows(w, rows)
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(s.table.DataFile)
+	if err != nil {
+		return err
+	}
+	if s.indexes == nil {
+		s.indexes = make(map[string]map[string][]Row)
+	}
+	s.rows, s.modTime = rows, info.ModTime()
+	return nil
+}
+
+// key of an index's hash, indexes can be dropped and created again with
+// the same name on other columns
+func indexID(index catalog.Index) string {
+	return index.Name + "(" + strings.Join(index.Columns, ",") + ")"
+}
+
+// rows by the IndexKey of their values in the index's columns
+func hashIndex(rows []Row, index catalog.Index) map[string][]Row {
+	hash := make(map[string][]Row)
+	for _, row := range rows {
+		values := make([]interface{}, len(index.Columns))
+		for i, col := range index.Columns {
+			values[i] = row[col]
+		}
+		k := IndexKey(values)
+		hash[k] = append(hash[k], row)
+	}
+
+	return hash
+}
+
+// rows of the data file, cached until the file's modification time changes
+func (s *jsonSource) read() ([]Row, error) {
+	info, err := os.Stat(s.table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+	if s.rows != nil && info.ModTime().Equal(s.modTime) {
+		return s.rows, nil
+	}
+
+	data, err := os.ReadFile(s.table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	// numbers are kept as text so decimals do not lose digits
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var docs []map[string]interface{}
+	if err := decoder.Decode(&docs); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	rows := make([]Row, len(docs))
+	for i, doc := range docs {
+		if rows[i], err = DecodeDocument(s.table, doc); err != nil {
+			return nil, err
+		}
+	}
+
+	s.rows, s.modTime = rows, info.ModTime()
+	s.indexes = make(map[string]map[string][]Row)
+	return rows, nil
+}
+
+// row of a decoded JSON document. Columns are read from their path and
+// converted to the column type, other top level keys are kept as they are
+func DecodeDocument(table *catalog.TableInfo, doc map[string]interface{}) (Row, error) {
+	values := make([]interface{}, len(table.Columns))
+	found := make([]bool, len(table.Columns))
+	for i, col := range table.Columns {
+		path, err := col.Source()
+		if err != nil {
+			return nil, err
+		}
+		values[i], found[i] = path.Lookup(doc)
+	}
+
+	row := make(Row, len(doc))
+	for k, v := range doc {
+		row[k] = types.NormalizeJSON(v)
+	}
+	for i, col := range table.Columns {
+		if !found[i] {
+			// nested paths can be missing in some documents
+			if col.Path != "" {
+				row[col.Name] = nil
+			}
+			continue
+		}
+
+		val, err := DecodeValue(values[i], col)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		row[col.Name] = val
+	}
+
+	return row, nil
+}
+
+// stored value as the column type, numbers stay float64 unless the column
+// is DECIMAL and temporal columns are stored as strings. JSON values keep
+// their nested objects and arrays
+func DecodeValue(v interface{}, col catalog.Column) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch col.Type {
+	case catalog.DecimalType:
+		if n, ok := v.(json.Number); ok {
+			return types.ParseDecimal(n.String())
+		}
+	case catalog.DateType, catalog.TimestampType, catalog.IntervalType:
+		if _, ok := v.(string); ok {
+			return function.CastTo(v, col.Type, col.Precision, col.Scale)
+		}
+	}
+
+	return types.NormalizeJSON(v), nil
+}
+
+// hash key of index column values, numbers of any type with the same value
+// share a key
+func IndexKey(values []interface{}) string {
+	var b bytes.Buffer
+	for _, v := range values {
+		switch x := v.(type) {
+		case int:
+			v = float64(x)
+		case types.Decimal:
+			v = x.Float64()
+		}
+		fmt.Fprintf(&b, "%T:%s|", v, function.FormatValue(v))
+	}
+
+	return b.String()
+}
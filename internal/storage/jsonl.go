@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+)
+
+func init() {
+	Register("jsonl", openJSONL)
+}
+
+// one JSON object per line, read a line at a time. Blank lines are skipped
+type jsonlSource struct {
+	table *catalog.TableInfo
+}
+
+func openJSONL(table *catalog.TableInfo) (TableSource, error) {
+	if table.DataFile == "" {
+		return nil, fmt.Errorf("table %s has no data file", table.Name)
+	}
+	for key := range table.Options {
+		return nil, fmt.Errorf("table %s: unknown option %q", table.Name, key)
+	}
+
+	return &jsonlSource{table: table}, nil
+}
+
+func (s *jsonlSource) Scan(opts ScanOptions) (RowIterator, error) {
+	file, err := os.Open(s.table.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	return &jsonlIterator{table: s.table, file: file, scanner: scanner, opts: opts}, nil
+}
+
+func (s *jsonlSource) Statistics(sample int) (*catalog.Statistics, error) {
+	it, err := s.Scan(ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+
+	return StatisticsOf(s.table, rows, sample), nil
+}
+
+func (s *jsonlSource) Insert(rows []Row) error {
+	if err := checkFlat(s.table); err != nil {
+		return err
+	}
+
+	return appendLines(s.table.DataFile, func(w io.Writer) error {
+		return writeJSONLines(w, rows)
+	})
+}
+
+func (s *jsonlSource) Replace(rows []Row) error {
+	if err := checkFlat(s.table); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.table.DataFile, func(w io.Writer) error {
+		return writeJSONLines(w, rows)
+	})
+}
+
+func writeJSONLines(w io.Writer, rows []Row) error {
+	for i, row := range rows {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// longest line a JSONL file may have
+const maxLineSize = 16 << 20
+
+type jsonlIterator struct {
+	table   *catalog.TableInfo
+	file    *os.File
+	scanner *bufio.Scanner
+	opts    ScanOptions
+	line    int
+}
+
+func (it *jsonlIterator) Next() (Row, error) {
+	for it.scanner.Scan() {
+		it.line++
+		text := bytes.TrimSpace(it.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", it.table.DataFile, it.line, err)
+		}
+		row, err := DecodeDocument(it.table, doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", it.table.DataFile, it.line, err)
+		}
+
+		if MatchesAll(it.opts.Predicates, row) {
+			return Project(row, it.opts.Columns), nil
+		}
+	}
+
+	if err := it.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", it.table.DataFile, it.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+func (it *jsonlIterator) Close() error {
+	return it.file.Close()
+}
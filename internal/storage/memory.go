@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+func init() {
+	Register("memory", openMemory)
+}
+
+// rows of tables registered from Go values, by catalog entry
+var memory = struct {
+	sync.RWMutex
+	tables map[*catalog.TableInfo]*memoryTable
+}{tables: make(map[*catalog.TableInfo]*memoryTable)}
+
+type memoryTable struct {
+	rows    []Row
+	indexes map[string]map[string][]Row // built on first lookup
+}
+
+// Registers a Go slice as a table of the catalog, replacing an earlier
+// table of Go values with the same name. The slice holds structs, pointers
+// to structs or map[string]any values.
+//
+// Struct fields become columns named in snake case, or as their sql tag
+// says. The tag may add options after the name:
+//
+//	ID    int       `sql:"id,pk"`
+//	Email string    `sql:",notnull"`
+//	Paid  string    `sql:"paid,type=DECIMAL(10,2)"`
+//	Notes []string  // a JSON column
+//	Debug bool      `sql:"-"` // left out
+//
+// Embedded structs add their fields, pointer fields may be NULL. Columns of
+// maps are their keys in sorted order, typed by their values. Statistics
+// are computed right away, so the optimizer plans with them
+func RegisterRows(cat *catalog.Catalog, name string, data interface{}) (*catalog.TableInfo, error) {
+	if old, err := cat.GetTable(name); err == nil && !old.Temporary {
+		return nil, fmt.Errorf("table %s already exists", name)
+	}
+
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("table %s: expected a slice, got %T", name, data)
+	}
+
+	table := &catalog.TableInfo{Name: name, Source: "memory", Temporary: true}
+	var rows []Row
+	var err error
+
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	switch {
+	case elem.Kind() == reflect.Struct:
+		rows, err = structRows(table, v, elem)
+	case elem.Kind() == reflect.Map && elem.Key().Kind() == reflect.String:
+		rows, err = mapRows(table, v)
+	default:
+		err = fmt.Errorf("expected structs or maps with string keys, got %s", v.Type().Elem())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", name, err)
+	}
+
+	table.Statistics = StatisticsOf(table, rows, 0)
+
+	memory.Lock()
+	if old, err := cat.GetTable(name); err == nil {
+		delete(memory.tables, old)
+	}
+	memory.tables[table] = &memoryTable{rows: rows}
+	memory.Unlock()
+
+	cat.RegisterTable(table)
+	return table, nil
+}
+
+// column read from a struct field
+type memoryField struct {
+	index  []int
+	column catalog.Column
+}
+
+func structRows(table *catalog.TableInfo, v reflect.Value, elem reflect.Type) ([]Row, error) {
+	fields, err := structFields(table, elem, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		table.Columns = append(table.Columns, f.column)
+	}
+
+	rows := make([]Row, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for item.Kind() == reflect.Pointer {
+			if item.IsNil() {
+				return nil, fmt.Errorf("row %d is nil", i)
+			}
+			item = item.Elem()
+		}
+
+		row := make(Row, len(fields))
+		for _, f := range fields {
+			val, err := columnValue(item.FieldByIndex(f.index), f.column)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", i, err)
+			}
+			row[f.column.Name] = val
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// columns of the exported fields of t, embedded structs flattened
+func structFields(table *catalog.TableInfo, t reflect.Type, parent []int) ([]memoryField, error) {
+	var fields []memoryField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag := sf.Tag.Get("sql")
+		if tag == "-" {
+			continue
+		}
+		// like encoding/json, exported fields of unexported embedded
+		// structs count too
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && tag == "" {
+			embedded, err := structFields(table, sf.Type, index)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		parts := splitTag(tag)
+		col := catalog.Column{Name: parts[0]}
+		if col.Name == "" {
+			col.Name = snakeCase(sf.Name)
+		}
+
+		var err error
+		if col.Type, err = goType(sf.Type); err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		for _, opt := range parts[1:] {
+			switch {
+			case opt == "notnull":
+				col.NotNull = true
+			case opt == "pk":
+				table.PrimaryKey = append(table.PrimaryKey, col.Name)
+			case strings.HasPrefix(opt, "type="):
+				if col.Type, col.Precision, col.Scale, err = catalog.ParseTypeName(strings.TrimPrefix(opt, "type=")); err != nil {
+					return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+				}
+			default:
+				return nil, fmt.Errorf("field %s: unknown tag option %q", sf.Name, opt)
+			}
+		}
+
+		fields = append(fields, memoryField{index: index, column: col})
+	}
+
+	return fields, nil
+}
+
+// tag parts split at commas outside parentheses, DECIMAL(10,2) stays whole
+func splitTag(tag string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range tag {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(tag[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, strings.TrimSpace(tag[start:]))
+}
+
+func mapRows(table *catalog.TableInfo, v reflect.Value) ([]Row, error) {
+	raw := make([]Row, v.Len())
+	kinds := make(map[string]catalog.DataType)
+	for i := range raw {
+		item := v.Index(i)
+		for item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		if !item.IsValid() {
+			return nil, fmt.Errorf("row %d is nil", i)
+		}
+
+		raw[i] = make(Row, item.Len())
+		iter := item.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			val, err := goValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %w", i, key, err)
+			}
+			raw[i][key] = val
+
+			t, err := valueType(val)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %w", i, key, err)
+			}
+			prev, seen := kinds[key]
+			switch {
+			case !seen || prev == catalog.NullType:
+				kinds[key] = t
+			case t == catalog.NullType || t == prev:
+			case prev.IsNumeric() && t.IsNumeric():
+				kinds[key] = catalog.FloatType
+			default:
+				return nil, fmt.Errorf("column %s has both %s and %s values", key, prev, t)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := kinds[name]
+		if t == catalog.NullType {
+			t = catalog.StringType // only NULLs seen
+		}
+		table.Columns = append(table.Columns, catalog.Column{Name: name, Type: t})
+	}
+
+	rows := make([]Row, len(raw))
+	for i, r := range raw {
+		rows[i] = make(Row, len(table.Columns))
+		for _, col := range table.Columns {
+			val, err := function.CastTo(r[col.Name], col.Type, col.Precision, col.Scale)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %w", i, col.Name, err)
+			}
+			rows[i][col.Name] = val
+		}
+	}
+
+	return rows, nil
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	decimalType   = reflect.TypeOf(types.Decimal{})
+	dateType      = reflect.TypeOf(types.Date{})
+	timestampType = reflect.TypeOf(types.Timestamp{})
+	intervalType  = reflect.TypeOf(types.Interval{})
+)
+
+// column type of a Go type, anything without a SQL counterpart is JSON
+func goType(t reflect.Type) (catalog.DataType, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, timestampType:
+		return catalog.TimestampType, nil
+	case durationType, intervalType:
+		return catalog.IntervalType, nil
+	case decimalType:
+		return catalog.DecimalType, nil
+	case dateType:
+		return catalog.DateType, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return catalog.IntType, nil
+	case reflect.Float32, reflect.Float64:
+		return catalog.FloatType, nil
+	case reflect.Bool:
+		return catalog.BoolType, nil
+	case reflect.String:
+		return catalog.StringType, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return catalog.StringType, nil
+		}
+		return catalog.JSONType, nil
+	case reflect.Map, reflect.Array, reflect.Struct, reflect.Interface:
+		return catalog.JSONType, nil
+	}
+
+	return catalog.NullType, fmt.Errorf("unsupported type %s", t)
+}
+
+// column type of a value goValue returned, NullType for nil
+func valueType(v interface{}) (catalog.DataType, error) {
+	switch v.(type) {
+	case nil:
+		return catalog.NullType, nil
+	case map[string]interface{}, []interface{}:
+		return catalog.JSONType, nil
+	}
+
+	return goType(reflect.TypeOf(v))
+}
+
+func columnValue(v reflect.Value, col catalog.Column) (interface{}, error) {
+	val, err := goValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	if col.Type == catalog.JSONType {
+		return val, nil
+	}
+
+	val, err = function.CastTo(val, col.Type, col.Precision, col.Scale)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	return val, nil
+}
+
+// engine value of a Go value: ints, float64, bools, strings, the types
+// package values, and JSON values for everything else
+func goValue(v reflect.Value) (interface{}, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return types.NewTimestamp(x), nil
+	case time.Duration:
+		return types.Interval{Micros: x.Microseconds()}, nil
+	case types.Decimal, types.Date, types.Timestamp, types.Interval:
+		return x, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+
+	// JSON through its encoding, so struct tags and marshalers apply
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseJSON(string(data))
+}
+
+// UserID becomes user_id and HTTPStatus http_status
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+func openMemory(table *catalog.TableInfo) (TableSource, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	if _, ok := memory.tables[table]; !ok {
+		return nil, fmt.Errorf("table %s has no rows in memory", table.Name)
+	}
+	return &memorySource{table: table}, nil
+}
+
+// drops the rows of a temporary table
+func forgetRows(table *catalog.TableInfo) {
+	memory.Lock()
+	defer memory.Unlock()
+
+	delete(memory.tables, table)
+}
+
+// table of Go values registered with RegisterRows
+type memorySource struct {
+	table *catalog.TableInfo
+}
+
+func (s *memorySource) data() (*memoryTable, error) {
+	memory.RLock()
+	defer memory.RUnlock()
+
+	data, ok := memory.tables[s.table]
+	if !ok {
+		return nil, fmt.Errorf("table %s has no rows in memory", s.table.Name)
+	}
+	return data, nil
+}
+
+func (s *memorySource) Scan(opts ScanOptions) (RowIterator, error) {
+	data, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSliceIterator(data.rows, opts), nil
+}
+
+func (s *memorySource) Statistics(sample int) (*catalog.Statistics, error) {
+	data, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+
+	return StatisticsOf(s.table, data.rows, sample), nil
+}
+
+func (s *memorySource) IndexLookup(index catalog.Index, key []interface{}) (RowIterator, error) {
+	if len(key) != len(index.Columns) {
+		return nil, fmt.Errorf("index %s has %d columns, got %d values", index.Name, len(index.Columns), len(key))
+	}
+	data, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+
+	memory.Lock()
+	if data.indexes == nil {
+		data.indexes = make(map[string]map[string][]Row)
+	}
+	hash, ok := data.indexes[indexID(index)]
+	if !ok {
+		hash = hashIndex(data.rows, index)
+		data.indexes[indexID(index)] = hash
+	}
+	memory.Unlock()
+
+	return NewSliceIterator(hash[IndexKey(key)], ScanOptions{}), nil
+}
+
+func (s *memorySource) Insert(rows []Row) error {
+	data, err := s.data()
+	if err != nil {
+		return err
+	}
+
+	memory.Lock()
+	defer memory.Unlock()
+	data.rows = append(data.rows[:len(data.rows):len(data.rows)], rows...)
+	addToIndexes(data.indexes, s.table, rows)
+	return nil
+}
+
+func (s *memorySource) Replace(rows []Row) error {
+	data, err := s.data()
+	if err != nil {
+		return err
+	}
+
+	memory.Lock()
+	defer memory.Unlock()
+	data.rows = rows
+	data.indexes = rebuildIndexes(data.indexes, s.table, rows)
+	return nil
+}
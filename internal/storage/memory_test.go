@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+type audit struct {
+	CreatedAt time.Time
+}
+
+type account struct {
+	ID      int `sql:"id,pk"`
+	Email   string
+	Balance string `sql:"balance,type=DECIMAL(10,2)"`
+	Score   *float64
+	Tags    []string
+	secret  string
+	Debug   bool `sql:"-"`
+	audit
+}
+
+func TestRegisterStructRows(t *testing.T) {
+	score := 4.5
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	accounts := []*account{
+		{ID: 1, Email: "a@example.com", Balance: "10.5", Score: &score, Tags: []string{"vip"}, audit: audit{created}},
+		{ID: 2, Email: "b@example.com", Balance: "3", secret: "x"},
+	}
+
+	cat := catalog.NewCatalog()
+	table, err := RegisterRows(cat, "accounts", accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id INT, email STRING, balance DECIMAL(10,2), score FLOAT, tags JSON, created_at TIMESTAMP"
+	got := ""
+	for i, col := range table.Columns {
+		if i > 0 {
+			got += ", "
+		}
+		got += col.Name + " " + col.TypeName()
+	}
+	if got != want {
+		t.Fatalf("expected columns %s, got %s", want, got)
+	}
+	if len(table.PrimaryKey) != 1 || table.PrimaryKey[0] != "id" {
+		t.Errorf("expected primary key id, got %v", table.PrimaryKey)
+	}
+	if table.Statistics == nil || table.Statistics.RowCount != 2 || table.Statistics.NullCount["score"] != 1 {
+		t.Errorf("unexpected statistics %+v", table.Statistics)
+	}
+
+	rows, err := ReadTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := rows[0]["balance"].(types.Decimal); !ok || d.String() != "10.50" {
+		t.Errorf("expected balance 10.50, got %v", rows[0]["balance"])
+	}
+	if tags, ok := rows[0]["tags"].([]interface{}); !ok || len(tags) != 1 || tags[0] != "vip" {
+		t.Errorf("expected tags [vip], got %v", rows[0]["tags"])
+	}
+	if rows[1]["score"] != nil || rows[1]["tags"] != nil {
+		t.Errorf("expected NULL score and tags, got %v", rows[1])
+	}
+}
+
+func TestRegisterMapRows(t *testing.T) {
+	cat := catalog.NewCatalog()
+	table, err := RegisterRows(cat, "events", []map[string]any{
+		{"kind": "click", "n": 1},
+		{"kind": "view", "n": 2.5, "extra": map[string]any{"x": 1}},
+		{"kind": nil, "n": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[string]catalog.DataType{}
+	for _, col := range table.Columns {
+		kinds[col.Name] = col.Type
+	}
+	if len(table.Columns) != 3 || table.Columns[0].Name != "extra" || kinds["n"] != catalog.FloatType ||
+		kinds["kind"] != catalog.StringType || kinds["extra"] != catalog.JSONType {
+		t.Fatalf("unexpected columns %+v", table.Columns)
+	}
+
+	if _, err := RegisterRows(cat, "bad", []map[string]any{{"a": 1}, {"a": "x"}}); err == nil {
+		t.Fatal("expected mixed value types to be rejected")
+	}
+}
+
+func TestTemporaryTablesNotSaved(t *testing.T) {
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{Name: "users", Columns: []catalog.Column{{Name: "id", Type: catalog.IntType}}})
+	if _, err := RegisterRows(cat, "events", []struct{ ID int }{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RegisterRows(cat, "users", []struct{ ID int }{{1}}); err == nil {
+		t.Fatal("expected a catalog table not to be replaced")
+	}
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	if err := cat.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	saved := catalog.NewCatalog()
+	if err := saved.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Tables()) != 1 {
+		t.Fatalf("expected only users to be saved, got %d tables", len(saved.Tables()))
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
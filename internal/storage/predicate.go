@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+)
+
+// simple conjunct a source can check on its own: column op value, or
+// column IS [NOT] NULL with no value
+type Predicate struct {
+	Column string
+	Op     string // =, !=, <, <=, >, >=, LIKE, IS NULL, IS NOT NULL
+	Value  interface{}
+}
+
+func (p Predicate) String() string {
+	switch p.Op {
+	case "IS NULL", "IS NOT NULL":
+		return p.Column + " " + p.Op
+	}
+	if s, ok := p.Value.(string); ok {
+		return fmt.Sprintf("%s %s '%s'", p.Column, p.Op, strings.ReplaceAll(s, "'", "''"))
+	}
+
+	return fmt.Sprintf("%s %s %s", p.Column, p.Op, function.FormatValue(p.Value))
+}
+
+// reports whether the row satisfies the predicate, NULL never does except
+// for IS NULL
+func (p Predicate) Matches(row Row) bool {
+	v := row[p.Column]
+
+	switch p.Op {
+	case "IS NULL":
+		return v == nil
+	case "IS NOT NULL":
+		return v != nil
+	}
+
+	ans, err := function.EvalBinary(v, p.Op, p.Value)
+	return err == nil && ans == true
+}
+
+func MatchesAll(preds []Predicate, row Row) bool {
+	for _, p := range preds {
+		if !p.Matches(row) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reports whether op can be pushed to a source
+func IsPredicateOp(op string) bool {
+	switch op {
+	case "=", "!=", "<>", "<", "<=", ">", ">=", "LIKE", "IS NULL", "IS NOT NULL":
+		return true
+	}
+
+	return false
+}
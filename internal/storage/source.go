@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+)
+
+// row as a source produces it, keyed by column name
+type Row = map[string]interface{}
+
+type RowIterator interface {
+	// next row, io.EOF after the last one
+	Next() (Row, error)
+	Close() error
+}
+
+// what a scan has to produce. Sources may return extra columns and rows
+// that fail the predicates, the executor filters again
+type ScanOptions struct {
+	Columns    []string    // nil reads every column
+	Predicates []Predicate // conjuncts every wanted row satisfies
+}
+
+// where the rows of a table come from
+type TableSource interface {
+	Scan(opts ScanOptions) (RowIterator, error)
+
+	// statistics computed from the data, sample > 0 limits how many rows
+	// distinct counts and distributions look at
+	Statistics(sample int) (*catalog.Statistics, error)
+}
+
+// optional, sources that find rows by the values of an index's columns
+// without reading the whole table
+type IndexSource interface {
+	TableSource
+	IndexLookup(index catalog.Index, key []interface{}) (RowIterator, error)
+}
+
+// optional, sources whose rows can be changed. A write is complete when
+// the call returns, a failed one leaves the table as it was
+type WritableSource interface {
+	TableSource
+
+	// adds rows, which hold a value for every column
+	Insert(rows []Row) error
+
+	// replaces every row of the table
+	Replace(rows []Row) error
+}
+
+// optional, writable sources that change single rows. Positions are those
+// of the rows in a full scan, a nil row deletes it
+type RowChanger interface {
+	WritableSource
+	Change(changes map[int]Row) error
+}
+
+// creates the source of a table, one per catalog source kind
+type Opener func(table *catalog.TableInfo) (TableSource, error)
+
+var openers = map[string]Opener{}
+
+// makes a source kind usable from catalog entries, kinds are case insensitive
+func Register(kind string, open Opener) {
+	openers[strings.ToLower(kind)] = open
+}
+
+// registered source kinds, sorted
+func Kinds() []string {
+	kinds := make([]string, 0, len(openers))
+	for kind := range openers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// source for a table, picked by the kind its catalog entry names
+func Open(table *catalog.TableInfo) (TableSource, error) {
+	kind := table.SourceKind()
+	open, ok := openers[strings.ToLower(kind)]
+	if !ok {
+		return nil, fmt.Errorf("table %s: unknown source kind %q", table.Name, kind)
+	}
+
+	return open(table)
+}
+
+// every row of an iterator, which is closed afterwards
+func ReadAll(it RowIterator) ([]Row, error) {
+	defer it.Close()
+
+	var rows []Row
+	for {
+		row, err := it.Next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// every row of a table with all of its columns
+func ReadTable(table *catalog.TableInfo) ([]Row, error) {
+	source, err := Open(table)
+	if err != nil {
+		return nil, err
+	}
+	it, err := source.Scan(ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadAll(it)
+}
+
+// statistics of rows a source has already read, for sources without a
+// cheaper way to compute them
+func StatisticsOf(table *catalog.TableInfo, rows []Row, sample int) *catalog.Statistics {
+	return catalog.ComputeStatistics(table, rows, sample)
+}
+
+func init() {
+	// ANALYZE and constraint checks read data through the sources too
+	catalog.SetRowReader(func(table *catalog.TableInfo) ([]map[string]interface{}, error) {
+		return ReadTable(table)
+	})
+}
+
+// iterates rows already in memory, applying the scan options
+type sliceIterator struct {
+	rows []Row
+	opts ScanOptions
+	idx  int
+}
+
+// iterator over rows in memory that keeps only rows matching the
+// predicates and only the projected columns
+func NewSliceIterator(rows []Row, opts ScanOptions) RowIterator {
+	return &sliceIterator{rows: rows, opts: opts}
+}
+
+func (s *sliceIterator) Next() (Row, error) {
+	for s.idx < len(s.rows) {
+		row := s.rows[s.idx]
+		s.idx++
+
+		if MatchesAll(s.opts.Predicates, row) {
+			return Project(row, s.opts.Columns), nil
+		}
+	}
+
+	return nil, io.EOF
+}
+
+func (s *sliceIterator) Close() error { return nil }
+
+// copy of row with only cols, all of it when cols is nil
+func Project(row Row, cols []string) Row {
+	out := make(Row, len(row))
+	if cols == nil {
+		for k, v := range row {
+			out[k] = v
+		}
+		return out
+	}
+
+	for _, col := range cols {
+		if v, ok := row[col]; ok {
+			out[col] = v
+		}
+	}
+	return out
+}
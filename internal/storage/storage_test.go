@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/function"
+	"github.com/Adit0507/sql-query-optimizer/internal/types"
+)
+
+func jsonTable(t *testing.T) *catalog.TableInfo {
+	t.Helper()
+
+	dataFile := filepath.Join(t.TempDir(), "orders.json")
+	data := `[
+  {"id": 1, "user_id": 1, "amount": 100, "status": "delivered"},
+  {"id": 2, "user_id": 1, "amount": 50, "status": null},
+  {"id": 3, "user_id": 2, "amount": 75, "status": "delivered"}
+]`
+	if err := os.WriteFile(dataFile, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &catalog.TableInfo{
+		Name: "orders",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "user_id", Type: catalog.IntType},
+			{Name: "amount", Type: catalog.IntType},
+			{Name: "status", Type: catalog.StringType},
+		},
+		Indexes:  []catalog.Index{{Name: "idx_user_id", Columns: []string{"user_id"}}},
+		DataFile: dataFile,
+	}
+}
+
+func TestJSONSourceScan(t *testing.T) {
+	source, err := Open(jsonTable(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := source.Scan(ScanOptions{
+		Columns:    []string{"id", "amount"},
+		Predicates: []Predicate{{Column: "amount", Op: ">=", Value: 75}, {Column: "status", Op: "IS NOT NULL"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if len(rows[0]) != 2 || rows[0]["id"] != 1.0 {
+		t.Fatalf("expected only id and amount of row 1, got %v", rows[0])
+	}
+}
+
+func TestJSONSourceIndexLookup(t *testing.T) {
+	table := jsonTable(t)
+	source, err := Open(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexed, ok := source.(IndexSource)
+	if !ok {
+		t.Fatal("expected the JSON source to support index lookups")
+	}
+	it, err := indexed.IndexLookup(table.Indexes[0], []interface{}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows for user 1, got %d", len(rows))
+	}
+
+	stats, err := source.Statistics(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RowCount != 3 || stats.NullCount["status"] != 1 {
+		t.Fatalf("unexpected statistics %+v", stats)
+	}
+}
+
+func TestUnknownSourceKind(t *testing.T) {
+	table := jsonTable(t)
+	table.Source = "parquet"
+
+	if _, err := Open(table); err == nil {
+		t.Fatal("expected an unknown source kind to be rejected")
+	}
+}
+
+func dataTable(t *testing.T, kind, name, data string, options map[string]string) *catalog.TableInfo {
+	t.Helper()
+
+	dataFile := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(dataFile, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &catalog.TableInfo{
+		Name: "payments",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "amount", Type: catalog.DecimalType, Precision: 10, Scale: 2},
+			{Name: "paid_on", Type: catalog.DateType},
+			{Name: "note", Type: catalog.StringType},
+		},
+		DataFile: dataFile,
+		Source:   kind,
+		Options:  options,
+	}
+}
+
+func TestCSVSourceTypedParsing(t *testing.T) {
+	data := "note,id,paid_on,amount\r\n" +
+		"\"first, with comma\",1,2024-01-15,10.5\r\n" +
+		"\"say \"\"hi\"\"\",2,2024-02-01,\r\n" +
+		"\"\",3,2024-03-01,7\r\n"
+	source, err := Open(dataTable(t, "csv", "payments.csv", data, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := source.Scan(ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if rows[0]["id"] != 1 || rows[0]["note"] != "first, with comma" {
+		t.Errorf("unexpected first row %v", rows[0])
+	}
+	if d, ok := rows[0]["amount"].(types.Decimal); !ok || d.String() != "10.50" {
+		t.Errorf("expected amount 10.50, got %v", rows[0]["amount"])
+	}
+	if d, ok := rows[0]["paid_on"].(types.Date); !ok || d.String() != "2024-01-15" {
+		t.Errorf("expected date 2024-01-15, got %v", rows[0]["paid_on"])
+	}
+	if rows[1]["note"] != `say "hi"` || rows[1]["amount"] != nil {
+		t.Errorf("expected escaped quotes and a NULL amount, got %v", rows[1])
+	}
+	if rows[2]["note"] != "" {
+		t.Errorf("expected a quoted empty field to be an empty string, got %v", rows[2]["note"])
+	}
+}
+
+func TestCSVSourceOptions(t *testing.T) {
+	data := "1;2.00;2024-01-15;'a;b'\n\n2;3.25;2024-01-16;NA\n"
+	source, err := Open(dataTable(t, "csv", "payments.csv", data, map[string]string{
+		"header": "false", "delimiter": ";", "quote": "'", "null": "NA",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := source.Scan(ScanOptions{
+		Columns:    []string{"id", "note"},
+		Predicates: []Predicate{{Column: "note", Op: "IS NULL"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadAll(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0]["id"] != 2 || len(rows[0]) != 2 {
+		t.Fatalf("expected only id and note of row 2, got %v", rows)
+	}
+
+	table := dataTable(t, "csv", "payments.csv", data, map[string]string{"delimiter": ";;"})
+	if _, err := Open(table); err == nil {
+		t.Fatal("expected an error for a multi-character delimiter")
+	}
+}
+
+func TestCSVSourceReportsBadValues(t *testing.T) {
+	data := "id,amount,paid_on,note\n1,2.00,2024-01-15,ok\n2,abc,2024-01-16,bad\n"
+	source, err := Open(dataTable(t, "csv", "payments.csv", data, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := source.Scan(ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	row, err := it.Next()
+	if err != nil || row["id"] != 1 {
+		t.Fatalf("expected the first row before the bad one, got %v, %v", row, err)
+	}
+	if _, err := it.Next(); err == nil || !strings.Contains(err.Error(), ":3: column amount") {
+		t.Fatalf("expected an error naming line 3 and column amount, got %v", err)
+	}
+}
+
+func TestJSONLSourceStreams(t *testing.T) {
+	data := `{"id": 1, "amount": 10.5, "paid_on": "2024-01-15", "note": "a"}
+
+{"id": 2, "amount": 20, "paid_on": "2024-01-16"}
+{"id": 3, "amount": oops}
+`
+	source, err := Open(dataTable(t, "jsonl", "payments.jsonl", data, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := source.Scan(ScanOptions{Predicates: []Predicate{{Column: "id", Op: ">", Value: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	row, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row["id"] != 2.0 || row["note"] != nil {
+		t.Fatalf("expected row 2 with a NULL note, got %v", row)
+	}
+	if d, ok := row["amount"].(types.Decimal); !ok || d.String() != "20" {
+		t.Errorf("expected a decimal amount, got %#v", row["amount"])
+	}
+	if _, err := it.Next(); err == nil || !strings.Contains(err.Error(), ":4:") {
+		t.Fatalf("expected an error on line 4, got %v", err)
+	}
+}
+
+func TestWritableSources(t *testing.T) {
+	kinds := map[string]string{"json": "[]", "jsonl": "", "csv": "id,amount,paid_on,note\n", "columnar": ""}
+	for kind, empty := range kinds {
+		table := dataTable(t, kind, "payments."+kind, empty, nil)
+		if kind == "columnar" {
+			if err := writeColumnarFile(table); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		source, err := Open(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writable, ok := source.(WritableSource)
+		if !ok {
+			t.Fatalf("%s: expected a writable source", kind)
+		}
+
+		rows := []Row{
+			{"id": 1, "amount": types.NewDecimal(1050, 2), "paid_on": types.NewDate(2024, 1, 15), "note": "a, \"b\""},
+			{"id": 2, "amount": nil, "paid_on": types.NewDate(2024, 1, 16), "note": ""},
+		}
+		if err := writable.Insert(rows[:1]); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if err := writable.Insert(rows[1:]); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+
+		got, err := ReadTable(table)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if len(got) != 2 || got[0]["note"] != `a, "b"` || got[1]["amount"] != nil || got[1]["note"] != "" {
+			t.Fatalf("%s: unexpected rows after inserts %v", kind, got)
+		}
+		if d, ok := got[0]["amount"].(types.Decimal); !ok || d.String() != "10.50" {
+			t.Errorf("%s: expected amount 10.50, got %v", kind, got[0]["amount"])
+		}
+
+		if err := writable.Replace(got[1:]); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if got, err = ReadTable(table); err != nil || len(got) != 1 || function.FormatValue(got[0]["id"]) != "2" {
+			t.Fatalf("%s: unexpected rows after replace %v, %v", kind, got, err)
+		}
+	}
+}
+
+func writeColumnarFile(table *catalog.TableInfo) error {
+	file, err := os.Create(table.DataFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := NewColumnarWriter(file, table.Columns, 0)
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func TestWritesKeepIndexesInSync(t *testing.T) {
+	table := jsonTable(t)
+	source, err := Open(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := source.(IndexSource)
+	lookup := func(user int) int {
+		it, err := indexed.IndexLookup(table.Indexes[0], []interface{}{user})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := ReadAll(it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	if n := lookup(1); n != 2 {
+		t.Fatalf("expected 2 rows for user 1, got %d", n)
+	}
+	writable := source.(WritableSource)
+	if err := writable.Insert([]Row{{"id": 4, "user_id": 1, "amount": 5, "status": "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := lookup(1); n != 3 {
+		t.Fatalf("expected the inserted row to be indexed, got %d rows", n)
+	}
+	if err := writable.Replace([]Row{{"id": 3, "user_id": 2, "amount": 75, "status": "delivered"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := lookup(1); n != 0 {
+		t.Fatalf("expected no rows for user 1 after the replace, got %d", n)
+	}
+
+	table.Columns[3].Path = "details.status"
+	if err := writable.Insert(nil); err == nil {
+		t.Fatal("expected tables with path columns to be read only")
+	}
+}
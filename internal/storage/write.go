@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+)
+
+// writes path through a temp file renamed over it, so readers see the old
+// or the new contents and never a partial write
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buffered := bufio.NewWriter(tmp)
+	if err := write(buffered); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+
+	return nil
+}
+
+// appends to path, starting on a new line when the file doesn't end
+// with one
+func appendLines(path string, write func(w io.Writer) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(file)
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			buffered.WriteByte('\n')
+		}
+	}
+
+	if err := write(buffered); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	return file.Sync()
+}
+
+// rows as a JSON array with one object per line, like the data files
+func writeJSONRows(w io.Writer, rows []Row) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		sep := ",\n  "
+		if i == 0 {
+			sep = "\n  "
+		}
+		if _, err := io.WriteString(w, sep+string(data)); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "\n]\n")
+	return err
+}
+
+// document sources write rows back as flat objects, which would lose
+// where columns mapped to nested paths came from
+func checkFlat(table *catalog.TableInfo) error {
+	for _, col := range table.Columns {
+		if col.Path != "" {
+			return fmt.Errorf("table %s is read only, column %s has a path", table.Name, col.Name)
+		}
+	}
+
+	return nil
+}
+
+// adds rows to the hash indexes built so far
+func addToIndexes(indexes map[string]map[string][]Row, table *catalog.TableInfo, rows []Row) {
+	for _, index := range table.Indexes {
+		hash, ok := indexes[indexID(index)]
+		if !ok {
+			continue
+		}
+		for k, matched := range hashIndex(rows, index) {
+			hash[k] = append(hash[k], matched...)
+		}
+	}
+}
+
+// hash indexes built so far rebuilt over rows
+func rebuildIndexes(indexes map[string]map[string][]Row, table *catalog.TableInfo, rows []Row) map[string]map[string][]Row {
+	rebuilt := make(map[string]map[string][]Row)
+	for _, index := range table.Indexes {
+		if _, ok := indexes[indexID(index)]; ok {
+			rebuilt[indexID(index)] = hashIndex(rows, index)
+		}
+	}
+
+	return rebuilt
+}
+
+// file name extension of new data files of a source kind
+func DataExtension(kind string) string {
+	switch kind = strings.ToLower(kind); kind {
+	case "", "json":
+		return "json"
+	case "columnar":
+		return "col"
+	}
+
+	return kind
+}
+
+// writes the empty data of a new table through its source, failing when
+// the data file already exists
+func CreateData(table *catalog.TableInfo) error {
+	if table.DataFile != "" {
+		if _, err := os.Stat(table.DataFile); err == nil {
+			return fmt.Errorf("table %s: data file %s already exists", table.Name, table.DataFile)
+		}
+		if err := os.MkdirAll(filepath.Dir(table.DataFile), 0o755); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+
+	source, err := Open(table)
+	if err != nil {
+		return err
+	}
+	writable, ok := source.(WritableSource)
+	if !ok {
+		return fmt.Errorf("table %s: %s tables can't be written", table.Name, table.SourceKind())
+	}
+
+	return writable.Replace(nil)
+}
+
+// removes the data of a dropped table, the rows of temporary tables and
+// the data file of others
+func DropData(table *catalog.TableInfo) error {
+	if table.Temporary {
+		forgetRows(table)
+		return nil
+	}
+	if table.DataFile == "" {
+		return nil
+	}
+	if err := os.Remove(table.DataFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("table %s: %w", table.Name, err)
+	}
+
+	return nil
+}
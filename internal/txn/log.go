@@ -0,0 +1,262 @@
+package txn
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+	"github.com/Adit0507/sql-query-optimizer/internal/wal"
+)
+
+// log size that triggers a checkpoint after a commit
+const checkpointSize = 4 << 20
+
+// stops commits, checkpoints and recovery at the named point as if the
+// process died there, tests replace it to check what recovery makes of it.
+// A manager that returned its error must not be used again, only reopened
+var crash = func(point string) error { return nil }
+
+// OpenLog makes commits durable through the write-ahead log at path.
+// Commits the log holds are replayed into the tables first, then the log
+// is checkpointed
+func (m *Manager) OpenLog(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.log != nil {
+		return fmt.Errorf("a log is already open")
+	}
+	log, records, err := wal.Open(path)
+	if err != nil {
+		return err
+	}
+	m.log = log
+
+	if err := m.recover(records); err != nil {
+		m.log = nil
+		log.Close()
+		return fmt.Errorf("recovery failed: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint writes the tables changed since the last checkpoint and
+// empties the log
+func (m *Manager) Checkpoint() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.checkpoint()
+}
+
+// tables whose changes go through the log, Go values aren't kept
+func (m *Manager) logged(table *catalog.TableInfo) bool {
+	return m.log != nil && !table.Temporary
+}
+
+// appends a change record for each logged table t wrote and the commit
+// record, then syncs the log. Called with the lock held and t's snapshot
+// moved to the newest commit
+func (t *Txn) logChanges() error {
+	m := t.m
+	id := m.clock + 1
+
+	var records []*wal.Record
+	for table, s := range t.writes {
+		if !m.logged(table) {
+			continue
+		}
+
+		r := &wal.Record{Kind: wal.Change, Txn: id, Table: table.Name}
+		before, after := 0, 0
+		for _, v := range s.versions {
+			if v.creator == nil && v.end == 0 {
+				if v.deleter == t {
+					r.Deleted = append(r.Deleted, before)
+				}
+				before++
+			}
+			if t.sees(v) {
+				if v.creator == t {
+					r.Inserted = append(r.Inserted, wal.Insert{Pos: after, Row: v.row})
+				}
+				after++
+			}
+		}
+		if len(r.Deleted) > 0 || len(r.Inserted) > 0 {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	records = append(records, &wal.Record{Kind: wal.Commit, Txn: id})
+	if err := m.log.Append(records...); err != nil {
+		return err
+	}
+	return m.log.Sync()
+}
+
+// writes every row of the dirty tables to the log and then to the tables,
+// so a crash while writing the tables is repaired by writing them again.
+// Called with the lock held
+func (m *Manager) checkpoint() error {
+	if m.log == nil {
+		return nil
+	}
+
+	images := make(map[string][]map[string]interface{})
+	for table, s := range m.stores {
+		if s.dirty {
+			images[table.Name] = committed(s)
+		}
+	}
+
+	if len(images) > 0 {
+		if err := m.log.Append(&wal.Record{Kind: wal.Checkpoint, Images: images}); err != nil {
+			return err
+		}
+		if err := m.log.Sync(); err != nil {
+			return err
+		}
+		if err := crash("checkpoint logged"); err != nil {
+			return err
+		}
+
+		for table, s := range m.stores {
+			if !s.dirty {
+				continue
+			}
+			if err := s.base.(storage.WritableSource).Replace(committed(s)); err != nil {
+				return fmt.Errorf("checkpoint of table %s: %w", table.Name, err)
+			}
+			if err := crash("checkpoint wrote " + table.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.log.Reset(); err != nil {
+		return err
+	}
+	for _, s := range m.stores {
+		s.dirty = false
+	}
+	m.collect()
+	return nil
+}
+
+// replays the committed changes of records, starting from the tables of
+// the last checkpoint, and checkpoints the result. Changes without a
+// commit record are dropped
+func (m *Manager) recover(records []*wal.Record) error {
+	tables := make(map[*catalog.TableInfo][]storage.Row)
+	var pending []*wal.Record
+
+	for _, r := range records {
+		switch r.Kind {
+		case wal.Checkpoint:
+			for name, docs := range r.Images {
+				table, err := m.catalog.GetTable(name)
+				if err != nil {
+					return err
+				}
+				if tables[table], err = decodeRows(table, docs); err != nil {
+					return err
+				}
+			}
+			pending = nil
+
+		case wal.Change:
+			pending = append(pending, r)
+
+		case wal.Commit:
+			for _, change := range pending {
+				if err := m.replay(tables, change); err != nil {
+					return fmt.Errorf("commit %d: %w", r.Txn, err)
+				}
+			}
+			pending = nil
+		}
+	}
+
+	for table, rows := range tables {
+		base, err := storage.Open(table)
+		if err != nil {
+			return err
+		}
+		s := &store{base: base, writers: make(map[*Txn]bool), dirty: true}
+		for _, row := range rows {
+			s.versions = append(s.versions, &version{row: row})
+		}
+		m.stores[table] = s
+
+		table.SetRowCount(len(rows))
+	}
+
+	if err := crash("recovered"); err != nil {
+		return err
+	}
+	return m.checkpoint()
+}
+
+// applies a change record to the rows of its table, read from the table's
+// source when no earlier record had them
+func (m *Manager) replay(tables map[*catalog.TableInfo][]storage.Row, r *wal.Record) error {
+	table, err := m.catalog.GetTable(r.Table)
+	if err != nil {
+		return err
+	}
+	rows, ok := tables[table]
+	if !ok {
+		if rows, err = storage.ReadTable(table); err != nil {
+			return err
+		}
+	}
+
+	deleted := make(map[int]bool, len(r.Deleted))
+	for _, pos := range r.Deleted {
+		if pos < 0 || pos >= len(rows) {
+			return fmt.Errorf("table %s has no row %d to delete", table.Name, pos+1)
+		}
+		deleted[pos] = true
+	}
+	kept := make([]storage.Row, 0, len(rows))
+	for i, row := range rows {
+		if !deleted[i] {
+			kept = append(kept, row)
+		}
+	}
+
+	out := make([]storage.Row, 0, len(kept)+len(r.Inserted))
+	next := 0
+	for _, ins := range r.Inserted {
+		for len(out) < ins.Pos && next < len(kept) {
+			out = append(out, kept[next])
+			next++
+		}
+		if len(out) != ins.Pos {
+			return fmt.Errorf("table %s has no position %d to insert at", table.Name, ins.Pos+1)
+		}
+		row, err := storage.DecodeDocument(table, ins.Row)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+		out = append(out, row)
+	}
+	tables[table] = append(out, kept[next:]...)
+	return nil
+}
+
+func decodeRows(table *catalog.TableInfo, docs []map[string]interface{}) ([]storage.Row, error) {
+	rows := make([]storage.Row, len(docs))
+	for i, doc := range docs {
+		var err error
+		if rows[i], err = storage.DecodeDocument(table, doc); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,272 @@
+package txn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+var errCrash = errors.New("crashed")
+
+// directory with a saved catalog of one JSON table, accounts
+func newLoggedDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "accounts.json")
+	if err := os.WriteFile(data, []byte(`[{"id": 1, "balance": 100}, {"id": 2, "balance": 50}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.NewCatalog()
+	cat.RegisterTable(&catalog.TableInfo{
+		Name: "accounts",
+		Columns: []catalog.Column{
+			{Name: "id", Type: catalog.IntType},
+			{Name: "balance", Type: catalog.IntType},
+		},
+		DataFile:   data,
+		PrimaryKey: []string{"id"},
+	})
+	if err := cat.SaveToFile(filepath.Join(dir, "catalog.json")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// catalog and manager of dir as a restarted process sees them
+type process struct {
+	m     *Manager
+	table *catalog.TableInfo
+	base  storage.TableSource
+}
+
+func start(t *testing.T, dir string) (*process, error) {
+	t.Helper()
+
+	cat := catalog.NewCatalog()
+	if err := cat.LoadFromFile(filepath.Join(dir, "catalog.json")); err != nil {
+		t.Fatal(err)
+	}
+	table, err := cat.GetTable("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := storage.Open(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ManagerOf(cat)
+	return &process{m: m, table: table, base: base}, m.OpenLog(filepath.Join(dir, "wal.log"))
+}
+
+// the process dies, its log is left as it is
+func (p *process) kill() {
+	if p.m.log != nil {
+		p.m.log.Close()
+	}
+}
+
+func (p *process) commit(change func(s storage.RowChanger) error) error {
+	tx := p.m.Begin()
+	if err := change(tx.Source(p.table, p.base).(storage.RowChanger)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rows of the table file as id:balance, sorted
+func contents(t *testing.T, table *catalog.TableInfo) string {
+	t.Helper()
+
+	rows, err := storage.ReadTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, row := range rows {
+		out = append(out, fmt.Sprintf("%v:%v", row["id"], row["balance"]))
+	}
+	sort.Strings(out)
+	return strings.Join(out, " ")
+}
+
+// changes of the crash tests and the table after each of them
+var steps = []struct {
+	run  func(p *process) error
+	want string
+}{
+	{func(p *process) error {
+		return p.commit(func(s storage.RowChanger) error {
+			return s.Insert([]storage.Row{{"id": 3, "balance": 10}})
+		})
+	}, "1:100 2:50 3:10"},
+	{func(p *process) error {
+		return p.commit(func(s storage.RowChanger) error {
+			return s.Change(map[int]storage.Row{0: {"id": 1, "balance": 70}, 1: nil})
+		})
+	}, "1:70 3:10"},
+	{func(p *process) error { return p.m.Checkpoint() }, "1:70 3:10"},
+	{func(p *process) error {
+		return p.commit(func(s storage.RowChanger) error {
+			return s.Insert([]storage.Row{{"id": 4, "balance": 1}})
+		})
+	}, "1:70 3:10 4:1"},
+}
+
+func TestRecoveryAfterCrash(t *testing.T) {
+	defer func() { crash = func(string) error { return nil } }()
+
+	for _, point := range []string{"commit", "checkpoint logged", "checkpoint wrote accounts", "recovered"} {
+		for at := 1; ; at++ {
+			dir := newLoggedDir(t)
+
+			// the at-th time the point is reached is the crash
+			reached := 0
+			crash = func(p string) error {
+				if p == point {
+					if reached++; reached == at {
+						return errCrash
+					}
+				}
+				return nil
+			}
+
+			want := "1:100 2:50"
+			p, err := start(t, dir)
+			if err != nil && !errors.Is(err, errCrash) {
+				t.Fatalf("%s: %v", point, err)
+			}
+			crashed := err != nil
+			for _, step := range steps {
+				if crashed {
+					break
+				}
+				err := step.run(p)
+				if err != nil && !errors.Is(err, errCrash) {
+					t.Fatalf("%s: %v", point, err)
+				}
+				// every crash point comes after the log was synced
+				want = step.want
+				if err != nil {
+					if len(p.m.active) > 0 {
+						t.Errorf("%s %d: expected the crashed transaction to end", point, at)
+					}
+					crashed = true
+					break
+				}
+			}
+			p.kill()
+
+			// recovery can crash too, starting again has to work
+			for restart := 0; restart < 2; restart++ {
+				p, err = start(t, dir)
+				if errors.Is(err, errCrash) {
+					crashed = true
+					p.kill()
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s %d: restart: %v", point, at, err)
+				}
+				if got := contents(t, p.table); got != want {
+					t.Errorf("%s %d: expected %s after recovery, got %s", point, at, want, got)
+				}
+				p.kill()
+			}
+
+			crash = func(string) error { return nil }
+			if !crashed {
+				break
+			}
+		}
+	}
+}
+
+func TestUncommittedChangesAreLost(t *testing.T) {
+	dir := newLoggedDir(t)
+	p, err := start(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := p.m.Begin()
+	if err := open.Source(p.table, p.base).(storage.WritableSource).Insert([]storage.Row{{"id": 9, "balance": 9}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps[0].run(p); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, p.table); got != "1:100 2:50" {
+		t.Fatalf("expected commits to stay in the log until a checkpoint, got %s in the file", got)
+	}
+	p.kill()
+
+	p, err = start(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.kill()
+	if got := contents(t, p.table); got != "1:100 2:50 3:10" {
+		t.Fatalf("expected only the commit to be recovered, got %s", got)
+	}
+	if p.table.Statistics != nil && p.table.Statistics.RowCount != 3 {
+		t.Fatalf("expected the row count to be recovered, got %d", p.table.Statistics.RowCount)
+	}
+}
+
+// a crash can cut the log anywhere, recovery keeps exactly the commits
+// whose records were written whole
+func TestTornLogTail(t *testing.T) {
+	dir := newLoggedDir(t)
+	p, err := start(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ends []int64
+	var wants []string
+	for _, i := range []int{0, 1, 3} {
+		if err := steps[i].run(p); err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, p.m.log.Size())
+		wants = append(wants, steps[i].want)
+	}
+	p.kill()
+
+	logData, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n <= len(logData); n++ {
+		cut := newLoggedDir(t)
+		if err := os.WriteFile(filepath.Join(cut, "wal.log"), logData[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "1:100 2:50"
+		for i, end := range ends {
+			if int64(n) >= end {
+				want = wants[i]
+			}
+		}
+
+		p, err := start(t, cut)
+		if err != nil {
+			t.Fatalf("cut at %d: %v", n, err)
+		}
+		if got := contents(t, p.table); got != want {
+			t.Fatalf("cut at %d: expected %s, got %s", n, want, got)
+		}
+		p.kill()
+	}
+}
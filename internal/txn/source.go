@@ -0,0 +1,165 @@
+package txn
+
+import (
+	"fmt"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+// Source is a table as t sees it. Scans read base directly until some
+// transaction writes the table, writes are kept as versions until commit
+func (t *Txn) Source(table *catalog.TableInfo, base storage.TableSource) storage.TableSource {
+	return &source{t: t, table: table, base: base}
+}
+
+type source struct {
+	t     *Txn
+	table *catalog.TableInfo
+	base  storage.TableSource
+}
+
+func (s *source) Scan(opts storage.ScanOptions) (storage.RowIterator, error) {
+	m := s.t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.t.done {
+		return nil, fmt.Errorf("transaction has ended")
+	}
+	if st, ok := m.stores[s.table]; ok {
+		rows, _ := s.t.visible(st)
+		return storage.NewSliceIterator(rows, opts), nil
+	}
+
+	// no commit can rewrite the data while the scan opens it
+	return s.base.Scan(opts)
+}
+
+func (s *source) Statistics(sample int) (*catalog.Statistics, error) {
+	return s.base.Statistics(sample)
+}
+
+func (s *source) IndexLookup(index catalog.Index, key []interface{}) (storage.RowIterator, error) {
+	m := s.t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.t.done {
+		return nil, fmt.Errorf("transaction has ended")
+	}
+	st, ok := m.stores[s.table]
+	if !ok {
+		if indexed, ok := s.base.(storage.IndexSource); ok {
+			return indexed.IndexLookup(index, key)
+		}
+		it, err := s.base.Scan(storage.ScanOptions{})
+		if err != nil {
+			return nil, err
+		}
+		rows, err := storage.ReadAll(it)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewSliceIterator(matching(rows, index, key), storage.ScanOptions{}), nil
+	}
+
+	rows, _ := s.t.visible(st)
+	return storage.NewSliceIterator(matching(rows, index, key), storage.ScanOptions{}), nil
+}
+
+// rows whose index columns hold key
+func matching(rows []storage.Row, index catalog.Index, key []interface{}) []storage.Row {
+	want := storage.IndexKey(key)
+	var out []storage.Row
+	for _, row := range rows {
+		values := make([]interface{}, len(index.Columns))
+		for i, col := range index.Columns {
+			values[i] = row[col]
+		}
+		if storage.IndexKey(values) == want {
+			out = append(out, row)
+		}
+	}
+
+	return out
+}
+
+func (s *source) Insert(rows []storage.Row) error {
+	m := s.t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, err := s.t.writable(s.table, s.base)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		st.versions = append(st.versions, &version{row: row, creator: s.t})
+	}
+
+	return nil
+}
+
+func (s *source) Replace(rows []storage.Row) error {
+	m := s.t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, err := s.t.writable(s.table, s.base)
+	if err != nil {
+		return err
+	}
+	_, versions := s.t.visible(st)
+	for _, v := range versions {
+		if err := s.t.delete(s.table, v); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		st.versions = append(st.versions, &version{row: row, creator: s.t})
+	}
+
+	return nil
+}
+
+// changed rows get new versions next to the old ones, so the table keeps
+// its order when it is written back
+func (s *source) Change(changes map[int]storage.Row) error {
+	m := s.t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, err := s.t.writable(s.table, s.base)
+	if err != nil {
+		return err
+	}
+	_, versions := s.t.visible(st)
+
+	replaced := make(map[*version]storage.Row, len(changes))
+	for pos, row := range changes {
+		if pos < 0 || pos >= len(versions) {
+			return fmt.Errorf("table %s has no row %d", s.table.Name, pos+1)
+		}
+		replaced[versions[pos]] = row
+	}
+	for _, v := range versions {
+		if _, ok := replaced[v]; !ok {
+			continue
+		}
+		if err := s.t.delete(s.table, v); err != nil {
+			return err
+		}
+	}
+
+	out := make([]*version, 0, len(st.versions)+len(changes))
+	for _, v := range st.versions {
+		out = append(out, v)
+		if row, ok := replaced[v]; ok && row != nil {
+			out = append(out, &version{row: row, creator: s.t})
+		}
+	}
+	st.versions = out
+
+	return nil
+}
@@ -0,0 +1,370 @@
+// Package txn runs statements in transactions with snapshot isolation.
+//
+// Rows of a table that a transaction writes are kept as versions, each
+// stamped with the commit timestamps of the transactions that created and
+// deleted it. A transaction sees the versions committed before it began and
+// its own changes. Committing writes the newest rows back through the
+// table's source, so tables nobody writes are read from their sources
+// directly.
+//
+// With a write-ahead log open, commits are appended to the log and synced
+// instead, and the changed tables are written when the log is checkpointed.
+package txn
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+	"github.com/Adit0507/sql-query-optimizer/internal/wal"
+)
+
+// returned when a transaction changes a row another transaction changed
+// after it began. The transaction is rolled back
+var ErrConflict = errors.New("could not serialize access due to a concurrent update")
+
+// one state of a row. begin and end are commit timestamps, zero begin is a
+// row read from the source and zero end a live row. creator and deleter are
+// set while the transaction changing the row is open
+type version struct {
+	row     storage.Row
+	begin   uint64
+	end     uint64
+	creator *Txn
+	deleter *Txn
+}
+
+// versions of a table's rows, oldest first
+type store struct {
+	base     storage.TableSource
+	versions []*version
+	writers  map[*Txn]bool
+	commit   uint64 // timestamp of the last commit that changed the table
+	dirty    bool   // has commits only the log holds
+}
+
+// transactions of one catalog share a manager, which orders their commits
+type Manager struct {
+	mu      sync.Mutex
+	catalog *catalog.Catalog
+	clock   uint64 // timestamp of the last commit
+	active  map[*Txn]bool
+	stores  map[*catalog.TableInfo]*store
+	log     *wal.Log
+}
+
+var managers = struct {
+	sync.Mutex
+	of map[*catalog.Catalog]*Manager
+}{of: make(map[*catalog.Catalog]*Manager)}
+
+// manager of the transactions on a catalog, created on first use
+func ManagerOf(cat *catalog.Catalog) *Manager {
+	managers.Lock()
+	defer managers.Unlock()
+
+	m, ok := managers.of[cat]
+	if !ok {
+		m = &Manager{
+			catalog: cat,
+			active:  make(map[*Txn]bool),
+			stores:  make(map[*catalog.TableInfo]*store),
+		}
+		managers.of[cat] = m
+	}
+	return m
+}
+
+type Txn struct {
+	m        *Manager
+	snapshot uint64 // sees commits up to this timestamp
+	done     bool
+	writes   map[*catalog.TableInfo]*store
+}
+
+// starts a transaction seeing everything committed so far
+func (m *Manager) Begin() *Txn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := &Txn{m: m, snapshot: m.clock, writes: make(map[*catalog.TableInfo]*store)}
+	m.active[t] = true
+	return t
+}
+
+// reports whether open transactions still need the versions of a table,
+// whose definition can't change until they end
+func (m *Manager) InUse(table *catalog.TableInfo) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.stores[table]
+	return ok
+}
+
+// reports whether the transaction has ended
+func (t *Txn) Done() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+
+	return t.done
+}
+
+// whether v is part of what t sees
+func (t *Txn) sees(v *version) bool {
+	if v.creator != t && (v.creator != nil || v.begin > t.snapshot) {
+		return false
+	}
+	if v.deleter == t {
+		return false
+	}
+
+	return v.deleter != nil || v.end == 0 || v.end > t.snapshot
+}
+
+// rows of a table as t sees them, with the versions they come from
+func (t *Txn) visible(s *store) ([]storage.Row, []*version) {
+	var rows []storage.Row
+	var versions []*version
+	for _, v := range s.versions {
+		if t.sees(v) {
+			rows = append(rows, v.row)
+			versions = append(versions, v)
+		}
+	}
+
+	return rows, versions
+}
+
+// versions of a table, read from its source the first time a transaction
+// writes it. Called with the lock held
+func (m *Manager) load(table *catalog.TableInfo, base storage.TableSource) (*store, error) {
+	if s, ok := m.stores[table]; ok {
+		return s, nil
+	}
+
+	it, err := base.Scan(storage.ScanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := storage.ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{base: base, writers: make(map[*Txn]bool)}
+	for _, row := range rows {
+		s.versions = append(s.versions, &version{row: row})
+	}
+	m.stores[table] = s
+	return s, nil
+}
+
+// store of a table t is about to change. Called with the lock held
+func (t *Txn) writable(table *catalog.TableInfo, base storage.TableSource) (*store, error) {
+	if t.done {
+		return nil, fmt.Errorf("transaction has ended")
+	}
+	if _, ok := base.(storage.WritableSource); !ok {
+		return nil, fmt.Errorf("table %s can't be written, its %s source is read only", table.Name, table.SourceKind())
+	}
+
+	s, err := t.m.load(table, base)
+	if err != nil {
+		return nil, err
+	}
+	s.writers[t] = true
+	t.writes[table] = s
+	return s, nil
+}
+
+// marks v deleted by t, a conflict when another transaction deleted it
+// first or committed a delete t doesn't see. Called with the lock held
+func (t *Txn) delete(table *catalog.TableInfo, v *version) error {
+	if (v.deleter != nil && v.deleter != t) || (v.deleter == nil && v.end != 0) {
+		t.abort()
+		return fmt.Errorf("table %s: %w", table.Name, ErrConflict)
+	}
+
+	v.deleter = t
+	return nil
+}
+
+// Commit makes t's changes visible to transactions that begin afterwards
+// and writes the changed tables through their sources. Keys and foreign
+// keys are checked again when other transactions committed since t began
+func (t *Txn) Commit() error {
+	m := t.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t.done {
+		return fmt.Errorf("transaction has ended")
+	}
+	if len(t.writes) == 0 {
+		t.end()
+		return nil
+	}
+
+	// the newest rows, what t sees once every commit so far is included
+	began := t.snapshot
+	t.snapshot = m.clock
+	latest := make(map[*catalog.TableInfo][]storage.Row, len(t.writes))
+	for table, s := range t.writes {
+		latest[table], _ = t.visible(s)
+	}
+
+	// rows other transactions committed since t began may clash with t's
+	if m.clock > began {
+		read := func(table *catalog.TableInfo) ([]map[string]interface{}, error) {
+			if rows, ok := latest[table]; ok {
+				return rows, nil
+			}
+			if s, ok := m.stores[table]; ok {
+				rows, _ := t.visible(s)
+				return rows, nil
+			}
+			return storage.ReadTable(table)
+		}
+		for table, rows := range latest {
+			if err := m.catalog.CheckRowsWith(table, rows, read); err != nil {
+				t.abort()
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+		}
+	}
+
+	// tables written so far are put back when a later one fails
+	var written []*catalog.TableInfo
+	undo := func() {
+		for _, w := range written {
+			s := t.writes[w]
+			s.base.(storage.WritableSource).Replace(committed(s))
+		}
+		t.abort()
+	}
+	for table, rows := range latest {
+		if m.logged(table) {
+			continue
+		}
+		if err := t.writes[table].base.(storage.WritableSource).Replace(rows); err != nil {
+			undo()
+			return err
+		}
+		written = append(written, table)
+	}
+	if err := t.logChanges(); err != nil {
+		undo()
+		return err
+	}
+	// the commit is in the log but not in memory, t ends so nothing waits
+	// on it. The manager is left behind like a dead process
+	if err := crash("commit"); err != nil {
+		t.abort()
+		return err
+	}
+
+	m.clock++
+	for table, s := range t.writes {
+		for _, v := range s.versions {
+			if v.creator == t {
+				v.creator, v.begin = nil, m.clock
+			}
+			if v.deleter == t {
+				v.deleter, v.end = nil, m.clock
+			}
+		}
+		s.commit = m.clock
+		s.dirty = s.dirty || m.logged(table)
+		table.SetRowCount(len(latest[table]))
+	}
+
+	t.end()
+
+	// a failed checkpoint is tried again after the next commit, the log
+	// still holds everything
+	if m.log != nil && m.log.Size() >= checkpointSize {
+		m.checkpoint()
+	}
+	return nil
+}
+
+// rows of a table as of the last commit
+func committed(s *store) []storage.Row {
+	var rows []storage.Row
+	for _, v := range s.versions {
+		if v.creator == nil && v.end == 0 {
+			rows = append(rows, v.row)
+		}
+	}
+
+	return rows
+}
+
+// Rollback drops t's changes
+func (t *Txn) Rollback() {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+
+	if !t.done {
+		t.abort()
+	}
+}
+
+// drops t's changes and ends it. Called with the lock held
+func (t *Txn) abort() {
+	for _, s := range t.writes {
+		kept := s.versions[:0]
+		for _, v := range s.versions {
+			if v.deleter == t {
+				v.deleter = nil
+			}
+			if v.creator != t {
+				kept = append(kept, v)
+			}
+		}
+		s.versions = kept
+	}
+
+	t.end()
+}
+
+// ends t and forgets versions no open transaction needs. Called with the
+// lock held
+func (t *Txn) end() {
+	m := t.m
+	t.done = true
+	delete(m.active, t)
+	for _, s := range t.writes {
+		delete(s.writers, t)
+	}
+
+	m.collect()
+}
+
+// tables nobody is writing whose last commit every open transaction sees
+// and which the log doesn't hold changes of are read from their sources
+// again. Called with the lock held
+func (m *Manager) collect() {
+	oldest := m.clock
+	for other := range m.active {
+		oldest = min(oldest, other.snapshot)
+	}
+
+	for table, s := range m.stores {
+		if len(s.writers) == 0 && s.commit <= oldest && !s.dirty {
+			delete(m.stores, table)
+			continue
+		}
+
+		kept := s.versions[:0]
+		for _, v := range s.versions {
+			if v.end == 0 || v.end > oldest {
+				kept = append(kept, v)
+			}
+		}
+		s.versions = kept
+	}
+}
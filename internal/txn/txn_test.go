@@ -0,0 +1,196 @@
+package txn
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Adit0507/sql-query-optimizer/internal/catalog"
+	"github.com/Adit0507/sql-query-optimizer/internal/optimizer"
+	"github.com/Adit0507/sql-query-optimizer/internal/plan"
+	"github.com/Adit0507/sql-query-optimizer/internal/storage"
+)
+
+type account struct {
+	ID      int `sql:"id,pk"`
+	Balance int
+}
+
+func newAccounts(t *testing.T) (*Manager, *catalog.TableInfo, storage.TableSource) {
+	t.Helper()
+
+	cat := catalog.NewCatalog()
+	table, err := storage.RegisterRows(cat, "accounts", []account{{1, 100}, {2, 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := storage.Open(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ManagerOf(cat), table, base
+}
+
+// ids of the rows tx sees
+func ids(t *testing.T, tx *Txn, table *catalog.TableInfo, base storage.TableSource) []interface{} {
+	t.Helper()
+
+	it, err := tx.Source(table, base).Scan(storage.ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := storage.ReadAll(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []interface{}
+	for _, row := range rows {
+		out = append(out, row["id"])
+	}
+	return out
+}
+
+func insert(t *testing.T, tx *Txn, table *catalog.TableInfo, base storage.TableSource, id int) {
+	t.Helper()
+
+	if err := tx.Source(table, base).(storage.WritableSource).Insert([]storage.Row{{"id": id, "balance": 0}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	m, table, base := newAccounts(t)
+
+	reader := m.Begin()
+	writer := m.Begin()
+	insert(t, writer, table, base, 3)
+
+	if got := ids(t, writer, table, base); len(got) != 3 {
+		t.Fatalf("expected the writer to see its insert, got %v", got)
+	}
+	if got := ids(t, reader, table, base); len(got) != 2 {
+		t.Fatalf("expected uncommitted rows to be invisible, got %v", got)
+	}
+
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, reader, table, base); len(got) != 2 {
+		t.Fatalf("expected the reader's snapshot to miss the later commit, got %v", got)
+	}
+	if got := ids(t, m.Begin(), table, base); len(got) != 3 {
+		t.Fatalf("expected new transactions to see the commit, got %v", got)
+	}
+
+	reader.Rollback()
+	if m.InUse(table) {
+		t.Fatal("expected versions to be dropped once no transaction needs them")
+	}
+}
+
+func TestWriteWriteConflict(t *testing.T) {
+	m, table, base := newAccounts(t)
+
+	first, second := m.Begin(), m.Begin()
+	change := func(tx *Txn) error {
+		return tx.Source(table, base).(storage.RowChanger).Change(map[int]storage.Row{0: {"id": 1, "balance": 0}})
+	}
+
+	if err := change(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := change(second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if !second.Done() {
+		t.Fatal("expected the conflict to roll the transaction back")
+	}
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a row changed by a commit the transaction doesn't see conflicts too
+	late := m.Begin()
+	old := m.Begin()
+	if err := change(late); err != nil {
+		t.Fatal(err)
+	}
+	if err := late.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := change(old); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict with the committed change, got %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	m, table, base := newAccounts(t)
+
+	tx := m.Begin()
+	insert(t, tx, table, base, 3)
+	if err := tx.Source(table, base).(storage.WritableSource).Replace(nil); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+
+	if got := ids(t, m.Begin(), table, base); len(got) != 2 {
+		t.Fatalf("expected rolled back changes to be gone, got %v", got)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected commit after rollback to fail")
+	}
+}
+
+func TestConcurrentInsertsCheckedAtCommit(t *testing.T) {
+	m, table, base := newAccounts(t)
+
+	first, second := m.Begin(), m.Begin()
+	insert(t, first, table, base, 3)
+	insert(t, second, table, base, 3)
+
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Commit(); err == nil {
+		t.Fatal("expected the duplicate key to fail the second commit")
+	}
+
+	rows, err := storage.ReadTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected the first commit's rows in the source, got %v", rows)
+	}
+}
+
+// commits publish row counts while other queries are being planned, the
+// race detector catches readers that skip the lock
+func TestRowCountsWhilePlanning(t *testing.T) {
+	m, table, base := newAccounts(t)
+
+	scan := &plan.LogicalScan{Table: table}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			optimizer.EstimateRows(scan)
+		}
+	}()
+
+	for id := 3; id < 50; id++ {
+		tx := m.Begin()
+		insert(t, tx, table, base, id)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if rows := optimizer.EstimateRows(scan); rows != 49 {
+		t.Fatalf("expected the last commit's row count, got %v", rows)
+	}
+}
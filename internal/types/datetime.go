@@ -0,0 +1,153 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// calendar date without a time of day
+type Date struct {
+	t time.Time // midnight UTC
+}
+
+// point in time without a time zone, stored as UTC
+type Timestamp struct {
+	t time.Time
+}
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02 15:04",
+	dateLayout,
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{t: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid DATE value '%s'", s)
+	}
+
+	return Date{t: t}, nil
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{t: t.UTC()}
+}
+
+func ParseTimestamp(s string) (Timestamp, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Timestamp{t: t.UTC()}, nil
+		}
+	}
+
+	return Timestamp{}, fmt.Errorf("invalid TIMESTAMP value '%s'", s)
+}
+
+func (d Date) Time() time.Time      { return d.t }
+func (d Date) String() string       { return d.t.Format(dateLayout) }
+func (d Date) Timestamp() Timestamp { return Timestamp{t: d.t} }
+
+func (d Date) Cmp(o Date) int { return d.t.Compare(o.t) }
+
+// date n days later
+func (d Date) AddDays(n int) Date { return Date{t: d.t.AddDate(0, 0, n)} }
+
+// days from o to d
+func (d Date) Sub(o Date) int { return int(d.t.Sub(o.t).Hours() / 24) }
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+func (t Timestamp) Time() time.Time { return t.t }
+func (t Timestamp) String() string  { return t.t.Format(timestampLayout) }
+func (t Timestamp) Date() Date {
+	y, m, d := t.t.Date()
+	return NewDate(y, m, d)
+}
+
+func (t Timestamp) Cmp(o Timestamp) int { return t.t.Compare(o.t) }
+
+// interval from o to t, in days and microseconds
+func (t Timestamp) Sub(o Timestamp) Interval {
+	diff := t.t.Sub(o.t)
+	days := int(diff / (24 * time.Hour))
+
+	return Interval{Days: days, Micros: (diff - time.Duration(days)*24*time.Hour).Microseconds()}
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// the named part of a date or timestamp, for EXTRACT
+func Extract(field string, t time.Time) (int, error) {
+	switch strings.ToUpper(field) {
+	case "YEAR":
+		return t.Year(), nil
+	case "QUARTER":
+		return (int(t.Month())-1)/3 + 1, nil
+	case "MONTH":
+		return int(t.Month()), nil
+	case "WEEK":
+		_, week := t.ISOWeek()
+		return week, nil
+	case "DAY":
+		return t.Day(), nil
+	case "DOW":
+		return int(t.Weekday()), nil
+	case "DOY":
+		return t.YearDay(), nil
+	case "HOUR":
+		return t.Hour(), nil
+	case "MINUTE":
+		return t.Minute(), nil
+	case "SECOND":
+		return t.Second(), nil
+	case "EPOCH":
+		return int(t.Unix()), nil
+	default:
+		return 0, fmt.Errorf("unknown date field %s", field)
+	}
+}
+
+// t with everything below field zeroed, for DATE_TRUNC
+func Truncate(field string, t time.Time) (time.Time, error) {
+	y, m, d := t.Date()
+	switch strings.ToUpper(field) {
+	case "YEAR":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC), nil
+	case "QUARTER":
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC), nil
+	case "MONTH":
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), nil
+	case "WEEK":
+		// weeks start on monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, time.UTC), nil
+	case "DAY":
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+	case "HOUR":
+		return t.Truncate(time.Hour), nil
+	case "MINUTE":
+		return t.Truncate(time.Minute), nil
+	case "SECOND":
+		return t.Truncate(time.Second), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown date field %s", field)
+	}
+}
@@ -0,0 +1,194 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// exact decimal number, the value is unscaled / 10^scale
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// digits kept after the point when a division does not terminate
+const divisionScale = 6
+
+func NewDecimal(unscaled int64, scale int) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+func DecimalFromInt(n int) Decimal {
+	return NewDecimal(int64(n), 0)
+}
+
+// parses numbers like 12, -0.50 or 1.5e3
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if _, err := fmt.Sscan(s[i+1:], &exp); err != nil {
+			return Decimal{}, fmt.Errorf("invalid DECIMAL value '%s'", s)
+		}
+		s = s[:i]
+	}
+
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+		s = s[:i] + s[i+1:]
+	}
+
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid DECIMAL value '%s'", s)
+	}
+
+	d := Decimal{unscaled: n, scale: scale - exp}
+	if d.scale < 0 {
+		d = d.Rescale(0)
+	}
+	return d, nil
+}
+
+// closest decimal to f with at most scale digits after the point
+func DecimalFromFloat(f float64, scale int) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("cannot convert %v to DECIMAL", f)
+	}
+
+	return ParseDecimal(fmt.Sprintf("%.*f", scale, f))
+}
+
+func (d Decimal) Scale() int { return d.scale }
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// same value with exactly scale digits after the point, rounding half away
+// from zero when digits are dropped
+func (d Decimal) Rescale(scale int) Decimal {
+	n := d.int()
+	if scale >= d.scale {
+		factor := pow10(scale - d.scale)
+		return Decimal{unscaled: new(big.Int).Mul(n, factor), scale: scale}
+	}
+
+	factor := pow10(d.scale - scale)
+	q, r := new(big.Int).QuoRem(n, factor, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(factor) >= 0 {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+
+	return Decimal{unscaled: q, scale: scale}
+}
+
+// digits in the unscaled value, the precision DECIMAL(p,s) limits
+func (d Decimal) Precision() int {
+	digits := len(new(big.Int).Abs(d.int()).String())
+	return max(digits, d.scale)
+}
+
+func (d Decimal) Sign() int { return d.int().Sign() }
+
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b := align(d, o)
+	return Decimal{unscaled: new(big.Int).Add(a.int(), b.int()), scale: a.scale}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	a, b := align(d, o)
+	return Decimal{unscaled: new(big.Int).Sub(a.int(), b.int()), scale: a.scale}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), o.int()), scale: d.scale + o.scale}
+}
+
+// quotient with the larger of both scales and divisionScale digits
+func (d Decimal) Div(o Decimal) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+
+	scale := max(d.scale, o.scale, divisionScale)
+	// one extra digit so the result rounds instead of truncating
+	num := new(big.Int).Mul(d.int(), pow10(o.scale+scale+1))
+	den := new(big.Int).Mul(o.int(), pow10(d.scale))
+	q := Decimal{unscaled: new(big.Int).Quo(num, den), scale: scale + 1}
+
+	return q.Rescale(scale), nil
+}
+
+// remainder with the sign of d
+func (d Decimal) Mod(o Decimal) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+
+	a, b := align(d, o)
+	return Decimal{unscaled: new(big.Int).Rem(a.int(), b.int()), scale: a.scale}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	a, b := align(d, o)
+	return a.int().Cmp(b.int())
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Float).SetInt(d.int()).Float64()
+	return f / math.Pow(10, float64(d.scale))
+}
+
+// integer part, truncated toward zero
+func (d Decimal) Int() int {
+	return int(new(big.Int).Quo(d.int(), pow10(d.scale)).Int64())
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale <= 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.scale
+
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// decimals print as JSON numbers
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func align(a, b Decimal) (Decimal, Decimal) {
+	switch {
+	case a.scale < b.scale:
+		return a.Rescale(b.scale), b
+	case b.scale < a.scale:
+		return a, b.Rescale(a.scale)
+	default:
+		return a, b
+	}
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,169 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// span of time. Months and days are kept apart from the clock part since
+// their length depends on the date they are added to
+type Interval struct {
+	Months int
+	Days   int
+	Micros int64
+}
+
+var intervalUnits = map[string]Interval{
+	"YEAR":        {Months: 12},
+	"MONTH":       {Months: 1},
+	"MON":         {Months: 1}, // as String prints it
+	"WEEK":        {Days: 7},
+	"DAY":         {Days: 1},
+	"HOUR":        {Micros: int64(time.Hour / time.Microsecond)},
+	"MINUTE":      {Micros: int64(time.Minute / time.Microsecond)},
+	"SECOND":      {Micros: int64(time.Second / time.Microsecond)},
+	"MILLISECOND": {Micros: 1000},
+	"MICROSECOND": {Micros: 1},
+}
+
+// parses quantity and unit pairs like '1 year 2 months' or '3 days 04:00:00'
+func ParseInterval(s string) (Interval, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Interval{}, fmt.Errorf("invalid INTERVAL value '%s'", s)
+	}
+
+	var result Interval
+	for i := 0; i < len(fields); i++ {
+		// clock part hh:mm[:ss]
+		if strings.Contains(fields[i], ":") {
+			d, err := parseClock(fields[i])
+			if err != nil {
+				return Interval{}, fmt.Errorf("invalid INTERVAL value '%s'", s)
+			}
+			result.Micros += d
+			continue
+		}
+
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || i+1 >= len(fields) {
+			return Interval{}, fmt.Errorf("invalid INTERVAL value '%s'", s)
+		}
+		unit, ok := intervalUnits[strings.TrimSuffix(strings.ToUpper(fields[i+1]), "S")]
+		if !ok {
+			return Interval{}, fmt.Errorf("unknown INTERVAL unit %s", fields[i+1])
+		}
+		result = result.Add(unit.Scale(n))
+		i++
+	}
+
+	return result, nil
+}
+
+func parseClock(s string) (int64, error) {
+	// the sign applies to the whole clock, -01:30:00 is an hour and a half
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		micros, err := parseClock(rest)
+		return -micros, err
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+
+	var micros int64
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, err
+		}
+		micros += int64(n * float64(units[i]/time.Microsecond))
+	}
+
+	return micros, nil
+}
+
+func (i Interval) Add(o Interval) Interval {
+	return Interval{Months: i.Months + o.Months, Days: i.Days + o.Days, Micros: i.Micros + o.Micros}
+}
+
+func (i Interval) Neg() Interval {
+	return i.Scale(-1)
+}
+
+func (i Interval) Scale(n int) Interval {
+	return Interval{Months: i.Months * n, Days: i.Days * n, Micros: i.Micros * int64(n)}
+}
+
+// reports whether the interval has a clock part
+func (i Interval) HasTime() bool { return i.Micros != 0 }
+
+// orders intervals assuming 30 day months, like PostgreSQL
+func (i Interval) Cmp(o Interval) int {
+	a, b := i.approxMicros(), o.approxMicros()
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (i Interval) approxMicros() int64 {
+	day := int64(24 * time.Hour / time.Microsecond)
+	return (int64(i.Months)*30+int64(i.Days))*day + i.Micros
+}
+
+// months are added first and clamp to the end of the month, so
+// 2024-01-31 plus a month is 2024-02-29
+func (i Interval) AddTo(t time.Time) time.Time {
+	if i.Months != 0 {
+		first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		first = first.AddDate(0, i.Months, 0)
+		last := first.AddDate(0, 1, -1).Day()
+		t = first.AddDate(0, 0, min(t.Day(), last)-1)
+	}
+
+	return t.AddDate(0, 0, i.Days).Add(time.Duration(i.Micros) * time.Microsecond)
+}
+
+func (i Interval) String() string {
+	var parts []string
+	plural := func(n int, unit string) {
+		if n == 0 {
+			return
+		}
+		if n != 1 && n != -1 {
+			unit += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, unit))
+	}
+
+	plural(i.Months/12, "year")
+	plural(i.Months%12, "mon")
+	plural(i.Days, "day")
+
+	if i.Micros != 0 || len(parts) == 0 {
+		d := time.Duration(i.Micros) * time.Microsecond
+		sign := ""
+		if d < 0 {
+			sign, d = "-", -d
+		}
+		clock := fmt.Sprintf("%s%02d:%02d:%02d", sign, int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+		if frac := d % time.Second; frac != 0 {
+			clock += fmt.Sprintf(".%06d", frac/time.Microsecond)
+		}
+		parts = append(parts, clock)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + i.String() + `"`), nil
+}
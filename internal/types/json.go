@@ -0,0 +1,176 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path into a JSON document, each step is an object key (string) or an
+// array index (int)
+type JSONPath []interface{}
+
+// parses paths like address.city, items[0].sku or $.items[0], a leading $
+// is optional. Keys with dots or brackets can be quoted: $."a.b"
+func ParseJSONPath(s string) (JSONPath, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "$")
+	s = strings.TrimPrefix(s, ".")
+
+	var path JSONPath
+	for s != "" {
+		switch {
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in JSON path")
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid array index %q in JSON path", s[1:end])
+			}
+			path = append(path, idx)
+			s = s[end+1:]
+
+		case s[0] == '"':
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in JSON path")
+			}
+			path = append(path, s[1:end+1])
+			s = s[end+2:]
+
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in JSON path")
+			}
+			path = append(path, s[:end])
+			s = s[end:]
+		}
+
+		if strings.HasPrefix(s, ".") {
+			s = s[1:]
+			if s == "" {
+				return nil, fmt.Errorf("JSON path ends with a dot")
+			}
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty JSON path")
+	}
+	return path, nil
+}
+
+// value at the path, false when a key or index is missing
+func (p JSONPath) Lookup(doc interface{}) (interface{}, bool) {
+	for _, step := range p {
+		var ok bool
+		if doc, ok = JSONStep(doc, step); !ok {
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// one step into an object or array, negative indexes count from the end
+func JSONStep(doc interface{}, step interface{}) (interface{}, bool) {
+	switch key := step.(type) {
+	case string:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := obj[key]
+		return v, ok
+
+	case int:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		if key < 0 {
+			key += len(arr)
+		}
+		if key < 0 || key >= len(arr) {
+			return nil, false
+		}
+		return arr[key], true
+	}
+
+	return nil, false
+}
+
+func (p JSONPath) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range p {
+		switch key := step.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", key)
+		case string:
+			if strings.ContainsAny(key, ".[]\"") {
+				fmt.Fprintf(&b, ".%q", key)
+			} else {
+				b.WriteString("." + key)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// parses JSON text into maps, slices, strings, float64s, bools and nils
+func ParseJSON(s string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	return v, nil
+}
+
+// turns json.Number values decoded with UseNumber back into float64
+func NormalizeJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = NormalizeJSON(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = NormalizeJSON(e)
+		}
+	}
+
+	return v
+}
+
+// JSON object or array
+func IsJSONContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+
+	return false
+}
+
+// compact JSON text of a value
+func JSONText(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(data)
+}
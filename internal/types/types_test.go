@@ -0,0 +1,121 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, _ := ParseDecimal("10.10")
+	b, _ := ParseDecimal("0.2")
+
+	if got := a.Add(b).String(); got != "10.30" {
+		t.Fatalf("expected 10.30, got %s", got)
+	}
+	if got := a.Mul(b).String(); got != "2.020" {
+		t.Fatalf("expected 2.020, got %s", got)
+	}
+
+	third, err := DecimalFromInt(1).Div(DecimalFromInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := third.String(); got != "0.333333" {
+		t.Fatalf("expected 0.333333, got %s", got)
+	}
+
+	if _, err := a.Div(DecimalFromInt(0)); err == nil {
+		t.Fatal("expected division by zero error")
+	}
+
+	tests := map[string]string{"2.345": "2.35", "-2.345": "-2.35", "2.344": "2.34", "1.5e2": "150.00"}
+	for in, expected := range tests {
+		d, err := ParseDecimal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Rescale(2).String(); got != expected {
+			t.Fatalf("%s: expected %s, got %s", in, expected, got)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := map[string]Interval{
+		"3 days":          {Days: 3},
+		"1 year 2 months": {Months: 14},
+		"1 day 02:30:00":  {Days: 1, Micros: int64(150 * time.Minute / time.Microsecond)},
+		"-1 week":         {Days: -7},
+	}
+	for in, expected := range tests {
+		got, err := ParseInterval(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %+v, got %+v", in, expected, got)
+		}
+	}
+
+	if _, err := ParseInterval("3 fortnights"); err == nil {
+		t.Fatal("expected unknown unit to be rejected")
+	}
+}
+
+func TestIntervalClampsMonthEnd(t *testing.T) {
+	d, _ := ParseDate("2024-01-31")
+	got := NewTimestamp(Interval{Months: 1}.AddTo(d.Time())).Date()
+	if got.String() != "2024-02-29" {
+		t.Fatalf("expected 2024-02-29, got %s", got)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	doc, err := ParseJSON(`{"a": {"b.c": [10, {"d": "x"}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]interface{}{
+		`$.a."b.c"[0]`:  10.0,
+		`a."b.c"[1].d`:  "x",
+		`$.a."b.c"[-1]`: map[string]interface{}{"d": "x"},
+	}
+	for in, expected := range tests {
+		path, err := ParseJSONPath(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		got, ok := path.Lookup(doc)
+		if !ok || JSONText(got) != JSONText(expected) {
+			t.Fatalf("%s: expected %v, got %v", in, expected, got)
+		}
+	}
+
+	if path, _ := ParseJSONPath("a.missing"); path != nil {
+		if _, ok := path.Lookup(doc); ok {
+			t.Fatal("expected missing key not to be found")
+		}
+	}
+	for _, bad := range []string{"", "a.", "a[x]", `a."b`} {
+		if _, err := ParseJSONPath(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestIntervalStringParsesBack(t *testing.T) {
+	for _, i := range []Interval{
+		{Months: 14, Days: 3},
+		{Months: 1, Micros: 1500},
+		{Days: -2, Micros: -5400000000},
+	} {
+		got, err := ParseInterval(i.String())
+		if err != nil {
+			t.Fatalf("%s: %v", i, err)
+		}
+		if got != i {
+			t.Errorf("%s: parsed back as %+v", i, got)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package wal is an append only log of change records.
+//
+// Each record is framed by its length and a CRC-32C checksum of its JSON
+// encoding. A crash can leave the last record half written, reading the log
+// stops at the first record that is cut short or doesn't match its
+// checksum and the log is truncated there.
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+type Kind string
+
+const (
+	Change     Kind = "change"     // rows of a table a transaction changed
+	Commit     Kind = "commit"     // the changes logged before it are committed
+	Checkpoint Kind = "checkpoint" // every row of the tables changed since the last checkpoint
+)
+
+// a row inserted at Pos of the table as it is after the change
+type Insert struct {
+	Pos int                    `json:"pos"`
+	Row map[string]interface{} `json:"row"`
+}
+
+type Record struct {
+	LSN   uint64 `json:"lsn"`
+	Kind  Kind   `json:"kind"`
+	Txn   uint64 `json:"txn,omitempty"`
+	Table string `json:"table,omitempty"`
+
+	// positions of the rows a change deleted, ascending, in the table as it
+	// was before. Inserts are ascending too
+	Deleted  []int    `json:"deleted,omitempty"`
+	Inserted []Insert `json:"inserted,omitempty"`
+
+	Images map[string][]map[string]interface{} `json:"images,omitempty"`
+}
+
+const headerSize = 8 // length and checksum, 4 bytes each
+
+var table = crc32.MakeTable(crc32.Castagnoli)
+
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	lsn  uint64 // of the last record
+
+	// the log as of the last sync, what a failed sync goes back to
+	syncedSize int64
+	syncedLSN  uint64
+	// set when a failed sync couldn't be undone, the log takes no more
+	// records since syncing them would make the failed ones durable too
+	err error
+}
+
+// tests replace it to make syncs fail
+var syncFile = (*os.File).Sync
+
+// Open reads the records of the log at path, creating it when it doesn't
+// exist, and opens it for appending. Numbers in rows are json.Number
+func Open(path string) (*Log, []*Record, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log: %w", err)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	records, valid := decode(data)
+
+	// a torn tail is dropped, so later records follow the valid ones
+	if valid < len(data) {
+		if err := f.Truncate(int64(valid)); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to truncate log: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to truncate log: %w", err)
+		}
+	}
+	if _, err := f.Seek(int64(valid), io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	l := &Log{f: f, size: int64(valid)}
+	if len(records) > 0 {
+		l.lsn = records[len(records)-1].LSN
+	}
+	l.syncedSize, l.syncedLSN = l.size, l.lsn
+	return l, records, nil
+}
+
+// records of data up to the first bad one, and the length they take
+func decode(data []byte) ([]*Record, int) {
+	var records []*Record
+	pos := 0
+	for len(data)-pos >= headerSize {
+		length := int(binary.LittleEndian.Uint32(data[pos:]))
+		sum := binary.LittleEndian.Uint32(data[pos+4:])
+		if length > len(data)-pos-headerSize {
+			break
+		}
+		payload := data[pos+headerSize : pos+headerSize+length]
+		if crc32.Checksum(payload, table) != sum {
+			break
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		var r Record
+		if err := decoder.Decode(&r); err != nil {
+			break
+		}
+		records = append(records, &r)
+		pos += headerSize + length
+	}
+
+	return records, pos
+}
+
+// Append numbers records and writes them to the end of the log. They are
+// durable once Sync returns
+func (l *Log) Append(records ...*Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+
+	var buf []byte
+	lsn := l.lsn
+	for _, r := range records {
+		lsn++
+		r.LSN = lsn
+		payload, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode log record: %w", err)
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload, table))
+		buf = append(buf, payload...)
+	}
+
+	if _, err := l.f.Write(buf); err != nil {
+		// records after a partly written one would never be read
+		l.f.Truncate(l.size)
+		l.f.Seek(l.size, io.SeekStart)
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+	l.size += int64(len(buf))
+	l.lsn = lsn
+	return nil
+}
+
+// Sync makes the records appended so far durable. When it fails they are
+// cut off again, so a later sync can't make them durable
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	if err := syncFile(l.f); err != nil {
+		if undo := l.truncate(l.syncedSize); undo != nil {
+			l.err = fmt.Errorf("log failed after a failed sync: %w", undo)
+		}
+		l.lsn = l.syncedLSN
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	l.syncedSize, l.syncedLSN = l.size, l.lsn
+	return nil
+}
+
+// cuts the log to size bytes and syncs it
+func (l *Log) truncate(size int64) error {
+	if err := l.f.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := l.f.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	if err := syncFile(l.f); err != nil {
+		return err
+	}
+	l.size = size
+	return nil
+}
+
+// bytes in the log
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+// Reset empties the log once everything in it is in the table files
+func (l *Log) Reset() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	if err := l.truncate(0); err != nil {
+		return fmt.Errorf("failed to reset log: %w", err)
+	}
+	l.syncedSize, l.syncedLSN = 0, l.lsn
+	return nil
+}
+
+func (l *Log) Close() error {
+	if l.f == nil {
+		return errors.New("log is closed")
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,192 @@
+package wal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendCommits(t *testing.T, l *Log, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		err := l.Append(
+			&Record{Kind: Change, Txn: uint64(i + 1), Table: "t", Inserted: []Insert{{Pos: i, Row: map[string]interface{}{"id": i}}}},
+			&Record{Kind: Commit, Txn: uint64(i + 1)},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l, records, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected a new log to be empty, got %d records", len(records))
+	}
+	appendCommits(t, l, 3)
+	l.Close()
+
+	l, records, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("expected 6 records, got %d", len(records))
+	}
+	for i, r := range records {
+		if r.LSN != uint64(i+1) {
+			t.Fatalf("record %d has LSN %d", i, r.LSN)
+		}
+	}
+	if r := records[4]; r.Kind != Change || r.Table != "t" || fmt.Sprint(r.Inserted[0].Row["id"]) != "2" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	// numbering goes on after reopening
+	appendCommits(t, l, 1)
+	l.Close()
+	if _, records, _ = Open(path); records[len(records)-1].LSN != 8 {
+		t.Fatalf("expected LSN 8 last, got %d", records[len(records)-1].LSN)
+	}
+}
+
+func TestTornAndCorruptTails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l, _, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendCommits(t, l, 2)
+	full := l.Size()
+	appendCommits(t, l, 1)
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-3] ^= 0xff
+	for name, tail := range map[string][]byte{
+		"torn":    data[:len(data)-5],
+		"corrupt": corrupt,
+	} {
+		if err := os.WriteFile(path, tail, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		l, records, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(records) != 5 {
+			t.Fatalf("%s: expected the records before the bad one, got %d", name, len(records))
+		}
+		if l.Size() <= full || l.Size() >= int64(len(data)) {
+			t.Fatalf("%s: expected the log cut inside the last commit, got %d bytes", name, l.Size())
+		}
+
+		// the bad record is cut off, so new ones can be read
+		if err := l.Append(&Record{Kind: Commit, Txn: 9}); err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+		if _, records, _ = Open(path); len(records) != 6 || records[5].Txn != 9 {
+			t.Fatalf("%s: expected the record appended after the valid ones, got %d records", name, len(records))
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l, _, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendCommits(t, l, 2)
+	if err := l.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	appendCommits(t, l, 1)
+	l.Close()
+
+	_, records, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].LSN != 5 {
+		t.Fatalf("expected the records after the reset, numbered on, got %+v", records)
+	}
+}
+
+// records of a failed sync never become durable, not even through a
+// later sync that works
+func TestFailedSync(t *testing.T) {
+	defer func() { syncFile = (*os.File).Sync }()
+
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l, _, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendCommits(t, l, 1)
+	synced := l.Size()
+
+	fail := 1 // syncs to fail
+	syncFile = func(f *os.File) error {
+		if fail > 0 {
+			fail--
+			return fmt.Errorf("disk gone")
+		}
+		return f.Sync()
+	}
+	if err := l.Append(&Record{Kind: Commit, Txn: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Sync(); err == nil {
+		t.Fatal("expected the sync to fail")
+	}
+	if l.Size() != synced {
+		t.Fatalf("expected the log cut back to %d bytes, got %d", synced, l.Size())
+	}
+	appendCommits(t, l, 1)
+	l.Close()
+
+	_, records, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[2].Txn != 1 || records[3].LSN != 4 {
+		t.Fatalf("expected the records of both good syncs, numbered on, got %+v", records)
+	}
+
+	// when the log can't be cut back it takes no more records
+	l, _, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail = 2
+	if err := l.Append(&Record{Kind: Commit, Txn: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Sync(); err == nil {
+		t.Fatal("expected the sync to fail")
+	}
+	if err := l.Append(&Record{Kind: Commit, Txn: 4}); err == nil {
+		t.Fatal("expected appends to fail after the log failed")
+	}
+	if err := l.Sync(); err == nil {
+		t.Fatal("expected syncs to fail after the log failed")
+	}
+	l.Close()
+}